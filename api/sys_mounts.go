@@ -120,12 +120,13 @@ func (c *Sys) MountConfig(path string) (*MountConfigOutput, error) {
 }
 
 type MountInput struct {
-	Type        string           `json:"type" structs:"type"`
-	Description string           `json:"description" structs:"description"`
-	Config      MountConfigInput `json:"config" structs:"config"`
-	Local       bool             `json:"local" structs:"local"`
-	PluginName  string           `json:"plugin_name,omitempty" structs:"plugin_name"`
-	SealWrap    bool             `json:"seal_wrap" structs:"seal_wrap" mapstructure:"seal_wrap"`
+	Type        string            `json:"type" structs:"type"`
+	Description string            `json:"description" structs:"description"`
+	Config      MountConfigInput  `json:"config" structs:"config"`
+	Local       bool              `json:"local" structs:"local"`
+	PluginName  string            `json:"plugin_name,omitempty" structs:"plugin_name"`
+	SealWrap    bool              `json:"seal_wrap" structs:"seal_wrap" mapstructure:"seal_wrap"`
+	Options     map[string]string `json:"options,omitempty" structs:"options,omitempty"`
 }
 
 type MountConfigInput struct {
@@ -144,6 +145,7 @@ type MountOutput struct {
 	Config      MountConfigOutput `json:"config" structs:"config"`
 	Local       bool              `json:"local" structs:"local"`
 	SealWrap    bool              `json:"seal_wrap" structs:"seal_wrap" mapstructure:"seal_wrap"`
+	Options     map[string]string `json:"options,omitempty" structs:"options,omitempty"`
 }
 
 type MountConfigOutput struct {
@@ -3,7 +3,9 @@ package ldap
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"strings"
 	"text/template"
 
 	"github.com/go-ldap/ldap"
@@ -27,7 +29,7 @@ func Backend() *backend {
 		Help: backendHelp,
 
 		PathsSpecial: &logical.Paths{
-			Root: mfa.MFARootPaths(),
+			Root: append(mfa.MFARootPaths(), "search"),
 
 			Unauthenticated: []string{
 				"login/*",
@@ -44,6 +46,7 @@ func Backend() *backend {
 			pathGroupsList(&b),
 			pathUsers(&b),
 			pathUsersList(&b),
+			pathSearch(&b),
 		},
 			mfa.MFAPaths(b.Backend, pathLogin(&b))...,
 		),
@@ -59,6 +62,37 @@ type backend struct {
 	*framework.Backend
 }
 
+// Values of on_multiple_matches, which governs user searches that match more
+// than one entry.
+const (
+	multipleMatchesError  = "error"
+	multipleMatchesFirst  = "first"
+	multipleMatchesReject = "reject"
+)
+
+// Values of username_case, which governs the case of the username used to bind
+// and search.
+const (
+	usernameCaseLower    = "lower"
+	usernameCaseUpper    = "upper"
+	usernameCasePreserve = "preserve"
+)
+
+// derefAliasesModes maps the values of deref_aliases to the ways searches
+// dereference aliases, from never to both when locating the base object and
+// when searching below it.
+var derefAliasesModes = map[string]int{
+	"never":     ldap.NeverDerefAliases,
+	"searching": ldap.DerefInSearching,
+	"finding":   ldap.DerefFindingBaseObj,
+	"always":    ldap.DerefAlways,
+}
+
+var (
+	errMultipleMatches         = errors.New("LDAP search for user returned multiple entries")
+	errMultipleMatchesRejected = errors.New("LDAP search for user was ambiguous")
+)
+
 func EscapeLDAPValue(input string) string {
 	// RFC4514 forbids un-escaped:
 	// - leading space or hash
@@ -103,7 +137,7 @@ func (b *backend) Login(ctx context.Context, req *logical.Request, username stri
 		return nil, logical.ErrorResponse("ldap backend not configured"), nil, nil
 	}
 
-	c, err := cfg.DialLDAP()
+	c, err := cfg.DialLDAP(ctx)
 	if err != nil {
 		return nil, logical.ErrorResponse(err.Error()), nil, nil
 	}
@@ -114,7 +148,14 @@ func (b *backend) Login(ctx context.Context, req *logical.Request, username stri
 	// Clean connection
 	defer c.Close()
 
-	userBindDN, err := b.getUserBindDN(cfg, c, username)
+	// The username given is kept for local users and the identity alias,
+	// while the directory is queried with its normalized form
+	ldapUsername := cfg.normalizeUsername(username)
+
+	userBindDN, err := b.getUserBindDN(cfg, c, ldapUsername)
+	if err == errMultipleMatchesRejected {
+		return nil, nil, nil, logical.ErrPermissionDenied
+	}
 	if err != nil {
 		return nil, logical.ErrorResponse(err.Error()), nil, nil
 	}
@@ -149,11 +190,20 @@ func (b *backend) Login(ctx context.Context, req *logical.Request, username stri
 	}
 
 	userDN, err := b.getUserDN(cfg, c, userBindDN)
+	if err == errMultipleMatchesRejected {
+		return nil, nil, nil, logical.ErrPermissionDenied
+	}
 	if err != nil {
 		return nil, logical.ErrorResponse(err.Error()), nil, nil
 	}
 
-	ldapGroups, err := b.getLdapGroups(cfg, c, userDN, username)
+	if cfg.AuthAttribute != "" {
+		if err := b.checkAuthAttribute(cfg, c, userDN); err != nil {
+			return nil, logical.ErrorResponse(err.Error()), nil, nil
+		}
+	}
+
+	ldapGroups, err := b.getLdapGroups(ctx, cfg, c, userDN, ldapUsername)
 	if err != nil {
 		return nil, logical.ErrorResponse(err.Error()), nil, nil
 	}
@@ -262,17 +312,22 @@ func (b *backend) getUserBindDN(cfg *ConfigEntry, c *ldap.Conn, username string)
 			b.Logger().Debug("auth/ldap: Discovering user", "userdn", cfg.UserDN, "filter", filter)
 		}
 		result, err := c.Search(&ldap.SearchRequest{
-			BaseDN: cfg.UserDN,
-			Scope:  2, // subtree
-			Filter: filter,
+			BaseDN:       cfg.UserDN,
+			Scope:        2, // subtree
+			DerefAliases: cfg.derefAliases(),
+			Filter:       filter,
 		})
 		if err != nil {
 			return bindDN, fmt.Errorf("LDAP search for binddn failed: %v", err)
 		}
-		if len(result.Entries) != 1 {
+		entry, err := userEntry(cfg, result.Entries)
+		if err != nil {
+			return bindDN, err
+		}
+		if entry == nil {
 			return bindDN, fmt.Errorf("LDAP search for binddn 0 or not unique")
 		}
-		bindDN = result.Entries[0].DN
+		bindDN = entry.DN
 	} else {
 		if cfg.UPNDomain != "" {
 			bindDN = fmt.Sprintf("%s@%s", EscapeLDAPValue(username), cfg.UPNDomain)
@@ -296,15 +351,20 @@ func (b *backend) getUserDN(cfg *ConfigEntry, c *ldap.Conn, bindDN string) (stri
 			b.Logger().Debug("auth/ldap: Searching UPN", "userdn", cfg.UserDN, "filter", filter)
 		}
 		result, err := c.Search(&ldap.SearchRequest{
-			BaseDN: cfg.UserDN,
-			Scope:  2, // subtree
-			Filter: filter,
+			BaseDN:       cfg.UserDN,
+			Scope:        2, // subtree
+			DerefAliases: cfg.derefAliases(),
+			Filter:       filter,
 		})
 		if err != nil {
 			return userDN, fmt.Errorf("LDAP search failed for detecting user: %v", err)
 		}
-		for _, e := range result.Entries {
-			userDN = e.DN
+		entry, err := userEntry(cfg, result.Entries)
+		if err != nil {
+			return userDN, err
+		}
+		if entry != nil {
+			userDN = entry.DN
 		}
 	} else {
 		userDN = bindDN
@@ -313,6 +373,73 @@ func (b *backend) getUserDN(cfg *ConfigEntry, c *ldap.Conn, bindDN string) (stri
 	return userDN, nil
 }
 
+/*
+ * Returns the single entry found by a user search, or nil if there is none.
+ * When the search matched several entries, cfg.OnMultipleMatches decides
+ * whether the first one is used, the login fails with errMultipleMatches or
+ * the login is denied with errMultipleMatchesRejected.
+ */
+func userEntry(cfg *ConfigEntry, entries []*ldap.Entry) (*ldap.Entry, error) {
+	switch len(entries) {
+	case 0:
+		return nil, nil
+	case 1:
+		return entries[0], nil
+	}
+
+	switch cfg.OnMultipleMatches {
+	case multipleMatchesFirst:
+		return entries[0], nil
+	case multipleMatchesReject:
+		return nil, errMultipleMatchesRejected
+	default:
+		return nil, errMultipleMatches
+	}
+}
+
+/*
+ * Checks that the user's entry holds one of cfg.AuthAttributeValues in the
+ * attribute named by cfg.AuthAttribute. This authorizes users by an attribute
+ * such as employeeType, for directories where group membership can't be used.
+ */
+func (b *backend) checkAuthAttribute(cfg *ConfigEntry, c *ldap.Conn, userDN string) error {
+	result, err := c.Search(&ldap.SearchRequest{
+		BaseDN:       userDN,
+		Scope:        0, // base object
+		DerefAliases: cfg.derefAliases(),
+		Filter:       "(objectClass=*)",
+		Attributes: []string{
+			cfg.AuthAttribute,
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("LDAP search for auth_attribute failed: %v", err)
+	}
+	if len(result.Entries) != 1 {
+		return fmt.Errorf("LDAP search for auth_attribute returned %d entries", len(result.Entries))
+	}
+
+	if !authAttributeAllowed(result.Entries[0].GetAttributeValues(cfg.AuthAttribute), cfg.AuthAttributeValues) {
+		return fmt.Errorf("user is not authorized by %s", cfg.AuthAttribute)
+	}
+
+	return nil
+}
+
+// authAttributeAllowed reports whether any of values is one of allowed,
+// ignoring case.
+func authAttributeAllowed(values, allowed []string) bool {
+	for _, v := range values {
+		for _, a := range allowed {
+			if strings.EqualFold(v, a) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
 /*
  * getLdapGroups queries LDAP and returns a slice describing the set of groups the authenticated user is a member of.
  *
@@ -331,7 +458,7 @@ func (b *backend) getUserDN(cfg *ConfigEntry, c *ldap.Conn, bindDN string) (stri
  * NOTE - If cfg.GroupFilter is empty, no query is performed and an empty result slice is returned.
  *
  */
-func (b *backend) getLdapGroups(cfg *ConfigEntry, c *ldap.Conn, userDN string, username string) ([]string, error) {
+func (b *backend) getLdapGroups(ctx context.Context, cfg *ConfigEntry, c *ldap.Conn, userDN string, username string) ([]string, error) {
 	// retrieve the groups in a string/bool map as a structure to avoid duplicates inside
 	ldapMap := make(map[string]bool)
 
@@ -374,22 +501,10 @@ func (b *backend) getLdapGroups(cfg *ConfigEntry, c *ldap.Conn, userDN string, u
 		b.Logger().Debug("auth/ldap: Searching", "groupdn", cfg.GroupDN, "rendered_query", renderedQuery.String())
 	}
 
-	result, err := c.Search(&ldap.SearchRequest{
-		BaseDN: cfg.GroupDN,
-		Scope:  2, // subtree
-		Filter: renderedQuery.String(),
-		Attributes: []string{
-			cfg.GroupAttr,
-		},
-	})
-	if err != nil {
-		return nil, fmt.Errorf("LDAP search failed: %v", err)
-	}
-
-	for _, e := range result.Entries {
+	err = searchCallback(ctx, c, cfg.derefAliases(), cfg.GroupDN, renderedQuery.String(), []string{cfg.GroupAttr}, func(e *ldap.Entry) error {
 		dn, err := ldap.ParseDN(e.DN)
 		if err != nil || len(dn.RDNs) == 0 {
-			continue
+			return nil
 		}
 
 		// Enumerate attributes of each result, parse out CN and add as group
@@ -404,6 +519,10 @@ func (b *backend) getLdapGroups(cfg *ConfigEntry, c *ldap.Conn, userDN string, u
 			groupCN := b.getCN(e.DN)
 			ldapMap[groupCN] = true
 		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("LDAP search failed: %v", err)
 	}
 
 	ldapGroups := make([]string, 0, len(ldapMap))
@@ -414,6 +533,67 @@ func (b *backend) getLdapGroups(cfg *ConfigEntry, c *ldap.Conn, userDN string, u
 	return ldapGroups, nil
 }
 
+// searchPageSize is the number of entries requested per page by
+// searchCallback.
+const searchPageSize = 500
+
+/*
+ * searchCallback runs a subtree search under baseDN, dereferencing aliases as
+ * set by deref, and calls fn with each entry found. Results are requested one
+ * page at a time, so only a page of entries is held in memory however large
+ * the result set. The search stops at the first error returned by fn, which
+ * is returned, or when ctx is done. Servers that don't support paging return
+ * every entry in a single page.
+ */
+func searchCallback(ctx context.Context, c *ldap.Conn, deref int, baseDN, filter string, attrs []string, fn func(*ldap.Entry) error) error {
+	paging := ldap.NewControlPaging(searchPageSize)
+	req := &ldap.SearchRequest{
+		BaseDN:       baseDN,
+		Scope:        2, // subtree
+		DerefAliases: deref,
+		Filter:       filter,
+		Attributes:   attrs,
+		Controls:     []ldap.Control{paging},
+	}
+
+	for {
+		if err := ctx.Err(); err != nil {
+			abandonSearch(c, req, paging)
+			return err
+		}
+
+		result, err := c.Search(req)
+		if err != nil {
+			return err
+		}
+
+		for _, entry := range result.Entries {
+			if err := fn(entry); err != nil {
+				abandonSearch(c, req, paging)
+				return err
+			}
+		}
+
+		pagingResult, ok := ldap.FindControl(result.Controls, ldap.ControlTypePaging).(*ldap.ControlPaging)
+		if !ok || len(pagingResult.Cookie) == 0 {
+			return nil
+		}
+		paging.SetCookie(pagingResult.Cookie)
+	}
+}
+
+/*
+ * abandonSearch tells the server that no more pages of a paged search will be
+ * requested, so it can release the search's resources.
+ */
+func abandonSearch(c *ldap.Conn, req *ldap.SearchRequest, paging *ldap.ControlPaging) {
+	if len(paging.Cookie) == 0 {
+		return
+	}
+	paging.PagingSize = 0
+	c.Search(req)
+}
+
 const backendHelp = `
 The "ldap" credential provider allows authentication querying
 a LDAP server, checking username and password, and associating groups
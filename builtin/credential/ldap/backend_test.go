@@ -2,12 +2,25 @@ package ldap
 
 import (
 	"context"
+	"crypto/tls"
+	"encoding/pem"
+	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
 	"reflect"
 	"sort"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/go-ldap/ldap"
+	multierror "github.com/hashicorp/go-multierror"
 	"github.com/hashicorp/vault/helper/policyutil"
 	"github.com/hashicorp/vault/logical"
 	logicaltest "github.com/hashicorp/vault/logical/testing"
@@ -271,6 +284,705 @@ func TestBackend_configDefaultsAfterUpdate(t *testing.T) {
 	})
 }
 
+func TestBackend_configResolver(t *testing.T) {
+	b := factory(t)
+
+	logicaltest.Test(t, logicaltest.TestCase{
+		Backend: b,
+		Steps: []logicaltest.TestStep{
+			logicaltest.TestStep{
+				Operation: logical.UpdateOperation,
+				Path:      "config",
+				Data: map[string]interface{}{
+					"resolver": "dns.example.com:53",
+				},
+				ErrorOk: true,
+				Check: func(resp *logical.Response) error {
+					if resp == nil || !resp.IsError() {
+						return fmt.Errorf("expected error for invalid resolver, got: %#v", resp)
+					}
+					return nil
+				},
+			},
+			logicaltest.TestStep{
+				Operation: logical.UpdateOperation,
+				Path:      "config",
+				Data: map[string]interface{}{
+					"resolver": "127.0.0.1:53",
+				},
+			},
+			logicaltest.TestStep{
+				Operation: logical.ReadOperation,
+				Path:      "config",
+				Check: func(resp *logical.Response) error {
+					if resp.Data["resolver"] != "127.0.0.1:53" {
+						return fmt.Errorf("bad: %#v", resp.Data["resolver"])
+					}
+					return nil
+				},
+			},
+		},
+	})
+}
+
+func TestBackend_configAuthAttribute(t *testing.T) {
+	b := factory(t)
+
+	logicaltest.Test(t, logicaltest.TestCase{
+		Backend: b,
+		Steps: []logicaltest.TestStep{
+			logicaltest.TestStep{
+				Operation: logical.UpdateOperation,
+				Path:      "config",
+				Data: map[string]interface{}{
+					"auth_attribute": "employeeType",
+				},
+				ErrorOk: true,
+				Check: func(resp *logical.Response) error {
+					if resp == nil || !resp.IsError() {
+						return fmt.Errorf("expected error for missing auth_attribute_values, got: %#v", resp)
+					}
+					return nil
+				},
+			},
+			logicaltest.TestStep{
+				Operation: logical.UpdateOperation,
+				Path:      "config",
+				Data: map[string]interface{}{
+					"auth_attribute_values": "staff",
+				},
+				ErrorOk: true,
+				Check: func(resp *logical.Response) error {
+					if resp == nil || !resp.IsError() {
+						return fmt.Errorf("expected error for missing auth_attribute, got: %#v", resp)
+					}
+					return nil
+				},
+			},
+			logicaltest.TestStep{
+				Operation: logical.UpdateOperation,
+				Path:      "config",
+				Data: map[string]interface{}{
+					"auth_attribute":        "employeeType",
+					"auth_attribute_values": "staff,contractor",
+				},
+			},
+			logicaltest.TestStep{
+				Operation: logical.ReadOperation,
+				Path:      "config",
+				Check: func(resp *logical.Response) error {
+					if resp.Data["auth_attribute"] != "employeeType" {
+						return fmt.Errorf("bad: %#v", resp.Data["auth_attribute"])
+					}
+					if !reflect.DeepEqual(resp.Data["auth_attribute_values"], []string{"staff", "contractor"}) {
+						return fmt.Errorf("bad: %#v", resp.Data["auth_attribute_values"])
+					}
+					return nil
+				},
+			},
+		},
+	})
+}
+
+func TestAuthAttributeAllowed(t *testing.T) {
+	allowed := []string{"staff", "contractor"}
+	if !authAttributeAllowed([]string{"Staff"}, allowed) {
+		t.Fatal("expected case-insensitive match to be allowed")
+	}
+	if !authAttributeAllowed([]string{"intern", "contractor"}, allowed) {
+		t.Fatal("expected any matching value to be allowed")
+	}
+	if authAttributeAllowed([]string{"intern"}, allowed) {
+		t.Fatal("expected non-matching value to be denied")
+	}
+	if authAttributeAllowed(nil, allowed) {
+		t.Fatal("expected missing attribute to be denied")
+	}
+}
+
+func TestBackend_configOnMultipleMatches(t *testing.T) {
+	b := factory(t)
+
+	logicaltest.Test(t, logicaltest.TestCase{
+		Backend: b,
+		Steps: []logicaltest.TestStep{
+			logicaltest.TestStep{
+				Operation: logical.ReadOperation,
+				Path:      "config",
+				Check: func(resp *logical.Response) error {
+					if resp.Data["on_multiple_matches"] != "error" {
+						return fmt.Errorf("bad: %#v", resp.Data["on_multiple_matches"])
+					}
+					return nil
+				},
+			},
+			logicaltest.TestStep{
+				Operation: logical.UpdateOperation,
+				Path:      "config",
+				Data: map[string]interface{}{
+					"on_multiple_matches": "last",
+				},
+				ErrorOk: true,
+				Check: func(resp *logical.Response) error {
+					if resp == nil || !resp.IsError() {
+						return fmt.Errorf("expected error for invalid on_multiple_matches, got: %#v", resp)
+					}
+					return nil
+				},
+			},
+			logicaltest.TestStep{
+				Operation: logical.UpdateOperation,
+				Path:      "config",
+				Data: map[string]interface{}{
+					"on_multiple_matches": "first",
+				},
+			},
+			logicaltest.TestStep{
+				Operation: logical.ReadOperation,
+				Path:      "config",
+				Check: func(resp *logical.Response) error {
+					if resp.Data["on_multiple_matches"] != "first" {
+						return fmt.Errorf("bad: %#v", resp.Data["on_multiple_matches"])
+					}
+					return nil
+				},
+			},
+		},
+	})
+}
+
+func TestBackend_configDerefAliases(t *testing.T) {
+	b, storage := createBackendWithStorage(t)
+
+	writeConfig := func(deref string) *logical.Response {
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "config",
+			Storage:   storage,
+			Data: map[string]interface{}{
+				"deref_aliases": deref,
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	if resp := writeConfig("sometimes"); resp == nil || !resp.IsError() {
+		t.Fatalf("expected error for invalid deref_aliases, got: %#v", resp)
+	}
+
+	if resp := writeConfig("finding"); resp != nil && resp.IsError() {
+		t.Fatalf("bad: %#v", resp)
+	}
+	cfg, err := b.Config(context.Background(), &logical.Request{Storage: storage})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.DerefAliases != "finding" || cfg.derefAliases() != ldap.DerefFindingBaseObj {
+		t.Fatalf("bad deref_aliases: %q", cfg.DerefAliases)
+	}
+
+	// Configurations saved without it keep never dereferencing aliases
+	if deref := (&ConfigEntry{}).derefAliases(); deref != ldap.NeverDerefAliases {
+		t.Fatalf("expected aliases never to be dereferenced, got %d", deref)
+	}
+}
+
+func TestBackend_configUsernameCase(t *testing.T) {
+	b := factory(t)
+
+	logicaltest.Test(t, logicaltest.TestCase{
+		Backend: b,
+		Steps: []logicaltest.TestStep{
+			logicaltest.TestStep{
+				Operation: logical.ReadOperation,
+				Path:      "config",
+				Check: func(resp *logical.Response) error {
+					if resp.Data["username_case"] != "preserve" {
+						return fmt.Errorf("bad: %#v", resp.Data["username_case"])
+					}
+					return nil
+				},
+			},
+			logicaltest.TestStep{
+				Operation: logical.UpdateOperation,
+				Path:      "config",
+				Data: map[string]interface{}{
+					"username_case": "title",
+				},
+				ErrorOk: true,
+				Check: func(resp *logical.Response) error {
+					if resp == nil || !resp.IsError() {
+						return fmt.Errorf("expected error for invalid username_case, got: %#v", resp)
+					}
+					return nil
+				},
+			},
+			logicaltest.TestStep{
+				Operation: logical.UpdateOperation,
+				Path:      "config",
+				Data: map[string]interface{}{
+					"username_case": "lower",
+				},
+			},
+			logicaltest.TestStep{
+				Operation: logical.ReadOperation,
+				Path:      "config",
+				Check: func(resp *logical.Response) error {
+					if resp.Data["username_case"] != "lower" {
+						return fmt.Errorf("bad: %#v", resp.Data["username_case"])
+					}
+					return nil
+				},
+			},
+		},
+	})
+
+	for usernameCase, expected := range map[string]string{
+		"lower":    "tesla.user",
+		"upper":    "TESLA.USER",
+		"preserve": "Tesla.User",
+	} {
+		cfg := &ConfigEntry{UsernameCase: usernameCase}
+		if username := cfg.normalizeUsername("Tesla.User"); username != expected {
+			t.Fatalf("%s: expected %q, got %q", usernameCase, expected, username)
+		}
+	}
+}
+
+func TestBackend_configVerifyBind(t *testing.T) {
+	b, storage := createBackendWithStorage(t)
+
+	writeConfig := func(data map[string]interface{}) *logical.Response {
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "config",
+			Storage:   storage,
+			Data:      data,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	// Nothing listens on this port, so verification fails by default
+	resp := writeConfig(map[string]interface{}{
+		"url": "ldap://127.0.0.1:1",
+	})
+	if resp == nil || !resp.IsError() {
+		t.Fatalf("expected error for unreachable server, got: %#v", resp)
+	}
+	if raw, err := storage.Get(context.Background(), "config"); err != nil || raw != nil {
+		t.Fatalf("expected the config not to be saved, got %v, %v", raw, err)
+	}
+
+	// A configuration that doesn't name a server has nothing to verify
+	resp = writeConfig(map[string]interface{}{
+		"userattr": "uid",
+	})
+	if resp != nil && resp.IsError() {
+		t.Fatalf("expected the config to be saved without verification, got: %#v", resp)
+	}
+
+	// Updates to a saved configuration are only verified when asked to
+	resp = writeConfig(map[string]interface{}{
+		"url": "ldap://127.0.0.1:1",
+	})
+	if resp != nil && resp.IsError() {
+		t.Fatalf("expected the update to be saved without verification, got: %#v", resp)
+	}
+	resp = writeConfig(map[string]interface{}{
+		"url":         "ldap://127.0.0.1:1",
+		"verify_bind": true,
+	})
+	if resp == nil || !resp.IsError() {
+		t.Fatalf("expected error for unreachable server, got: %#v", resp)
+	}
+}
+
+func TestBackend_configPinnedCertificate(t *testing.T) {
+	b, storage := createBackendWithStorage(t)
+
+	// The test server's certificate stands in for a self-signed LDAP server
+	// certificate
+	server := httptest.NewTLSServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	defer server.Close()
+	pinned := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}))
+
+	writeConfig := func(data map[string]interface{}) *logical.Response {
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "config",
+			Storage:   storage,
+			Data:      data,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	resp := writeConfig(map[string]interface{}{
+		"pinned_certificate": pinned,
+		"insecure_tls":       true,
+	})
+	if resp == nil || !resp.IsError() {
+		t.Fatalf("expected error combining pinned_certificate and insecure_tls, got: %#v", resp)
+	}
+
+	resp = writeConfig(map[string]interface{}{
+		"pinned_certificate": "not a certificate",
+	})
+	if resp == nil || !resp.IsError() {
+		t.Fatalf("expected error for invalid pinned_certificate, got: %#v", resp)
+	}
+
+	resp = writeConfig(map[string]interface{}{
+		"pinned_certificate": pinned,
+	})
+	if resp != nil && resp.IsError() {
+		t.Fatalf("bad: %#v", resp)
+	}
+
+	cfg, err := b.Config(context.Background(), &logical.Request{Storage: storage})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.PinnedCertificate != pinned {
+		t.Fatalf("expected the pinned certificate to be saved, got %q", cfg.PinnedCertificate)
+	}
+
+	// The server is trusted for its certificate alone, although neither its
+	// chain nor the host name verify
+	tlsConfig, err := cfg.GetTLSConfig("ldap.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, err := tls.Dial("tcp", server.Listener.Addr().String(), tlsConfig)
+	if err != nil {
+		t.Fatalf("expected the pinned certificate to be accepted, got: %v", err)
+	}
+	conn.Close()
+
+	// Other certificates are still rejected
+	cfg.PinnedCertificate = ""
+	tlsConfig, err = cfg.GetTLSConfig("ldap.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tls.Dial("tcp", server.Listener.Addr().String(), tlsConfig); err == nil {
+		t.Fatal("expected the certificate to be rejected without pinning")
+	}
+}
+
+func TestBackend_configVerifyBindCredentials(t *testing.T) {
+	b := factory(t)
+
+	logicaltest.Test(t, logicaltest.TestCase{
+		Backend: b,
+		Steps: []logicaltest.TestStep{
+			logicaltest.TestStep{
+				Operation: logical.UpdateOperation,
+				Path:      "config",
+				Data: map[string]interface{}{
+					// Online LDAP test server
+					// http://www.forumsys.com/tutorials/integration-how-to/ldap/online-ldap-test-server/
+					"url":      "ldap://ldap.forumsys.com",
+					"userdn":   "dc=example,dc=com",
+					"binddn":   "cn=read-only-admin,dc=example,dc=com",
+					"bindpass": "wrong",
+				},
+				ErrorOk: true,
+				Check: func(resp *logical.Response) error {
+					if resp == nil || !resp.IsError() {
+						return fmt.Errorf("expected error for bad bind credentials, got: %#v", resp)
+					}
+					return nil
+				},
+			},
+			testAccStepConfigUrlWithAuthBind(t),
+		},
+	})
+}
+
+func TestLDAPAddress(t *testing.T) {
+	testCases := []struct {
+		url  string
+		host string
+		addr string
+		err  bool
+	}{
+		{"ldap://ldap.example.com", "ldap.example.com", "ldap.example.com:389", false},
+		{"ldaps://ldap.example.com", "ldap.example.com", "ldap.example.com:636", false},
+		{"ldap://ldap.example.com:1389", "ldap.example.com", "ldap.example.com:1389", false},
+		{"ldap://127.0.0.1", "127.0.0.1", "127.0.0.1:389", false},
+		{"ldaps://127.0.0.1:1636", "127.0.0.1", "127.0.0.1:1636", false},
+		{"ldap://[::1]", "::1", "[::1]:389", false},
+		{"ldaps://[::1]", "::1", "[::1]:636", false},
+		{"ldap://[::1]:1389", "::1", "[::1]:1389", false},
+		{"ldaps://[2001:db8::1]:1636", "2001:db8::1", "[2001:db8::1]:1636", false},
+		{"ldap://::1", "::1", "[::1]:389", false},
+		{"ldaps://2001:db8::1", "2001:db8::1", "[2001:db8::1]:636", false},
+		{"ldap://[fe80::1%25eth0]:1389", "fe80::1%eth0", "[fe80::1%eth0]:1389", false},
+		{"ldap://", "", "", true},
+		{"foobar://ldap.example.com", "", "", true},
+	}
+
+	for _, tc := range testCases {
+		u, err := url.Parse(tc.url)
+		if err != nil {
+			t.Fatalf("%s: %s", tc.url, err)
+		}
+		host, addr, err := ldapAddress(u)
+		if tc.err {
+			if err == nil {
+				t.Fatalf("%s: expected error, got host %q and address %q", tc.url, host, addr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: %s", tc.url, err)
+		}
+		if host != tc.host || addr != tc.addr {
+			t.Fatalf("%s: expected host %q and address %q, got %q and %q", tc.url, tc.host, tc.addr, host, addr)
+		}
+	}
+}
+
+func TestDialLDAP_maxConnectionAttempts(t *testing.T) {
+	// Nothing listens on these ports, so every attempt fails
+	cfg := &ConfigEntry{
+		Url: "ldap://127.0.0.1:1,ldap://127.0.0.1:2,ldap://127.0.0.1:3",
+	}
+
+	attempts := func(err error) int {
+		merr, ok := err.(*multierror.Error)
+		if !ok {
+			t.Fatalf("expected a multierror, got %#v", err)
+		}
+		return len(merr.Errors)
+	}
+
+	_, err := cfg.DialLDAP(context.Background())
+	if n := attempts(err); n != 3 {
+		t.Fatalf("expected every URL to be tried, got %d errors", n)
+	}
+
+	cfg.MaxConnectionAttempts = 2
+	_, err = cfg.DialLDAP(context.Background())
+	if n := attempts(err); n != 2 {
+		t.Fatalf("expected 2 URLs to be tried, got %d errors", n)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = cfg.DialLDAP(ctx)
+	merr := err.(*multierror.Error)
+	if len(merr.Errors) != 1 || merr.Errors[0] != context.Canceled {
+		t.Fatalf("expected no URL to be tried once the context is done, got %v", err)
+	}
+}
+
+func TestDialStartTLS_timeout(t *testing.T) {
+	// The server accepts connections but never answers StartTLS
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	var accepted int32
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			atomic.AddInt32(&accepted, 1)
+			go io.Copy(ioutil.Discard, conn)
+		}
+	}()
+
+	cfg := &ConfigEntry{StartTLSTimeout: 1}
+	_, err = cfg.dialStartTLS(context.Background(), &net.Dialer{}, ln.Addr().String(), &tls.Config{ServerName: "127.0.0.1"})
+	serr, ok := err.(*startTLSError)
+	if !ok || !serr.transient {
+		t.Fatalf("expected a transient StartTLS error, got %#v", err)
+	}
+	if n := atomic.LoadInt32(&accepted); n != 2 {
+		t.Fatalf("expected StartTLS to be retried once, got %d connections", n)
+	}
+}
+
+func TestIsTransientStartTLSError(t *testing.T) {
+	testCases := []struct {
+		err       error
+		transient bool
+	}{
+		{ldap.NewError(ldap.ErrorNetwork, errors.New("ldap: response channel closed")), true},
+		{ldap.NewError(ldap.ErrorNetwork, errors.New("TLS handshake failed (x509: certificate signed by unknown authority)")), false},
+		{ldap.NewError(ldap.LDAPResultProtocolError, errors.New("ldap: cannot StartTLS (unsupported extended operation)")), false},
+		{errors.New("unexpected"), false},
+	}
+
+	for _, tc := range testCases {
+		if transient := isTransientStartTLSError(tc.err); transient != tc.transient {
+			t.Errorf("%v: expected transient %t, got %t", tc.err, tc.transient, transient)
+		}
+	}
+}
+
+func TestUserEntry(t *testing.T) {
+	entries := []*ldap.Entry{
+		&ldap.Entry{DN: "cn=one,dc=example,dc=com"},
+		&ldap.Entry{DN: "cn=two,dc=example,dc=com"},
+	}
+
+	cases := []struct {
+		onMultipleMatches string
+		entries           []*ldap.Entry
+		dn                string
+		err               error
+	}{
+		{"error", nil, "", nil},
+		{"error", entries[1:], "cn=two,dc=example,dc=com", nil},
+		{"error", entries, "", errMultipleMatches},
+		{"first", entries, "cn=one,dc=example,dc=com", nil},
+		{"reject", entries, "", errMultipleMatchesRejected},
+	}
+	for _, tc := range cases {
+		entry, err := userEntry(&ConfigEntry{OnMultipleMatches: tc.onMultipleMatches}, tc.entries)
+		if err != tc.err {
+			t.Fatalf("%s: expected error %v, got %v", tc.onMultipleMatches, tc.err, err)
+		}
+		var dn string
+		if entry != nil {
+			dn = entry.DN
+		}
+		if dn != tc.dn {
+			t.Fatalf("%s: expected %q, got %q", tc.onMultipleMatches, tc.dn, dn)
+		}
+	}
+}
+
+func TestSearchCallback(t *testing.T) {
+	if os.Getenv(logicaltest.TestEnvVar) == "" {
+		t.Skip(fmt.Sprintf("Acceptance tests skipped unless env '%s' set", logicaltest.TestEnvVar))
+	}
+
+	// Online LDAP test server
+	// http://www.forumsys.com/tutorials/integration-how-to/ldap/online-ldap-test-server/
+	c, err := ldap.Dial("tcp", "ldap.forumsys.com:389")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	var dns []string
+	err = searchCallback(context.Background(), c, ldap.NeverDerefAliases, "dc=example,dc=com", "(objectClass=groupOfUniqueNames)", []string{"ou"}, func(e *ldap.Entry) error {
+		dns = append(dns, e.DN)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dns) < 2 {
+		t.Fatalf("expected several groups, got %v", dns)
+	}
+
+	// The search stops at the first error returned by the callback
+	errStop := errors.New("stop")
+	calls := 0
+	err = searchCallback(context.Background(), c, ldap.NeverDerefAliases, "dc=example,dc=com", "(objectClass=groupOfUniqueNames)", []string{"ou"}, func(e *ldap.Entry) error {
+		calls++
+		return errStop
+	})
+	if err != errStop || calls != 1 {
+		t.Fatalf("expected the search to stop after one entry, got %d calls and error %v", calls, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = searchCallback(ctx, c, ldap.NeverDerefAliases, "dc=example,dc=com", "(objectClass=groupOfUniqueNames)", nil, func(e *ldap.Entry) error {
+		t.Fatal("expected no entries after the context is done")
+		return nil
+	})
+	if err != context.Canceled {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+}
+
+func TestBackend_searchValidation(t *testing.T) {
+	b, storage := createBackendWithStorage(t)
+
+	for _, data := range []map[string]interface{}{
+		{},
+		{"filter": "(cn=Scientists"},
+		{"filter": "(cn=Scientists)", "limit": 0},
+		{"filter": "(cn=Scientists)", "base_dn": "not a dn"},
+	} {
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "search",
+			Storage:   storage,
+			Data:      data,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp == nil || !resp.IsError() {
+			t.Fatalf("expected error for %v, got: %#v", data, resp)
+		}
+	}
+}
+
+func TestBackend_search(t *testing.T) {
+	b := factory(t)
+
+	logicaltest.Test(t, logicaltest.TestCase{
+		Backend: b,
+		Steps: []logicaltest.TestStep{
+			testAccStepConfigUrlWithAuthBind(t),
+			logicaltest.TestStep{
+				Operation: logical.UpdateOperation,
+				Path:      "search",
+				Data: map[string]interface{}{
+					"filter":     "(uid=tesla)",
+					"attributes": "mail",
+				},
+				Check: func(resp *logical.Response) error {
+					entries := resp.Data["entries"].([]map[string]interface{})
+					if len(entries) != 1 || entries[0]["dn"] != "uid=tesla,dc=example,dc=com" {
+						return fmt.Errorf("bad: %#v", entries)
+					}
+					values := entries[0]["attributes"].(map[string][]string)
+					if len(values["mail"]) != 1 || values["mail"][0] != "tesla@ldap.forumsys.com" {
+						return fmt.Errorf("bad: %#v", values)
+					}
+					return nil
+				},
+			},
+			logicaltest.TestStep{
+				Operation: logical.UpdateOperation,
+				Path:      "search",
+				Data: map[string]interface{}{
+					"filter": "(objectClass=groupOfUniqueNames)",
+					"limit":  1,
+				},
+				Check: func(resp *logical.Response) error {
+					if len(resp.Data["entries"].([]map[string]interface{})) != 1 || len(resp.Warnings) != 1 {
+						return fmt.Errorf("expected a single entry and a warning, got: %#v", resp)
+					}
+					return nil
+				},
+			},
+		},
+	})
+}
+
 func testAccStepConfigUrl(t *testing.T) logicaltest.TestStep {
 	return logicaltest.TestStep{
 		Operation: logical.UpdateOperation,
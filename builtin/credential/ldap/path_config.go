@@ -10,10 +10,13 @@ import (
 	"net/url"
 	"strings"
 	"text/template"
+	"time"
 
 	"github.com/fatih/structs"
 	"github.com/go-ldap/ldap"
 	multierror "github.com/hashicorp/go-multierror"
+	"github.com/hashicorp/vault/helper/dnsutil"
+	"github.com/hashicorp/vault/helper/redactutil"
 	"github.com/hashicorp/vault/helper/tlsutil"
 	"github.com/hashicorp/vault/logical"
 	"github.com/hashicorp/vault/logical/framework"
@@ -84,6 +87,11 @@ Default: cn`,
 				Description: "CA certificate to use when verifying LDAP server certificate, must be x509 PEM encoded (optional)",
 			},
 
+			"pinned_certificate": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "The LDAP server's own certificate, such as a self-signed one, to trust instead of verifying its chain and host name; the server must present exactly this certificate. Must be x509 PEM encoded and can't be combined with insecure_tls (optional)",
+			},
+
 			"discoverdn": &framework.FieldSchema{
 				Type:        framework.TypeBool,
 				Description: "Use anonymous bind to discover the bind DN of a user (optional)",
@@ -99,6 +107,12 @@ Default: cn`,
 				Description: "Issue a StartTLS command after establishing unencrypted connection (optional)",
 			},
 
+			"starttls_timeout": &framework.FieldSchema{
+				Type:        framework.TypeDurationSecond,
+				Default:     defaultStartTLSTimeout,
+				Description: "Time to wait for the StartTLS upgrade of a connection, including its TLS handshake, before retrying it once on a new connection; defaults to 10 seconds, and 0 waits indefinitely",
+			},
+
 			"tls_min_version": &framework.FieldSchema{
 				Type:        framework.TypeString,
 				Default:     "tls12",
@@ -110,11 +124,46 @@ Default: cn`,
 				Default:     "tls12",
 				Description: "Maximum TLS version to use. Accepted values are 'tls10', 'tls11' or 'tls12'. Defaults to 'tls12'",
 			},
+			"resolver": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Address (ip:port) of the DNS server used to resolve LDAP hostnames. Defaults to the system resolver (optional)",
+			},
 			"deny_null_bind": &framework.FieldSchema{
 				Type:        framework.TypeBool,
 				Default:     true,
 				Description: "Denies an unauthenticated LDAP bind request if the user's password is empty; defaults to true",
 			},
+			"on_multiple_matches": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Default:     multipleMatchesError,
+				Description: "What to do when a user search matches more than one entry: 'error' fails the login with an error, 'first' uses the first entry and 'reject' denies the login. Defaults to 'error'",
+			},
+			"username_case": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Default:     usernameCasePreserve,
+				Description: "Case the username is converted to before it's used to bind and search: 'lower', 'upper' or 'preserve'. Defaults to 'preserve'",
+			},
+			"deref_aliases": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Default:     "never",
+				Description: "When searches dereference aliases: 'never', 'searching' (below the base object), 'finding' (the base object) or 'always'. Defaults to 'never'",
+			},
+			"auth_attribute": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Attribute of the user's entry that must hold one of auth_attribute_values for the user to log in (optional)",
+			},
+			"auth_attribute_values": &framework.FieldSchema{
+				Type:        framework.TypeCommaStringSlice,
+				Description: "Comma separated list of auth_attribute values that allow the user to log in; compared case-insensitively",
+			},
+			"max_connection_attempts": &framework.FieldSchema{
+				Type:        framework.TypeInt,
+				Description: "Maximum number of URLs tried when connecting, in order; defaults to 0, which tries all of them",
+			},
+			"verify_bind": &framework.FieldSchema{
+				Type:        framework.TypeBool,
+				Description: "Verifies the configuration by connecting to the LDAP server and binding with binddn and bindpass before saving it; defaults to true when a url is first configured, and to false when updating a saved configuration",
+			},
 		},
 
 		Callbacks: map[logical.Operation]framework.OperationFunc{
@@ -240,6 +289,21 @@ func (b *backend) newConfigEntry(d *framework.FieldData) (*ConfigEntry, error) {
 	if insecureTLS {
 		cfg.InsecureTLS = insecureTLS
 	}
+	pinnedCertificate := d.Get("pinned_certificate").(string)
+	if pinnedCertificate != "" {
+		if cfg.InsecureTLS {
+			return nil, fmt.Errorf("'pinned_certificate' and 'insecure_tls' are mutually exclusive")
+		}
+		if _, err := tlsutil.ParsePinnedCertificate(pinnedCertificate); err != nil {
+			return nil, err
+		}
+		cfg.PinnedCertificate = pinnedCertificate
+	}
+	startTLSTimeout := d.Get("starttls_timeout").(int)
+	if startTLSTimeout < 0 {
+		return nil, fmt.Errorf("'starttls_timeout' cannot be negative")
+	}
+	cfg.StartTLSTimeout = startTLSTimeout
 	cfg.TLSMinVersion = d.Get("tls_min_version").(string)
 	if cfg.TLSMinVersion == "" {
 		return nil, fmt.Errorf("failed to get 'tls_min_version' value")
@@ -284,6 +348,49 @@ func (b *backend) newConfigEntry(d *framework.FieldData) (*ConfigEntry, error) {
 	if discoverDN {
 		cfg.DiscoverDN = discoverDN
 	}
+	resolver := d.Get("resolver").(string)
+	if resolver != "" {
+		if err := dnsutil.ValidateResolver(resolver); err != nil {
+			return nil, err
+		}
+		cfg.Resolver = resolver
+	}
+	onMultipleMatches := d.Get("on_multiple_matches").(string)
+	switch onMultipleMatches {
+	case multipleMatchesError, multipleMatchesFirst, multipleMatchesReject:
+		cfg.OnMultipleMatches = onMultipleMatches
+	default:
+		return nil, fmt.Errorf("invalid 'on_multiple_matches', must be one of 'error', 'first' or 'reject'")
+	}
+	usernameCase := d.Get("username_case").(string)
+	switch usernameCase {
+	case usernameCaseLower, usernameCaseUpper, usernameCasePreserve:
+		cfg.UsernameCase = usernameCase
+	default:
+		return nil, fmt.Errorf("invalid 'username_case', must be one of 'lower', 'upper' or 'preserve'")
+	}
+	derefAliases := d.Get("deref_aliases").(string)
+	if _, ok := derefAliasesModes[derefAliases]; !ok {
+		return nil, fmt.Errorf("invalid 'deref_aliases', must be one of 'never', 'searching', 'finding' or 'always'")
+	}
+	cfg.DerefAliases = derefAliases
+	authAttribute := d.Get("auth_attribute").(string)
+	authAttributeValues := d.Get("auth_attribute_values").([]string)
+	if authAttribute != "" && len(authAttributeValues) == 0 {
+		return nil, fmt.Errorf("auth_attribute_values must be set when auth_attribute is set")
+	}
+	if authAttribute == "" && len(authAttributeValues) > 0 {
+		return nil, fmt.Errorf("auth_attribute must be set when auth_attribute_values is set")
+	}
+	if authAttribute != "" {
+		cfg.AuthAttribute = authAttribute
+		cfg.AuthAttributeValues = authAttributeValues
+	}
+	maxConnectionAttempts := d.Get("max_connection_attempts").(int)
+	if maxConnectionAttempts < 0 {
+		return nil, fmt.Errorf("'max_connection_attempts' cannot be negative")
+	}
+	cfg.MaxConnectionAttempts = maxConnectionAttempts
 
 	return cfg, nil
 }
@@ -295,6 +402,25 @@ func (b *backend) pathConfigWrite(ctx context.Context, req *logical.Request, d *
 		return logical.ErrorResponse(err.Error()), nil
 	}
 
+	// Only new configurations naming a server are verified by default, so
+	// that updating a saved one doesn't start requiring the server to be
+	// reachable
+	verifyBind, ok := d.GetOk("verify_bind")
+	if !ok {
+		storedConfig, err := req.Storage.Get(ctx, "config")
+		if err != nil {
+			return nil, err
+		}
+		_, hasURL := d.GetOk("url")
+		verifyBind = storedConfig == nil && hasURL
+	}
+
+	if verifyBind.(bool) {
+		if err := cfg.verifyBind(ctx); err != nil {
+			return logical.ErrorResponse(fmt.Sprintf("error verifying the configuration: %s", err)), nil
+		}
+	}
+
 	entry, err := logical.StorageEntryJSON("config", cfg)
 	if err != nil {
 		return nil, err
@@ -324,6 +450,75 @@ type ConfigEntry struct {
 	DiscoverDN    bool   `json:"discoverdn" structs:"discoverdn" mapstructure:"discoverdn"`
 	TLSMinVersion string `json:"tls_min_version" structs:"tls_min_version" mapstructure:"tls_min_version"`
 	TLSMaxVersion string `json:"tls_max_version" structs:"tls_max_version" mapstructure:"tls_max_version"`
+	Resolver      string `json:"resolver" structs:"resolver" mapstructure:"resolver"`
+
+	OnMultipleMatches string `json:"on_multiple_matches" structs:"on_multiple_matches" mapstructure:"on_multiple_matches"`
+	UsernameCase      string `json:"username_case" structs:"username_case" mapstructure:"username_case"`
+	DerefAliases      string `json:"deref_aliases" structs:"deref_aliases" mapstructure:"deref_aliases"`
+
+	AuthAttribute       string   `json:"auth_attribute" structs:"auth_attribute" mapstructure:"auth_attribute"`
+	AuthAttributeValues []string `json:"auth_attribute_values" structs:"auth_attribute_values" mapstructure:"auth_attribute_values"`
+
+	MaxConnectionAttempts int `json:"max_connection_attempts" structs:"max_connection_attempts" mapstructure:"max_connection_attempts"`
+
+	StartTLSTimeout int `json:"starttls_timeout" structs:"starttls_timeout" mapstructure:"starttls_timeout"`
+
+	PinnedCertificate string `json:"pinned_certificate" structs:"pinned_certificate" mapstructure:"pinned_certificate"`
+}
+
+/*
+ * normalizeUsername converts username to the case set by username_case, so
+ * that logins differing only in case bind and search as the same user.
+ */
+func (c *ConfigEntry) normalizeUsername(username string) string {
+	switch c.UsernameCase {
+	case usernameCaseLower:
+		return strings.ToLower(username)
+	case usernameCaseUpper:
+		return strings.ToUpper(username)
+	default:
+		return username
+	}
+}
+
+/*
+ * derefAliases returns the way searches dereference aliases as set by
+ * deref_aliases. Configurations saved before it existed never dereference
+ * them, as searches did then.
+ */
+func (c *ConfigEntry) derefAliases() int {
+	return derefAliasesModes[c.DerefAliases]
+}
+
+/*
+ * verifyBind connects to the LDAP server and, if a bind DN is configured,
+ * binds with it the same way user searches do, so that a wrong URL or bad
+ * bind credentials are reported when the configuration is written rather
+ * than at the first login.
+ */
+func (c *ConfigEntry) verifyBind(ctx context.Context) error {
+	conn, err := c.DialLDAP(ctx)
+	if err != nil {
+		return err
+	}
+	if conn == nil {
+		return fmt.Errorf("invalid connection returned from LDAP dial")
+	}
+	defer conn.Close()
+
+	if c.BindDN == "" {
+		return nil
+	}
+	if c.BindPassword != "" {
+		err = conn.Bind(c.BindDN, c.BindPassword)
+	} else {
+		err = conn.UnauthenticatedBind(c.BindDN)
+	}
+	if err != nil {
+		return fmt.Errorf("LDAP bind (service) failed: %s", redactutil.String(err.Error(), c.BindPassword))
+	}
+
+	return nil
 }
 
 func (c *ConfigEntry) GetTLSConfig(host string) (*tls.Config, error) {
@@ -358,57 +553,66 @@ func (c *ConfigEntry) GetTLSConfig(host string) (*tls.Config, error) {
 		}
 		tlsConfig.RootCAs = caPool
 	}
+	if c.PinnedCertificate != "" {
+		cert, err := tlsutil.ParsePinnedCertificate(c.PinnedCertificate)
+		if err != nil {
+			return nil, err
+		}
+		tlsutil.PinCertificate(tlsConfig, cert)
+	}
 	return tlsConfig, nil
 }
 
-func (c *ConfigEntry) DialLDAP() (*ldap.Conn, error) {
+/*
+ * DialLDAP connects to the first of the configured URLs that accepts a
+ * connection. At most MaxConnectionAttempts URLs are tried, and no more are
+ * tried once ctx is done. The errors from every URL tried are returned if none
+ * of them could be connected to.
+ */
+func (c *ConfigEntry) DialLDAP(ctx context.Context) (*ldap.Conn, error) {
 	var retErr *multierror.Error
 	var conn *ldap.Conn
+	dialer := &net.Dialer{
+		Timeout:  ldap.DefaultTimeout,
+		Resolver: dnsutil.NewResolver(c.Resolver),
+	}
 	urls := strings.Split(c.Url, ",")
+	if c.MaxConnectionAttempts > 0 && len(urls) > c.MaxConnectionAttempts {
+		urls = urls[:c.MaxConnectionAttempts]
+	}
 	for _, uut := range urls {
+		if err := ctx.Err(); err != nil {
+			retErr = multierror.Append(retErr, err)
+			break
+		}
+
 		u, err := url.Parse(uut)
 		if err != nil {
-			retErr = multierror.Append(retErr, fmt.Errorf("error parsing url %q: %s", uut, err.Error()))
+			retErr = multierror.Append(retErr, fmt.Errorf("error parsing url %q: %s", redactutil.String(uut), redactutil.String(err.Error())))
 			continue
 		}
-		host, port, err := net.SplitHostPort(u.Host)
+		host, addr, err := ldapAddress(u)
 		if err != nil {
-			host = u.Host
+			retErr = multierror.Append(retErr, fmt.Errorf("error parsing url %q: %s", redactutil.String(uut), err))
+			continue
 		}
 
 		var tlsConfig *tls.Config
 		switch u.Scheme {
 		case "ldap":
-			if port == "" {
-				port = "389"
-			}
-			conn, err = ldap.Dial("tcp", net.JoinHostPort(host, port))
-			if err != nil {
-				break
-			}
-			if conn == nil {
-				err = fmt.Errorf("empty connection after dialing")
-				break
-			}
 			if c.StartTLS {
 				tlsConfig, err = c.GetTLSConfig(host)
 				if err != nil {
 					break
 				}
-				err = conn.StartTLS(tlsConfig)
 			}
+			conn, err = c.dialStartTLS(ctx, dialer, addr, tlsConfig)
 		case "ldaps":
-			if port == "" {
-				port = "636"
-			}
 			tlsConfig, err = c.GetTLSConfig(host)
 			if err != nil {
 				break
 			}
-			conn, err = ldap.DialTLS("tcp", net.JoinHostPort(host, port), tlsConfig)
-		default:
-			retErr = multierror.Append(retErr, fmt.Errorf("invalid LDAP scheme in url %q", net.JoinHostPort(host, port)))
-			continue
+			conn, err = dialLDAP(ctx, dialer, addr, tlsConfig)
 		}
 		if err == nil {
 			if retErr != nil {
@@ -419,12 +623,196 @@ func (c *ConfigEntry) DialLDAP() (*ldap.Conn, error) {
 			retErr = nil
 			break
 		}
-		retErr = multierror.Append(retErr, fmt.Errorf("error connecting to host %q: %s", uut, err.Error()))
+		retErr = multierror.Append(retErr, fmt.Errorf("error connecting to host %q: %s", redactutil.String(uut), redactutil.String(err.Error(), c.BindPassword)))
 	}
 
 	return conn, retErr.ErrorOrNil()
 }
 
+// defaultLDAPPorts are the ports dialed for each LDAP URL scheme when the URL
+// doesn't set one.
+var defaultLDAPPorts = map[string]string{
+	"ldap":  "389",
+	"ldaps": "636",
+}
+
+/*
+ * ldapAddress returns the host of an LDAP URL and the address to dial for it,
+ * using the scheme's default port if the URL doesn't set one.
+ */
+func ldapAddress(u *url.URL) (string, string, error) {
+	defaultPort, ok := defaultLDAPPorts[u.Scheme]
+	if !ok {
+		return "", "", fmt.Errorf("invalid LDAP scheme %q", u.Scheme)
+	}
+
+	host, port, err := splitHostPort(u.Host)
+	if err != nil {
+		return "", "", err
+	}
+	if port == "" {
+		port = defaultPort
+	}
+	return host, net.JoinHostPort(host, port), nil
+}
+
+/*
+ * splitHostPort splits the host of an LDAP URL into a host and a port, which
+ * is empty if the URL doesn't set one. The host may be a name, an IPv4 address
+ * or an IPv6 address, which may be bracketed and carry a zone. IPv6 addresses
+ * must be bracketed to be followed by a port.
+ */
+func splitHostPort(hostport string) (string, string, error) {
+	if hostport == "" {
+		return "", "", fmt.Errorf("missing host")
+	}
+
+	// A bare IPv6 address has colons but no port
+	if isIPAddress(hostport) {
+		return hostport, "", nil
+	}
+	if strings.HasPrefix(hostport, "[") && strings.HasSuffix(hostport, "]") {
+		host := hostport[1 : len(hostport)-1]
+		if !isIPAddress(host) {
+			return "", "", fmt.Errorf("invalid IPv6 address %q", host)
+		}
+		return host, "", nil
+	}
+	if !strings.Contains(hostport, ":") {
+		return hostport, "", nil
+	}
+
+	host, port, err := net.SplitHostPort(hostport)
+	if err != nil {
+		return "", "", err
+	}
+	if host == "" {
+		return "", "", fmt.Errorf("missing host")
+	}
+	return host, port, nil
+}
+
+// isIPAddress reports whether s is an IP address, ignoring any IPv6 zone.
+func isIPAddress(s string) bool {
+	if i := strings.LastIndex(s, "%"); i >= 0 {
+		s = s[:i]
+	}
+	return net.ParseIP(s) != nil
+}
+
+// dialLDAP connects to addr using dialer, which carries the configured
+// resolver, and performs a TLS handshake if tlsConfig is set. It mirrors
+// ldap.Dial and ldap.DialTLS, which always use the system resolver.
+func dialLDAP(ctx context.Context, dialer *net.Dialer, addr string, tlsConfig *tls.Config) (*ldap.Conn, error) {
+	c, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, ldap.NewError(ldap.ErrorNetwork, err)
+	}
+
+	if tlsConfig == nil {
+		conn := ldap.NewConn(c, false)
+		conn.Start()
+		return conn, nil
+	}
+
+	tc := tls.Client(c, tlsConfig)
+	if err := tc.Handshake(); err != nil {
+		// Handshake error, close the established connection before we return an error
+		c.Close()
+		return nil, ldap.NewError(ldap.ErrorNetwork, err)
+	}
+	conn := ldap.NewConn(tc, true)
+	conn.Start()
+	return conn, nil
+}
+
+// defaultStartTLSTimeout is the number of seconds a StartTLS upgrade may take
+// when the config doesn't set starttls_timeout.
+const defaultStartTLSTimeout = 10
+
+// startTLSError is returned when the StartTLS upgrade of a connection fails,
+// as opposed to dialing, an ldaps handshake or a bind failing. Transient
+// failures, such as timeouts and dropped connections, are retried.
+type startTLSError struct {
+	err       error
+	transient bool
+}
+
+func (e *startTLSError) Error() string {
+	return fmt.Sprintf("StartTLS failed: %s", e.err)
+}
+
+/*
+ * dialStartTLS connects to addr without TLS and, if tlsConfig is set, upgrades
+ * the connection with StartTLS. An upgrade failing transiently is retried once
+ * on a new connection, since busy directories drop some of them.
+ */
+func (c *ConfigEntry) dialStartTLS(ctx context.Context, dialer *net.Dialer, addr string, tlsConfig *tls.Config) (*ldap.Conn, error) {
+	for attempt := 0; ; attempt++ {
+		conn, err := dialLDAP(ctx, dialer, addr, nil)
+		if err != nil {
+			return nil, err
+		}
+		if conn == nil {
+			return nil, fmt.Errorf("empty connection after dialing")
+		}
+		if tlsConfig == nil {
+			return conn, nil
+		}
+
+		err = startTLS(conn, tlsConfig, time.Duration(c.StartTLSTimeout)*time.Second)
+		if err == nil {
+			return conn, nil
+		}
+		if attempt > 0 || !err.(*startTLSError).transient || ctx.Err() != nil {
+			return nil, err
+		}
+		if c.logger != nil && c.logger.IsDebug() {
+			c.logger.Debug("ldap: retrying StartTLS", "addr", addr, "error", err)
+		}
+	}
+}
+
+// startTLS upgrades conn with StartTLS, giving up once timeout passes if it's
+// positive. The connection is closed if the upgrade fails, which also stops
+// an upgrade still waiting on the server.
+func startTLS(conn *ldap.Conn, tlsConfig *tls.Config, timeout time.Duration) error {
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- conn.StartTLS(tlsConfig)
+	}()
+
+	var timeoutCh <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		timeoutCh = timer.C
+	}
+
+	select {
+	case err := <-errCh:
+		if err == nil {
+			return nil
+		}
+		conn.Close()
+		return &startTLSError{err: err, transient: isTransientStartTLSError(err)}
+	case <-timeoutCh:
+		conn.Close()
+		return &startTLSError{err: fmt.Errorf("timed out after %s", timeout), transient: true}
+	}
+}
+
+// isTransientStartTLSError reports whether err, returned by a StartTLS upgrade,
+// is a network failure worth retrying. The server refusing the upgrade and
+// failed TLS handshakes, such as for an untrusted certificate, are not.
+func isTransientStartTLSError(err error) bool {
+	lerr, ok := err.(*ldap.Error)
+	if !ok || lerr.ResultCode != ldap.ErrorNetwork {
+		return false
+	}
+	return !strings.Contains(lerr.Err.Error(), "TLS handshake failed")
+}
+
 /*
  * Returns FieldData describing our ConfigEntry struct schema
  */
@@ -0,0 +1,155 @@
+package ldap
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/go-ldap/ldap"
+	"github.com/hashicorp/vault/helper/redactutil"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+// errSearchLimit stops a test search once it has found as many entries as it
+// may return.
+var errSearchLimit = errors.New("search limit reached")
+
+func pathSearch(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: `search`,
+		Fields: map[string]*framework.FieldSchema{
+			"filter": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "LDAP filter to run, used as-is (eg: (&(objectClass=group)(cn=Scientists)))",
+			},
+
+			"base_dn": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "LDAP search base to run the filter under (default: userdn)",
+			},
+
+			"attributes": &framework.FieldSchema{
+				Type:        framework.TypeCommaStringSlice,
+				Description: "Comma separated list of attributes to return for each entry (optional)",
+			},
+
+			"limit": &framework.FieldSchema{
+				Type:        framework.TypeInt,
+				Default:     100,
+				Description: "Maximum number of entries to return (default: 100)",
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathSearchWrite,
+		},
+
+		HelpSynopsis:    pathSearchHelpSyn,
+		HelpDescription: pathSearchHelpDesc,
+	}
+}
+
+func (b *backend) pathSearchWrite(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	filter := d.Get("filter").(string)
+	if filter == "" {
+		return logical.ErrorResponse("filter is required"), nil
+	}
+	if _, err := ldap.CompileFilter(filter); err != nil {
+		return logical.ErrorResponse(fmt.Sprintf("invalid filter: %v", err)), nil
+	}
+
+	limit := d.Get("limit").(int)
+	if limit <= 0 {
+		return logical.ErrorResponse("limit must be positive"), nil
+	}
+	attrs := d.Get("attributes").([]string)
+
+	cfg, err := b.Config(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if cfg == nil {
+		return logical.ErrorResponse("ldap backend not configured"), nil
+	}
+
+	baseDN := d.Get("base_dn").(string)
+	if baseDN == "" {
+		baseDN = cfg.UserDN
+	}
+	if _, err := ldap.ParseDN(baseDN); err != nil {
+		return logical.ErrorResponse(fmt.Sprintf("invalid base_dn: %v", err)), nil
+	}
+
+	c, err := cfg.DialLDAP(ctx)
+	if err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+	if c == nil {
+		return logical.ErrorResponse("invalid connection returned from LDAP dial"), nil
+	}
+
+	// Clean connection
+	defer c.Close()
+
+	// Search as the service account, the same as logins do
+	if cfg.BindPassword != "" {
+		err = c.Bind(cfg.BindDN, cfg.BindPassword)
+	} else if cfg.BindDN != "" {
+		err = c.UnauthenticatedBind(cfg.BindDN)
+	}
+	if err != nil {
+		return logical.ErrorResponse(fmt.Sprintf("LDAP bind (service) failed: %v", err)), nil
+	}
+
+	if b.Logger().IsDebug() {
+		b.Logger().Debug("auth/ldap: Test search", "base_dn", baseDN, "filter", filter)
+	}
+
+	entries := make([]map[string]interface{}, 0)
+	err = searchCallback(ctx, c, cfg.derefAliases(), baseDN, filter, attrs, func(e *ldap.Entry) error {
+		if len(entries) == limit {
+			return errSearchLimit
+		}
+
+		values := make(map[string][]string, len(e.Attributes))
+		for _, attr := range e.Attributes {
+			values[attr.Name] = attr.Values
+		}
+		entries = append(entries, map[string]interface{}{
+			"dn":         e.DN,
+			"attributes": redactutil.LDAPAttributes(values),
+		})
+		return nil
+	})
+	truncated := err == errSearchLimit
+	if err != nil && !truncated {
+		return logical.ErrorResponse(fmt.Sprintf("LDAP search failed: %v", err)), nil
+	}
+
+	resp := &logical.Response{
+		Data: map[string]interface{}{
+			"entries": entries,
+		},
+	}
+	if truncated {
+		resp.AddWarning(fmt.Sprintf("the search matched more than %d entries; only the first %d are returned", limit, limit))
+	}
+	return resp, nil
+}
+
+const pathSearchHelpSyn = `
+Run an LDAP filter against the configured directory.
+`
+
+const pathSearchHelpDesc = `
+This endpoint runs the given filter under base_dn, or userdn if it is not
+set, and returns the DN and requested attributes of each matching entry. It is
+meant for troubleshooting user and group filters.
+
+The search binds with the configured binddn and bindpass, or anonymously if
+they are not set, so it sees what logins see. The filter is used as-is and
+must be escaped by the caller. Values of sensitive attributes such as
+userPassword are redacted. At most "limit" entries are returned; a warning is
+added when more entries matched.
+`
@@ -2,28 +2,178 @@ package database
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/rpc"
+	"net/url"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
+	metrics "github.com/armon/go-metrics"
 	log "github.com/mgutz/logxi/v1"
 
+	multierror "github.com/hashicorp/go-multierror"
 	"github.com/hashicorp/vault/builtin/logical/database/dbplugin"
+	"github.com/hashicorp/vault/helper/locksutil"
+	"github.com/hashicorp/vault/helper/redactutil"
+	"github.com/hashicorp/vault/helper/strutil"
 	"github.com/hashicorp/vault/logical"
 	"github.com/hashicorp/vault/logical/framework"
 )
 
 const databaseConfigPath = "database/config/"
 
+// closeAllDBsTimeout bounds how long closeAllDBs waits for connections to
+// close when the provided context has no earlier deadline.
+var closeAllDBsTimeout = 10 * time.Second
+
+// closeAllDBsWorkers bounds how many connections closeAllDBs closes at once.
+var closeAllDBsWorkers = 16
+
+// mountOptions are the options the backend accepts from sys/mounts. Vault
+// also passes plugin_name to backends mounted as plugins.
+var mountOptions = map[string]bool{
+	"plugin_name":                 true,
+	"default_params":              true,
+	"max_cached_connections":      true,
+	"uncached_plugins":            true,
+	"startup_verification_jitter": true,
+	"allowed_environments":        true,
+	"name_case":                   true,
+	"name_collisions":             true,
+	"notification_webhook":        true,
+	"issued_user_retention":       true,
+}
+
+// Factory creates the backend with the options given when it was mounted. It
+// fails on invalid options, which rejects the mount before they are stored.
 func Factory(ctx context.Context, conf *logical.BackendConfig) (logical.Backend, error) {
 	b := Backend(conf)
+
+	for k := range conf.Config {
+		if !mountOptions[k] {
+			return nil, fmt.Errorf("unknown option %q", k)
+		}
+	}
+
+	defaultParams, err := parseDefaultParams(conf.Config["default_params"])
+	if err != nil {
+		return nil, err
+	}
+	b.defaultParams = defaultParams
+
+	maxConnections, err := parseMaxCachedConnections(conf.Config["max_cached_connections"])
+	if err != nil {
+		return nil, err
+	}
+	b.maxConnections = maxConnections
+
+	b.uncachedPlugins, err = parseUncachedPlugins(conf.Config["uncached_plugins"])
+	if err != nil {
+		return nil, err
+	}
+
+	b.startupJitter, err = parseStartupVerificationJitter(conf.Config["startup_verification_jitter"])
+	if err != nil {
+		return nil, err
+	}
+
+	b.allowedEnvironments, err = parseAllowedEnvironments(conf.Config["allowed_environments"])
+	if err != nil {
+		return nil, err
+	}
+
+	b.nameCase, b.nameCollisions, err = parseNameCase(conf.Config["name_case"], conf.Config["name_collisions"])
+	if err != nil {
+		return nil, err
+	}
+
+	b.notifier, err = parseNotificationWebhook(conf.Config["notification_webhook"])
+	if err != nil {
+		return nil, err
+	}
+
+	b.issuedUserRetention, err = parseIssuedUserRetention(conf.Config["issued_user_retention"])
+	if err != nil {
+		return nil, err
+	}
+
 	if err := b.Setup(ctx, conf); err != nil {
 		return nil, err
 	}
 	return b, nil
 }
 
+// MountOptions returns the options the backend accepts from sys/mounts. The
+// plugin name isn't one of them, since Vault passes it separately.
+func (b *databaseBackend) MountOptions() []string {
+	options := make([]string, 0, len(mountOptions))
+	for k := range mountOptions {
+		if k != "plugin_name" {
+			options = append(options, k)
+		}
+	}
+	sort.Strings(options)
+	return options
+}
+
+// parseDefaultParams parses the "default_params" mount option, a query string
+// of connection parameters applied to every connection that doesn't set them.
+func parseDefaultParams(raw string) (map[string]string, error) {
+	if raw == "" {
+		return nil, nil
+	}
+
+	query, err := url.ParseQuery(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid default_params: %s", err)
+	}
+
+	params := make(map[string]string, len(query))
+	for k := range query {
+		params[k] = query.Get(k)
+	}
+	return params, nil
+}
+
+// parseMaxCachedConnections parses the "max_cached_connections" mount option,
+// the number of connections kept open before the least recently used ones are
+// closed. Zero, the default, is unbounded.
+func parseMaxCachedConnections(raw string) (int, error) {
+	if raw == "" {
+		return 0, nil
+	}
+
+	max, err := strconv.Atoi(raw)
+	if err != nil || max < 0 {
+		return 0, fmt.Errorf("invalid max_cached_connections %q: must be a non-negative integer", raw)
+	}
+	return max, nil
+}
+
+// parseUncachedPlugins parses the "uncached_plugins" mount option, a comma
+// separated list of the plugins whose connections are started for each request
+// and closed after it instead of being cached.
+func parseUncachedPlugins(raw string) (map[string]bool, error) {
+	if raw == "" {
+		return nil, nil
+	}
+
+	plugins := make(map[string]bool)
+	for _, name := range strings.Split(raw, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			return nil, fmt.Errorf("invalid uncached_plugins %q: plugin names cannot be empty", raw)
+		}
+		plugins[name] = true
+	}
+	return plugins, nil
+}
+
 func Backend(conf *logical.BackendConfig) *databaseBackend {
 	var b databaseBackend
 	b.Backend = &framework.Backend{
@@ -32,87 +182,745 @@ func Backend(conf *logical.BackendConfig) *databaseBackend {
 		PathsSpecial: &logical.Paths{
 			SealWrapStorage: []string{
 				"config/*",
+				previousConfigPath + "*",
+			},
+
+			LocalStorage: []string{
+				pendingRevocationPath,
+				issuedUserPath,
 			},
 		},
 
 		Paths: []*framework.Path{
 			pathListPluginConnection(&b),
 			pathConfigurePluginConnection(&b),
+			pathConfigConnectionEffective(&b),
+			pathConfigConnectionRollback(&b),
+			pathVerifyConnection(&b),
 			pathListRoles(&b),
 			pathRoles(&b),
+			pathRoleMigrate(&b),
+			pathRoleUsers(&b),
 			pathCredsCreate(&b),
+			pathCredsPreview(&b),
 			pathResetConnection(&b),
+			pathExport(&b),
+			pathExportEncrypted(&b),
+			pathImport(&b),
+			pathHealth(&b),
+			pathRevokeUsers(&b),
 		},
 
 		Secrets: []*framework.Secret{
 			secretCreds(&b),
 		},
-		Clean:       b.closeAllDBs,
-		Invalidate:  b.invalidate,
-		BackendType: logical.TypeLogical,
+		Clean:        b.closeAllDBs,
+		Invalidate:   b.invalidate,
+		PeriodicFunc: b.periodicFunc,
+		BackendType:  logical.TypeLogical,
 	}
 
 	b.logger = conf.Logger
-	b.connections = make(map[string]dbplugin.Database)
+	b.connections = make(map[string]*dbPluginInstance)
+	b.lastVerified = make(map[string]time.Time)
+	b.failures = make(map[string]*connectionFailures)
+	b.credsLimiters = make(map[string]*credsRateLimiter)
+	b.draining = make(map[*dbPluginInstance]string)
+	b.drainPollInterval = defaultDrainPollInterval
+	b.jittered = make(map[string]bool)
+	b.nameCase = nameCaseSensitive
+	b.nameCollisions = nameCollisionsReject
+	b.notifier = noopSink{}
+	b.pendingNotifications = make(chan struct{}, maxPendingNotifications)
+	b.issuedUserRetention = defaultIssuedUserRetention
+	b.issuedUserLocks = locksutil.CreateLocks()
 	return &b
 }
 
 type databaseBackend struct {
-	connections map[string]dbplugin.Database
-	logger      log.Logger
+	connections   map[string]*dbPluginInstance
+	defaultParams map[string]string
+	logger        log.Logger
+
+	// maxConnections caps the number of cached connections. Past it, the
+	// least recently used connection is closed to make room. Zero is
+	// unbounded.
+	maxConnections int
+
+	// uncachedPlugins are the plugins, set by the uncached_plugins mount
+	// option, whose connections are never cached.
+	uncachedPlugins map[string]bool
+
+	// startupJitter is the startup_verification_jitter mount option, and
+	// jittered holds the connections that have waited it out. They have
+	// their own lock since the delay is taken without the backend's lock.
+	startupJitter time.Duration
+	jittered      map[string]bool
+	jitteredLock  sync.Mutex
+
+	// allowedEnvironments are the environment tags, set by the
+	// allowed_environments mount option, that connections may be given. Any
+	// tag is allowed if it's nil.
+	allowedEnvironments map[string]bool
+
+	// nameCase and nameCollisions are the name_case and name_collisions
+	// mount options, controlling how connection and role names are
+	// normalized.
+	nameCase       string
+	nameCollisions string
+
+	// lastVerified records when each connection was last verified, so that
+	// re-establishing it within the connection's verification_freshness
+	// window can skip verification.
+	lastVerified map[string]time.Time
+
+	// failures tracks consecutive failures to start each connection's plugin
+	// and whether the connection is quarantined because of them.
+	failures map[string]*connectionFailures
+
+	// credsLimiters holds the rate limiters of the connections that set
+	// creds_rate_limit. They have their own lock since they're used while
+	// holding the backend's read lock.
+	credsLimiters     map[string]*credsRateLimiter
+	credsLimitersLock sync.Mutex
+
+	// draining holds the connections replaced by a configuration change that
+	// are waiting out their reload_grace_period, with their names. They are
+	// checked every drainPollInterval, and drains counts the goroutines
+	// waiting for them.
+	draining          map[*dbPluginInstance]string
+	drainPollInterval time.Duration
+	drains            sync.WaitGroup
+
+	// notifier receives an event for each credential issued, set by the
+	// notification_webhook mount option. pendingNotifications holds a slot
+	// for each notification being delivered.
+	notifier             notificationSink
+	pendingNotifications chan struct{}
+
+	// issuedUserRetention is the issued_user_retention mount option, how
+	// long index entries are kept past the end of their lease. Zero keeps
+	// them. issuedUserLocks guard the entries, by username.
+	issuedUserRetention time.Duration
+	issuedUserLocks     []*locksutil.LockEntry
 
 	*framework.Backend
 	sync.RWMutex
 }
 
-// closeAllDBs closes all connections from all database types
+// closeAllDBs closes all connections from all database types. Connections are
+// closed concurrently by up to closeAllDBsWorkers workers, and errors closing
+// them are logged together. Any connection whose Close does not return before
+// the context is done, or before closeAllDBsTimeout elapses, is abandoned so
+// that shutdown can't hang on a misbehaving plugin. The backend's lock is held
+// throughout, so no connection is created while the mount is torn down.
 func (b *databaseBackend) closeAllDBs(ctx context.Context) {
 	b.Lock()
 	defer b.Unlock()
 
-	for _, db := range b.connections {
-		db.Close()
+	ctx, cancel := context.WithTimeout(ctx, closeAllDBsTimeout)
+	defer cancel()
+
+	type closeJob struct {
+		name string
+		db   *dbPluginInstance
+	}
+	// pending holds the connections that haven't closed yet, including
+	// those still waiting for a worker
+	var resultLock sync.Mutex
+	var closeErr *multierror.Error
+	pending := make(map[string]struct{}, len(b.connections)+len(b.draining))
+
+	jobs := make(chan closeJob, len(b.connections)+len(b.draining))
+	for name, db := range b.connections {
+		pending[name] = struct{}{}
+		jobs <- closeJob{name, db}
+	}
+	for db, name := range b.draining {
+		name += " (draining)"
+		pending[name] = struct{}{}
+		jobs <- closeJob{name, db}
+	}
+	close(jobs)
+
+	workers := closeAllDBsWorkers
+	if workers > len(jobs) {
+		workers = len(jobs)
+	}
+
+	// Workers keep closing connections after the deadline so that no plugin
+	// is left running, they are only no longer waited for
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for job := range jobs {
+				err := job.db.Close()
+
+				resultLock.Lock()
+				delete(pending, job.name)
+				if err != nil {
+					closeErr = multierror.Append(closeErr, fmt.Errorf("%s: %s", job.name, err))
+				}
+				resultLock.Unlock()
+			}
+		}()
+	}
+
+	doneCh := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(doneCh)
+	}()
+
+	select {
+	case <-doneCh:
+	case <-ctx.Done():
+		resultLock.Lock()
+		for name := range pending {
+			b.logger.Warn("database: abandoning connection that did not close in time", "name", name)
+		}
+		resultLock.Unlock()
+	}
+
+	resultLock.Lock()
+	if err := closeErr.ErrorOrNil(); err != nil {
+		b.logger.Error("database: error closing connections", "error", err)
+	}
+	resultLock.Unlock()
+
+	b.connections = make(map[string]*dbPluginInstance)
+	b.draining = make(map[*dbPluginInstance]string)
+}
+
+// errTooManyConcurrentCreations is returned when a credential creation gives
+// up waiting for a slot on a connection with max_concurrent_creations set.
+var errTooManyConcurrentCreations = errors.New("too many concurrent operations on this connection")
+
+// dbPluginInstance is a cached database object along with the capabilities
+// its plugin reported when the object was created.
+type dbPluginInstance struct {
+	dbplugin.Database
+
+	dbType       string
+	capabilities []string
+
+	// creationSem bounds the number of in-flight credential creations. It is
+	// nil if the connection doesn't limit them.
+	creationSem chan struct{}
+
+	// recycleErrors are substrings of errors after which the connection is
+	// recycled, as if the plugin had exited.
+	recycleErrors []string
+
+	versionLock   sync.Mutex
+	serverVersion string
+
+	// buildInfo is the version and build the plugin reported when the
+	// instance was created, or nil if it didn't report them.
+	buildInfo *dbplugin.BuildInfo
+
+	// lastUsed is the time, in Unix nanoseconds, the instance was last
+	// handed out from the cache. users counts the callers using the
+	// instance without holding the backend's lock. Both are accessed
+	// atomically since the instance is used under the read lock.
+	lastUsed int64
+	users    int32
+
+	// uncached is set if the instance's plugin isn't cached, in which case
+	// it's closed once the request that started it is done with it.
+	uncached bool
+}
+
+// newDBPluginInstance discovers the capabilities of an initialized database
+// object and wraps it for caching.
+func newDBPluginInstance(ctx context.Context, db dbplugin.Database, config *DatabaseConfig) (*dbPluginInstance, error) {
+	capabilities, err := dbplugin.Capabilities(ctx, db)
+	if err != nil {
+		return nil, fmt.Errorf("error discovering plugin capabilities: %s", err)
+	}
+
+	dbType, err := db.Type()
+	if err != nil {
+		return nil, fmt.Errorf("error determining database type: %s", err)
+	}
+
+	recycleErrors := config.RecycleErrors
+	if recycleErrors == nil {
+		recycleErrors = defaultRecycleErrors[dbType]
+	}
+
+	// The build info is only informational, so failing to get it doesn't
+	// prevent using the connection
+	var buildInfo *dbplugin.BuildInfo
+	if strutil.StrListContains(capabilities, dbplugin.CapabilityPluginInfo) {
+		buildInfo, _ = dbplugin.PluginInfo(ctx, db)
+	}
+
+	dbi := &dbPluginInstance{
+		Database:      db,
+		dbType:        dbType,
+		capabilities:  capabilities,
+		recycleErrors: recycleErrors,
+		buildInfo:     buildInfo,
+	}
+	if config.MaxConcurrentCreations > 0 {
+		dbi.creationSem = make(chan struct{}, config.MaxConcurrentCreations)
+	}
+
+	return dbi, nil
+}
+
+// acquireCreation blocks until a credential creation may run against the
+// connection, or returns errTooManyConcurrentCreations if ctx is done first.
+// Every successful call must be paired with releaseCreation.
+func (d *dbPluginInstance) acquireCreation(ctx context.Context) error {
+	if d.creationSem == nil {
+		return nil
+	}
+
+	select {
+	case d.creationSem <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return errTooManyConcurrentCreations
+	}
+}
+
+// releaseCreation frees the slot taken by acquireCreation.
+func (d *dbPluginInstance) releaseCreation() {
+	if d.creationSem == nil {
+		return
+	}
+
+	<-d.creationSem
+}
+
+// touch records that the instance is being used, for eviction.
+func (d *dbPluginInstance) touch() {
+	atomic.StoreInt64(&d.lastUsed, time.Now().UnixNano())
+}
+
+// release marks the instance returned by pluginInstance as no longer in use,
+// allowing it to be evicted.
+func (d *dbPluginInstance) release() {
+	if atomic.AddInt32(&d.users, -1) == 0 && d.uncached {
+		d.Close()
+	}
+}
+
+// closeAfter returns unlock, extended to close the instance if it isn't
+// cached. Callers that obtain the instance from createDBObj or uncachedDBObj
+// use it in place of the unlock function.
+func (d *dbPluginInstance) closeAfter(unlock func()) func() {
+	if !d.uncached {
+		return unlock
+	}
+
+	return func() {
+		d.Close()
+		unlock()
+	}
+}
+
+// supports returns an error if the plugin did not report the given
+// capability.
+func (d *dbPluginInstance) supports(capability string) error {
+	if strutil.StrListContains(d.capabilities, capability) {
+		return nil
+	}
+
+	return fmt.Errorf("%s: %s", dbplugin.ErrUnsupportedOperation, capability)
+}
+
+// checkUsernamePrefix returns an error if the plugin refuses to generate
+// usernames starting with prefix, as it does when the prefix leaves too little
+// room within the database's username length limit. This lets such a prefix
+// be refused when it's configured rather than when credentials are requested.
+// Plugins that can't generate credentials on their own aren't checked.
+func (d *dbPluginInstance) checkUsernamePrefix(ctx context.Context, prefix string) error {
+	if prefix == "" || d.supports(dbplugin.CapabilityGenerateCredentials) != nil {
+		return nil
+	}
+
+	_, _, err := dbplugin.GenerateCredentials(ctx, d.Database, dbplugin.UsernameConfig{UsernamePrefix: prefix})
+	if err != nil {
+		return fmt.Errorf("username prefix %q refused by the plugin: %s", prefix, err)
+	}
+	return nil
+}
+
+// version returns the version of the database server, querying the plugin the
+// first time it is needed. Reconnecting creates a new instance, so the version
+// is refreshed along with the connection. Plugins that can't report a version
+// are reported as "unknown".
+func (d *dbPluginInstance) version(ctx context.Context) string {
+	d.versionLock.Lock()
+	defer d.versionLock.Unlock()
+
+	if d.serverVersion != "" {
+		return d.serverVersion
 	}
 
-	b.connections = make(map[string]dbplugin.Database)
+	if d.supports(dbplugin.CapabilityServerVersion) != nil {
+		return "unknown"
+	}
+
+	version, err := dbplugin.ServerVersion(ctx, d.Database)
+	if err != nil || version == "" {
+		return "unknown"
+	}
+
+	d.serverVersion = version
+	return version
+}
+
+// pluginVersion returns the version the plugin reported and the rest of its
+// build info. Anything the plugin didn't report is "unknown".
+func (d *dbPluginInstance) pluginVersion() (string, map[string]string) {
+	info := d.buildInfo
+	if info == nil {
+		info = &dbplugin.BuildInfo{}
+	}
+
+	orUnknown := func(s string) string {
+		if s == "" {
+			return "unknown"
+		}
+		return s
+	}
+
+	return orUnknown(info.Version), map[string]string{
+		"revision":   orUnknown(info.Revision),
+		"go_version": orUnknown(info.GoVersion),
+	}
 }
 
 // This function is used to retrieve a database object either from the cached
 // connection map. The caller of this function needs to hold the backend's read
 // lock.
-func (b *databaseBackend) getDBObj(name string) (dbplugin.Database, bool) {
+func (b *databaseBackend) getDBObj(name string) (*dbPluginInstance, bool) {
 	db, ok := b.connections[name]
+	if ok {
+		incrConnectionCounter("reuse", name)
+		db.touch()
+	}
 	return db, ok
 }
 
+// pluginInstance returns the cached plugin of the named connection, starting
+// it if needed. The caller must not hold the backend's lock, and must call
+// release on the instance once done with it so that it can be evicted.
+func (b *databaseBackend) pluginInstance(ctx context.Context, s logical.Storage, name string) (*dbPluginInstance, error) {
+	b.RLock()
+	dbi, ok := b.getDBObj(name)
+	if ok {
+		atomic.AddInt32(&dbi.users, 1)
+	}
+	b.RUnlock()
+	if ok {
+		return dbi, nil
+	}
+
+	if err := b.waitStartupJitter(ctx, name); err != nil {
+		return nil, err
+	}
+
+	dbi, uncached, err := b.uncachedDBObj(ctx, s, name)
+	if err != nil {
+		return nil, redactutil.Error(err)
+	}
+	if uncached {
+		atomic.AddInt32(&dbi.users, 1)
+		return dbi, nil
+	}
+
+	b.Lock()
+	defer b.Unlock()
+
+	dbi, err = b.createDBObj(ctx, s, name)
+	if err != nil {
+		return nil, redactutil.Error(err)
+	}
+	atomic.AddInt32(&dbi.users, 1)
+
+	return dbi, nil
+}
+
+// lockedDBObj returns the named connection, creating it if it isn't cached,
+// while holding the backend's lock so that the connection isn't replaced while
+// in use. The caller must call the returned function once done with the
+// connection to release the lock. Connections of uncached plugins aren't
+// shared, so they're returned without holding the lock, and the returned
+// function closes them instead.
+func (b *databaseBackend) lockedDBObj(ctx context.Context, s logical.Storage, name string) (*dbPluginInstance, func(), error) {
+	// Grab the read lock
+	b.RLock()
+	unlockFunc := b.RUnlock
+
+	// Get the Database object
+	db, ok := b.getDBObj(name)
+	if !ok {
+		b.RUnlock()
+		if err := b.waitStartupJitter(ctx, name); err != nil {
+			return nil, nil, err
+		}
+
+		var uncached bool
+		var err error
+		db, uncached, err = b.uncachedDBObj(ctx, s, name)
+		if err != nil {
+			return nil, nil, fmt.Errorf("cound not retrieve db with name: %s, got error: %s", name, redactutil.Error(err))
+		}
+		if uncached {
+			return db, db.closeAfter(func() {}), nil
+		}
+
+		// Upgrade lock
+		b.Lock()
+		unlockFunc = b.Unlock
+
+		// Create a new DB object
+		db, err = b.createDBObj(ctx, s, name)
+		if err != nil {
+			unlockFunc()
+			return nil, nil, fmt.Errorf("cound not retrieve db with name: %s, got error: %s", name, redactutil.Error(err))
+		}
+	}
+
+	return db, db.closeAfter(unlockFunc), nil
+}
+
+// cacheConnection caches dbi as the named connection, first evicting the
+// least recently used connections if the cache is full. Connections in use
+// outside the backend's lock are never evicted, so the cache may briefly
+// exceed the cap. The caller needs to hold the backend's write lock.
+func (b *databaseBackend) cacheConnection(name string, dbi *dbPluginInstance) {
+	dbi.touch()
+	if _, ok := b.connections[name]; ok || b.maxConnections <= 0 {
+		b.connections[name] = dbi
+		return
+	}
+
+	for len(b.connections) >= b.maxConnections {
+		var lru string
+		var lruUsed int64
+		for n, candidate := range b.connections {
+			if atomic.LoadInt32(&candidate.users) > 0 {
+				continue
+			}
+			used := atomic.LoadInt64(&candidate.lastUsed)
+			if lru == "" || used < lruUsed {
+				lru, lruUsed = n, used
+			}
+		}
+		if lru == "" {
+			break
+		}
+
+		incrConnectionCounter("evict", lru)
+		b.clearConnection(lru)
+	}
+
+	b.connections[name] = dbi
+}
+
+// incrConnectionCounter counts an event in the lifecycle of the plugin serving
+// the named connection: "reuse" when a cached plugin is used, "spawn" when a
+// plugin is started, "shutdown" when a plugin exits unexpectedly and "evict"
+// when a plugin is closed to stay within max_cached_connections.
+func incrConnectionCounter(event, name string) {
+	metrics.IncrCounterWithLabels([]string{"database", "connection", event}, 1, []metrics.Label{{Name: "name", Value: name}})
+}
+
+// connectionDetails returns the connection details passed to the plugin for
+// config, which include the mount's default params.
+func (b *databaseBackend) connectionDetails(config *DatabaseConfig) map[string]interface{} {
+	if len(b.defaultParams) == 0 {
+		return config.ConnectionDetails
+	}
+
+	details := make(map[string]interface{}, len(config.ConnectionDetails)+1)
+	for k, v := range config.ConnectionDetails {
+		details[k] = v
+	}
+	details["default_params"] = b.defaultParams
+	return details
+}
+
 // This function creates a new db object from the stored configuration and
-// caches it in the connections map. The caller of this function needs to hold
-// the backend's write lock
-func (b *databaseBackend) createDBObj(ctx context.Context, s logical.Storage, name string) (dbplugin.Database, error) {
-	db, ok := b.connections[name]
+// caches it in the connections map, unless its plugin isn't cached. The caller
+// of this function needs to hold the backend's write lock
+func (b *databaseBackend) createDBObj(ctx context.Context, s logical.Storage, name string) (*dbPluginInstance, error) {
+	dbi, ok := b.connections[name]
 	if ok {
-		return db, nil
+		incrConnectionCounter("reuse", name)
+		return dbi, nil
+	}
+
+	config, err := b.DatabaseConfig(ctx, s, name)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := b.checkQuarantine(name); err != nil {
+		return nil, err
+	}
+
+	verify := b.needsVerify(name, config)
+	dbi, err = b.startDBObj(ctx, name, config, verify)
+	if err != nil {
+		b.recordConnectionFailure(name, config)
+		return nil, err
+	}
+	delete(b.failures, name)
+	if verify {
+		b.lastVerified[name] = time.Now()
+	}
+
+	if !b.cachesPlugin(config.PluginName) {
+		dbi.uncached = true
+		return dbi, nil
+	}
+	b.cacheConnection(name, dbi)
+
+	return dbi, nil
+}
+
+// uncachedDBObj starts the plugin of the named connection if the plugin isn't
+// cached, and reports false otherwise. The backend's lock is only held to
+// check and update the connection's quarantine and verification state, not
+// while the plugin starts, so a slow database doesn't block other connections.
+// The caller must not hold the backend's lock.
+func (b *databaseBackend) uncachedDBObj(ctx context.Context, s logical.Storage, name string) (*dbPluginInstance, bool, error) {
+	if len(b.uncachedPlugins) == 0 {
+		return nil, false, nil
 	}
 
 	config, err := b.DatabaseConfig(ctx, s, name)
+	if err != nil {
+		return nil, false, err
+	}
+	if b.cachesPlugin(config.PluginName) {
+		return nil, false, nil
+	}
+
+	b.Lock()
+	err = b.checkQuarantine(name)
+	verify := b.needsVerify(name, config)
+	b.Unlock()
+	if err != nil {
+		return nil, false, err
+	}
+
+	dbi, err := b.startDBObj(ctx, name, config, verify)
+
+	b.Lock()
+	defer b.Unlock()
+
+	if err != nil {
+		b.recordConnectionFailure(name, config)
+		return nil, false, err
+	}
+	delete(b.failures, name)
+	if verify {
+		b.lastVerified[name] = time.Now()
+	}
+
+	dbi.uncached = true
+	return dbi, true, nil
+}
+
+// cachesPlugin reports whether connections of the named plugin are cached.
+func (b *databaseBackend) cachesPlugin(pluginName string) bool {
+	return !b.uncachedPlugins[pluginName]
+}
+
+// needsVerify reports whether the named connection should be verified when its
+// plugin starts. A connection that was verified recently isn't, so that a
+// burst of reconnects doesn't verify it over and over. The caller needs to
+// hold the backend's write lock.
+func (b *databaseBackend) needsVerify(name string, config *DatabaseConfig) bool {
+	if config.VerificationFreshness <= 0 {
+		return true
+	}
+
+	last, ok := b.lastVerified[name]
+	return !ok || time.Since(last) >= time.Duration(config.VerificationFreshness)*time.Second
+}
+
+// startDBObj starts and initializes the plugin of the named connection,
+// verifying the connection if verify is set. It doesn't touch the backend's
+// state, so the caller needn't hold the backend's lock.
+func (b *databaseBackend) startDBObj(ctx context.Context, name string, config *DatabaseConfig, verify bool) (*dbPluginInstance, error) {
+	incrConnectionCounter("spawn", name)
+	db, err := dbplugin.PluginFactory(ctx, config.PluginName, b.System(), b.logger)
 	if err != nil {
 		return nil, err
 	}
 
-	db, err = dbplugin.PluginFactory(ctx, config.PluginName, b.System(), b.logger)
+	err = db.Initialize(ctx, b.connectionDetails(config), verify)
 	if err != nil {
+		db.Close()
 		return nil, err
 	}
 
-	err = db.Initialize(ctx, config.ConnectionDetails, true)
+	dbi, err := newDBPluginInstance(ctx, db, config)
 	if err != nil {
 		db.Close()
 		return nil, err
 	}
 
-	b.connections[name] = db
+	return dbi, nil
+}
 
-	return db, nil
+// connectionFailures counts the consecutive failures to start a connection's
+// plugin. Once they reach the connection's quarantine_threshold, the connection
+// is quarantined until quarantinedUntil.
+type connectionFailures struct {
+	count            int
+	quarantinedUntil time.Time
+}
+
+// checkQuarantine returns an error if the named connection is quarantined, so
+// that requests fail fast instead of trying to reach a database that is down.
+// A quarantine that has expired is cleared. The caller needs to hold the
+// backend's write lock.
+func (b *databaseBackend) checkQuarantine(name string) error {
+	f, ok := b.failures[name]
+	if !ok || f.quarantinedUntil.IsZero() {
+		return nil
+	}
+
+	if time.Now().Before(f.quarantinedUntil) {
+		return fmt.Errorf("connection quarantined until %s after %d consecutive failures", f.quarantinedUntil.UTC().Format(time.RFC3339), f.count)
+	}
+
+	delete(b.failures, name)
+	return nil
+}
+
+// recordConnectionFailure counts a failure to start the named connection's
+// plugin, quarantining the connection when it reaches the threshold. The
+// caller needs to hold the backend's write lock.
+func (b *databaseBackend) recordConnectionFailure(name string, config *DatabaseConfig) {
+	if config.QuarantineThreshold <= 0 {
+		return
+	}
+
+	f, ok := b.failures[name]
+	if !ok {
+		f = &connectionFailures{}
+		b.failures[name] = f
+	}
+
+	f.count++
+	if f.count >= config.QuarantineThreshold {
+		f.quarantinedUntil = time.Now().Add(time.Duration(config.QuarantineCooldown) * time.Second)
+		incrConnectionCounter("quarantine", name)
+	}
 }
 
 func (b *databaseBackend) DatabaseConfig(ctx context.Context, s logical.Storage, name string) (*DatabaseConfig, error) {
@@ -148,7 +956,7 @@ type upgradeCheck struct {
 }
 
 func (b *databaseBackend) Role(ctx context.Context, s logical.Storage, roleName string) (*roleEntry, error) {
-	entry, err := s.Get(ctx, "role/"+roleName)
+	entry, err := s.Get(ctx, "role/"+b.canonicalName(roleName))
 	if err != nil {
 		return nil, err
 	}
@@ -174,9 +982,23 @@ func (b *databaseBackend) Role(ctx context.Context, s logical.Storage, roleName
 		result.Statements.RenewStatements = upgradeCh.Statements.RenewStatements
 	}
 
+	// Roles written before names were normalized may refer to their
+	// connection in a different case.
+	result.DBName = b.canonicalName(result.DBName)
+
 	return &result, nil
 }
 
+// periodicFunc retries revocations that were queued after failing and prunes
+// the index entries of users whose leases are gone.
+func (b *databaseBackend) periodicFunc(ctx context.Context, req *logical.Request) error {
+	if err := b.sweepPendingRevocations(ctx, req.Storage); err != nil {
+		return err
+	}
+
+	return b.pruneIssuedUsers(ctx, req.Storage)
+}
+
 func (b *databaseBackend) invalidate(ctx context.Context, key string) {
 	b.Lock()
 	defer b.Unlock()
@@ -185,26 +1007,146 @@ func (b *databaseBackend) invalidate(ctx context.Context, key string) {
 	case strings.HasPrefix(key, databaseConfigPath):
 		name := strings.TrimPrefix(key, databaseConfigPath)
 		b.clearConnection(name)
+		delete(b.lastVerified, name)
+		delete(b.failures, name)
 	}
 }
 
 // clearConnection closes the database connection and
-// removes it from the b.connections map.
+// removes it from the b.connections map. A connection still in use outside
+// the backend's lock is closed once the last operation using it releases it.
 func (b *databaseBackend) clearConnection(name string) {
 	db, ok := b.connections[name]
-	if ok {
-		db.Close()
-		delete(b.connections, name)
+	if !ok {
+		return
 	}
+	delete(b.connections, name)
+
+	if atomic.LoadInt32(&db.users) > 0 {
+		b.drainConnection(name, db, 0)
+		return
+	}
+	db.Close()
 }
 
-func (b *databaseBackend) closeIfShutdown(name string, err error) {
-	// Plugin has shutdown, close it so next call can reconnect.
-	switch err {
-	case rpc.ErrShutdown, dbplugin.ErrPluginShutdown:
+// defaultDrainPollInterval is how often a draining connection is checked for
+// operations still using it.
+const defaultDrainPollInterval = 100 * time.Millisecond
+
+// retireConnection removes the named connection from the cache so that new
+// requests start a new one. Without a grace period it's cleared as by
+// clearConnection. Otherwise it keeps serving the operations still using it
+// and is closed once they are done or the grace period ends, whichever comes
+// first. The caller needs to hold the backend's write lock.
+func (b *databaseBackend) retireConnection(name string, grace time.Duration) {
+	if grace <= 0 {
+		b.clearConnection(name)
+		return
+	}
+
+	dbi, ok := b.connections[name]
+	if !ok {
+		return
+	}
+	delete(b.connections, name)
+	b.drainConnection(name, dbi, grace)
+}
+
+// drainConnection closes dbi, a connection removed from the cache, once no
+// operation is using it or the grace period ends, whichever comes first.
+// Without a grace period it waits for the last operation. The caller needs to
+// hold the backend's write lock.
+func (b *databaseBackend) drainConnection(name string, dbi *dbPluginInstance, grace time.Duration) {
+	b.draining[dbi] = name
+	interval := b.drainPollInterval
+	b.drains.Add(1)
+	go func() {
+		defer b.drains.Done()
+
+		var deadline time.Time
+		if grace > 0 {
+			deadline = time.Now().Add(grace)
+		}
+		for atomic.LoadInt32(&dbi.users) > 0 && (deadline.IsZero() || time.Now().Before(deadline)) {
+			time.Sleep(interval)
+		}
+
 		b.Lock()
+		defer b.Unlock()
+
+		// closeAllDBs may have closed it already
+		if _, ok := b.draining[dbi]; ok {
+			delete(b.draining, dbi)
+			dbi.Close()
+		}
+	}()
+}
+
+// defaultRecycleErrors maps database types to substrings of the errors their
+// plugins return when the server has dropped the connection, such as during a
+// restart. Connections can set their own list with recycle_errors.
+var defaultRecycleErrors = map[string][]string{
+	"postgres": {
+		"terminating connection due to administrator command",
+		"the database system is shutting down",
+		"the database system is starting up",
+		"server closed the connection unexpectedly",
+	},
+	"mysql": {
+		"invalid connection",
+		"Server shutdown in progress",
+		"MySQL server has gone away",
+		"Lost connection to MySQL server",
+	},
+	"mssql": {
+		"connection reset by peer",
+		"Server is in script upgrade mode",
+	},
+	"hdb": {
+		"connection reset by peer",
+		"Connection lost",
+	},
+}
+
+// recycles reports whether err means the connection can't be used anymore
+// and should be recycled.
+func (d *dbPluginInstance) recycles(err error) bool {
+	for _, s := range d.recycleErrors {
+		if strings.Contains(err.Error(), s) {
+			return true
+		}
+	}
+	return false
+}
+
+// isPluginShutdown reports whether err means the plugin serving a connection
+// has shut down.
+func isPluginShutdown(err error) bool {
+	return err == rpc.ErrShutdown || err == dbplugin.ErrPluginShutdown
+}
+
+// closeIfShutdown clears dbi, the instance of the named connection an
+// operation failed with err on, if err means it can't be used anymore. The
+// connection may have been replaced while the operation ran, in which case
+// the replacement is left alone.
+func (b *databaseBackend) closeIfShutdown(name string, dbi *dbPluginInstance, err error) {
+	b.Lock()
+	defer b.Unlock()
+
+	if cached, ok := b.connections[name]; !ok || cached != dbi {
+		return
+	}
+
+	switch {
+	case isPluginShutdown(err):
+		// Plugin has shutdown, close it so next call can reconnect.
+		incrConnectionCounter("shutdown", name)
+		b.clearConnection(name)
+	case dbi.recycles(err):
+		// The database dropped the connection, so recycle the plugin's
+		// pool the same way.
+		incrConnectionCounter("recycle", name)
 		b.clearConnection(name)
-		b.Unlock()
 	}
 }
 
@@ -3,19 +3,24 @@ package database
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"log"
 	"os"
 	"reflect"
+	"strings"
 	"sync"
 	"testing"
 	"time"
 
+	metrics "github.com/armon/go-metrics"
+	"github.com/hashicorp/vault/api"
 	"github.com/hashicorp/vault/builtin/logical/database/dbplugin"
 	"github.com/hashicorp/vault/helper/pluginutil"
 	vaulthttp "github.com/hashicorp/vault/http"
 	"github.com/hashicorp/vault/logical"
 	"github.com/hashicorp/vault/plugins/database/postgresql"
+	"github.com/hashicorp/vault/plugins/helper/database/dbutil"
 	"github.com/hashicorp/vault/vault"
 	"github.com/lib/pq"
 	"github.com/mitchellh/mapstructure"
@@ -121,7 +126,7 @@ func TestBackend_PluginMain(t *testing.T) {
 func TestBackend_RoleUpgrade(t *testing.T) {
 
 	storage := &logical.InmemStorage{}
-	backend := &databaseBackend{}
+	backend := Backend(logical.TestBackendConfig())
 
 	roleEnt := &roleEntry{
 		Statements: dbplugin.Statements{
@@ -165,6 +170,48 @@ func TestBackend_RoleUpgrade(t *testing.T) {
 		t.Fatalf("bad role %#v", role)
 	}
 
+	// Migrating rewrites the entry without the legacy fields
+	resp, err := backend.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "roles/test/migrate",
+		Storage:   storage,
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%s resp:%#v\n", err, resp)
+	}
+	if resp.Data["migrated"] != true {
+		t.Fatalf("expected role to be migrated, got: %#v", resp.Data)
+	}
+
+	entry, err = storage.Get(context.Background(), "role/test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(entry.Value), "statments") {
+		t.Fatalf("expected legacy fields to be removed, got: %s", entry.Value)
+	}
+
+	role, err = backend.Role(context.Background(), storage, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(role, roleEnt) {
+		t.Fatalf("bad role %#v", role)
+	}
+
+	// A role in the current format is left as is
+	resp, err = backend.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "roles/test/migrate",
+		Storage:   storage,
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%s resp:%#v\n", err, resp)
+	}
+	if resp.Data["migrated"] != false {
+		t.Fatalf("expected role not to be migrated, got: %#v", resp.Data)
+	}
 }
 
 func TestBackend_config_connection(t *testing.T) {
@@ -201,12 +248,50 @@ func TestBackend_config_connection(t *testing.T) {
 		t.Fatalf("err:%s resp:%#v\n", err, resp)
 	}
 
+	buildInfo := dbutil.BuildInfo()
 	expected := map[string]interface{}{
 		"plugin_name": "postgresql-database-plugin",
 		"connection_details": map[string]interface{}{
 			"connection_url": "sample_connection_url",
 		},
-		"allowed_roles": []string{"*"},
+		"allowed_roles":             []string{"*"},
+		"username_prefix":           "",
+		"environment":               "",
+		"environment_prefix":        false,
+		"issuance_ping_timeout":     0,
+		"max_concurrent_creations":  0,
+		"revocation_retries":        0,
+		"recycle_errors":            []string(nil),
+		"verification_freshness":    0,
+		"quarantine_threshold":      5,
+		"quarantine_cooldown":       60,
+		"client_certificate_ca":     "",
+		"reload_grace_period":       0,
+		"statement_timeout":         0,
+		"max_statement_timeout":     0,
+		"creds_rate_limit":          0,
+		"creds_rate_limit_interval": 60,
+		"statement_variables":       map[string]string{},
+		"log_statements":            false,
+		"plugin_capabilities": []string{
+			dbplugin.CapabilityCreateUser,
+			dbplugin.CapabilityRenewUser,
+			dbplugin.CapabilityRevokeUser,
+			dbplugin.CapabilityUserGrants,
+			dbplugin.CapabilityUpdatePoolSettings,
+			dbplugin.CapabilityServerVersion,
+			dbplugin.CapabilityPing,
+			dbplugin.CapabilityGenerateCredentials,
+			dbplugin.CapabilityPluginInfo,
+			dbplugin.CapabilityStatementLists,
+			dbplugin.CapabilityRevocationSteps,
+		},
+		"server_version": "unknown",
+		"plugin_version": buildInfo.Version,
+		"plugin_build_info": map[string]string{
+			"revision":   "unknown",
+			"go_version": buildInfo.GoVersion,
+		},
 	}
 	configReq.Operation = logical.ReadOperation
 	resp, err = b.HandleRequest(context.Background(), configReq)
@@ -462,12 +547,49 @@ func TestBackend_connectionCrud(t *testing.T) {
 	}
 
 	// Read connection
+	buildInfo := dbutil.BuildInfo()
 	expected := map[string]interface{}{
 		"plugin_name": "postgresql-database-plugin",
 		"connection_details": map[string]interface{}{
 			"connection_url": connURL,
 		},
-		"allowed_roles": []string{"plugin-role-test"},
+		"allowed_roles":             []string{"plugin-role-test"},
+		"username_prefix":           "",
+		"environment":               "",
+		"environment_prefix":        false,
+		"issuance_ping_timeout":     0,
+		"max_concurrent_creations":  0,
+		"revocation_retries":        0,
+		"recycle_errors":            []string(nil),
+		"verification_freshness":    0,
+		"quarantine_threshold":      5,
+		"quarantine_cooldown":       60,
+		"client_certificate_ca":     "",
+		"reload_grace_period":       0,
+		"statement_timeout":         0,
+		"max_statement_timeout":     0,
+		"creds_rate_limit":          0,
+		"creds_rate_limit_interval": 60,
+		"statement_variables":       map[string]string{},
+		"log_statements":            false,
+		"plugin_capabilities": []string{
+			dbplugin.CapabilityCreateUser,
+			dbplugin.CapabilityRenewUser,
+			dbplugin.CapabilityRevokeUser,
+			dbplugin.CapabilityUserGrants,
+			dbplugin.CapabilityUpdatePoolSettings,
+			dbplugin.CapabilityServerVersion,
+			dbplugin.CapabilityPing,
+			dbplugin.CapabilityGenerateCredentials,
+			dbplugin.CapabilityPluginInfo,
+			dbplugin.CapabilityStatementLists,
+			dbplugin.CapabilityRevocationSteps,
+		},
+		"plugin_version": buildInfo.Version,
+		"plugin_build_info": map[string]string{
+			"revision":   "unknown",
+			"go_version": buildInfo.GoVersion,
+		},
 	}
 	req.Operation = logical.ReadOperation
 	resp, err = b.HandleRequest(context.Background(), req)
@@ -475,6 +597,11 @@ func TestBackend_connectionCrud(t *testing.T) {
 		t.Fatalf("err:%s resp:%#v\n", err, resp)
 	}
 
+	if !strings.HasPrefix(resp.Data["server_version"].(string), "PostgreSQL") {
+		t.Fatalf("bad server version: %#v", resp.Data["server_version"])
+	}
+	delete(resp.Data, "server_version")
+
 	delete(resp.Data["connection_details"].(map[string]interface{}), "name")
 	if !reflect.DeepEqual(expected, resp.Data) {
 		t.Fatalf("bad: expected:%#v\nactual:%#v\n", expected, resp.Data)
@@ -648,6 +775,7 @@ func TestBackend_roleCrud(t *testing.T) {
 		t.Fatal("Expected response to be nil")
 	}
 }
+
 func TestBackend_allowedRoles(t *testing.T) {
 	cluster, sys := getCluster(t)
 	defer cluster.Cleanup()
@@ -905,3 +1033,1348 @@ REVOKE USAGE ON SCHEMA public FROM {{name}};
 
 DROP ROLE IF EXISTS {{name}};
 `
+
+// fakeDatabase is a dbplugin.Database used to exercise backend logic without
+// running a real plugin. Each operation calls the function of the same name if
+// it's set, and otherwise succeeds, creating "user" with "password". The
+// optional operations are only reported as capabilities when their function
+// is set, unless caps lists the capabilities to report instead.
+type fakeDatabase struct {
+	dbType string
+	caps   []string
+
+	createUser          func(ctx context.Context, statements dbplugin.Statements, usernameConfig dbplugin.UsernameConfig, expiration time.Time) (string, string, error)
+	revokeUser          func(ctx context.Context, statements dbplugin.Statements, username string) error
+	close               func() error
+	userGrants          func(ctx context.Context, username string) ([]string, error)
+	updatePoolSettings  func(ctx context.Context, config map[string]interface{}) error
+	serverVersion       func(ctx context.Context) (string, error)
+	ping                func(ctx context.Context) error
+	generateCredentials func(ctx context.Context, usernameConfig dbplugin.UsernameConfig) (string, string, error)
+	pluginInfo          func(ctx context.Context) (*dbplugin.BuildInfo, error)
+
+	sync.Mutex
+	closed bool
+}
+
+func (f *fakeDatabase) Type() (string, error) {
+	if f.dbType != "" {
+		return f.dbType, nil
+	}
+	return "fake", nil
+}
+
+func (f *fakeDatabase) CreateUser(ctx context.Context, statements dbplugin.Statements, usernameConfig dbplugin.UsernameConfig, expiration time.Time) (string, string, error) {
+	if f.createUser != nil {
+		return f.createUser(ctx, statements, usernameConfig, expiration)
+	}
+	return "user", "password", nil
+}
+
+func (f *fakeDatabase) RenewUser(_ context.Context, _ dbplugin.Statements, _ string, _ time.Time) error {
+	return nil
+}
+
+func (f *fakeDatabase) RevokeUser(ctx context.Context, statements dbplugin.Statements, username string) error {
+	if f.revokeUser != nil {
+		return f.revokeUser(ctx, statements, username)
+	}
+	return nil
+}
+
+func (f *fakeDatabase) Initialize(_ context.Context, _ map[string]interface{}, _ bool) error {
+	return nil
+}
+
+func (f *fakeDatabase) Close() error {
+	var err error
+	if f.close != nil {
+		err = f.close()
+	}
+
+	f.Lock()
+	f.closed = true
+	f.Unlock()
+	return err
+}
+
+func (f *fakeDatabase) isClosed() bool {
+	f.Lock()
+	defer f.Unlock()
+	return f.closed
+}
+
+func (f *fakeDatabase) Capabilities(_ context.Context) ([]string, error) {
+	if f.caps != nil {
+		return f.caps, nil
+	}
+
+	caps := append([]string(nil), dbplugin.DefaultCapabilities...)
+	if f.userGrants != nil {
+		caps = append(caps, dbplugin.CapabilityUserGrants)
+	}
+	if f.updatePoolSettings != nil {
+		caps = append(caps, dbplugin.CapabilityUpdatePoolSettings)
+	}
+	if f.serverVersion != nil {
+		caps = append(caps, dbplugin.CapabilityServerVersion)
+	}
+	if f.ping != nil {
+		caps = append(caps, dbplugin.CapabilityPing)
+	}
+	if f.generateCredentials != nil {
+		caps = append(caps, dbplugin.CapabilityGenerateCredentials)
+	}
+	if f.pluginInfo != nil {
+		caps = append(caps, dbplugin.CapabilityPluginInfo)
+	}
+	return caps, nil
+}
+
+func (f *fakeDatabase) UserGrants(ctx context.Context, username string) ([]string, error) {
+	if f.userGrants == nil {
+		return nil, dbplugin.ErrUnsupportedOperation
+	}
+	return f.userGrants(ctx, username)
+}
+
+func (f *fakeDatabase) UpdatePoolSettings(ctx context.Context, config map[string]interface{}) error {
+	if f.updatePoolSettings == nil {
+		return dbplugin.ErrUnsupportedOperation
+	}
+	return f.updatePoolSettings(ctx, config)
+}
+
+func (f *fakeDatabase) ServerVersion(ctx context.Context) (string, error) {
+	if f.serverVersion == nil {
+		return "", dbplugin.ErrUnsupportedOperation
+	}
+	return f.serverVersion(ctx)
+}
+
+func (f *fakeDatabase) Ping(ctx context.Context) error {
+	if f.ping == nil {
+		return dbplugin.ErrUnsupportedOperation
+	}
+	return f.ping(ctx)
+}
+
+func (f *fakeDatabase) GenerateCredentials(ctx context.Context, usernameConfig dbplugin.UsernameConfig) (string, string, error) {
+	if f.generateCredentials == nil {
+		return "", "", dbplugin.ErrUnsupportedOperation
+	}
+	return f.generateCredentials(ctx, usernameConfig)
+}
+
+func (f *fakeDatabase) PluginInfo(ctx context.Context) (*dbplugin.BuildInfo, error) {
+	if f.pluginInfo == nil {
+		return nil, dbplugin.ErrUnsupportedOperation
+	}
+	return f.pluginInfo(ctx)
+}
+
+// fakeGenerateCredentials is a generateCredentials function for a
+// fakeDatabase, naming users after their role and username prefix.
+func fakeGenerateCredentials(_ context.Context, usernameConfig dbplugin.UsernameConfig) (string, string, error) {
+	return usernameConfig.UsernamePrefix + usernameConfig.RoleName, "password", nil
+}
+
+// testFakeConnection stores a connection named "fake" that allows all roles,
+// caches dbi as its plugin instance and stores role as "readonly" against it.
+func testFakeConnection(t *testing.T, b *databaseBackend, s logical.Storage, dbi *dbPluginInstance, role *roleEntry) {
+	entry, err := logical.StorageEntryJSON("config/fake", &DatabaseConfig{
+		PluginName:   "fake",
+		AllowedRoles: []string{"*"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Put(context.Background(), entry); err != nil {
+		t.Fatal(err)
+	}
+
+	role.DBName = "fake"
+	entry, err = logical.StorageEntryJSON("role/readonly", role)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Put(context.Background(), entry); err != nil {
+		t.Fatal(err)
+	}
+
+	b.connections["fake"] = dbi
+}
+
+// fakePluginSystemView is a system view whose plugin catalog only holds the
+// "fake" plugin. Unless factory is set the plugin is known but can't be
+// started, since the fake databases are cached directly by the tests.
+type fakePluginSystemView struct {
+	*logical.StaticSystemView
+
+	factory func() (interface{}, error)
+}
+
+func (v fakePluginSystemView) LookupPlugin(_ context.Context, name string) (*pluginutil.PluginRunner, error) {
+	if name != "fake" {
+		return nil, fmt.Errorf("no plugin found: %s", name)
+	}
+
+	factory := v.factory
+	if factory == nil {
+		factory = func() (interface{}, error) {
+			return nil, errors.New("fake plugins can't be started")
+		}
+	}
+
+	return &pluginutil.PluginRunner{
+		Name:           name,
+		Builtin:        true,
+		BuiltinFactory: factory,
+	}, nil
+}
+
+// testFakePluginBackendConfig returns a backend config with in-memory storage
+// and a fakePluginSystemView.
+func testFakePluginBackendConfig() *logical.BackendConfig {
+	config := logical.TestBackendConfig()
+	config.StorageView = &logical.InmemStorage{}
+	config.System = fakePluginSystemView{StaticSystemView: logical.TestSystemView()}
+
+	return config
+}
+
+// getBackend returns a backend set up with testFakePluginBackendConfig, along
+// with its storage.
+func getBackend(t *testing.T) (*databaseBackend, logical.Storage) {
+	config := testFakePluginBackendConfig()
+	b := Backend(config)
+	if err := b.Setup(context.Background(), config); err != nil {
+		t.Fatal(err)
+	}
+
+	return b, config.StorageView
+}
+
+// testMountCluster returns a cluster whose database secrets engines are
+// created through sys/mounts, and a function returning the backend created by
+// the last successful mount along with its storage.
+func testMountCluster(t *testing.T) (*vault.TestCluster, func() (*databaseBackend, logical.Storage)) {
+	var lock sync.Mutex
+	var mounted *databaseBackend
+	var storage logical.Storage
+
+	coreConfig := &vault.CoreConfig{
+		LogicalBackends: map[string]logical.Factory{
+			"database": func(ctx context.Context, conf *logical.BackendConfig) (logical.Backend, error) {
+				b, err := Factory(ctx, conf)
+				if err != nil {
+					return nil, err
+				}
+				lock.Lock()
+				mounted = b.(*databaseBackend)
+				storage = conf.StorageView
+				lock.Unlock()
+				return b, nil
+			},
+		},
+	}
+
+	cluster := vault.NewTestCluster(t, coreConfig, &vault.TestClusterOptions{
+		HandlerFunc: vaulthttp.Handler,
+	})
+	cluster.Start()
+
+	os.Setenv(pluginutil.PluginCACertPEMEnv, cluster.CACertPEMFile)
+	vault.TestAddTestPlugin(t, cluster.Cores[0].Core, "postgresql-database-plugin", "TestBackend_PluginMain")
+
+	return cluster, func() (*databaseBackend, logical.Storage) {
+		lock.Lock()
+		defer lock.Unlock()
+		return mounted, storage
+	}
+}
+
+// testMountOptions mounts a database secrets engine at path with options
+// through the API.
+func testMountOptions(t *testing.T, cluster *vault.TestCluster, path string, options map[string]string) error {
+	return cluster.Cores[0].Client.Sys().Mount(path, &api.MountInput{
+		Type:    "database",
+		Options: options,
+	})
+}
+
+// testReloadedBackend steps the active node down and returns the backend
+// created from the stored mount table by the node taking over, as on unseal.
+func testReloadedBackend(t *testing.T, cluster *vault.TestCluster, mounted func() (*databaseBackend, logical.Storage)) *databaseBackend {
+	prev, _ := mounted()
+	if err := cluster.Cores[0].Client.Sys().StepDown(); err != nil {
+		t.Fatal(err)
+	}
+
+	for timeout := time.Now().Add(30 * time.Second); time.Now().Before(timeout); time.Sleep(100 * time.Millisecond) {
+		if b, _ := mounted(); b != prev {
+			return b
+		}
+	}
+	t.Fatal("timed out waiting for the mount to be loaded by another node")
+	return nil
+}
+
+func TestBackend_closeAllDBs(t *testing.T) {
+	config := logical.TestBackendConfig()
+	config.StorageView = &logical.InmemStorage{}
+	b := Backend(config)
+
+	blockCh := make(chan struct{})
+	defer close(blockCh)
+
+	healthy := &fakeDatabase{}
+	b.connections["healthy"] = &dbPluginInstance{Database: healthy}
+	b.connections["hung"] = &dbPluginInstance{Database: &fakeDatabase{close: func() error {
+		<-blockCh
+		return nil
+	}}}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	doneCh := make(chan struct{})
+	go func() {
+		b.closeAllDBs(ctx)
+		close(doneCh)
+	}()
+
+	select {
+	case <-doneCh:
+	case <-time.After(5 * time.Second):
+		t.Fatal("closeAllDBs did not return after the context deadline")
+	}
+
+	if !healthy.isClosed() {
+		t.Fatal("expected healthy connection to be closed")
+	}
+	if len(b.connections) != 0 {
+		t.Fatalf("expected connections to be cleared, got %d", len(b.connections))
+	}
+}
+
+// fakeCloseCounter tracks how many fakeDatabases are closing at once.
+type fakeCloseCounter struct {
+	sync.Mutex
+	inFlight    int
+	maxInFlight int
+}
+
+// closeFunc returns a close function for a fakeDatabase that takes a moment
+// and returns err.
+func (c *fakeCloseCounter) closeFunc(err error) func() error {
+	return func() error {
+		c.Lock()
+		c.inFlight++
+		if c.inFlight > c.maxInFlight {
+			c.maxInFlight = c.inFlight
+		}
+		c.Unlock()
+
+		time.Sleep(5 * time.Millisecond)
+
+		c.Lock()
+		c.inFlight--
+		c.Unlock()
+		return err
+	}
+}
+
+func TestBackend_closeAllDBsParallel(t *testing.T) {
+	defer func(workers int) {
+		closeAllDBsWorkers = workers
+	}(closeAllDBsWorkers)
+	closeAllDBsWorkers = 4
+
+	config := logical.TestBackendConfig()
+	config.StorageView = &logical.InmemStorage{}
+	b := Backend(config)
+
+	counter := &fakeCloseCounter{}
+	var dbs []*fakeDatabase
+	for i := 0; i < 200; i++ {
+		var closeErr error
+		if i%10 == 0 {
+			closeErr = errors.New("close failed")
+		}
+		db := &fakeDatabase{close: counter.closeFunc(closeErr)}
+		dbs = append(dbs, db)
+
+		dbi := &dbPluginInstance{Database: db}
+		if i%20 == 0 {
+			b.draining[dbi] = fmt.Sprintf("db-%d", i)
+		} else {
+			b.connections[fmt.Sprintf("db-%d", i)] = dbi
+		}
+	}
+
+	b.closeAllDBs(context.Background())
+
+	// Every connection is closed, even those that fail to close cleanly
+	for i, db := range dbs {
+		if !db.isClosed() {
+			t.Fatalf("expected connection %d to be closed", i)
+		}
+	}
+	if counter.maxInFlight > 4 {
+		t.Fatalf("expected at most 4 connections closing at once, got %d", counter.maxInFlight)
+	}
+	if counter.maxInFlight < 2 {
+		t.Fatalf("expected connections to be closed in parallel, got %d at once", counter.maxInFlight)
+	}
+	if len(b.connections) != 0 || len(b.draining) != 0 {
+		t.Fatalf("expected connections to be cleared, got %d and %d draining", len(b.connections), len(b.draining))
+	}
+}
+
+func TestBackend_pluginCapabilities(t *testing.T) {
+	b, storage := getBackend(t)
+
+	// Databases support the default capabilities unless they report others
+	dbi, err := newDBPluginInstance(context.Background(), &fakeDatabase{}, &DatabaseConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(dbi.capabilities, dbplugin.DefaultCapabilities) {
+		t.Fatalf("expected default capabilities, got %v", dbi.capabilities)
+	}
+
+	dbi, err = newDBPluginInstance(context.Background(), &fakeDatabase{
+		caps: []string{dbplugin.CapabilityRevokeUser},
+	}, &DatabaseConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := dbi.supports(dbplugin.CapabilityRevokeUser); err != nil {
+		t.Fatal(err)
+	}
+
+	testFakeConnection(t, b, storage, dbi, &roleEntry{})
+
+	// Creating credentials isn't supported by the plugin
+	_, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "creds/readonly",
+		Storage:   storage,
+	})
+	if err == nil || !strings.Contains(err.Error(), dbplugin.ErrUnsupportedOperation.Error()) {
+		t.Fatalf("expected unsupported operation error, got: %v", err)
+	}
+
+	// The capabilities are reported when reading the connection
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "config/fake",
+		Storage:   storage,
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%s resp:%#v\n", err, resp)
+	}
+	if !reflect.DeepEqual(resp.Data["plugin_capabilities"], []string{dbplugin.CapabilityRevokeUser}) {
+		t.Fatalf("bad: %#v", resp.Data["plugin_capabilities"])
+	}
+}
+
+func TestBackend_maxConcurrentCreations(t *testing.T) {
+	dbi, err := newDBPluginInstance(context.Background(), &fakeDatabase{}, &DatabaseConfig{
+		MaxConcurrentCreations: 1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := dbi.acquireCreation(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// The only slot is taken, so a second creation gives up when its context
+	// expires
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := dbi.acquireCreation(ctx); err != errTooManyConcurrentCreations {
+		t.Fatalf("expected errTooManyConcurrentCreations, got: %v", err)
+	}
+
+	dbi.releaseCreation()
+	if err := dbi.acquireCreation(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	dbi.releaseCreation()
+
+	// Unset means unbounded
+	dbi, err = newDBPluginInstance(context.Background(), &fakeDatabase{}, &DatabaseConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		if err := dbi.acquireCreation(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestBackend_defaultParams(t *testing.T) {
+	config := logical.TestBackendConfig()
+	config.StorageView = &logical.InmemStorage{}
+
+	config.Config = map[string]string{"default_params": "connect_timeout=%zz"}
+	if _, err := Factory(context.Background(), config); err == nil {
+		t.Fatal("expected error for invalid default_params")
+	}
+
+	config.Config = map[string]string{"default_params": "connect_timeout=5&sslmode=require"}
+	b, err := Factory(context.Background(), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dbConfig := &DatabaseConfig{
+		ConnectionDetails: map[string]interface{}{
+			"connection_url": "postgres://localhost/db",
+		},
+	}
+	expected := map[string]interface{}{
+		"connection_url": "postgres://localhost/db",
+		"default_params": map[string]string{
+			"connect_timeout": "5",
+			"sslmode":         "require",
+		},
+	}
+	details := b.(*databaseBackend).connectionDetails(dbConfig)
+	if !reflect.DeepEqual(details, expected) {
+		t.Fatalf("expected %#v, got %#v", expected, details)
+	}
+	if _, ok := dbConfig.ConnectionDetails["default_params"]; ok {
+		t.Fatal("expected stored connection details to be unchanged")
+	}
+}
+
+func TestBackend_mountOptions(t *testing.T) {
+	cluster, mounted := testMountCluster(t)
+	defer cluster.Cleanup()
+	client := cluster.Cores[0].Client
+
+	// Invalid and unknown options reject the mount, so they are never stored
+	for _, options := range []map[string]string{
+		{"default_params": "connect_timeout=%zz"},
+		{"default_parms": "connect_timeout=5"},
+	} {
+		if err := testMountOptions(t, cluster, "bad", options); err == nil {
+			t.Fatalf("expected error mounting with options %v", options)
+		}
+	}
+	mounts, err := client.Sys().ListMounts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := mounts["bad/"]; ok {
+		t.Fatal("expected the mount with invalid options not to be added")
+	}
+
+	options := map[string]string{"default_params": "connect_timeout=5&sslmode=require"}
+	if err := testMountOptions(t, cluster, "db", options); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{"connect_timeout": "5", "sslmode": "require"}
+	if b, _ := mounted(); b == nil || !reflect.DeepEqual(b.defaultParams, expected) {
+		t.Fatalf("expected default params %v on the mounted backend", expected)
+	}
+
+	mounts, err = client.Sys().ListMounts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mount, ok := mounts["db/"]; !ok || !reflect.DeepEqual(mount.Options, options) {
+		t.Fatalf("expected the options to be listed with the mount, got: %#v", mount)
+	}
+}
+
+func TestBackend_maxCachedConnectionsMount(t *testing.T) {
+	cluster, mounted := testMountCluster(t)
+	defer cluster.Cleanup()
+
+	if err := testMountOptions(t, cluster, "bad", map[string]string{"max_cached_connections": "-1"}); err == nil {
+		t.Fatal("expected error for invalid max_cached_connections")
+	}
+
+	if err := testMountOptions(t, cluster, "db", map[string]string{"max_cached_connections": "2"}); err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := mounted(); b == nil || b.maxConnections != 2 {
+		t.Fatal("expected max_cached_connections to be set on the mounted backend")
+	}
+
+	// The stored option is used again when the mount table is loaded
+	if b := testReloadedBackend(t, cluster, mounted); b.maxConnections != 2 {
+		t.Fatalf("expected max_cached_connections to be 2 after reloading, got %d", b.maxConnections)
+	}
+}
+
+func TestBackend_maxCachedConnections(t *testing.T) {
+	config := logical.TestBackendConfig()
+	config.StorageView = &logical.InmemStorage{}
+
+	config.Config = map[string]string{"max_cached_connections": "-1"}
+	if _, err := Factory(context.Background(), config); err == nil {
+		t.Fatal("expected error for invalid max_cached_connections")
+	}
+
+	config.Config = map[string]string{"max_cached_connections": "2"}
+	raw, err := Factory(context.Background(), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := raw.(*databaseBackend)
+
+	newInstance := func(lastUsed int64) (*dbPluginInstance, *fakeDatabase) {
+		db := &fakeDatabase{}
+		dbi, err := newDBPluginInstance(context.Background(), db, &DatabaseConfig{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		dbi.lastUsed = lastUsed
+		return dbi, db
+	}
+
+	b.Lock()
+	defer b.Unlock()
+
+	first, firstDB := newInstance(0)
+	second, secondDB := newInstance(0)
+	b.cacheConnection("first", first)
+	b.cacheConnection("second", second)
+
+	// Using the first connection makes the second the least recently used
+	first.lastUsed, second.lastUsed = 2, 1
+	third, _ := newInstance(0)
+	b.cacheConnection("third", third)
+
+	if len(b.connections) != 2 {
+		t.Fatalf("expected 2 cached connections, got %d", len(b.connections))
+	}
+	if _, ok := b.connections["second"]; ok || !secondDB.isClosed() {
+		t.Fatal("expected the least recently used connection to be evicted")
+	}
+	if _, ok := b.connections["first"]; !ok || firstDB.isClosed() {
+		t.Fatal("expected the recently used connection to be kept")
+	}
+
+	// Replacing a cached connection doesn't evict another one
+	replacement, _ := newInstance(0)
+	b.cacheConnection("third", replacement)
+	if len(b.connections) != 2 || b.connections["first"] != first {
+		t.Fatalf("expected replacement without eviction, got %#v", b.connections)
+	}
+
+	// Connections in use are never evicted, even if that exceeds the cap
+	first.lastUsed, replacement.lastUsed = 1, 2
+	first.users, replacement.users = 1, 1
+	fourth, _ := newInstance(0)
+	b.cacheConnection("fourth", fourth)
+	if len(b.connections) != 3 || firstDB.isClosed() {
+		t.Fatalf("expected in-use connections to be kept, got %#v", b.connections)
+	}
+
+	// Once released, the least recently used is evicted again
+	first.release()
+	replacement.release()
+	fifth, _ := newInstance(0)
+	b.cacheConnection("fifth", fifth)
+	if _, ok := b.connections["first"]; ok || !firstDB.isClosed() {
+		t.Fatal("expected the released connection to be evicted")
+	}
+	if len(b.connections) != 2 {
+		t.Fatalf("expected 2 cached connections, got %d", len(b.connections))
+	}
+}
+
+func TestBackend_reloadGracePeriod(t *testing.T) {
+	b, _ := getBackend(t)
+
+	b.drainPollInterval = 10 * time.Millisecond
+
+	newInstance := func() (*dbPluginInstance, *fakeDatabase) {
+		db := &fakeDatabase{}
+		dbi, err := newDBPluginInstance(context.Background(), db, &DatabaseConfig{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return dbi, db
+	}
+
+	waitClosed := func(db *fakeDatabase) bool {
+		for i := 0; i < 100; i++ {
+			if db.isClosed() {
+				return true
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		return false
+	}
+
+	// Without a grace period the connection is closed immediately
+	dbi, db := newInstance()
+	b.Lock()
+	b.connections["fake"] = dbi
+	b.retireConnection("fake", 0)
+	b.Unlock()
+	if !db.isClosed() {
+		t.Fatal("expected the connection to be closed")
+	}
+
+	// A connection in use is closed once it's released
+	dbi, db = newInstance()
+	dbi.users = 1
+	b.Lock()
+	b.connections["fake"] = dbi
+	b.retireConnection("fake", time.Minute)
+	_, cached := b.connections["fake"]
+	b.Unlock()
+	if cached {
+		t.Fatal("expected new requests not to use the draining connection")
+	}
+	time.Sleep(5 * b.drainPollInterval)
+	if db.isClosed() {
+		t.Fatal("expected the connection to drain")
+	}
+	dbi.release()
+	if !waitClosed(db) {
+		t.Fatal("expected the connection to be closed once released")
+	}
+
+	// The grace period bounds how long the connection drains
+	dbi, db = newInstance()
+	dbi.users = 1
+	b.Lock()
+	b.connections["fake"] = dbi
+	b.retireConnection("fake", 50*time.Millisecond)
+	b.Unlock()
+	if !waitClosed(db) {
+		t.Fatal("expected the connection to be closed after the grace period")
+	}
+
+	// Cleaning up the backend closes draining connections too
+	dbi, db = newInstance()
+	dbi.users = 1
+	b.Lock()
+	b.connections["fake"] = dbi
+	b.retireConnection("fake", time.Minute)
+	b.Unlock()
+	b.closeAllDBs(context.Background())
+	if !db.isClosed() {
+		t.Fatal("expected the draining connection to be closed")
+	}
+
+	// Don't leave the drain running past the test
+	dbi.release()
+	b.drains.Wait()
+}
+
+func TestBackend_clearConnectionInUse(t *testing.T) {
+	b, storage := getBackend(t)
+
+	db := &fakeDatabase{}
+	dbi, err := newDBPluginInstance(context.Background(), db, &DatabaseConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	testFakeConnection(t, b, storage, dbi, &roleEntry{})
+
+	// An instance in use outside the backend's lock outlives invalidation
+	inUse, err := b.pluginInstance(context.Background(), storage, "fake")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.invalidate(context.Background(), databaseConfigPath+"fake")
+	if _, ok := b.connections["fake"]; ok {
+		t.Fatal("expected the connection to be cleared")
+	}
+	if db.isClosed() {
+		t.Fatal("expected the connection in use not to be closed")
+	}
+
+	inUse.release()
+	b.drains.Wait()
+	if !db.isClosed() {
+		t.Fatal("expected the connection to be closed once released")
+	}
+}
+
+func TestBackend_serverVersion(t *testing.T) {
+	b, storage := getBackend(t)
+
+	configReq := &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "config/fake",
+		Storage:   storage,
+	}
+
+	queries := 0
+	db := &fakeDatabase{
+		serverVersion: func(_ context.Context) (string, error) {
+			queries++
+			return "FakeDB 1.2.3", nil
+		},
+	}
+	dbi, err := newDBPluginInstance(context.Background(), db, &DatabaseConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	testFakeConnection(t, b, storage, dbi, &roleEntry{})
+
+	// The version is queried once per connection
+	for i := 0; i < 2; i++ {
+		resp, err := b.HandleRequest(context.Background(), configReq)
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("err:%s resp:%#v\n", err, resp)
+		}
+		if resp.Data["server_version"] != "FakeDB 1.2.3" {
+			t.Fatalf("bad server version: %#v", resp.Data["server_version"])
+		}
+	}
+	if queries != 1 {
+		t.Fatalf("expected the version to be cached, got %d queries", queries)
+	}
+
+	// Plugins that can't report a version are reported as unknown
+	dbi, err = newDBPluginInstance(context.Background(), &fakeDatabase{}, &DatabaseConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	testFakeConnection(t, b, storage, dbi, &roleEntry{})
+
+	resp, err := b.HandleRequest(context.Background(), configReq)
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%s resp:%#v\n", err, resp)
+	}
+	if resp.Data["server_version"] != "unknown" {
+		t.Fatalf("bad server version: %#v", resp.Data["server_version"])
+	}
+}
+
+func TestBackend_pluginVersion(t *testing.T) {
+	b, storage := getBackend(t)
+
+	configReq := &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "config/fake",
+		Storage:   storage,
+	}
+
+	queries := 0
+	db := &fakeDatabase{
+		pluginInfo: func(_ context.Context) (*dbplugin.BuildInfo, error) {
+			queries++
+			return &dbplugin.BuildInfo{
+				Version:  "v1.2.3",
+				Revision: "abc123",
+			}, nil
+		},
+	}
+	dbi, err := newDBPluginInstance(context.Background(), db, &DatabaseConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	testFakeConnection(t, b, storage, dbi, &roleEntry{})
+
+	// The build info is queried once per connection, and missing values are
+	// reported as unknown
+	for i := 0; i < 2; i++ {
+		resp, err := b.HandleRequest(context.Background(), configReq)
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("err:%s resp:%#v\n", err, resp)
+		}
+		if resp.Data["plugin_version"] != "v1.2.3" {
+			t.Fatalf("bad plugin version: %#v", resp.Data["plugin_version"])
+		}
+		expected := map[string]string{
+			"revision":   "abc123",
+			"go_version": "unknown",
+		}
+		if !reflect.DeepEqual(resp.Data["plugin_build_info"], expected) {
+			t.Fatalf("bad build info: %#v", resp.Data["plugin_build_info"])
+		}
+	}
+	if queries != 1 {
+		t.Fatalf("expected the build info to be cached, got %d queries", queries)
+	}
+
+	// Plugins that can't report their version are reported as unknown
+	dbi, err = newDBPluginInstance(context.Background(), &fakeDatabase{}, &DatabaseConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	testFakeConnection(t, b, storage, dbi, &roleEntry{})
+
+	resp, err := b.HandleRequest(context.Background(), configReq)
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%s resp:%#v\n", err, resp)
+	}
+	if resp.Data["plugin_version"] != "unknown" {
+		t.Fatalf("bad plugin version: %#v", resp.Data["plugin_version"])
+	}
+}
+
+// fakeShutdownDatabase returns a fakeDatabase whose plugin has exited, counting
+// the calls made to it in calls.
+func fakeShutdownDatabase(calls *int) *fakeDatabase {
+	return &fakeDatabase{
+		createUser: func(_ context.Context, _ dbplugin.Statements, _ dbplugin.UsernameConfig, _ time.Time) (string, string, error) {
+			*calls++
+			return "", "", dbplugin.ErrPluginShutdown
+		},
+		revokeUser: func(_ context.Context, _ dbplugin.Statements, _ string) error {
+			*calls++
+			return dbplugin.ErrPluginShutdown
+		},
+	}
+}
+
+func TestBackend_pluginShutdownRetry(t *testing.T) {
+	b, storage := getBackend(t)
+
+	// The fake plugin can't be restarted, so the retry fails to re-establish
+	// the connection rather than reporting the shutdown
+	calls := 0
+	dbi, err := newDBPluginInstance(context.Background(), fakeShutdownDatabase(&calls), &DatabaseConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	testFakeConnection(t, b, storage, dbi, &roleEntry{})
+
+	_, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "creds/readonly",
+		Storage:   storage,
+	})
+	if err == nil || !strings.Contains(err.Error(), "cound not retrieve db") {
+		t.Fatalf("expected the creation to be retried on a new connection, got: %v", err)
+	}
+	if calls != 1 {
+		t.Fatalf("expected the exited plugin to be called once, got %d calls", calls)
+	}
+
+	calls = 0
+	dbi, err = newDBPluginInstance(context.Background(), fakeShutdownDatabase(&calls), &DatabaseConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	testFakeConnection(t, b, storage, dbi, &roleEntry{})
+
+	err = b.revokeUser(context.Background(), storage, "fake", dbplugin.Statements{}, "user")
+	if err == nil || !strings.Contains(err.Error(), "cound not retrieve db") {
+		t.Fatalf("expected the revocation to be retried on a new connection, got: %v", err)
+	}
+	if calls != 1 {
+		t.Fatalf("expected the exited plugin to be called once, got %d calls", calls)
+	}
+
+	// Other errors aren't retried
+	dbi, err = newDBPluginInstance(context.Background(), fakeErrorDatabase(errors.New("permission denied")), &DatabaseConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	testFakeConnection(t, b, storage, dbi, &roleEntry{})
+
+	_, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "creds/readonly",
+		Storage:   storage,
+	})
+	if err == nil || err.Error() != "permission denied" {
+		t.Fatalf("expected the error to be returned as is, got: %v", err)
+	}
+}
+
+func TestBackend_connectionMetrics(t *testing.T) {
+	inm := metrics.NewInmemSink(time.Minute, time.Minute)
+	metricsConf := metrics.DefaultConfig("")
+	metricsConf.EnableHostname = false
+	metricsConf.EnableRuntimeMetrics = false
+	metrics.NewGlobal(metricsConf, inm)
+	defer metrics.NewGlobal(metricsConf, &metrics.BlackholeSink{})
+
+	b, storage := getBackend(t)
+
+	credsReq := &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "creds/readonly",
+		Storage:   storage,
+	}
+
+	dbi, err := newDBPluginInstance(context.Background(), &fakeDatabase{}, &DatabaseConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	testFakeConnection(t, b, storage, dbi, &roleEntry{})
+
+	for i := 0; i < 2; i++ {
+		resp, err := b.HandleRequest(context.Background(), credsReq)
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("err:%s resp:%#v\n", err, resp)
+		}
+	}
+
+	dbi, err = newDBPluginInstance(context.Background(), fakeShutdownDatabase(new(int)), &DatabaseConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	testFakeConnection(t, b, storage, dbi, &roleEntry{})
+
+	if _, err := b.HandleRequest(context.Background(), credsReq); err == nil {
+		t.Fatal("expected error from exited plugin")
+	}
+	if _, ok := b.connections["fake"]; ok {
+		t.Fatal("expected exited plugin to be removed")
+	}
+
+	counters := inm.Data()[0].Counters
+	for key, expected := range map[string]int{
+		"database.connection.reuse;name=fake":    3,
+		"database.connection.shutdown;name=fake": 1,
+	} {
+		if counter, ok := counters[key]; !ok || counter.Count != expected {
+			t.Fatalf("expected %s to be %d, got: %#v", key, expected, counters)
+		}
+	}
+}
+
+// fakeErrorDatabase returns a postgres fakeDatabase whose credential creation
+// fails with err.
+func fakeErrorDatabase(err error) *fakeDatabase {
+	return &fakeDatabase{
+		dbType: "postgres",
+		createUser: func(_ context.Context, _ dbplugin.Statements, _ dbplugin.UsernameConfig, _ time.Time) (string, string, error) {
+			return "", "", err
+		},
+	}
+}
+
+func TestBackend_recycleErrors(t *testing.T) {
+	b, storage := getBackend(t)
+
+	credsReq := &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "creds/readonly",
+		Storage:   storage,
+	}
+
+	cases := []struct {
+		name          string
+		err           error
+		recycleErrors []string
+		recycled      bool
+	}{
+		{"built-in", errors.New("pq: terminating connection due to administrator command"), nil, true},
+		{"other error", errors.New(`pq: relation "users" does not exist`), nil, false},
+		{"override", errors.New("pq: custom proxy restarting"), []string{"proxy restarting"}, true},
+		{"disabled", errors.New("pq: terminating connection due to administrator command"), []string{}, false},
+	}
+	for _, tc := range cases {
+		db := fakeErrorDatabase(tc.err)
+		dbi, err := newDBPluginInstance(context.Background(), db, &DatabaseConfig{RecycleErrors: tc.recycleErrors})
+		if err != nil {
+			t.Fatal(err)
+		}
+		testFakeConnection(t, b, storage, dbi, &roleEntry{})
+
+		if _, err := b.HandleRequest(context.Background(), credsReq); err == nil {
+			t.Fatalf("%s: expected error", tc.name)
+		}
+
+		_, ok := b.connections["fake"]
+		if ok == tc.recycled {
+			t.Fatalf("%s: expected recycled to be %t", tc.name, tc.recycled)
+		}
+		if db.isClosed() != tc.recycled {
+			t.Fatalf("%s: expected closed to be %t", tc.name, tc.recycled)
+		}
+	}
+}
+
+func TestBackend_verificationFreshness(t *testing.T) {
+	cluster, sys := getCluster(t)
+	defer cluster.Cleanup()
+
+	config := logical.TestBackendConfig()
+	config.StorageView = &logical.InmemStorage{}
+	config.System = sys
+	b, err := Factory(context.Background(), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Cleanup(context.Background())
+
+	// Nothing listens on the port, so only unverified connections succeed
+	req := &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "config/plugin-test",
+		Storage:   config.StorageView,
+		Data: map[string]interface{}{
+			"connection_url":         "postgresql://localhost:1/db?sslmode=disable",
+			"plugin_name":            "postgresql-database-plugin",
+			"verify_connection":      false,
+			"verification_freshness": "1h",
+		},
+	}
+	resp, err := b.HandleRequest(context.Background(), req)
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%s resp:%#v\n", err, resp)
+	}
+
+	db := b.(*databaseBackend)
+	db.Lock()
+	defer db.Unlock()
+
+	// The connection has never been verified
+	db.clearConnection("plugin-test")
+	if _, err := db.createDBObj(context.Background(), config.StorageView, "plugin-test"); err == nil {
+		t.Fatal("expected verification to fail")
+	}
+
+	// Within the window verification is skipped
+	db.lastVerified["plugin-test"] = time.Now()
+	if _, err := db.createDBObj(context.Background(), config.StorageView, "plugin-test"); err != nil {
+		t.Fatalf("expected verification to be skipped, got: %s", err)
+	}
+
+	// Once the window has passed the connection is verified again
+	db.clearConnection("plugin-test")
+	db.lastVerified["plugin-test"] = time.Now().Add(-2 * time.Hour)
+	if _, err := db.createDBObj(context.Background(), config.StorageView, "plugin-test"); err == nil {
+		t.Fatal("expected verification to fail")
+	}
+}
+
+func TestBackend_uncachedPluginsMount(t *testing.T) {
+	cluster, mounted := testMountCluster(t)
+	defer cluster.Cleanup()
+
+	if err := testMountOptions(t, cluster, "bad", map[string]string{"uncached_plugins": "mysql-database-plugin,,"}); err == nil {
+		t.Fatal("expected error for invalid uncached_plugins")
+	}
+
+	if err := testMountOptions(t, cluster, "db", map[string]string{"uncached_plugins": "postgresql-database-plugin"}); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := cluster.Cores[0].Client.Logical().Write("db/config/plugin-test", map[string]interface{}{
+		"connection_url":    "postgresql://localhost:1/db?sslmode=disable",
+		"plugin_name":       "postgresql-database-plugin",
+		"verify_connection": false,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, _ := mounted()
+	b.RLock()
+	_, ok := b.connections["plugin-test"]
+	b.RUnlock()
+	if ok {
+		t.Fatal("expected the connection not to be cached")
+	}
+}
+
+func TestBackend_uncachedPlugins(t *testing.T) {
+	cluster, sys := getCluster(t)
+	defer cluster.Cleanup()
+
+	config := logical.TestBackendConfig()
+	config.StorageView = &logical.InmemStorage{}
+	config.System = sys
+
+	config.Config = map[string]string{"uncached_plugins": "mysql-database-plugin,,"}
+	if _, err := Factory(context.Background(), config); err == nil {
+		t.Fatal("expected error for invalid uncached_plugins")
+	}
+
+	config.Config = map[string]string{"uncached_plugins": "mysql-database-plugin, postgresql-database-plugin"}
+	b, err := Factory(context.Background(), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Cleanup(context.Background())
+
+	req := &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "config/plugin-test",
+		Storage:   config.StorageView,
+		Data: map[string]interface{}{
+			"connection_url":         "postgresql://localhost:1/db?sslmode=disable",
+			"plugin_name":            "postgresql-database-plugin",
+			"verify_connection":      false,
+			"verification_freshness": "1h",
+		},
+	}
+	resp, err := b.HandleRequest(context.Background(), req)
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%s resp:%#v\n", err, resp)
+	}
+
+	db := b.(*databaseBackend)
+	if _, ok := db.connections["plugin-test"]; ok {
+		t.Fatal("expected the connection not to be cached")
+	}
+
+	// Nothing listens on the port, so skip verifying the connection
+	db.lastVerified["plugin-test"] = time.Now()
+
+	dbi, err := db.pluginInstance(context.Background(), config.StorageView, "plugin-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := db.connections["plugin-test"]; ok {
+		t.Fatal("expected the connection not to be cached")
+	}
+	if _, err := dbi.Type(); err != nil {
+		t.Fatalf("expected the plugin to be running, got: %s", err)
+	}
+
+	// It's closed once it's no longer used
+	dbi.release()
+	if _, err := dbi.Type(); err == nil {
+		t.Fatal("expected the plugin to be closed")
+	}
+}
+
+func TestBackend_uncachedPluginsUnlocked(t *testing.T) {
+	config := testFakePluginBackendConfig()
+	config.Config = map[string]string{"uncached_plugins": "fake"}
+
+	// Plugins are started without holding the backend's lock, which the
+	// factory checks by taking it
+	var b *databaseBackend
+	var spawned []*fakeDatabase
+	config.System = fakePluginSystemView{
+		StaticSystemView: logical.TestSystemView(),
+		factory: func() (interface{}, error) {
+			locked := make(chan struct{})
+			go func() {
+				b.Lock()
+				b.Unlock()
+				close(locked)
+			}()
+			select {
+			case <-locked:
+			case <-time.After(5 * time.Second):
+				return nil, errors.New("plugin started under the backend's lock")
+			}
+
+			db := &fakeDatabase{}
+			spawned = append(spawned, db)
+			return db, nil
+		},
+	}
+	f, err := Factory(context.Background(), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b = f.(*databaseBackend)
+	defer b.Cleanup(context.Background())
+
+	entry, err := logical.StorageEntryJSON("config/fake", &DatabaseConfig{
+		PluginName:   "fake",
+		AllowedRoles: []string{"*"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := config.StorageView.Put(context.Background(), entry); err != nil {
+		t.Fatal(err)
+	}
+
+	dbi, err := b.pluginInstance(context.Background(), config.StorageView, "fake")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := b.connections["fake"]; ok {
+		t.Fatal("expected the connection not to be cached")
+	}
+	dbi.release()
+	if len(spawned) != 1 || !spawned[0].isClosed() {
+		t.Fatal("expected the plugin to be closed once released")
+	}
+
+	// The function returned by lockedDBObj closes the plugin rather than
+	// releasing a lock
+	dbi, unlockFunc, err := b.lockedDBObj(context.Background(), config.StorageView, "fake")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(spawned) != 2 || spawned[1].isClosed() {
+		t.Fatal("expected a new plugin to be started")
+	}
+	b.Lock()
+	b.Unlock()
+	unlockFunc()
+	if !spawned[1].isClosed() {
+		t.Fatal("expected the plugin to be closed once done with")
+	}
+}
+
+func TestBackend_connectionQuarantine(t *testing.T) {
+	b, storage := getBackend(t)
+
+	// The plugin doesn't exist, so every attempt to start it fails
+	entry, err := logical.StorageEntryJSON("config/fake", &DatabaseConfig{
+		PluginName:          "missing-database-plugin",
+		AllowedRoles:        []string{"*"},
+		QuarantineThreshold: 2,
+		QuarantineCooldown:  60,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := storage.Put(context.Background(), entry); err != nil {
+		t.Fatal(err)
+	}
+
+	createDBObj := func() error {
+		b.Lock()
+		defer b.Unlock()
+		_, err := b.createDBObj(context.Background(), storage, "fake")
+		return err
+	}
+
+	for i := 0; i < 2; i++ {
+		err := createDBObj()
+		if err == nil || strings.Contains(err.Error(), "quarantined") {
+			t.Fatalf("expected plugin error, got: %v", err)
+		}
+	}
+
+	err = createDBObj()
+	if err == nil || !strings.Contains(err.Error(), "connection quarantined until") {
+		t.Fatalf("expected quarantine error, got: %v", err)
+	}
+
+	// Resetting the connection lifts the quarantine
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "reset/fake",
+		Storage:   storage,
+	})
+	if err == nil || strings.Contains(err.Error(), "quarantined") {
+		t.Fatalf("expected plugin error, got: %v %#v", err, resp)
+	}
+	if b.failures["fake"].count != 1 {
+		t.Fatalf("expected failure count to restart, got: %d", b.failures["fake"].count)
+	}
+
+	// So does the cooldown expiring
+	b.failures["fake"].count = 2
+	b.failures["fake"].quarantinedUntil = time.Now().Add(-time.Second)
+	err = createDBObj()
+	if err == nil || strings.Contains(err.Error(), "quarantined") {
+		t.Fatalf("expected plugin error, got: %v", err)
+	}
+	if b.failures["fake"].count != 1 {
+		t.Fatalf("expected failure count to restart, got: %d", b.failures["fake"].count)
+	}
+}
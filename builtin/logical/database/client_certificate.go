@@ -0,0 +1,117 @@
+package database
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/vault/helper/certutil"
+)
+
+// Values of a role's credential_type.
+const (
+	credentialTypePassword          = "password"
+	credentialTypeClientCertificate = "client_certificate"
+)
+
+// clientCertificateKeyBits is the size of the RSA keys generated for client
+// certificates, which every supported database accepts.
+const clientCertificateKeyBits = 2048
+
+// parseClientCertificateCA parses a connection's client_certificate_ca, a PEM
+// bundle holding the CA certificate that signs client certificates and its
+// private key.
+func parseClientCertificateCA(pemBundle string) (*certutil.ParsedCertBundle, error) {
+	bundle, err := certutil.ParsePEMBundle(pemBundle)
+	if err != nil {
+		return nil, err
+	}
+	if bundle.Certificate == nil || bundle.PrivateKey == nil {
+		return nil, errors.New("client_certificate_ca must contain a certificate and its private key")
+	}
+	if !bundle.Certificate.BasicConstraintsValid || !bundle.Certificate.IsCA {
+		return nil, errors.New("client_certificate_ca must be a CA certificate")
+	}
+
+	return bundle, nil
+}
+
+// clientCertificateCAPEM returns only the certificate of a client_certificate_ca
+// bundle so that it can be returned without the private key.
+func clientCertificateCAPEM(pemBundle string) string {
+	if pemBundle == "" {
+		return ""
+	}
+
+	bundle, err := parseClientCertificateCA(pemBundle)
+	if err != nil {
+		return ""
+	}
+
+	return string(pem.EncodeToMemory(&pem.Block{
+		Type:  "CERTIFICATE",
+		Bytes: bundle.CertificateBytes,
+	}))
+}
+
+// issueClientCertificate signs a certificate for commonName, valid for ttl or
+// until the CA expires, with the connection's client_certificate_ca. It
+// returns the PEM encoded certificate, private key and CA certificate.
+func issueClientCertificate(caBundle, commonName string, ttl time.Duration) (map[string]interface{}, error) {
+	ca, err := parseClientCertificateCA(caBundle)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := rsa.GenerateKey(rand.Reader, clientCertificateKeyBits)
+	if err != nil {
+		return nil, fmt.Errorf("error generating private key: %s", err)
+	}
+
+	serial, err := certutil.GenerateSerialNumber()
+	if err != nil {
+		return nil, err
+	}
+
+	notAfter := time.Now().Add(ttl)
+	if notAfter.After(ca.Certificate.NotAfter) {
+		notAfter = ca.Certificate.NotAfter
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject: pkix.Name{
+			CommonName: commonName,
+		},
+		// Allow for clock skew between Vault and the database
+		NotBefore:   time.Now().Add(-30 * time.Second),
+		NotAfter:    notAfter,
+		KeyUsage:    x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+
+	certBytes, err := x509.CreateCertificate(rand.Reader, template, ca.Certificate, key.Public(), ca.PrivateKey)
+	if err != nil {
+		return nil, fmt.Errorf("error signing client certificate: %s", err)
+	}
+
+	return map[string]interface{}{
+		"certificate": string(pem.EncodeToMemory(&pem.Block{
+			Type:  "CERTIFICATE",
+			Bytes: certBytes,
+		})),
+		"private_key": string(pem.EncodeToMemory(&pem.Block{
+			Type:  "RSA PRIVATE KEY",
+			Bytes: x509.MarshalPKCS1PrivateKey(key),
+		})),
+		"ca_certificate": string(pem.EncodeToMemory(&pem.Block{
+			Type:  "CERTIFICATE",
+			Bytes: ca.CertificateBytes,
+		})),
+	}, nil
+}
@@ -0,0 +1,155 @@
+package database
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/logical"
+)
+
+// testClientCertificateCA returns a PEM bundle with a self-signed CA
+// certificate and its key.
+func testClientCertificateCA(t *testing.T) string {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "database-ca"},
+		NotBefore:             time.Now().Add(-time.Minute),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	certBytes, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certBytes})) +
+		string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}))
+}
+
+func TestBackend_clientCertificate(t *testing.T) {
+	b, storage := getBackend(t)
+
+	dbi, err := newDBPluginInstance(context.Background(), &fakeDatabase{}, &DatabaseConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	testFakeConnection(t, b, storage, dbi, &roleEntry{})
+
+	// Connections without a CA don't support client certificates
+	roleReq := &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "roles/certs",
+		Storage:   storage,
+		Data: map[string]interface{}{
+			"db_name":             "fake",
+			"creation_statements": "CREATE USER",
+			"credential_type":     "client_certificate",
+		},
+	}
+	resp, err := b.HandleRequest(context.Background(), roleReq)
+	if err != nil || resp == nil || !resp.IsError() {
+		t.Fatalf("expected error, got err:%s resp:%#v", err, resp)
+	}
+
+	caBundle := testClientCertificateCA(t)
+	entry, err := logical.StorageEntryJSON("config/fake", &DatabaseConfig{
+		PluginName:          "fake",
+		AllowedRoles:        []string{"*"},
+		ClientCertificateCA: caBundle,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := storage.Put(context.Background(), entry); err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err = b.HandleRequest(context.Background(), roleReq)
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%s resp:%#v\n", err, resp)
+	}
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "creds/certs",
+		Storage:   storage,
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%s resp:%#v\n", err, resp)
+	}
+	if resp.Data["username"] != "user" || resp.Data["password"] != "password" {
+		t.Fatalf("bad credential: %#v", resp.Data)
+	}
+
+	block, _ := pem.Decode([]byte(resp.Data["certificate"].(string)))
+	if block == nil {
+		t.Fatalf("bad certificate: %q", resp.Data["certificate"])
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cert.Subject.CommonName != "user" {
+		t.Fatalf("bad common name: %q", cert.Subject.CommonName)
+	}
+	if len(cert.ExtKeyUsage) != 1 || cert.ExtKeyUsage[0] != x509.ExtKeyUsageClientAuth {
+		t.Fatalf("bad extended key usage: %v", cert.ExtKeyUsage)
+	}
+
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM([]byte(resp.Data["ca_certificate"].(string))) {
+		t.Fatalf("bad CA certificate: %q", resp.Data["ca_certificate"])
+	}
+	if _, err := cert.Verify(x509.VerifyOptions{
+		Roots:     roots,
+		KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(resp.Data["private_key"].(string), "RSA PRIVATE KEY") {
+		t.Fatalf("bad private key: %q", resp.Data["private_key"])
+	}
+
+	// Reading the connection never returns the CA's key
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "config/fake",
+		Storage:   storage,
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%s resp:%#v\n", err, resp)
+	}
+	caPEM := resp.Data["client_certificate_ca"].(string)
+	if !strings.Contains(caPEM, "CERTIFICATE") || strings.Contains(caPEM, "PRIVATE KEY") {
+		t.Fatalf("bad client_certificate_ca: %q", caPEM)
+	}
+
+	// A CA without its key is rejected
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "config/other",
+		Storage:   storage,
+		Data: map[string]interface{}{
+			"plugin_name":           "fake",
+			"verify_connection":     false,
+			"client_certificate_ca": caPEM,
+		},
+	})
+	if err != nil || resp == nil || !resp.IsError() || !strings.Contains(resp.Data["error"].(string), "client_certificate_ca") {
+		t.Fatalf("expected error, got err:%s resp:%#v", err, resp)
+	}
+}
@@ -0,0 +1,206 @@
+package database
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/vault/plugins/helper/database/connutil"
+)
+
+// credentialFormatter builds the response data returned for a newly issued
+// credential for a connection to a dbType database.
+type credentialFormatter func(username, password, dbType string, config *DatabaseConfig) (map[string]interface{}, error)
+
+// credentialFormatters is the registry of the formats a role may select with
+// "credential_format". The empty format is the default and returns the plain
+// username and password.
+var credentialFormatters = map[string]credentialFormatter{
+	"":        formatCredentialDefault,
+	"default": formatCredentialDefault,
+	"jdbc":    formatCredentialJDBC,
+	"env":     formatCredentialEnv,
+	"all":     formatCredentialAll,
+}
+
+// credentialFormatNames returns the sorted names of the registered, non-empty
+// credential formats.
+func credentialFormatNames() []string {
+	var names []string
+	for name := range credentialFormatters {
+		if name == "" {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+func formatCredentialDefault(username, password, _ string, _ *DatabaseConfig) (map[string]interface{}, error) {
+	return map[string]interface{}{
+		"username": username,
+		"password": password,
+	}, nil
+}
+
+// formatCredentialEnv returns the credential as a block of KEY=value lines
+// suitable for an environment file. The values are single quoted so they're
+// read back verbatim by a shell.
+func formatCredentialEnv(username, password, _ string, _ *DatabaseConfig) (map[string]interface{}, error) {
+	return map[string]interface{}{
+		"username": username,
+		"env":      fmt.Sprintf("DATABASE_USERNAME=%s\nDATABASE_PASSWORD=%s\n", envQuote(username), envQuote(password)),
+	}, nil
+}
+
+// envQuote single quotes v for a shell, closing the quotes around any single
+// quote it contains.
+func envQuote(v string) string {
+	return "'" + strings.Replace(v, "'", `'\''`, -1) + "'"
+}
+
+// formatCredentialJDBC returns a JDBC URL derived from the connection's URL
+// with the credential embedded.
+func formatCredentialJDBC(username, password, dbType string, config *DatabaseConfig) (map[string]interface{}, error) {
+	connURL, err := connectionURL(dbType, config, username, password)
+	if err != nil {
+		return nil, err
+	}
+	jdbcURL, err := buildJDBCURL(connURL, username, password)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"username": username,
+		"jdbc_url": jdbcURL,
+	}, nil
+}
+
+// formatCredentialAll returns the plain username and password along with a
+// "dsn" and a "jdbc_url" for the credential, each only where it can be derived
+// from the connection's URL.
+func formatCredentialAll(username, password, dbType string, config *DatabaseConfig) (map[string]interface{}, error) {
+	data := map[string]interface{}{
+		"username": username,
+		"password": password,
+	}
+
+	connURL, err := connectionURL(dbType, config, username, password)
+	if err != nil {
+		return data, nil
+	}
+	if dsn, err := buildDSN(connURL, username, password); err == nil {
+		data["dsn"] = dsn
+	}
+	if jdbcURL, err := buildJDBCURL(connURL, username, password); err == nil {
+		data["jdbc_url"] = jdbcURL
+	}
+
+	return data, nil
+}
+
+// connectionURL returns the URL of the connection, either its connection_url
+// or the URL built from its host, port and database for a dbType database
+// with the given credential. It's empty if the connection has neither.
+func connectionURL(dbType string, config *DatabaseConfig, username, password string) (string, error) {
+	if config == nil {
+		return "", nil
+	}
+
+	if connURL, _ := config.ConnectionDetails["connection_url"].(string); connURL != "" {
+		return connURL, nil
+	}
+	return connutil.StructuredConnectionURL(dbType, config.ConnectionDetails, username, password)
+}
+
+// mysqlDSNRe matches MySQL style DSNs, e.g. "user:pass@tcp(host:3306)/db".
+var mysqlDSNRe = regexp.MustCompile(`^(?:[^@]*@)?tcp\(([^)]+)\)/([^?]*)`)
+
+// dsnQueryParams are the query parameters kept in the DSNs derived from
+// connection URLs of each scheme, since they locate the database. The others
+// are settings of the connection's own user, and are dropped.
+var dsnQueryParams = map[string]string{
+	"sqlserver": "database",
+	"hdb":       "databaseName",
+}
+
+// buildDSN returns the connection URL of a driver with the given credential in
+// place of the connection's own.
+func buildDSN(connURL, username, password string) (string, error) {
+	if connURL == "" {
+		return "", fmt.Errorf("connection has no connection URL to derive a DSN from")
+	}
+
+	if m := mysqlDSNRe.FindStringSubmatch(connURL); m != nil {
+		return fmt.Sprintf("%s:%s@tcp(%s)/%s", username, password, m[1], m[2]), nil
+	}
+
+	u, err := url.Parse(connURL)
+	if err != nil {
+		return "", fmt.Errorf("unable to parse connection URL to derive a DSN")
+	}
+
+	switch u.Scheme {
+	case "postgres", "postgresql", "sqlserver", "hdb":
+		query := url.Values{}
+		if param := dsnQueryParams[u.Scheme]; param != "" && u.Query().Get(param) != "" {
+			query.Set(param, u.Query().Get(param))
+		}
+		u.User = url.UserPassword(username, password)
+		u.RawQuery = query.Encode()
+		return u.String(), nil
+	}
+
+	return "", fmt.Errorf("unable to derive a DSN from a %q connection URL", u.Scheme)
+}
+
+// buildJDBCURL translates a driver connection URL into a JDBC URL for the same
+// database using the given credential.
+func buildJDBCURL(connURL, username, password string) (string, error) {
+	if connURL == "" {
+		return "", fmt.Errorf("connection has no connection URL to derive a JDBC URL from")
+	}
+
+	if m := mysqlDSNRe.FindStringSubmatch(connURL); m != nil {
+		return fmt.Sprintf("jdbc:mysql://%s/%s?user=%s&password=%s", m[1], m[2], url.QueryEscape(username), url.QueryEscape(password)), nil
+	}
+
+	u, err := url.Parse(connURL)
+	if err != nil {
+		return "", fmt.Errorf("unable to parse connection URL to derive a JDBC URL")
+	}
+
+	switch u.Scheme {
+	case "postgres", "postgresql":
+		return fmt.Sprintf("jdbc:postgresql://%s%s?user=%s&password=%s", u.Host, u.Path, url.QueryEscape(username), url.QueryEscape(password)), nil
+	case "sqlserver":
+		jdbcURL := fmt.Sprintf("jdbc:sqlserver://%s;user=%s;password=%s", u.Host, jdbcSQLServerValue(username), jdbcSQLServerValue(password))
+		if db := u.Query().Get("database"); db != "" {
+			jdbcURL = fmt.Sprintf("%s;databaseName=%s", jdbcURL, jdbcSQLServerValue(db))
+		}
+		return jdbcURL, nil
+	}
+
+	return "", fmt.Errorf("unable to derive a JDBC URL from a %q connection URL", u.Scheme)
+}
+
+// jdbcSQLServerPlainRe matches the SQL Server JDBC property values that can be
+// used as they are.
+var jdbcSQLServerPlainRe = regexp.MustCompile(`^[A-Za-z0-9_.@$-]*$`)
+
+// jdbcSQLServerValue braces v for use as a property value in a SQL Server
+// JDBC URL unless it's made only of characters the driver reads verbatim.
+// Braces keep separators and surrounding spaces in the value, and a closing
+// brace within it is doubled.
+func jdbcSQLServerValue(v string) string {
+	if jdbcSQLServerPlainRe.MatchString(v) {
+		return v
+	}
+
+	return "{" + strings.Replace(v, "}", "}}", -1) + "}"
+}
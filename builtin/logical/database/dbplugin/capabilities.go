@@ -0,0 +1,215 @@
+package dbplugin
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+// Capabilities describe the operations a database plugin supports.
+const (
+	CapabilityCreateUser = "create_user"
+	CapabilityRenewUser  = "renew_user"
+	CapabilityRevokeUser = "revoke_user"
+	CapabilityUserGrants = "user_grants"
+
+	CapabilityUpdatePoolSettings = "update_pool_settings"
+	CapabilityServerVersion      = "server_version"
+	CapabilityPing               = "ping"
+
+	CapabilityGenerateCredentials = "generate_credentials"
+	CapabilityPluginInfo          = "plugin_info"
+
+	// CapabilityStatementLists is reported by plugins that run the
+	// create_statements, grant_statements and revoke_statements of roles.
+	CapabilityStatementLists = "statement_lists"
+
+	// CapabilityRevocationSteps is reported by plugins that run the
+	// revocation_steps of roles.
+	CapabilityRevocationSteps = "revocation_steps"
+)
+
+// DefaultCapabilities are assumed for plugins that don't report their
+// capabilities, which is true of every plugin built before capability
+// discovery was added.
+var DefaultCapabilities = []string{
+	CapabilityCreateUser,
+	CapabilityRenewUser,
+	CapabilityRevokeUser,
+}
+
+var (
+	// ErrUnsupportedOperation is returned when an operation is requested that
+	// the plugin does not report as supported.
+	ErrUnsupportedOperation = errors.New("operation unsupported by this plugin")
+
+	// ErrConnectionIdentityChanged is returned by UpdatePoolSettings when
+	// settings other than the pool settings differ, meaning a new connection
+	// must be established instead.
+	ErrConnectionIdentityChanged = errors.New("connection settings other than the pool settings changed")
+)
+
+// CapabilityReporter is an optional interface a Database may implement to
+// report which operations it supports. Databases that don't implement it are
+// assumed to support DefaultCapabilities, along with the capabilities of the
+// optional interfaces they implement.
+type CapabilityReporter interface {
+	Capabilities(ctx context.Context) ([]string, error)
+}
+
+// GrantIntrospector is an optional interface a Database may implement to
+// summarize the privileges held by a user.
+type GrantIntrospector interface {
+	UserGrants(ctx context.Context, username string) ([]string, error)
+}
+
+// PoolSettingsUpdater is an optional interface a Database may implement to
+// apply changed connection pool settings without closing its connection.
+type PoolSettingsUpdater interface {
+	UpdatePoolSettings(ctx context.Context, config map[string]interface{}) error
+}
+
+// VersionReporter is an optional interface a Database may implement to report
+// the version of the database server it is connected to.
+type VersionReporter interface {
+	ServerVersion(ctx context.Context) (string, error)
+}
+
+// Pinger is an optional interface a Database may implement to check that the
+// database server is reachable.
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// CredentialsGenerator is an optional interface a Database may implement to
+// generate a username and password the way CreateUser would, without creating
+// anything in the database.
+type CredentialsGenerator interface {
+	GenerateCredentials(ctx context.Context, usernameConfig UsernameConfig) (username string, password string, err error)
+}
+
+// PluginInfoReporter is an optional interface a Database may implement to
+// report the version and build of the plugin itself.
+type PluginInfoReporter interface {
+	PluginInfo(ctx context.Context) (*BuildInfo, error)
+}
+
+// ImplementedCapabilities returns DefaultCapabilities along with the
+// capabilities of the optional interfaces db implements. Databases
+// implementing CapabilityReporter can use it to report those they support
+// beyond their interfaces.
+func ImplementedCapabilities(db Database) []string {
+	caps := append([]string(nil), DefaultCapabilities...)
+	if _, ok := db.(GrantIntrospector); ok {
+		caps = append(caps, CapabilityUserGrants)
+	}
+	if _, ok := db.(PoolSettingsUpdater); ok {
+		caps = append(caps, CapabilityUpdatePoolSettings)
+	}
+	if _, ok := db.(VersionReporter); ok {
+		caps = append(caps, CapabilityServerVersion)
+	}
+	if _, ok := db.(Pinger); ok {
+		caps = append(caps, CapabilityPing)
+	}
+	if _, ok := db.(CredentialsGenerator); ok {
+		caps = append(caps, CapabilityGenerateCredentials)
+	}
+	if _, ok := db.(PluginInfoReporter); ok {
+		caps = append(caps, CapabilityPluginInfo)
+	}
+	return caps
+}
+
+// Capabilities returns the operations supported by db. Plugins that predate
+// capability discovery don't implement the RPC, in which case
+// DefaultCapabilities is returned.
+func Capabilities(ctx context.Context, db Database) ([]string, error) {
+	reporter, ok := db.(CapabilityReporter)
+	if !ok {
+		return ImplementedCapabilities(db), nil
+	}
+
+	caps, err := reporter.Capabilities(ctx)
+	switch {
+	case err == nil:
+		return caps, nil
+	case grpc.Code(err) == codes.Unimplemented:
+		return DefaultCapabilities, nil
+	case strings.Contains(err.Error(), "can't find method"):
+		// net/rpc servers return this for unknown methods
+		return DefaultCapabilities, nil
+	}
+
+	return nil, err
+}
+
+// UserGrants returns a summary of the privileges held by username, or
+// ErrUnsupportedOperation if db can't introspect grants.
+func UserGrants(ctx context.Context, db Database, username string) ([]string, error) {
+	introspector, ok := db.(GrantIntrospector)
+	if !ok {
+		return nil, ErrUnsupportedOperation
+	}
+
+	return introspector.UserGrants(ctx, username)
+}
+
+// UpdatePoolSettings applies the pool settings in config to db's live
+// connection, or returns ErrUnsupportedOperation if db can't.
+func UpdatePoolSettings(ctx context.Context, db Database, config map[string]interface{}) error {
+	updater, ok := db.(PoolSettingsUpdater)
+	if !ok {
+		return ErrUnsupportedOperation
+	}
+
+	return updater.UpdatePoolSettings(ctx, config)
+}
+
+// ServerVersion returns the version of the database server db is connected to,
+// or ErrUnsupportedOperation if db can't report it.
+func ServerVersion(ctx context.Context, db Database) (string, error) {
+	reporter, ok := db.(VersionReporter)
+	if !ok {
+		return "", ErrUnsupportedOperation
+	}
+
+	return reporter.ServerVersion(ctx)
+}
+
+// Ping checks that the database server db is connected to is reachable, or
+// returns ErrUnsupportedOperation if db can't check.
+func Ping(ctx context.Context, db Database) error {
+	pinger, ok := db.(Pinger)
+	if !ok {
+		return ErrUnsupportedOperation
+	}
+
+	return pinger.Ping(ctx)
+}
+
+// GenerateCredentials returns a username and password generated by db as
+// CreateUser would generate them, or ErrUnsupportedOperation if db can't
+// generate them on their own.
+func GenerateCredentials(ctx context.Context, db Database, usernameConfig UsernameConfig) (string, string, error) {
+	generator, ok := db.(CredentialsGenerator)
+	if !ok {
+		return "", "", ErrUnsupportedOperation
+	}
+
+	return generator.GenerateCredentials(ctx, usernameConfig)
+}
+
+// PluginInfo returns the version and build of the plugin serving db, or
+// ErrUnsupportedOperation if db can't report them.
+func PluginInfo(ctx context.Context, db Database) (*BuildInfo, error) {
+	reporter, ok := db.(PluginInfoReporter)
+	if !ok {
+		return nil, ErrUnsupportedOperation
+	}
+
+	return reporter.PluginInfo(ctx)
+}
@@ -28,6 +28,37 @@ func (dc *DatabasePluginClient) Close() error {
 	return err
 }
 
+// Capabilities, UserGrants, UpdatePoolSettings, ServerVersion, Ping,
+// GenerateCredentials and PluginInfo forward to the embedded Database, which
+// would otherwise be hidden by the embedding.
+func (dc *DatabasePluginClient) Capabilities(ctx context.Context) ([]string, error) {
+	return Capabilities(ctx, dc.Database)
+}
+
+func (dc *DatabasePluginClient) UserGrants(ctx context.Context, username string) ([]string, error) {
+	return UserGrants(ctx, dc.Database, username)
+}
+
+func (dc *DatabasePluginClient) UpdatePoolSettings(ctx context.Context, config map[string]interface{}) error {
+	return UpdatePoolSettings(ctx, dc.Database, config)
+}
+
+func (dc *DatabasePluginClient) ServerVersion(ctx context.Context) (string, error) {
+	return ServerVersion(ctx, dc.Database)
+}
+
+func (dc *DatabasePluginClient) Ping(ctx context.Context) error {
+	return Ping(ctx, dc.Database)
+}
+
+func (dc *DatabasePluginClient) GenerateCredentials(ctx context.Context, usernameConfig UsernameConfig) (string, string, error) {
+	return GenerateCredentials(ctx, dc.Database, usernameConfig)
+}
+
+func (dc *DatabasePluginClient) PluginInfo(ctx context.Context) (*BuildInfo, error) {
+	return PluginInfo(ctx, dc.Database)
+}
+
 // newPluginClient returns a databaseRPCClient with a connection to a running
 // plugin. The client is wrapped in a DatabasePluginClient object to ensure the
 // plugin is killed on call of Close().
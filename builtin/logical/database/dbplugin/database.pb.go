@@ -5,9 +5,11 @@
 Package dbplugin is a generated protocol buffer package.
 
 It is generated from these files:
+
 	builtin/logical/database/dbplugin/database.proto
 
 It has these top-level messages:
+
 	InitializeRequest
 	CreateUserRequest
 	RenewUserRequest
@@ -17,6 +19,14 @@ It has these top-level messages:
 	CreateUserResponse
 	TypeResponse
 	Empty
+	CapabilitiesResponse
+	UserGrantsRequest
+	UserGrantsResponse
+	UpdatePoolSettingsRequest
+	ServerVersionResponse
+	GenerateCredentialsRequest
+	RevocationStep
+	BuildInfo
 */
 package dbplugin
 
@@ -154,10 +164,14 @@ func (m *RevokeUserRequest) GetUsername() string {
 }
 
 type Statements struct {
-	CreationStatements   string `protobuf:"bytes,1,opt,name=creation_statements,json=creationStatements" json:"creation_statements,omitempty"`
-	RevocationStatements string `protobuf:"bytes,2,opt,name=revocation_statements,json=revocationStatements" json:"revocation_statements,omitempty"`
-	RollbackStatements   string `protobuf:"bytes,3,opt,name=rollback_statements,json=rollbackStatements" json:"rollback_statements,omitempty"`
-	RenewStatements      string `protobuf:"bytes,4,opt,name=renew_statements,json=renewStatements" json:"renew_statements,omitempty"`
+	CreationStatements   string            `protobuf:"bytes,1,opt,name=creation_statements,json=creationStatements" json:"creation_statements,omitempty"`
+	RevocationStatements string            `protobuf:"bytes,2,opt,name=revocation_statements,json=revocationStatements" json:"revocation_statements,omitempty"`
+	RollbackStatements   string            `protobuf:"bytes,3,opt,name=rollback_statements,json=rollbackStatements" json:"rollback_statements,omitempty"`
+	RenewStatements      string            `protobuf:"bytes,4,opt,name=renew_statements,json=renewStatements" json:"renew_statements,omitempty"`
+	CreateStatements     []string          `protobuf:"bytes,5,rep,name=create_statements,json=createStatements" json:"create_statements,omitempty"`
+	GrantStatements      []string          `protobuf:"bytes,6,rep,name=grant_statements,json=grantStatements" json:"grant_statements,omitempty"`
+	RevokeStatements     []string          `protobuf:"bytes,7,rep,name=revoke_statements,json=revokeStatements" json:"revoke_statements,omitempty"`
+	RevocationSteps      []*RevocationStep `protobuf:"bytes,8,rep,name=revocation_steps,json=revocationSteps" json:"revocation_steps,omitempty"`
 }
 
 func (m *Statements) Reset()                    { *m = Statements{} }
@@ -193,9 +207,43 @@ func (m *Statements) GetRenewStatements() string {
 	return ""
 }
 
+func (m *Statements) GetCreateStatements() []string {
+	if m != nil {
+		return m.CreateStatements
+	}
+	return nil
+}
+
+func (m *Statements) GetGrantStatements() []string {
+	if m != nil {
+		return m.GrantStatements
+	}
+	return nil
+}
+
+func (m *Statements) GetRevokeStatements() []string {
+	if m != nil {
+		return m.RevokeStatements
+	}
+	return nil
+}
+
+func (m *Statements) GetRevocationSteps() []*RevocationStep {
+	if m != nil {
+		return m.RevocationSteps
+	}
+	return nil
+}
+
 type UsernameConfig struct {
-	DisplayName string `protobuf:"bytes,1,opt,name=DisplayName" json:"DisplayName,omitempty"`
-	RoleName    string `protobuf:"bytes,2,opt,name=RoleName" json:"RoleName,omitempty"`
+	DisplayName          string `protobuf:"bytes,1,opt,name=DisplayName" json:"DisplayName,omitempty"`
+	RoleName             string `protobuf:"bytes,2,opt,name=RoleName" json:"RoleName,omitempty"`
+	UsernamePrefix       string `protobuf:"bytes,3,opt,name=UsernamePrefix" json:"UsernamePrefix,omitempty"`
+	PoolClass            string `protobuf:"bytes,4,opt,name=PoolClass" json:"PoolClass,omitempty"`
+	PasswordMinUppercase int32  `protobuf:"varint,5,opt,name=PasswordMinUppercase" json:"PasswordMinUppercase,omitempty"`
+	PasswordMinLowercase int32  `protobuf:"varint,6,opt,name=PasswordMinLowercase" json:"PasswordMinLowercase,omitempty"`
+	PasswordMinDigits    int32  `protobuf:"varint,7,opt,name=PasswordMinDigits" json:"PasswordMinDigits,omitempty"`
+	PasswordMinSpecial   int32  `protobuf:"varint,8,opt,name=PasswordMinSpecial" json:"PasswordMinSpecial,omitempty"`
 }
 
 func (m *UsernameConfig) Reset()                    { *m = UsernameConfig{} }
@@ -217,6 +265,48 @@ func (m *UsernameConfig) GetRoleName() string {
 	return ""
 }
 
+func (m *UsernameConfig) GetUsernamePrefix() string {
+	if m != nil {
+		return m.UsernamePrefix
+	}
+	return ""
+}
+
+func (m *UsernameConfig) GetPoolClass() string {
+	if m != nil {
+		return m.PoolClass
+	}
+	return ""
+}
+
+func (m *UsernameConfig) GetPasswordMinUppercase() int32 {
+	if m != nil {
+		return m.PasswordMinUppercase
+	}
+	return 0
+}
+
+func (m *UsernameConfig) GetPasswordMinLowercase() int32 {
+	if m != nil {
+		return m.PasswordMinLowercase
+	}
+	return 0
+}
+
+func (m *UsernameConfig) GetPasswordMinDigits() int32 {
+	if m != nil {
+		return m.PasswordMinDigits
+	}
+	return 0
+}
+
+func (m *UsernameConfig) GetPasswordMinSpecial() int32 {
+	if m != nil {
+		return m.PasswordMinSpecial
+	}
+	return 0
+}
+
 type CreateUserResponse struct {
 	Username string `protobuf:"bytes,1,opt,name=username" json:"username,omitempty"`
 	Password string `protobuf:"bytes,2,opt,name=password" json:"password,omitempty"`
@@ -265,6 +355,158 @@ func (m *Empty) String() string            { return proto.CompactTextString(m) }
 func (*Empty) ProtoMessage()               {}
 func (*Empty) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{8} }
 
+type CapabilitiesResponse struct {
+	Capabilities []string `protobuf:"bytes,1,rep,name=capabilities" json:"capabilities,omitempty"`
+}
+
+func (m *CapabilitiesResponse) Reset()                    { *m = CapabilitiesResponse{} }
+func (m *CapabilitiesResponse) String() string            { return proto.CompactTextString(m) }
+func (*CapabilitiesResponse) ProtoMessage()               {}
+func (*CapabilitiesResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{9} }
+
+func (m *CapabilitiesResponse) GetCapabilities() []string {
+	if m != nil {
+		return m.Capabilities
+	}
+	return nil
+}
+
+type UserGrantsRequest struct {
+	Username string `protobuf:"bytes,1,opt,name=username" json:"username,omitempty"`
+}
+
+func (m *UserGrantsRequest) Reset()                    { *m = UserGrantsRequest{} }
+func (m *UserGrantsRequest) String() string            { return proto.CompactTextString(m) }
+func (*UserGrantsRequest) ProtoMessage()               {}
+func (*UserGrantsRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{10} }
+
+func (m *UserGrantsRequest) GetUsername() string {
+	if m != nil {
+		return m.Username
+	}
+	return ""
+}
+
+type UserGrantsResponse struct {
+	Grants []string `protobuf:"bytes,1,rep,name=grants" json:"grants,omitempty"`
+}
+
+func (m *UserGrantsResponse) Reset()                    { *m = UserGrantsResponse{} }
+func (m *UserGrantsResponse) String() string            { return proto.CompactTextString(m) }
+func (*UserGrantsResponse) ProtoMessage()               {}
+func (*UserGrantsResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{11} }
+
+func (m *UserGrantsResponse) GetGrants() []string {
+	if m != nil {
+		return m.Grants
+	}
+	return nil
+}
+
+type UpdatePoolSettingsRequest struct {
+	Config []byte `protobuf:"bytes,1,opt,name=config,proto3" json:"config,omitempty"`
+}
+
+func (m *UpdatePoolSettingsRequest) Reset()                    { *m = UpdatePoolSettingsRequest{} }
+func (m *UpdatePoolSettingsRequest) String() string            { return proto.CompactTextString(m) }
+func (*UpdatePoolSettingsRequest) ProtoMessage()               {}
+func (*UpdatePoolSettingsRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{12} }
+
+func (m *UpdatePoolSettingsRequest) GetConfig() []byte {
+	if m != nil {
+		return m.Config
+	}
+	return nil
+}
+
+type ServerVersionResponse struct {
+	Version string `protobuf:"bytes,1,opt,name=version" json:"version,omitempty"`
+}
+
+func (m *ServerVersionResponse) Reset()                    { *m = ServerVersionResponse{} }
+func (m *ServerVersionResponse) String() string            { return proto.CompactTextString(m) }
+func (*ServerVersionResponse) ProtoMessage()               {}
+func (*ServerVersionResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{13} }
+
+func (m *ServerVersionResponse) GetVersion() string {
+	if m != nil {
+		return m.Version
+	}
+	return ""
+}
+
+type GenerateCredentialsRequest struct {
+	UsernameConfig *UsernameConfig `protobuf:"bytes,1,opt,name=username_config,json=usernameConfig" json:"username_config,omitempty"`
+}
+
+func (m *GenerateCredentialsRequest) Reset()                    { *m = GenerateCredentialsRequest{} }
+func (m *GenerateCredentialsRequest) String() string            { return proto.CompactTextString(m) }
+func (*GenerateCredentialsRequest) ProtoMessage()               {}
+func (*GenerateCredentialsRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{14} }
+
+func (m *GenerateCredentialsRequest) GetUsernameConfig() *UsernameConfig {
+	if m != nil {
+		return m.UsernameConfig
+	}
+	return nil
+}
+
+type RevocationStep struct {
+	Statement       string `protobuf:"bytes,1,opt,name=statement" json:"statement,omitempty"`
+	ContinueOnError bool   `protobuf:"varint,2,opt,name=continue_on_error,json=continueOnError" json:"continue_on_error,omitempty"`
+}
+
+func (m *RevocationStep) Reset()                    { *m = RevocationStep{} }
+func (m *RevocationStep) String() string            { return proto.CompactTextString(m) }
+func (*RevocationStep) ProtoMessage()               {}
+func (*RevocationStep) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{15} }
+
+func (m *RevocationStep) GetStatement() string {
+	if m != nil {
+		return m.Statement
+	}
+	return ""
+}
+
+func (m *RevocationStep) GetContinueOnError() bool {
+	if m != nil {
+		return m.ContinueOnError
+	}
+	return false
+}
+
+type BuildInfo struct {
+	Version   string `protobuf:"bytes,1,opt,name=version" json:"version,omitempty"`
+	Revision  string `protobuf:"bytes,2,opt,name=revision" json:"revision,omitempty"`
+	GoVersion string `protobuf:"bytes,3,opt,name=go_version,json=goVersion" json:"go_version,omitempty"`
+}
+
+func (m *BuildInfo) Reset()                    { *m = BuildInfo{} }
+func (m *BuildInfo) String() string            { return proto.CompactTextString(m) }
+func (*BuildInfo) ProtoMessage()               {}
+func (*BuildInfo) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{16} }
+
+func (m *BuildInfo) GetVersion() string {
+	if m != nil {
+		return m.Version
+	}
+	return ""
+}
+
+func (m *BuildInfo) GetRevision() string {
+	if m != nil {
+		return m.Revision
+	}
+	return ""
+}
+
+func (m *BuildInfo) GetGoVersion() string {
+	if m != nil {
+		return m.GoVersion
+	}
+	return ""
+}
+
 func init() {
 	proto.RegisterType((*InitializeRequest)(nil), "dbplugin.InitializeRequest")
 	proto.RegisterType((*CreateUserRequest)(nil), "dbplugin.CreateUserRequest")
@@ -275,6 +517,14 @@ func init() {
 	proto.RegisterType((*CreateUserResponse)(nil), "dbplugin.CreateUserResponse")
 	proto.RegisterType((*TypeResponse)(nil), "dbplugin.TypeResponse")
 	proto.RegisterType((*Empty)(nil), "dbplugin.Empty")
+	proto.RegisterType((*CapabilitiesResponse)(nil), "dbplugin.CapabilitiesResponse")
+	proto.RegisterType((*UserGrantsRequest)(nil), "dbplugin.UserGrantsRequest")
+	proto.RegisterType((*UserGrantsResponse)(nil), "dbplugin.UserGrantsResponse")
+	proto.RegisterType((*UpdatePoolSettingsRequest)(nil), "dbplugin.UpdatePoolSettingsRequest")
+	proto.RegisterType((*ServerVersionResponse)(nil), "dbplugin.ServerVersionResponse")
+	proto.RegisterType((*GenerateCredentialsRequest)(nil), "dbplugin.GenerateCredentialsRequest")
+	proto.RegisterType((*RevocationStep)(nil), "dbplugin.RevocationStep")
+	proto.RegisterType((*BuildInfo)(nil), "dbplugin.BuildInfo")
 }
 
 // Reference imports to suppress errors if they are not otherwise used.
@@ -294,6 +544,13 @@ type DatabaseClient interface {
 	RevokeUser(ctx context.Context, in *RevokeUserRequest, opts ...grpc.CallOption) (*Empty, error)
 	Initialize(ctx context.Context, in *InitializeRequest, opts ...grpc.CallOption) (*Empty, error)
 	Close(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*Empty, error)
+	Capabilities(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*CapabilitiesResponse, error)
+	UserGrants(ctx context.Context, in *UserGrantsRequest, opts ...grpc.CallOption) (*UserGrantsResponse, error)
+	UpdatePoolSettings(ctx context.Context, in *UpdatePoolSettingsRequest, opts ...grpc.CallOption) (*Empty, error)
+	ServerVersion(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*ServerVersionResponse, error)
+	Ping(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*Empty, error)
+	GenerateCredentials(ctx context.Context, in *GenerateCredentialsRequest, opts ...grpc.CallOption) (*CreateUserResponse, error)
+	PluginInfo(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*BuildInfo, error)
 }
 
 type databaseClient struct {
@@ -358,6 +615,69 @@ func (c *databaseClient) Close(ctx context.Context, in *Empty, opts ...grpc.Call
 	return out, nil
 }
 
+func (c *databaseClient) Capabilities(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*CapabilitiesResponse, error) {
+	out := new(CapabilitiesResponse)
+	err := grpc.Invoke(ctx, "/dbplugin.Database/Capabilities", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *databaseClient) UserGrants(ctx context.Context, in *UserGrantsRequest, opts ...grpc.CallOption) (*UserGrantsResponse, error) {
+	out := new(UserGrantsResponse)
+	err := grpc.Invoke(ctx, "/dbplugin.Database/UserGrants", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *databaseClient) UpdatePoolSettings(ctx context.Context, in *UpdatePoolSettingsRequest, opts ...grpc.CallOption) (*Empty, error) {
+	out := new(Empty)
+	err := grpc.Invoke(ctx, "/dbplugin.Database/UpdatePoolSettings", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *databaseClient) ServerVersion(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*ServerVersionResponse, error) {
+	out := new(ServerVersionResponse)
+	err := grpc.Invoke(ctx, "/dbplugin.Database/ServerVersion", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *databaseClient) Ping(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*Empty, error) {
+	out := new(Empty)
+	err := grpc.Invoke(ctx, "/dbplugin.Database/Ping", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *databaseClient) GenerateCredentials(ctx context.Context, in *GenerateCredentialsRequest, opts ...grpc.CallOption) (*CreateUserResponse, error) {
+	out := new(CreateUserResponse)
+	err := grpc.Invoke(ctx, "/dbplugin.Database/GenerateCredentials", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *databaseClient) PluginInfo(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*BuildInfo, error) {
+	out := new(BuildInfo)
+	err := grpc.Invoke(ctx, "/dbplugin.Database/PluginInfo", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // Server API for Database service
 
 type DatabaseServer interface {
@@ -367,6 +687,13 @@ type DatabaseServer interface {
 	RevokeUser(context.Context, *RevokeUserRequest) (*Empty, error)
 	Initialize(context.Context, *InitializeRequest) (*Empty, error)
 	Close(context.Context, *Empty) (*Empty, error)
+	Capabilities(context.Context, *Empty) (*CapabilitiesResponse, error)
+	UserGrants(context.Context, *UserGrantsRequest) (*UserGrantsResponse, error)
+	UpdatePoolSettings(context.Context, *UpdatePoolSettingsRequest) (*Empty, error)
+	ServerVersion(context.Context, *Empty) (*ServerVersionResponse, error)
+	Ping(context.Context, *Empty) (*Empty, error)
+	GenerateCredentials(context.Context, *GenerateCredentialsRequest) (*CreateUserResponse, error)
+	PluginInfo(context.Context, *Empty) (*BuildInfo, error)
 }
 
 func RegisterDatabaseServer(s *grpc.Server, srv DatabaseServer) {
@@ -481,6 +808,132 @@ func _Database_Close_Handler(srv interface{}, ctx context.Context, dec func(inte
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Database_Capabilities_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DatabaseServer).Capabilities(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/dbplugin.Database/Capabilities",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DatabaseServer).Capabilities(ctx, req.(*Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Database_UserGrants_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UserGrantsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DatabaseServer).UserGrants(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/dbplugin.Database/UserGrants",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DatabaseServer).UserGrants(ctx, req.(*UserGrantsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Database_UpdatePoolSettings_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdatePoolSettingsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DatabaseServer).UpdatePoolSettings(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/dbplugin.Database/UpdatePoolSettings",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DatabaseServer).UpdatePoolSettings(ctx, req.(*UpdatePoolSettingsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Database_ServerVersion_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DatabaseServer).ServerVersion(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/dbplugin.Database/ServerVersion",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DatabaseServer).ServerVersion(ctx, req.(*Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Database_Ping_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DatabaseServer).Ping(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/dbplugin.Database/Ping",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DatabaseServer).Ping(ctx, req.(*Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Database_GenerateCredentials_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GenerateCredentialsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DatabaseServer).GenerateCredentials(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/dbplugin.Database/GenerateCredentials",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DatabaseServer).GenerateCredentials(ctx, req.(*GenerateCredentialsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Database_PluginInfo_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DatabaseServer).PluginInfo(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/dbplugin.Database/PluginInfo",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DatabaseServer).PluginInfo(ctx, req.(*Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 var _Database_serviceDesc = grpc.ServiceDesc{
 	ServiceName: "dbplugin.Database",
 	HandlerType: (*DatabaseServer)(nil),
@@ -509,6 +962,34 @@ var _Database_serviceDesc = grpc.ServiceDesc{
 			MethodName: "Close",
 			Handler:    _Database_Close_Handler,
 		},
+		{
+			MethodName: "Capabilities",
+			Handler:    _Database_Capabilities_Handler,
+		},
+		{
+			MethodName: "UserGrants",
+			Handler:    _Database_UserGrants_Handler,
+		},
+		{
+			MethodName: "UpdatePoolSettings",
+			Handler:    _Database_UpdatePoolSettings_Handler,
+		},
+		{
+			MethodName: "ServerVersion",
+			Handler:    _Database_ServerVersion_Handler,
+		},
+		{
+			MethodName: "Ping",
+			Handler:    _Database_Ping_Handler,
+		},
+		{
+			MethodName: "GenerateCredentials",
+			Handler:    _Database_GenerateCredentials_Handler,
+		},
+		{
+			MethodName: "PluginInfo",
+			Handler:    _Database_PluginInfo_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "builtin/logical/database/dbplugin/database.proto",
@@ -517,40 +998,68 @@ var _Database_serviceDesc = grpc.ServiceDesc{
 func init() { proto.RegisterFile("builtin/logical/database/dbplugin/database.proto", fileDescriptor0) }
 
 var fileDescriptor0 = []byte{
-	// 548 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xb4, 0x54, 0xcf, 0x6e, 0xd3, 0x4e,
-	0x10, 0x96, 0xdb, 0xb4, 0xbf, 0x64, 0x5a, 0x35, 0xc9, 0xfe, 0x4a, 0x15, 0x19, 0x24, 0x22, 0x9f,
-	0x5a, 0x21, 0xd9, 0xa8, 0xe5, 0x80, 0xb8, 0xa1, 0x14, 0x21, 0x24, 0x94, 0x83, 0x69, 0x25, 0x6e,
-	0xd1, 0xda, 0x99, 0x44, 0xab, 0x3a, 0xbb, 0xc6, 0xbb, 0x4e, 0x09, 0x4f, 0xc3, 0xe3, 0x70, 0xe2,
-	0x1d, 0x78, 0x13, 0xe4, 0x75, 0xd6, 0xbb, 0xf9, 0x73, 0xab, 0xb8, 0x79, 0x66, 0xbe, 0x6f, 0xf6,
-	0xf3, 0xb7, 0x33, 0x0b, 0xaf, 0x93, 0x92, 0x65, 0x8a, 0xf1, 0x28, 0x13, 0x73, 0x96, 0xd2, 0x2c,
-	0x9a, 0x52, 0x45, 0x13, 0x2a, 0x31, 0x9a, 0x26, 0x79, 0x56, 0xce, 0x19, 0x6f, 0x32, 0x61, 0x5e,
-	0x08, 0x25, 0x48, 0xdb, 0x14, 0xfc, 0x97, 0x73, 0x21, 0xe6, 0x19, 0x46, 0x3a, 0x9f, 0x94, 0xb3,
-	0x48, 0xb1, 0x05, 0x4a, 0x45, 0x17, 0x79, 0x0d, 0x0d, 0xbe, 0x42, 0xff, 0x13, 0x67, 0x8a, 0xd1,
-	0x8c, 0xfd, 0xc0, 0x18, 0xbf, 0x95, 0x28, 0x15, 0xb9, 0x80, 0xe3, 0x54, 0xf0, 0x19, 0x9b, 0x0f,
-	0xbc, 0xa1, 0x77, 0x79, 0x1a, 0xaf, 0x23, 0xf2, 0x0a, 0xfa, 0x4b, 0x2c, 0xd8, 0x6c, 0x35, 0x49,
-	0x05, 0xe7, 0x98, 0x2a, 0x26, 0xf8, 0xe0, 0x60, 0xe8, 0x5d, 0xb6, 0xe3, 0x5e, 0x5d, 0x18, 0x35,
-	0xf9, 0xe0, 0x97, 0x07, 0xfd, 0x51, 0x81, 0x54, 0xe1, 0xbd, 0xc4, 0xc2, 0xb4, 0x7e, 0x03, 0x20,
-	0x15, 0x55, 0xb8, 0x40, 0xae, 0xa4, 0x6e, 0x7f, 0x72, 0x7d, 0x1e, 0x1a, 0xbd, 0xe1, 0x97, 0xa6,
-	0x16, 0x3b, 0x38, 0xf2, 0x1e, 0xba, 0xa5, 0xc4, 0x82, 0xd3, 0x05, 0x4e, 0xd6, 0xca, 0x0e, 0x34,
-	0x75, 0x60, 0xa9, 0xf7, 0x6b, 0xc0, 0x48, 0xd7, 0xe3, 0xb3, 0x72, 0x23, 0x26, 0xef, 0x00, 0xf0,
-	0x7b, 0xce, 0x0a, 0xaa, 0x45, 0x1f, 0x6a, 0xb6, 0x1f, 0xd6, 0xf6, 0x84, 0xc6, 0x9e, 0xf0, 0xce,
-	0xd8, 0x13, 0x3b, 0xe8, 0xe0, 0xa7, 0x07, 0xbd, 0x18, 0x39, 0x3e, 0x3e, 0xfd, 0x4f, 0x7c, 0x68,
-	0x1b, 0x61, 0xfa, 0x17, 0x3a, 0x71, 0x13, 0x3f, 0x49, 0x22, 0x42, 0x3f, 0xc6, 0xa5, 0x78, 0xc0,
-	0x7f, 0x2a, 0x31, 0xf8, 0xed, 0x01, 0x58, 0x1a, 0x89, 0xe0, 0xff, 0xb4, 0xba, 0x62, 0x26, 0xf8,
-	0x64, 0xeb, 0xa4, 0x4e, 0x4c, 0x4c, 0xc9, 0x21, 0xdc, 0xc0, 0xb3, 0x02, 0x97, 0x22, 0xdd, 0xa1,
-	0xd4, 0x07, 0x9d, 0xdb, 0xe2, 0xe6, 0x29, 0x85, 0xc8, 0xb2, 0x84, 0xa6, 0x0f, 0x2e, 0xe5, 0xb0,
-	0x3e, 0xc5, 0x94, 0x1c, 0xc2, 0x15, 0xf4, 0x8a, 0xea, 0xba, 0x5c, 0x74, 0x4b, 0xa3, 0xbb, 0x3a,
-	0x6f, 0xa1, 0xc1, 0x18, 0xce, 0x36, 0x07, 0x87, 0x0c, 0xe1, 0xe4, 0x96, 0xc9, 0x3c, 0xa3, 0xab,
-	0x71, 0xe5, 0x40, 0xfd, 0x2f, 0x6e, 0xaa, 0x32, 0x28, 0x16, 0x19, 0x8e, 0x1d, 0x83, 0x4c, 0x1c,
-	0x7c, 0x06, 0xe2, 0x0e, 0xbd, 0xcc, 0x05, 0x97, 0xb8, 0x61, 0xa9, 0xb7, 0x75, 0xeb, 0x3e, 0xb4,
-	0x73, 0x2a, 0xe5, 0xa3, 0x28, 0xa6, 0xa6, 0x9b, 0x89, 0x83, 0x00, 0x4e, 0xef, 0x56, 0x39, 0x36,
-	0x7d, 0x08, 0xb4, 0xd4, 0x2a, 0x37, 0x3d, 0xf4, 0x77, 0xf0, 0x1f, 0x1c, 0x7d, 0x58, 0xe4, 0x6a,
-	0x75, 0xfd, 0xe7, 0x00, 0xda, 0xb7, 0xeb, 0x87, 0x80, 0x44, 0xd0, 0xaa, 0x98, 0xa4, 0x6b, 0xaf,
-	0x5b, 0xa3, 0xfc, 0x0b, 0x9b, 0xd8, 0x68, 0xfd, 0x11, 0xc0, 0x0a, 0x27, 0xcf, 0x2d, 0x6a, 0x67,
-	0x87, 0xfd, 0x17, 0xfb, 0x8b, 0xeb, 0x46, 0x6f, 0xa1, 0xd3, 0xec, 0x0a, 0xf1, 0x2d, 0x74, 0x7b,
-	0x81, 0xfc, 0x6d, 0x69, 0xd5, 0xfc, 0xdb, 0x19, 0x76, 0x25, 0xec, 0x4c, 0xf6, 0x5e, 0xae, 0x7d,
-	0xc7, 0x5c, 0xee, 0xce, 0xeb, 0xb6, 0xcb, 0xbd, 0x82, 0xa3, 0x51, 0x26, 0xe4, 0x1e, 0xb3, 0xb6,
-	0x13, 0xc9, 0xb1, 0x5e, 0xc3, 0x9b, 0xbf, 0x01, 0x00, 0x00, 0xff, 0xff, 0x8c, 0x55, 0x84, 0x56,
-	0x94, 0x05, 0x00, 0x00,
+	// 993 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xb4, 0x56, 0x6f, 0x6f, 0x1b, 0xc5,
+	0x13, 0x96, 0xf3, 0xc7, 0xb1, 0xa7, 0xf9, 0x25, 0xf1, 0x26, 0x8d, 0xfc, 0x3b, 0x02, 0xb5, 0x0e,
+	0x84, 0x5c, 0x5a, 0xd9, 0x90, 0xf0, 0x02, 0xf5, 0x0d, 0x2a, 0x4e, 0x15, 0x15, 0x95, 0x62, 0x5d,
+	0x1a, 0x04, 0xbc, 0x31, 0xeb, 0xf3, 0xf8, 0xb4, 0xea, 0x79, 0xf7, 0xd8, 0x5d, 0x3b, 0x35, 0x9f,
+	0x84, 0x97, 0x7c, 0x07, 0xbe, 0x04, 0x9f, 0x81, 0x4f, 0x83, 0x6e, 0xef, 0xdf, 0x9e, 0xef, 0x42,
+	0x41, 0x15, 0xef, 0xbc, 0xcf, 0xcc, 0x33, 0x3b, 0x3b, 0x33, 0x8f, 0xe7, 0xe0, 0xd3, 0xe9, 0x92,
+	0x85, 0x9a, 0xf1, 0x61, 0x28, 0x02, 0xe6, 0xd3, 0x70, 0x38, 0xa3, 0x9a, 0x4e, 0xa9, 0xc2, 0xe1,
+	0x6c, 0x1a, 0x85, 0xcb, 0x80, 0xf1, 0x1c, 0x19, 0x44, 0x52, 0x68, 0x41, 0x5a, 0x99, 0xc1, 0x79,
+	0x10, 0x08, 0x11, 0x84, 0x38, 0x34, 0xf8, 0x74, 0x39, 0x1f, 0x6a, 0xb6, 0x40, 0xa5, 0xe9, 0x22,
+	0x4a, 0x5c, 0xdd, 0xef, 0xa1, 0xf3, 0x9c, 0x33, 0xcd, 0x68, 0xc8, 0x7e, 0x41, 0x0f, 0x7f, 0x5e,
+	0xa2, 0xd2, 0xe4, 0x14, 0x9a, 0xbe, 0xe0, 0x73, 0x16, 0x74, 0x1b, 0xbd, 0x46, 0x7f, 0xdf, 0x4b,
+	0x4f, 0xe4, 0x11, 0x74, 0x56, 0x28, 0xd9, 0x7c, 0x3d, 0xf1, 0x05, 0xe7, 0xe8, 0x6b, 0x26, 0x78,
+	0x77, 0xab, 0xd7, 0xe8, 0xb7, 0xbc, 0xa3, 0xc4, 0x30, 0xca, 0x71, 0xf7, 0x8f, 0x06, 0x74, 0x46,
+	0x12, 0xa9, 0xc6, 0x1b, 0x85, 0x32, 0x0b, 0xfd, 0x39, 0x80, 0xd2, 0x54, 0xe3, 0x02, 0xb9, 0x56,
+	0x26, 0xfc, 0xbd, 0xf3, 0x93, 0x41, 0x96, 0xef, 0xe0, 0x3a, 0xb7, 0x79, 0x96, 0x1f, 0x79, 0x0a,
+	0x87, 0x4b, 0x85, 0x92, 0xd3, 0x05, 0x4e, 0xd2, 0xcc, 0xb6, 0x0c, 0xb5, 0x5b, 0x50, 0x6f, 0x52,
+	0x87, 0x91, 0xb1, 0x7b, 0x07, 0xcb, 0xd2, 0x99, 0x3c, 0x01, 0xc0, 0x37, 0x11, 0x93, 0xd4, 0x24,
+	0xbd, 0x6d, 0xd8, 0xce, 0x20, 0x29, 0xcf, 0x20, 0x2b, 0xcf, 0xe0, 0x55, 0x56, 0x1e, 0xcf, 0xf2,
+	0x76, 0x7f, 0x6b, 0xc0, 0x91, 0x87, 0x1c, 0x6f, 0xdf, 0xfd, 0x25, 0x0e, 0xb4, 0xb2, 0xc4, 0xcc,
+	0x13, 0xda, 0x5e, 0x7e, 0x7e, 0xa7, 0x14, 0x11, 0x3a, 0x1e, 0xae, 0xc4, 0x6b, 0xfc, 0x4f, 0x53,
+	0x74, 0x7f, 0xdd, 0x06, 0x28, 0x68, 0x64, 0x08, 0xc7, 0x7e, 0xdc, 0x62, 0x26, 0xf8, 0x64, 0xe3,
+	0xa6, 0xb6, 0x47, 0x32, 0x93, 0x45, 0xb8, 0x80, 0xfb, 0x12, 0x57, 0xc2, 0xaf, 0x50, 0x92, 0x8b,
+	0x4e, 0x0a, 0x63, 0xf9, 0x16, 0x29, 0xc2, 0x70, 0x4a, 0xfd, 0xd7, 0x36, 0x65, 0x3b, 0xb9, 0x25,
+	0x33, 0x59, 0x84, 0x87, 0x70, 0x24, 0xe3, 0x76, 0xd9, 0xde, 0x3b, 0xc6, 0xfb, 0xd0, 0xe0, 0x96,
+	0xeb, 0x23, 0xe8, 0x98, 0x34, 0xd1, 0xf6, 0xdd, 0xed, 0x6d, 0xf7, 0xdb, 0xde, 0x51, 0x62, 0x28,
+	0xc7, 0x0d, 0x24, 0xe5, 0xda, 0xf6, 0x6d, 0x1a, 0xdf, 0x43, 0x83, 0x97, 0xe3, 0x4a, 0xd3, 0x0f,
+	0xdb, 0x77, 0x2f, 0x89, 0x9b, 0x18, 0x2c, 0xe7, 0x11, 0x1c, 0x95, 0xaa, 0x82, 0x91, 0xea, 0xb6,
+	0x7a, 0xdb, 0xe5, 0xf9, 0xf6, 0xac, 0xd2, 0x60, 0x14, 0xbf, 0xc4, 0x3e, 0x2b, 0xf7, 0xcf, 0x2d,
+	0x38, 0x28, 0x6b, 0x80, 0xf4, 0xe0, 0xde, 0x25, 0x53, 0x51, 0x48, 0xd7, 0x2f, 0xe3, 0x66, 0x26,
+	0x6d, 0xb1, 0xa1, 0xb8, 0xd7, 0x9e, 0x08, 0xf1, 0xa5, 0xd5, 0xeb, 0xec, 0x4c, 0x3e, 0x2e, 0xe2,
+	0x8d, 0x25, 0xce, 0xd9, 0x9b, 0xb4, 0xe2, 0x1b, 0x28, 0x39, 0x83, 0xf6, 0x58, 0x88, 0x70, 0x14,
+	0x52, 0x95, 0x95, 0xb9, 0x00, 0xc8, 0x39, 0x9c, 0x8c, 0xa9, 0x52, 0xb7, 0x42, 0xce, 0xbe, 0x61,
+	0xfc, 0x26, 0x8a, 0x50, 0xfa, 0x54, 0x61, 0x77, 0xb7, 0xd7, 0xe8, 0xef, 0x7a, 0xb5, 0xb6, 0x0d,
+	0xce, 0x0b, 0x71, 0x9b, 0x72, 0x9a, 0x15, 0x4e, 0x6e, 0x23, 0x8f, 0xa1, 0x63, 0xe1, 0x97, 0x2c,
+	0x60, 0xa6, 0xe0, 0x31, 0xa1, 0x6a, 0x20, 0x03, 0x20, 0x16, 0x78, 0x1d, 0xa1, 0xcf, 0x68, 0xd8,
+	0x6d, 0x19, 0xf7, 0x1a, 0x8b, 0xfb, 0x02, 0x88, 0xfd, 0x5f, 0xa6, 0x22, 0xc1, 0x15, 0x96, 0x94,
+	0xd2, 0xd8, 0x10, 0xb3, 0x03, 0xad, 0x28, 0x8d, 0x93, 0x55, 0x36, 0x3b, 0xbb, 0x2e, 0xec, 0xbf,
+	0x5a, 0x47, 0x98, 0xc7, 0x21, 0xb0, 0xa3, 0xd7, 0x51, 0x16, 0xc3, 0xfc, 0x76, 0xf7, 0x60, 0xf7,
+	0xd9, 0x22, 0xd2, 0x6b, 0xf7, 0x09, 0x9c, 0x8c, 0x68, 0x44, 0xa7, 0x2c, 0x64, 0x9a, 0xa1, 0xca,
+	0x49, 0x2e, 0xec, 0xfb, 0x16, 0xde, 0x6d, 0x98, 0xe1, 0x2a, 0x61, 0xee, 0x10, 0x3a, 0x71, 0xc2,
+	0x57, 0xf1, 0x70, 0xaa, 0xec, 0x5f, 0xe1, 0x6f, 0xb2, 0x76, 0x1f, 0x03, 0xb1, 0x09, 0xe9, 0x55,
+	0xa7, 0xd0, 0x34, 0xf3, 0x9d, 0x5d, 0x92, 0x9e, 0xdc, 0x0b, 0xf8, 0xff, 0x4d, 0x34, 0xa3, 0x1a,
+	0xe3, 0x76, 0x5f, 0xa3, 0xd6, 0x8c, 0x07, 0xea, 0x2d, 0x4b, 0xc4, 0xfd, 0x0c, 0xee, 0x5f, 0xa3,
+	0x5c, 0xa1, 0xfc, 0x0e, 0xa5, 0x62, 0x82, 0xe7, 0xb7, 0x74, 0x61, 0x6f, 0x95, 0x40, 0x69, 0x5a,
+	0xd9, 0xd1, 0x9d, 0x80, 0x73, 0x85, 0x1c, 0x25, 0xd5, 0x38, 0x92, 0x38, 0x43, 0x1e, 0xef, 0xab,
+	0xfc, 0xa2, 0x9a, 0xe5, 0xd0, 0xf8, 0x77, 0xcb, 0xc1, 0xfd, 0x11, 0x0e, 0xca, 0xf2, 0x8a, 0x87,
+	0x3a, 0x17, 0x6e, 0x9a, 0x4e, 0x01, 0x90, 0x4f, 0xa0, 0xe3, 0x0b, 0xae, 0x19, 0x5f, 0xe2, 0x44,
+	0xf0, 0x09, 0x4a, 0x29, 0x64, 0xba, 0x08, 0x0f, 0x33, 0xc3, 0xb7, 0xfc, 0x59, 0x0c, 0xbb, 0x3f,
+	0x41, 0xfb, 0xab, 0x25, 0x0b, 0x67, 0xcf, 0xf9, 0x5c, 0xdc, 0xfd, 0xc6, 0xb8, 0x2b, 0x12, 0x57,
+	0x4c, 0x65, 0x2b, 0xb5, 0xed, 0xe5, 0x67, 0xf2, 0x3e, 0x40, 0x20, 0x26, 0x19, 0x31, 0x51, 0x61,
+	0x3b, 0x10, 0x69, 0x01, 0xcf, 0x7f, 0x6f, 0x42, 0xeb, 0x32, 0xfd, 0x02, 0x20, 0x43, 0xd8, 0x89,
+	0x67, 0x8b, 0x1c, 0x16, 0x8f, 0x37, 0x73, 0xe4, 0x9c, 0x16, 0x40, 0x69, 0xf8, 0xae, 0x00, 0x8a,
+	0xd1, 0x26, 0xef, 0x15, 0x5e, 0x95, 0xe5, 0xed, 0x9c, 0xd5, 0x1b, 0xd3, 0x40, 0x5f, 0x40, 0x3b,
+	0x5f, 0x92, 0xc4, 0xb1, 0xff, 0xb8, 0xca, 0x9b, 0xd3, 0xd9, 0x4c, 0x2d, 0x5e, 0x7c, 0xc5, 0xf2,
+	0xb2, 0x53, 0xa8, 0xac, 0xb4, 0x5a, 0x6e, 0xf1, 0x01, 0x63, 0x73, 0x2b, 0x9f, 0x35, 0x55, 0xee,
+	0x43, 0xd8, 0x1d, 0x85, 0x42, 0xd5, 0x14, 0xab, 0xe2, 0xfa, 0x25, 0xec, 0xdb, 0x2a, 0xac, 0x32,
+	0x3e, 0xb0, 0x6a, 0x53, 0x27, 0xd7, 0x2b, 0x80, 0x42, 0x59, 0x76, 0x9e, 0x15, 0x81, 0x3a, 0x67,
+	0xf5, 0xc6, 0x34, 0xd0, 0xd7, 0x40, 0xaa, 0xa2, 0x23, 0x1f, 0x5a, 0x9c, 0xbb, 0x24, 0x59, 0x7d,
+	0xd5, 0x53, 0xf8, 0x5f, 0x49, 0x8b, 0xd5, 0x67, 0x3d, 0x28, 0x80, 0x7a, 0xd5, 0xf6, 0x61, 0x67,
+	0xcc, 0x78, 0xf0, 0x0f, 0x4a, 0xf8, 0x03, 0x1c, 0xd7, 0xa8, 0x98, 0x7c, 0x54, 0xf8, 0xdd, 0x2d,
+	0xf2, 0xb7, 0x8c, 0xde, 0x39, 0xc0, 0xd8, 0x18, 0x8d, 0xc8, 0x2a, 0xa9, 0x1c, 0x17, 0x40, 0x2e,
+	0xc5, 0x69, 0xd3, 0x7c, 0x51, 0x5d, 0xfc, 0x35, 0x00, 0x28, 0xd6, 0xc7, 0x47, 0x5f, 0x0b, 0x00,
+	0x00,
 }
@@ -69,6 +69,69 @@ func (mw *databaseTracingMiddleware) Close() (err error) {
 	return mw.next.Close()
 }
 
+func (mw *databaseTracingMiddleware) Capabilities(ctx context.Context) (capabilities []string, err error) {
+	defer func(then time.Time) {
+		mw.logger.Trace("database", "operation", "Capabilities", "status", "finished", "type", mw.typeStr, "transport", mw.transport, "err", err, "took", time.Since(then))
+	}(time.Now())
+
+	mw.logger.Trace("database", "operation", "Capabilities", "status", "started", "type", mw.typeStr, "transport", mw.transport)
+	return Capabilities(ctx, mw.next)
+}
+
+func (mw *databaseTracingMiddleware) UserGrants(ctx context.Context, username string) (grants []string, err error) {
+	defer func(then time.Time) {
+		mw.logger.Trace("database", "operation", "UserGrants", "status", "finished", "type", mw.typeStr, "transport", mw.transport, "err", err, "took", time.Since(then))
+	}(time.Now())
+
+	mw.logger.Trace("database", "operation", "UserGrants", "status", "started", "type", mw.typeStr, "transport", mw.transport)
+	return UserGrants(ctx, mw.next, username)
+}
+
+func (mw *databaseTracingMiddleware) UpdatePoolSettings(ctx context.Context, conf map[string]interface{}) (err error) {
+	defer func(then time.Time) {
+		mw.logger.Trace("database", "operation", "UpdatePoolSettings", "status", "finished", "type", mw.typeStr, "transport", mw.transport, "err", err, "took", time.Since(then))
+	}(time.Now())
+
+	mw.logger.Trace("database", "operation", "UpdatePoolSettings", "status", "started", "type", mw.typeStr, "transport", mw.transport)
+	return UpdatePoolSettings(ctx, mw.next, conf)
+}
+
+func (mw *databaseTracingMiddleware) ServerVersion(ctx context.Context) (version string, err error) {
+	defer func(then time.Time) {
+		mw.logger.Trace("database", "operation", "ServerVersion", "status", "finished", "type", mw.typeStr, "transport", mw.transport, "err", err, "took", time.Since(then))
+	}(time.Now())
+
+	mw.logger.Trace("database", "operation", "ServerVersion", "status", "started", "type", mw.typeStr, "transport", mw.transport)
+	return ServerVersion(ctx, mw.next)
+}
+
+func (mw *databaseTracingMiddleware) Ping(ctx context.Context) (err error) {
+	defer func(then time.Time) {
+		mw.logger.Trace("database", "operation", "Ping", "status", "finished", "type", mw.typeStr, "transport", mw.transport, "err", err, "took", time.Since(then))
+	}(time.Now())
+
+	mw.logger.Trace("database", "operation", "Ping", "status", "started", "type", mw.typeStr, "transport", mw.transport)
+	return Ping(ctx, mw.next)
+}
+
+func (mw *databaseTracingMiddleware) GenerateCredentials(ctx context.Context, usernameConfig UsernameConfig) (username string, password string, err error) {
+	defer func(then time.Time) {
+		mw.logger.Trace("database", "operation", "GenerateCredentials", "status", "finished", "type", mw.typeStr, "transport", mw.transport, "err", err, "took", time.Since(then))
+	}(time.Now())
+
+	mw.logger.Trace("database", "operation", "GenerateCredentials", "status", "started", "type", mw.typeStr, "transport", mw.transport)
+	return GenerateCredentials(ctx, mw.next, usernameConfig)
+}
+
+func (mw *databaseTracingMiddleware) PluginInfo(ctx context.Context) (info *BuildInfo, err error) {
+	defer func(then time.Time) {
+		mw.logger.Trace("database", "operation", "PluginInfo", "status", "finished", "type", mw.typeStr, "transport", mw.transport, "err", err, "took", time.Since(then))
+	}(time.Now())
+
+	mw.logger.Trace("database", "operation", "PluginInfo", "status", "started", "type", mw.typeStr, "transport", mw.transport)
+	return PluginInfo(ctx, mw.next)
+}
+
 // ---- Metrics Middleware Domain ----
 
 // databaseMetricsMiddleware wraps an implementation of Databases and on
@@ -162,3 +225,55 @@ func (mw *databaseMetricsMiddleware) Close() (err error) {
 	metrics.IncrCounter([]string{"database", mw.typeStr, "Close"}, 1)
 	return mw.next.Close()
 }
+
+func (mw *databaseMetricsMiddleware) Capabilities(ctx context.Context) ([]string, error) {
+	return Capabilities(ctx, mw.next)
+}
+
+func (mw *databaseMetricsMiddleware) UserGrants(ctx context.Context, username string) (grants []string, err error) {
+	defer func(now time.Time) {
+		metrics.MeasureSince([]string{"database", "UserGrants"}, now)
+		metrics.MeasureSince([]string{"database", mw.typeStr, "UserGrants"}, now)
+
+		if err != nil {
+			metrics.IncrCounter([]string{"database", "UserGrants", "error"}, 1)
+			metrics.IncrCounter([]string{"database", mw.typeStr, "UserGrants", "error"}, 1)
+		}
+	}(time.Now())
+
+	metrics.IncrCounter([]string{"database", "UserGrants"}, 1)
+	metrics.IncrCounter([]string{"database", mw.typeStr, "UserGrants"}, 1)
+	return UserGrants(ctx, mw.next, username)
+}
+
+func (mw *databaseMetricsMiddleware) UpdatePoolSettings(ctx context.Context, conf map[string]interface{}) (err error) {
+	defer func(now time.Time) {
+		metrics.MeasureSince([]string{"database", "UpdatePoolSettings"}, now)
+		metrics.MeasureSince([]string{"database", mw.typeStr, "UpdatePoolSettings"}, now)
+
+		if err != nil {
+			metrics.IncrCounter([]string{"database", "UpdatePoolSettings", "error"}, 1)
+			metrics.IncrCounter([]string{"database", mw.typeStr, "UpdatePoolSettings", "error"}, 1)
+		}
+	}(time.Now())
+
+	metrics.IncrCounter([]string{"database", "UpdatePoolSettings"}, 1)
+	metrics.IncrCounter([]string{"database", mw.typeStr, "UpdatePoolSettings"}, 1)
+	return UpdatePoolSettings(ctx, mw.next, conf)
+}
+
+func (mw *databaseMetricsMiddleware) ServerVersion(ctx context.Context) (string, error) {
+	return ServerVersion(ctx, mw.next)
+}
+
+func (mw *databaseMetricsMiddleware) Ping(ctx context.Context) error {
+	return Ping(ctx, mw.next)
+}
+
+func (mw *databaseMetricsMiddleware) GenerateCredentials(ctx context.Context, usernameConfig UsernameConfig) (string, string, error) {
+	return GenerateCredentials(ctx, mw.next, usernameConfig)
+}
+
+func (mw *databaseMetricsMiddleware) PluginInfo(ctx context.Context) (*BuildInfo, error) {
+	return PluginInfo(ctx, mw.next)
+}
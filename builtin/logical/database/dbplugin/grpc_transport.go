@@ -78,6 +78,72 @@ func (s *gRPCServer) Close(_ context.Context, _ *Empty) (*Empty, error) {
 	return &Empty{}, nil
 }
 
+func (s *gRPCServer) Capabilities(ctx context.Context, _ *Empty) (*CapabilitiesResponse, error) {
+	caps, err := Capabilities(ctx, s.impl)
+	if err != nil {
+		return nil, err
+	}
+
+	return &CapabilitiesResponse{
+		Capabilities: caps,
+	}, nil
+}
+
+func (s *gRPCServer) UserGrants(ctx context.Context, req *UserGrantsRequest) (*UserGrantsResponse, error) {
+	grants, err := UserGrants(ctx, s.impl, req.Username)
+	if err != nil {
+		return nil, err
+	}
+
+	return &UserGrantsResponse{
+		Grants: grants,
+	}, nil
+}
+
+func (s *gRPCServer) ServerVersion(ctx context.Context, _ *Empty) (*ServerVersionResponse, error) {
+	version, err := ServerVersion(ctx, s.impl)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ServerVersionResponse{
+		Version: version,
+	}, nil
+}
+
+func (s *gRPCServer) Ping(ctx context.Context, _ *Empty) (*Empty, error) {
+	err := Ping(ctx, s.impl)
+	return &Empty{}, err
+}
+
+func (s *gRPCServer) GenerateCredentials(ctx context.Context, req *GenerateCredentialsRequest) (*CreateUserResponse, error) {
+	u, p, err := GenerateCredentials(ctx, s.impl, *req.UsernameConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	return &CreateUserResponse{
+		Username: u,
+		Password: p,
+	}, nil
+}
+
+func (s *gRPCServer) PluginInfo(ctx context.Context, _ *Empty) (*BuildInfo, error) {
+	return PluginInfo(ctx, s.impl)
+}
+
+func (s *gRPCServer) UpdatePoolSettings(ctx context.Context, req *UpdatePoolSettingsRequest) (*Empty, error) {
+	config := map[string]interface{}{}
+
+	err := json.Unmarshal(req.Config, &config)
+	if err != nil {
+		return nil, err
+	}
+
+	err = UpdatePoolSettings(ctx, s.impl, config)
+	return &Empty{}, err
+}
+
 // ---- gRPC client domain ----
 
 type gRPCClient struct {
@@ -202,3 +268,140 @@ func (c *gRPCClient) Close() error {
 	_, err := c.client.Close(c.doneCtx, &Empty{})
 	return err
 }
+
+func (c *gRPCClient) Capabilities(ctx context.Context) ([]string, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	quitCh := pluginutil.CtxCancelIfCanceled(cancel, c.doneCtx)
+	defer close(quitCh)
+	defer cancel()
+
+	resp, err := c.client.Capabilities(ctx, &Empty{})
+	if err != nil {
+		if c.doneCtx.Err() != nil {
+			return nil, ErrPluginShutdown
+		}
+
+		return nil, err
+	}
+
+	return resp.Capabilities, nil
+}
+
+func (c *gRPCClient) UserGrants(ctx context.Context, username string) ([]string, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	quitCh := pluginutil.CtxCancelIfCanceled(cancel, c.doneCtx)
+	defer close(quitCh)
+	defer cancel()
+
+	resp, err := c.client.UserGrants(ctx, &UserGrantsRequest{
+		Username: username,
+	})
+	if err != nil {
+		if c.doneCtx.Err() != nil {
+			return nil, ErrPluginShutdown
+		}
+
+		return nil, err
+	}
+
+	return resp.Grants, nil
+}
+
+func (c *gRPCClient) UpdatePoolSettings(ctx context.Context, config map[string]interface{}) error {
+	configRaw, err := json.Marshal(config)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	quitCh := pluginutil.CtxCancelIfCanceled(cancel, c.doneCtx)
+	defer close(quitCh)
+	defer cancel()
+
+	_, err = c.client.UpdatePoolSettings(ctx, &UpdatePoolSettingsRequest{
+		Config: configRaw,
+	})
+	if err != nil {
+		if c.doneCtx.Err() != nil {
+			return ErrPluginShutdown
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+func (c *gRPCClient) ServerVersion(ctx context.Context) (string, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	quitCh := pluginutil.CtxCancelIfCanceled(cancel, c.doneCtx)
+	defer close(quitCh)
+	defer cancel()
+
+	resp, err := c.client.ServerVersion(ctx, &Empty{})
+	if err != nil {
+		if c.doneCtx.Err() != nil {
+			return "", ErrPluginShutdown
+		}
+
+		return "", err
+	}
+
+	return resp.Version, nil
+}
+
+func (c *gRPCClient) Ping(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	quitCh := pluginutil.CtxCancelIfCanceled(cancel, c.doneCtx)
+	defer close(quitCh)
+	defer cancel()
+
+	_, err := c.client.Ping(ctx, &Empty{})
+	if err != nil {
+		if c.doneCtx.Err() != nil {
+			return ErrPluginShutdown
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+func (c *gRPCClient) GenerateCredentials(ctx context.Context, usernameConfig UsernameConfig) (string, string, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	quitCh := pluginutil.CtxCancelIfCanceled(cancel, c.doneCtx)
+	defer close(quitCh)
+	defer cancel()
+
+	resp, err := c.client.GenerateCredentials(ctx, &GenerateCredentialsRequest{
+		UsernameConfig: &usernameConfig,
+	})
+	if err != nil {
+		if c.doneCtx.Err() != nil {
+			return "", "", ErrPluginShutdown
+		}
+
+		return "", "", err
+	}
+
+	return resp.Username, resp.Password, nil
+}
+
+func (c *gRPCClient) PluginInfo(ctx context.Context) (*BuildInfo, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	quitCh := pluginutil.CtxCancelIfCanceled(cancel, c.doneCtx)
+	defer close(quitCh)
+	defer cancel()
+
+	info, err := c.client.PluginInfo(ctx, &Empty{})
+	if err != nil {
+		if c.doneCtx.Err() != nil {
+			return nil, ErrPluginShutdown
+		}
+
+		return nil, err
+	}
+
+	return info, nil
+}
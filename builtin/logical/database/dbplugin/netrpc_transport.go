@@ -47,6 +47,50 @@ func (ds *databasePluginRPCServer) Close(_ struct{}, _ *struct{}) error {
 	return nil
 }
 
+func (ds *databasePluginRPCServer) Capabilities(_ struct{}, resp *[]string) error {
+	var err error
+	*resp, err = Capabilities(context.Background(), ds.impl)
+	return err
+}
+
+func (ds *databasePluginRPCServer) UserGrants(username string, resp *[]string) error {
+	var err error
+	*resp, err = UserGrants(context.Background(), ds.impl, username)
+	return err
+}
+
+func (ds *databasePluginRPCServer) ServerVersion(_ struct{}, resp *string) error {
+	var err error
+	*resp, err = ServerVersion(context.Background(), ds.impl)
+	return err
+}
+
+func (ds *databasePluginRPCServer) Ping(_ struct{}, _ *struct{}) error {
+	err := Ping(context.Background(), ds.impl)
+	return err
+}
+
+func (ds *databasePluginRPCServer) GenerateCredentials(usernameConfig UsernameConfig, resp *CreateUserResponse) error {
+	var err error
+	resp.Username, resp.Password, err = GenerateCredentials(context.Background(), ds.impl, usernameConfig)
+	return err
+}
+
+func (ds *databasePluginRPCServer) PluginInfo(_ struct{}, resp *BuildInfo) error {
+	info, err := PluginInfo(context.Background(), ds.impl)
+	if err != nil {
+		return err
+	}
+
+	*resp = *info
+	return nil
+}
+
+func (ds *databasePluginRPCServer) UpdatePoolSettings(config map[string]interface{}, _ *struct{}) error {
+	err := UpdatePoolSettings(context.Background(), ds.impl, config)
+	return err
+}
+
 // ---- RPC client domain ----
 // databasePluginRPCClient implements Database and is used on the client to
 // make RPC calls to a plugin.
@@ -114,6 +158,55 @@ func (dr *databasePluginRPCClient) Close() error {
 	return err
 }
 
+func (dr *databasePluginRPCClient) Capabilities(_ context.Context) ([]string, error) {
+	var caps []string
+	err := dr.client.Call("Plugin.Capabilities", struct{}{}, &caps)
+
+	return caps, err
+}
+
+func (dr *databasePluginRPCClient) UserGrants(_ context.Context, username string) ([]string, error) {
+	var grants []string
+	err := dr.client.Call("Plugin.UserGrants", username, &grants)
+
+	return grants, err
+}
+
+func (dr *databasePluginRPCClient) ServerVersion(_ context.Context) (string, error) {
+	var version string
+	err := dr.client.Call("Plugin.ServerVersion", struct{}{}, &version)
+
+	return version, err
+}
+
+func (dr *databasePluginRPCClient) Ping(_ context.Context) error {
+	err := dr.client.Call("Plugin.Ping", struct{}{}, &struct{}{})
+	return err
+}
+
+func (dr *databasePluginRPCClient) GenerateCredentials(_ context.Context, usernameConfig UsernameConfig) (string, string, error) {
+	var resp CreateUserResponse
+	err := dr.client.Call("Plugin.GenerateCredentials", usernameConfig, &resp)
+
+	return resp.Username, resp.Password, err
+}
+
+func (dr *databasePluginRPCClient) PluginInfo(_ context.Context) (*BuildInfo, error) {
+	var info BuildInfo
+	err := dr.client.Call("Plugin.PluginInfo", struct{}{}, &info)
+	if err != nil {
+		return nil, err
+	}
+
+	return &info, nil
+}
+
+func (dr *databasePluginRPCClient) UpdatePoolSettings(_ context.Context, config map[string]interface{}) error {
+	err := dr.client.Call("Plugin.UpdatePoolSettings", config, &struct{}{})
+
+	return err
+}
+
 // ---- RPC Request Args Domain ----
 
 type InitializeRequestRPC struct {
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"os"
+	"reflect"
 	"testing"
 	"time"
 
@@ -58,6 +59,13 @@ func (m *mockPlugin) RevokeUser(_ context.Context, statements dbplugin.Statement
 		return err
 	}
 
+	// A "fail" step fails, stopping the revocation unless it may fail
+	for _, step := range statements.RevocationSteps {
+		if step.Statement == "fail" && !step.ContinueOnError {
+			return err
+		}
+	}
+
 	delete(m.users, username)
 	return nil
 }
@@ -73,6 +81,23 @@ func (m *mockPlugin) Close() error {
 	m.users = nil
 	return nil
 }
+func (m *mockPlugin) Capabilities(_ context.Context) ([]string, error) {
+	return []string{dbplugin.CapabilityCreateUser, dbplugin.CapabilityRevokeUser}, nil
+}
+func (m *mockPlugin) GenerateCredentials(_ context.Context, usernameConf dbplugin.UsernameConfig) (username string, password string, err error) {
+	if usernameConf.DisplayName == "" {
+		return "", "", errors.New("err")
+	}
+
+	return usernameConf.UsernamePrefix + usernameConf.DisplayName, "test", nil
+}
+func (m *mockPlugin) PluginInfo(_ context.Context) (*dbplugin.BuildInfo, error) {
+	return &dbplugin.BuildInfo{
+		Version:   "v0.1.0",
+		Revision:  "abc123",
+		GoVersion: "go1.10",
+	}, nil
+}
 
 func getCluster(t *testing.T) (*vault.TestCluster, logical.SystemView) {
 	cluster := vault.NewTestCluster(t, nil, &vault.TestClusterOptions{
@@ -265,10 +290,100 @@ func TestPlugin_RevokeUser(t *testing.T) {
 	}
 
 	// Try adding the same username back so we can verify it was removed
-	_, _, err = db.CreateUser(context.Background(), dbplugin.Statements{}, usernameConf, time.Now().Add(time.Minute))
+	us, _, err = db.CreateUser(context.Background(), dbplugin.Statements{}, usernameConf, time.Now().Add(time.Minute))
 	if err != nil {
 		t.Fatalf("err: %s", err)
 	}
+
+	// Revocation steps reach the plugin with their flags
+	failing := dbplugin.Statements{
+		RevocationSteps: []*dbplugin.RevocationStep{{Statement: "fail"}},
+	}
+	if err := db.RevokeUser(context.Background(), failing, us); err == nil {
+		t.Fatal("expected the failing step to stop the revocation")
+	}
+	failing.RevocationSteps[0].ContinueOnError = true
+	if err := db.RevokeUser(context.Background(), failing, us); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+}
+
+func TestPlugin_Capabilities(t *testing.T) {
+	cluster, sys := getCluster(t)
+	defer cluster.Cleanup()
+
+	db, err := dbplugin.PluginFactory(context.Background(), "test-plugin", sys, &log.NullLogger{})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer db.Close()
+
+	caps, err := dbplugin.Capabilities(context.Background(), db)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := []string{dbplugin.CapabilityCreateUser, dbplugin.CapabilityRevokeUser}
+	if !reflect.DeepEqual(caps, expected) {
+		t.Fatalf("expected %v, got %v", expected, caps)
+	}
+}
+
+func TestPlugin_GenerateCredentials(t *testing.T) {
+	cluster, sys := getCluster(t)
+	defer cluster.Cleanup()
+
+	db, err := dbplugin.PluginFactory(context.Background(), "test-plugin", sys, &log.NullLogger{})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer db.Close()
+
+	usernameConf := dbplugin.UsernameConfig{
+		DisplayName:    "test",
+		RoleName:       "test",
+		UsernamePrefix: "pre-",
+	}
+	username, password, err := dbplugin.GenerateCredentials(context.Background(), db, usernameConf)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if username != "pre-test" || password != "test" {
+		t.Fatalf("unexpected credentials: %s, %s", username, password)
+	}
+
+	// Nothing was created
+	if err := db.RevokeUser(context.Background(), dbplugin.Statements{}, username); err == nil {
+		t.Fatal("expected an error revoking a user that was never created")
+	}
+
+	if _, _, err := dbplugin.GenerateCredentials(context.Background(), db, dbplugin.UsernameConfig{}); err == nil {
+		t.Fatal("expected an error")
+	}
+}
+
+func TestPlugin_PluginInfo(t *testing.T) {
+	cluster, sys := getCluster(t)
+	defer cluster.Cleanup()
+
+	db, err := dbplugin.PluginFactory(context.Background(), "test-plugin", sys, &log.NullLogger{})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer db.Close()
+
+	info, err := dbplugin.PluginInfo(context.Background(), db)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	expected := &dbplugin.BuildInfo{
+		Version:   "v0.1.0",
+		Revision:  "abc123",
+		GoVersion: "go1.10",
+	}
+	if !reflect.DeepEqual(info, expected) {
+		t.Fatalf("expected %v, got %v", expected, info)
+	}
 }
 
 // Test the code is still compatible with an old netRPC plugin
@@ -405,8 +520,89 @@ func TestPlugin_NetRPC_RevokeUser(t *testing.T) {
 	}
 
 	// Try adding the same username back so we can verify it was removed
-	_, _, err = db.CreateUser(context.Background(), dbplugin.Statements{}, usernameConf, time.Now().Add(time.Minute))
+	us, _, err = db.CreateUser(context.Background(), dbplugin.Statements{}, usernameConf, time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// Revocation steps reach the plugin with their flags
+	failing := dbplugin.Statements{
+		RevocationSteps: []*dbplugin.RevocationStep{{Statement: "fail"}},
+	}
+	if err := db.RevokeUser(context.Background(), failing, us); err == nil {
+		t.Fatal("expected the failing step to stop the revocation")
+	}
+	failing.RevocationSteps[0].ContinueOnError = true
+	if err := db.RevokeUser(context.Background(), failing, us); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+}
+
+func TestPlugin_NetRPC_Capabilities(t *testing.T) {
+	cluster, sys := getCluster(t)
+	defer cluster.Cleanup()
+
+	db, err := dbplugin.PluginFactory(context.Background(), "test-plugin-netRPC", sys, &log.NullLogger{})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer db.Close()
+
+	caps, err := dbplugin.Capabilities(context.Background(), db)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := []string{dbplugin.CapabilityCreateUser, dbplugin.CapabilityRevokeUser}
+	if !reflect.DeepEqual(caps, expected) {
+		t.Fatalf("expected %v, got %v", expected, caps)
+	}
+}
+
+func TestPlugin_NetRPC_GenerateCredentials(t *testing.T) {
+	cluster, sys := getCluster(t)
+	defer cluster.Cleanup()
+
+	db, err := dbplugin.PluginFactory(context.Background(), "test-plugin-netRPC", sys, &log.NullLogger{})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer db.Close()
+
+	usernameConf := dbplugin.UsernameConfig{
+		DisplayName:    "test",
+		RoleName:       "test",
+		UsernamePrefix: "pre-",
+	}
+	username, password, err := dbplugin.GenerateCredentials(context.Background(), db, usernameConf)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if username != "pre-test" || password != "test" {
+		t.Fatalf("unexpected credentials: %s, %s", username, password)
+	}
+}
+
+func TestPlugin_NetRPC_PluginInfo(t *testing.T) {
+	cluster, sys := getCluster(t)
+	defer cluster.Cleanup()
+
+	db, err := dbplugin.PluginFactory(context.Background(), "test-plugin-netRPC", sys, &log.NullLogger{})
 	if err != nil {
 		t.Fatalf("err: %s", err)
 	}
+	defer db.Close()
+
+	info, err := dbplugin.PluginInfo(context.Background(), db)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	expected := &dbplugin.BuildInfo{
+		Version:   "v0.1.0",
+		Revision:  "abc123",
+		GoVersion: "go1.10",
+	}
+	if !reflect.DeepEqual(info, expected) {
+		t.Fatalf("expected %v, got %v", expected, info)
+	}
 }
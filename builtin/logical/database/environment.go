@@ -0,0 +1,44 @@
+package database
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// environmentRe matches valid environment tags. They are kept to lowercase
+// letters and digits so that they are valid in the usernames of every
+// database type, and short so that they leave room for the rest of the
+// username.
+var environmentRe = regexp.MustCompile(`^[a-z0-9]{1,12}$`)
+
+// parseAllowedEnvironments parses the "allowed_environments" mount option, a
+// comma separated list of the environment tags connections may be given. If
+// it's empty any valid tag is allowed.
+func parseAllowedEnvironments(raw string) (map[string]bool, error) {
+	if raw == "" {
+		return nil, nil
+	}
+
+	environments := make(map[string]bool)
+	for _, env := range strings.Split(raw, ",") {
+		env = strings.TrimSpace(env)
+		if !environmentRe.MatchString(env) {
+			return nil, fmt.Errorf("invalid allowed_environments %q: %q is not a valid environment", raw, env)
+		}
+		environments[env] = true
+	}
+	return environments, nil
+}
+
+// validateEnvironment checks that env is a valid environment tag allowed on
+// the mount.
+func (b *databaseBackend) validateEnvironment(env string) error {
+	if !environmentRe.MatchString(env) {
+		return fmt.Errorf("environment %q must be 1 to 12 lowercase letters or digits", env)
+	}
+	if b.allowedEnvironments != nil && !b.allowedEnvironments[env] {
+		return fmt.Errorf("environment %q is not one of the allowed_environments of the mount", env)
+	}
+	return nil
+}
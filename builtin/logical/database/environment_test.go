@@ -0,0 +1,112 @@
+package database
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/vault/logical"
+)
+
+func TestBackend_connectionEnvironmentMount(t *testing.T) {
+	cluster, _ := testMountCluster(t)
+	defer cluster.Cleanup()
+	client := cluster.Cores[0].Client
+
+	if err := testMountOptions(t, cluster, "bad", map[string]string{"allowed_environments": "prod,"}); err == nil {
+		t.Fatal("expected error for invalid allowed_environments")
+	}
+
+	if err := testMountOptions(t, cluster, "db", map[string]string{"allowed_environments": "prod,staging"}); err != nil {
+		t.Fatal(err)
+	}
+
+	data := map[string]interface{}{
+		"connection_url":    "postgresql://localhost:1/db?sslmode=disable",
+		"plugin_name":       "postgresql-database-plugin",
+		"verify_connection": false,
+		"environment":       "dev",
+	}
+	if _, err := client.Logical().Write("db/config/plugin-test", data); err == nil {
+		t.Fatal("expected error for an environment that isn't allowed")
+	}
+
+	data["environment"] = "prod"
+	if _, err := client.Logical().Write("db/config/plugin-test", data); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBackend_connectionEnvironment(t *testing.T) {
+	for _, raw := range []string{"prod,", "prod,Staging", "production-eu"} {
+		if _, err := parseAllowedEnvironments(raw); err == nil {
+			t.Fatalf("expected error for %q", raw)
+		}
+	}
+
+	config := logical.TestBackendConfig()
+	config.StorageView = &logical.InmemStorage{}
+	config.Config = map[string]string{"allowed_environments": "prod, staging"}
+	raw, err := Factory(context.Background(), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := raw.(*databaseBackend)
+
+	for _, data := range []map[string]interface{}{
+		{"environment": "dev"},
+		{"environment": "Prod"},
+		{"environment_prefix": true},
+	} {
+		data["plugin_name"] = "fake"
+		data["verify_connection"] = false
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "config/fake",
+			Storage:   config.StorageView,
+			Data:      data,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp == nil || !resp.IsError() {
+			t.Fatalf("expected error for %#v, got: %#v", data, resp)
+		}
+	}
+
+	// The environment is added to the display name, or starts the username
+	role := &roleEntry{UsernamePrefix: "app-"}
+	usernameConfig := role.usernameConfig("token", "readonly", &DatabaseConfig{Environment: "prod"})
+	if usernameConfig.DisplayName != "prod-token" || usernameConfig.UsernamePrefix != "app-" {
+		t.Fatalf("bad username config: %#v", usernameConfig)
+	}
+
+	dbi, err := newDBPluginInstance(context.Background(), &fakeDatabase{generateCredentials: fakeGenerateCredentials}, &DatabaseConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	testFakeConnection(t, b, config.StorageView, dbi, role)
+	entry, err := logical.StorageEntryJSON("config/fake", &DatabaseConfig{
+		PluginName:        "fake",
+		AllowedRoles:      []string{"*"},
+		Environment:       "prod",
+		EnvironmentPrefix: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := config.StorageView.Put(context.Background(), entry); err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "creds/readonly/preview",
+		Storage:   config.StorageView,
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%s resp:%#v\n", err, resp)
+	}
+	if resp.Data["username"] != "prod-app-readonly" {
+		t.Fatalf("expected the environment to start the username, got %q", resp.Data["username"])
+	}
+}
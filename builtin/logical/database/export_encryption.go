@@ -0,0 +1,182 @@
+package database
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"golang.org/x/crypto/hkdf"
+	"golang.org/x/crypto/pbkdf2"
+)
+
+const (
+	// exportBlobVersion is the version of the encrypted export format.
+	exportBlobVersion = 1
+
+	// exportKDFKey and exportKDFPassphrase identify how the encryption key
+	// of a blob is derived: from a 256-bit key with HKDF-SHA256, or from a
+	// passphrase with PBKDF2-HMAC-SHA256.
+	exportKDFKey        = 1
+	exportKDFPassphrase = 2
+
+	// exportPBKDF2Iterations is the number of PBKDF2 iterations used for new
+	// blobs. Blobs with more than exportMaxPBKDF2Iterations are rejected so a
+	// crafted blob can't tie up the server.
+	exportPBKDF2Iterations    = 600000
+	exportMaxPBKDF2Iterations = 10000000
+
+	// exportMinPassphraseLength is the minimum length of a passphrase.
+	exportMinPassphraseLength = 12
+
+	exportSaltSize   = 16
+	exportHeaderSize = 1 + 1 + 4 + exportSaltSize
+)
+
+var errExportDecrypt = errors.New("unable to decrypt blob: wrong key or passphrase, or the blob was modified")
+
+// exportSecret is the secret an encrypted export is protected with: either a
+// 256-bit key, such as the plaintext of a data key generated by the transit
+// secrets engine, or a passphrase.
+type exportSecret struct {
+	key        []byte
+	passphrase string
+}
+
+// parseExportSecret returns the secret given by exactly one of the base64
+// encoded key and the passphrase.
+func parseExportSecret(key, passphrase string) (*exportSecret, error) {
+	switch {
+	case key != "" && passphrase != "":
+		return nil, fmt.Errorf("only one of key and passphrase can be given")
+	case key != "":
+		raw, err := base64.StdEncoding.DecodeString(key)
+		if err != nil {
+			return nil, fmt.Errorf("key must be base64 encoded: %s", err)
+		}
+		if len(raw) != 32 {
+			return nil, fmt.Errorf("key must be 256 bits, got %d", len(raw)*8)
+		}
+		return &exportSecret{key: raw}, nil
+	case passphrase != "":
+		if len(passphrase) < exportMinPassphraseLength {
+			return nil, fmt.Errorf("passphrase must be at least %d characters", exportMinPassphraseLength)
+		}
+		return &exportSecret{passphrase: passphrase}, nil
+	}
+
+	return nil, fmt.Errorf("one of key or passphrase is required")
+}
+
+// encryptExport seals plaintext with AES-256-GCM under a key derived from
+// secret with a fresh random salt. The blob is the base64 encoding of
+//
+//	version (1) | kdf (1) | iterations (4) | salt (16) | nonce (12) | ciphertext
+//
+// where the header before the nonce is authenticated as additional data, so
+// neither the ciphertext nor the key derivation parameters can be altered.
+func encryptExport(secret *exportSecret, plaintext []byte) (string, error) {
+	header := make([]byte, exportHeaderSize)
+	header[0] = exportBlobVersion
+	if secret.passphrase != "" {
+		header[1] = exportKDFPassphrase
+		binary.BigEndian.PutUint32(header[2:6], exportPBKDF2Iterations)
+	} else {
+		header[1] = exportKDFKey
+	}
+	if _, err := io.ReadFull(rand.Reader, header[6:]); err != nil {
+		return "", err
+	}
+
+	gcm, err := exportCipher(secret, header)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+
+	blob := make([]byte, 0, len(header)+len(nonce)+len(plaintext)+gcm.Overhead())
+	blob = append(blob, header...)
+	blob = append(blob, nonce...)
+	blob = gcm.Seal(blob, nonce, plaintext, header)
+	return base64.StdEncoding.EncodeToString(blob), nil
+}
+
+// decryptExport opens a blob created by encryptExport.
+func decryptExport(secret *exportSecret, encoded string) ([]byte, error) {
+	blob, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("blob must be base64 encoded: %s", err)
+	}
+	if len(blob) < exportHeaderSize {
+		return nil, fmt.Errorf("blob is truncated")
+	}
+
+	header := blob[:exportHeaderSize]
+	if header[0] != exportBlobVersion {
+		return nil, fmt.Errorf("unsupported blob version %d", header[0])
+	}
+	switch header[1] {
+	case exportKDFKey:
+		if secret.key == nil {
+			return nil, fmt.Errorf("blob was encrypted with a key, not a passphrase")
+		}
+	case exportKDFPassphrase:
+		if secret.passphrase == "" {
+			return nil, fmt.Errorf("blob was encrypted with a passphrase, not a key")
+		}
+	default:
+		return nil, fmt.Errorf("unsupported blob key derivation %d", header[1])
+	}
+
+	gcm, err := exportCipher(secret, header)
+	if err != nil {
+		return nil, err
+	}
+
+	rest := blob[exportHeaderSize:]
+	if len(rest) < gcm.NonceSize()+gcm.Overhead() {
+		return nil, fmt.Errorf("blob is truncated")
+	}
+
+	plaintext, err := gcm.Open(nil, rest[:gcm.NonceSize()], rest[gcm.NonceSize():], header)
+	if err != nil {
+		return nil, errExportDecrypt
+	}
+	return plaintext, nil
+}
+
+// exportCipher returns the AES-256-GCM cipher keyed with the key derived
+// from secret using the parameters in header.
+func exportCipher(secret *exportSecret, header []byte) (cipher.AEAD, error) {
+	salt := header[6:exportHeaderSize]
+
+	var key []byte
+	switch header[1] {
+	case exportKDFKey:
+		key = make([]byte, 32)
+		if _, err := io.ReadFull(hkdf.New(sha256.New, secret.key, salt, []byte("vault database export")), key); err != nil {
+			return nil, err
+		}
+	case exportKDFPassphrase:
+		iterations := binary.BigEndian.Uint32(header[2:6])
+		if iterations == 0 || iterations > exportMaxPBKDF2Iterations {
+			return nil, fmt.Errorf("unsupported number of PBKDF2 iterations %d", iterations)
+		}
+		key = pbkdf2.Key([]byte(secret.passphrase), salt, int(iterations), 32, sha256.New)
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
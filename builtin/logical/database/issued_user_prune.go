@@ -0,0 +1,99 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/vault/helper/locksutil"
+	"github.com/hashicorp/vault/helper/parseutil"
+	"github.com/hashicorp/vault/logical"
+)
+
+// defaultIssuedUserRetention is how long past the end of its lease an issued
+// user's index entry is kept before it's pruned, unless the
+// issued_user_retention mount option says otherwise.
+const defaultIssuedUserRetention = 24 * time.Hour
+
+// parseIssuedUserRetention parses the "issued_user_retention" mount option.
+// Zero disables pruning; otherwise the retention can't be shorter than the
+// time it takes for entries to be considered stale, since their leases may
+// still be revoked until then.
+func parseIssuedUserRetention(raw string) (time.Duration, error) {
+	if raw == "" {
+		return defaultIssuedUserRetention, nil
+	}
+
+	retention, err := parseutil.ParseDurationSecond(raw)
+	if err != nil || retention < 0 || (retention > 0 && retention < issuedUserStaleAfter) {
+		return 0, fmt.Errorf("invalid issued_user_retention %q: must be 0 or a duration of at least %s", raw, issuedUserStaleAfter)
+	}
+	return retention, nil
+}
+
+// issuedUserLock returns the lock guarding the index entry of username. It's
+// held while the entry is changed along with the user in the database, so
+// that revocations, renewals and pruning don't undo each other's changes. It
+// must be taken before the backend's lock, if both are held.
+func (b *databaseBackend) issuedUserLock(username string) *locksutil.LockEntry {
+	return locksutil.LockForKey(b.issuedUserLocks, username)
+}
+
+// issuedUserExpired reports whether user's lease ended more than the
+// retention before now. Entries without a recorded end are never pruned.
+func (b *databaseBackend) issuedUserExpired(user *issuedUser, now time.Time) bool {
+	if b.issuedUserRetention <= 0 || user.ExpiresAt.IsZero() {
+		return false
+	}
+	return now.After(user.ExpiresAt.Add(b.issuedUserRetention))
+}
+
+// pruneIssuedUsers removes the index entries of users whose lease ended more
+// than the issued_user_retention ago. Their leases are gone without having
+// removed them, for example because they were forcibly revoked.
+func (b *databaseBackend) pruneIssuedUsers(ctx context.Context, s logical.Storage) error {
+	if b.issuedUserRetention <= 0 {
+		return nil
+	}
+
+	users, err := listIssuedUsers(ctx, s)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for _, user := range users {
+		if !b.issuedUserExpired(user, now) {
+			continue
+		}
+		if err := b.pruneIssuedUser(ctx, s, user.Username); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// pruneIssuedUser removes the index entry of username if it's still expired.
+// The lease may have been renewed or revoked since the index was listed, so
+// the entry is read again under its lock before it's removed.
+func (b *databaseBackend) pruneIssuedUser(ctx context.Context, s logical.Storage, username string) error {
+	lock := b.issuedUserLock(username)
+	lock.Lock()
+	defer lock.Unlock()
+
+	user, err := getIssuedUser(ctx, s, username)
+	if err != nil || user == nil || !b.issuedUserExpired(user, time.Now()) {
+		return err
+	}
+
+	// Users revoked through revoke-users are gone from the database, but the
+	// others may remain
+	if user.Revoked {
+		b.logger.Debug("database: pruning index entry of revoked user", "username", username, "name", user.Connection)
+	} else {
+		b.logger.Warn("database: pruning index entry of user whose lease ended without revoking it", "username", username, "role", user.Role, "name", user.Connection, "expired", user.ExpiresAt.Format(time.RFC3339))
+	}
+
+	return deleteIssuedUser(ctx, s, username)
+}
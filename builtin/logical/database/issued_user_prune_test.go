@@ -0,0 +1,123 @@
+package database
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/logical"
+)
+
+func TestBackend_pruneIssuedUsersMount(t *testing.T) {
+	cluster, mounted := testMountCluster(t)
+	defer cluster.Cleanup()
+
+	if err := testMountOptions(t, cluster, "bad", map[string]string{"issued_user_retention": "30m"}); err == nil {
+		t.Fatal("expected error for invalid issued_user_retention")
+	}
+
+	if err := testMountOptions(t, cluster, "db", map[string]string{"issued_user_retention": "2h"}); err != nil {
+		t.Fatal(err)
+	}
+	b, storage := mounted()
+
+	for _, user := range []*issuedUser{
+		{Username: "crashed", Role: "readonly", Connection: "fake", ExpiresAt: time.Now().Add(-3 * time.Hour)},
+		{Username: "stale", Role: "readonly", Connection: "fake", ExpiresAt: time.Now().Add(-90 * time.Minute)},
+	} {
+		if err := putIssuedUser(context.Background(), storage, user); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := b.periodicFunc(context.Background(), &logical.Request{Storage: storage}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Only the user past the retention is pruned from the index
+	resp, err := cluster.Cores[0].Client.Logical().Read("db/roles/readonly/users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale, ok := resp.Data["stale"].([]interface{})
+	if !ok || len(stale) != 1 || stale[0].(map[string]interface{})["username"] != "stale" {
+		t.Fatalf("bad stale users after pruning: %#v", resp.Data["stale"])
+	}
+}
+
+func TestBackend_pruneIssuedUsers(t *testing.T) {
+	config := logical.TestBackendConfig()
+	config.StorageView = &logical.InmemStorage{}
+
+	for _, raw := range []string{"-1h", "30m", "soon"} {
+		config.Config = map[string]string{"issued_user_retention": raw}
+		if _, err := Factory(context.Background(), config); err == nil {
+			t.Fatalf("expected error for issued_user_retention %q", raw)
+		}
+	}
+
+	config.Config = map[string]string{"issued_user_retention": "2h"}
+	raw, err := Factory(context.Background(), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := raw.(*databaseBackend)
+
+	for _, user := range []*issuedUser{
+		{Username: "crashed", Role: "readonly", Connection: "fake", ExpiresAt: time.Now().Add(-3 * time.Hour)},
+		{Username: "revoked", Role: "readonly", Connection: "fake", ExpiresAt: time.Now().Add(-3 * time.Hour), Revoked: true},
+		// Stale, but still within the retention
+		{Username: "stale", Role: "readonly", Connection: "fake", ExpiresAt: time.Now().Add(-90 * time.Minute)},
+		{Username: "live", Role: "readonly", Connection: "fake", ExpiresAt: time.Now().Add(time.Hour)},
+		// Entries without an expiration are never pruned
+		{Username: "legacy", Role: "readonly", Connection: "fake"},
+	} {
+		if err := putIssuedUser(context.Background(), config.StorageView, user); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := b.periodicFunc(context.Background(), &logical.Request{Storage: config.StorageView}); err != nil {
+		t.Fatal(err)
+	}
+
+	users, err := listIssuedUsers(context.Background(), config.StorageView)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var usernames []string
+	for _, user := range users {
+		usernames = append(usernames, user.Username)
+	}
+	if !reflect.DeepEqual(usernames, []string{"legacy", "live", "stale"}) {
+		t.Fatalf("bad index after pruning: %v", usernames)
+	}
+
+	// An entry renewed after the index was listed is kept
+	if err := putIssuedUser(context.Background(), config.StorageView, &issuedUser{Username: "renewed", ExpiresAt: time.Now().Add(time.Hour)}); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.pruneIssuedUser(context.Background(), config.StorageView, "renewed"); err != nil {
+		t.Fatal(err)
+	}
+	if user, err := getIssuedUser(context.Background(), config.StorageView, "renewed"); err != nil || user == nil {
+		t.Fatalf("expected renewed entry to be kept, got %#v, err: %v", user, err)
+	}
+
+	// An entry revoked after the index was listed is already gone
+	if err := b.pruneIssuedUser(context.Background(), config.StorageView, "missing"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Pruning can be disabled
+	b.issuedUserRetention = 0
+	if err := putIssuedUser(context.Background(), config.StorageView, &issuedUser{Username: "crashed", ExpiresAt: time.Now().Add(-48 * time.Hour)}); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.periodicFunc(context.Background(), &logical.Request{Storage: config.StorageView}); err != nil {
+		t.Fatal(err)
+	}
+	if user, err := getIssuedUser(context.Background(), config.StorageView, "crashed"); err != nil || user == nil {
+		t.Fatalf("expected entry to be kept with pruning disabled, got %#v, err: %v", user, err)
+	}
+}
@@ -0,0 +1,86 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/vault/logical"
+)
+
+// Values of the "name_case" mount option.
+const (
+	nameCaseSensitive = "sensitive"
+	nameCaseLower     = "lower"
+)
+
+// Values of the "name_collisions" mount option.
+const (
+	nameCollisionsReject = "reject"
+	nameCollisionsMerge  = "merge"
+)
+
+// parseNameCase parses the "name_case" and "name_collisions" mount options.
+// With name_case set to "lower", connection and role names are lowercased
+// before they are used, and name_collisions decides what happens to existing
+// entries whose names differ from a newly written one only by case.
+func parseNameCase(nameCase, collisions string) (string, string, error) {
+	switch nameCase {
+	case "":
+		nameCase = nameCaseSensitive
+	case nameCaseSensitive, nameCaseLower:
+	default:
+		return "", "", fmt.Errorf("invalid name_case %q: must be %q or %q", nameCase, nameCaseSensitive, nameCaseLower)
+	}
+
+	switch collisions {
+	case "":
+		collisions = nameCollisionsReject
+	case nameCollisionsReject, nameCollisionsMerge:
+	default:
+		return "", "", fmt.Errorf("invalid name_collisions %q: must be %q or %q", collisions, nameCollisionsReject, nameCollisionsMerge)
+	}
+
+	return nameCase, collisions, nil
+}
+
+// canonicalName returns the name under which a connection or role is stored
+// and cached.
+func (b *databaseBackend) canonicalName(name string) string {
+	if b.nameCase == nameCaseLower {
+		return strings.ToLower(name)
+	}
+	return name
+}
+
+// caseCollisions returns the entries under prefix whose names differ from name
+// only by case. They can only exist if they were written before names were
+// normalized.
+func (b *databaseBackend) caseCollisions(ctx context.Context, s logical.Storage, prefix, name string) ([]string, error) {
+	if b.nameCase == nameCaseSensitive {
+		return nil, nil
+	}
+
+	names, err := s.List(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	var collisions []string
+	for _, existing := range names {
+		if existing != name && b.canonicalName(existing) == name {
+			collisions = append(collisions, existing)
+		}
+	}
+	return collisions, nil
+}
+
+// collisionError returns the error reported when writing name is rejected
+// because of collisions, or nil if the write may go ahead.
+func (b *databaseBackend) collisionError(kind, name string, collisions []string) error {
+	if len(collisions) == 0 || b.nameCollisions == nameCollisionsMerge {
+		return nil
+	}
+
+	return fmt.Errorf("%s %q collides with existing %s %s that differ only by case; delete them or set the name_collisions mount option to %q", kind, name, kind, strings.Join(collisions, ", "), nameCollisionsMerge)
+}
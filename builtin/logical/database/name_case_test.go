@@ -0,0 +1,151 @@
+package database
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/vault/logical"
+)
+
+func TestBackend_nameCaseMount(t *testing.T) {
+	cluster, _ := testMountCluster(t)
+	defer cluster.Cleanup()
+	client := cluster.Cores[0].Client
+
+	for _, options := range []map[string]string{
+		{"name_case": "upper"},
+		{"name_case": "lower", "name_collisions": "ignore"},
+	} {
+		if err := testMountOptions(t, cluster, "bad", options); err == nil {
+			t.Fatalf("expected error mounting with options %v", options)
+		}
+	}
+
+	if err := testMountOptions(t, cluster, "db", map[string]string{"name_case": "lower", "name_collisions": "merge"}); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := client.Logical().Write("db/roles/MyRole", map[string]interface{}{
+		"db_name":             "MyDB",
+		"creation_statements": testRole,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := client.Logical().Read("db/roles/myrole")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp == nil {
+		t.Fatal("expected the role to be stored under its lowercased name")
+	}
+	if dbName := resp.Data["db_name"]; dbName != "mydb" {
+		t.Fatalf("expected db_name to be lowercased, got %v", dbName)
+	}
+}
+
+func TestBackend_nameCase(t *testing.T) {
+	config := logical.TestBackendConfig()
+	config.StorageView = &logical.InmemStorage{}
+
+	for _, opts := range []map[string]string{
+		{"name_case": "upper"},
+		{"name_collisions": "ignore"},
+	} {
+		config.Config = opts
+		if _, err := Factory(context.Background(), config); err == nil {
+			t.Fatalf("expected error for %v", opts)
+		}
+	}
+
+	// Names are case sensitive by default
+	config.Config = map[string]string{}
+	raw, err := Factory(context.Background(), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := raw.(*databaseBackend)
+	if b.canonicalName("MyDB") != "MyDB" {
+		t.Fatalf("expected names to be unchanged, got %q", b.canonicalName("MyDB"))
+	}
+
+	// Legacy entries written before normalization was enabled
+	for _, key := range []string{"config/MyDB", "config/mydb", "config/other", "role/MyRole"} {
+		if err := config.StorageView.Put(context.Background(), &logical.StorageEntry{Key: key, Value: []byte(`{"db_name":"MyDB"}`)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	collisions, err := b.caseCollisions(context.Background(), config.StorageView, "config/", "mydb")
+	if err != nil || collisions != nil {
+		t.Fatalf("expected no collisions when case sensitive, got %v, %v", collisions, err)
+	}
+
+	config.Config = map[string]string{"name_case": "lower"}
+	raw, err = Factory(context.Background(), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b = raw.(*databaseBackend)
+
+	collisions, err = b.caseCollisions(context.Background(), config.StorageView, "config/", b.canonicalName("MYDB"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(collisions, []string{"MyDB"}) {
+		t.Fatalf("expected collision with MyDB, got %v", collisions)
+	}
+	if b.collisionError("connection", "mydb", collisions) == nil {
+		t.Fatal("expected collisions to be rejected")
+	}
+	if b.collisionError("connection", "mydb", nil) != nil {
+		t.Fatal("expected no error without collisions")
+	}
+
+	roleReq := &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "roles/MYROLE",
+		Storage:   config.StorageView,
+		Data: map[string]interface{}{
+			"db_name":             "MyDB",
+			"creation_statements": "CREATE USER",
+		},
+	}
+	resp, err := b.HandleRequest(context.Background(), roleReq)
+	if err != nil || resp == nil || !resp.IsError() {
+		t.Fatalf("expected collision error, got err:%s resp:%#v", err, resp)
+	}
+
+	// Merging replaces the entries that differ only by case
+	config.Config = map[string]string{"name_case": "lower", "name_collisions": "merge"}
+	raw, err = Factory(context.Background(), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b = raw.(*databaseBackend)
+
+	resp, err = b.HandleRequest(context.Background(), roleReq)
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%s resp:%#v\n", err, resp)
+	}
+	roles, err := config.StorageView.List(context.Background(), "role/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(roles, []string{"myrole"}) {
+		t.Fatalf("expected only the canonical role, got %v", roles)
+	}
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "roles/MyRole",
+		Storage:   config.StorageView,
+	})
+	if err != nil || resp == nil || resp.IsError() {
+		t.Fatalf("err:%s resp:%#v\n", err, resp)
+	}
+	if resp.Data["db_name"] != "mydb" {
+		t.Fatalf("expected canonical db_name, got %q", resp.Data["db_name"])
+	}
+}
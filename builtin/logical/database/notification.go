@@ -0,0 +1,124 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/hashicorp/vault/helper/redactutil"
+)
+
+// credentialEventIssued is the type of the event sent when a credential is
+// created for a role.
+const credentialEventIssued = "issued"
+
+// maxPendingNotifications caps the number of notifications being delivered at
+// once. Past it, new notifications are dropped rather than queued.
+const maxPendingNotifications = 64
+
+// notificationTimeout bounds how long a single notification may take to be
+// delivered.
+var notificationTimeout = 10 * time.Second
+
+// credentialEvent describes a credential that was issued. It identifies the
+// credential but never holds the secret itself.
+type credentialEvent struct {
+	Type       string    `json:"type"`
+	Connection string    `json:"connection"`
+	Role       string    `json:"role"`
+	Username   string    `json:"username"`
+	Timestamp  time.Time `json:"timestamp"`
+}
+
+// notificationSink receives credential events. Delivery is best-effort: errors
+// are logged and the event is not retried.
+type notificationSink interface {
+	Notify(context.Context, *credentialEvent) error
+}
+
+// noopSink discards every event. It is used when no sink is configured.
+type noopSink struct{}
+
+func (noopSink) Notify(context.Context, *credentialEvent) error {
+	return nil
+}
+
+// webhookSink posts each event as JSON to a URL.
+type webhookSink struct {
+	url    string
+	client *http.Client
+}
+
+func (s *webhookSink) Notify(ctx context.Context, event *credentialEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("POST", s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %q", resp.Status)
+	}
+	return nil
+}
+
+// parseNotificationWebhook parses the "notification_webhook" mount option, the
+// http or https URL credential events are posted to. Without it events are
+// discarded.
+func parseNotificationWebhook(raw string) (notificationSink, error) {
+	if raw == "" {
+		return noopSink{}, nil
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid notification_webhook %q: must be an http or https URL", redactutil.String(raw))
+	}
+
+	return &webhookSink{
+		url:    raw,
+		client: &http.Client{},
+	}, nil
+}
+
+// notify hands event to the mount's notification sink without waiting for it
+// to be delivered, so that a slow sink can't stall the operation that caused
+// it. The event is dropped if too many notifications are already pending.
+func (b *databaseBackend) notify(event *credentialEvent) {
+	if _, ok := b.notifier.(noopSink); ok {
+		return
+	}
+
+	select {
+	case b.pendingNotifications <- struct{}{}:
+	default:
+		b.logger.Warn("database: dropping credential notification, too many pending", "type", event.Type, "role", event.Role)
+		return
+	}
+
+	go func() {
+		defer func() { <-b.pendingNotifications }()
+
+		ctx, cancel := context.WithTimeout(context.Background(), notificationTimeout)
+		defer cancel()
+
+		if err := b.notifier.Notify(ctx, event); err != nil {
+			b.logger.Warn("database: failed to deliver credential notification", "type", event.Type, "role", event.Role, "error", redactutil.Error(err))
+		}
+	}()
+}
@@ -0,0 +1,187 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/logical"
+)
+
+// fakeSink is a notificationSink that hands each event to a channel, after
+// waiting for unblock if it's set.
+type fakeSink struct {
+	events  chan *credentialEvent
+	unblock chan struct{}
+}
+
+func (f *fakeSink) Notify(ctx context.Context, event *credentialEvent) error {
+	if f.unblock != nil {
+		<-f.unblock
+	}
+	f.events <- event
+	return nil
+}
+
+func TestBackend_notifications(t *testing.T) {
+	config := logical.TestBackendConfig()
+	config.StorageView = &logical.InmemStorage{}
+
+	config.Config = map[string]string{"notification_webhook": "ftp://example.com"}
+	if _, err := Factory(context.Background(), config); err == nil {
+		t.Fatal("expected error for invalid notification_webhook")
+	}
+
+	b := Backend(config)
+	if err := b.Setup(context.Background(), config); err != nil {
+		t.Fatal(err)
+	}
+
+	credsReq := &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "creds/readonly",
+		Storage:   config.StorageView,
+	}
+
+	dbi, err := newDBPluginInstance(context.Background(), &fakeDatabase{}, &DatabaseConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	testFakeConnection(t, b, config.StorageView, dbi, &roleEntry{})
+
+	// Issuing a credential sends an event without the secret
+	sink := &fakeSink{events: make(chan *credentialEvent, 1)}
+	b.notifier = sink
+	resp, err := b.HandleRequest(context.Background(), credsReq)
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%s resp:%#v\n", err, resp)
+	}
+	select {
+	case event := <-sink.events:
+		if event.Type != credentialEventIssued || event.Connection != "fake" || event.Role != "readonly" || event.Username != "user" || event.Timestamp.IsZero() {
+			t.Fatalf("bad event: %#v", event)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected an event")
+	}
+
+	// A slow sink doesn't stall issuance, and events past the pending limit
+	// are dropped
+	sink = &fakeSink{
+		events:  make(chan *credentialEvent, maxPendingNotifications+1),
+		unblock: make(chan struct{}),
+	}
+	b.notifier = sink
+	for i := 0; i < maxPendingNotifications+1; i++ {
+		resp, err := b.HandleRequest(context.Background(), credsReq)
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("err:%s resp:%#v\n", err, resp)
+		}
+	}
+	close(sink.unblock)
+	for i := 0; i < maxPendingNotifications; i++ {
+		select {
+		case <-sink.events:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("expected %d events, got %d", maxPendingNotifications, i)
+		}
+	}
+	select {
+	case event := <-sink.events:
+		t.Fatalf("expected the event past the limit to be dropped, got %#v", event)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestBackend_notificationWebhookMount(t *testing.T) {
+	events := make(chan *credentialEvent, 1)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var event credentialEvent
+		if err := json.NewDecoder(r.Body).Decode(&event); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		events <- &event
+	}))
+	defer ts.Close()
+
+	cluster, mounted := testMountCluster(t)
+	defer cluster.Cleanup()
+
+	if err := testMountOptions(t, cluster, "bad", map[string]string{"notification_webhook": "ftp://example.com"}); err == nil {
+		t.Fatal("expected error for invalid notification_webhook")
+	}
+
+	if err := testMountOptions(t, cluster, "db", map[string]string{"notification_webhook": ts.URL}); err != nil {
+		t.Fatal(err)
+	}
+	b, storage := mounted()
+
+	dbi, err := newDBPluginInstance(context.Background(), &fakeDatabase{}, &DatabaseConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	testFakeConnection(t, b, storage, dbi, &roleEntry{})
+
+	// Credentials issued through the API are sent to the webhook
+	if _, err := cluster.Cores[0].Client.Logical().Read("db/creds/readonly"); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case event := <-events:
+		if event.Type != credentialEventIssued || event.Connection != "fake" || event.Role != "readonly" || event.Username != "user" {
+			t.Fatalf("bad event: %#v", event)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected an event")
+	}
+}
+
+func TestWebhookSink(t *testing.T) {
+	events := make(chan *credentialEvent, 1)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var event credentialEvent
+		if err := json.NewDecoder(r.Body).Decode(&event); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		events <- &event
+		if event.Role == "fail" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer ts.Close()
+
+	sink, err := parseNotificationWebhook(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	event := &credentialEvent{
+		Type:       credentialEventIssued,
+		Connection: "fake",
+		Role:       "readonly",
+		Username:   "user",
+		Timestamp:  time.Now().UTC().Truncate(time.Second),
+	}
+	if err := sink.Notify(context.Background(), event); err != nil {
+		t.Fatal(err)
+	}
+	if received := <-events; !reflect.DeepEqual(received, event) {
+		t.Fatalf("expected %#v, got %#v", event, received)
+	}
+
+	event.Role = "fail"
+	if err := sink.Notify(context.Background(), event); err == nil {
+		t.Fatal("expected error for unsuccessful status")
+	}
+	<-events
+
+	if sink, err := parseNotificationWebhook(""); err != nil || sink != (noopSink{}) {
+		t.Fatalf("expected the no-op sink, got %#v, %v", sink, err)
+	}
+}
@@ -4,11 +4,17 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/fatih/structs"
 	"github.com/hashicorp/vault/builtin/logical/database/dbplugin"
+	"github.com/hashicorp/vault/helper/builtinplugins"
+	"github.com/hashicorp/vault/helper/redactutil"
 	"github.com/hashicorp/vault/logical"
 	"github.com/hashicorp/vault/logical/framework"
+	"github.com/hashicorp/vault/plugins/helper/database/dbutil"
 )
 
 var (
@@ -24,6 +30,61 @@ type DatabaseConfig struct {
 	// by each database type.
 	ConnectionDetails map[string]interface{} `json:"connection_details" structs:"connection_details" mapstructure:"connection_details"`
 	AllowedRoles      []string               `json:"allowed_roles" structs:"allowed_roles" mapstructure:"allowed_roles"`
+	// UsernamePrefix is prepended to every username generated for roles
+	// using this connection, unless the role sets its own prefix.
+	UsernamePrefix string `json:"username_prefix" structs:"username_prefix" mapstructure:"username_prefix"`
+	// Environment tags the usernames generated for roles using this
+	// connection with the environment the database belongs to. If
+	// EnvironmentPrefix is set, it starts every username rather than being
+	// added to the display name.
+	Environment       string `json:"environment" structs:"environment" mapstructure:"environment"`
+	EnvironmentPrefix bool   `json:"environment_prefix" structs:"environment_prefix" mapstructure:"environment_prefix"`
+	// MaxConcurrentCreations limits the number of credential creations that
+	// may be in flight against this connection at once. Zero is unbounded.
+	MaxConcurrentCreations int `json:"max_concurrent_creations" structs:"max_concurrent_creations" mapstructure:"max_concurrent_creations"`
+	// RevocationRetries is the number of times a failed revocation is retried
+	// before it is queued for the periodic sweep.
+	RevocationRetries int `json:"revocation_retries" structs:"revocation_retries" mapstructure:"revocation_retries"`
+	// RecycleErrors overrides the built-in substrings of errors after which
+	// the connection is recycled for the plugin's database type.
+	RecycleErrors []string `json:"recycle_errors" structs:"recycle_errors" mapstructure:"recycle_errors"`
+	// VerificationFreshness is the number of seconds after a successful
+	// verification during which re-establishing the connection skips
+	// verifying it again. Zero always verifies.
+	VerificationFreshness int `json:"verification_freshness" structs:"verification_freshness" mapstructure:"verification_freshness"`
+	// QuarantineThreshold is the number of consecutive failures to establish
+	// the connection after which it is quarantined. Zero never quarantines.
+	QuarantineThreshold int `json:"quarantine_threshold" structs:"quarantine_threshold" mapstructure:"quarantine_threshold"`
+	// QuarantineCooldown is the number of seconds a quarantine lasts.
+	QuarantineCooldown int `json:"quarantine_cooldown" structs:"quarantine_cooldown" mapstructure:"quarantine_cooldown"`
+	// ClientCertificateCA is a PEM bundle with the CA certificate and key
+	// used to sign client certificates for roles with a client_certificate
+	// credential type. It is never read out with its private key.
+	ClientCertificateCA string `json:"client_certificate_ca" structs:"-" mapstructure:"client_certificate_ca"`
+	// ReloadGracePeriod is the number of seconds the previous connection may
+	// keep serving the operations using it after a configuration change.
+	// Zero closes it immediately.
+	ReloadGracePeriod int `json:"reload_grace_period" structs:"reload_grace_period" mapstructure:"reload_grace_period"`
+	// StatementTimeout is the number of seconds a role's creation or renewal
+	// statements may run, unless the role sets its own. Zero is unbounded.
+	StatementTimeout int `json:"statement_timeout" structs:"statement_timeout" mapstructure:"statement_timeout"`
+	// MaxStatementTimeout caps the statement timeout roles may set. Zero is
+	// unbounded.
+	MaxStatementTimeout int `json:"max_statement_timeout" structs:"max_statement_timeout" mapstructure:"max_statement_timeout"`
+	// CredsRateLimit is the number of credentials that may be requested
+	// against this connection per CredsRateLimitInterval seconds. Zero is
+	// unbounded.
+	CredsRateLimit         int `json:"creds_rate_limit" structs:"creds_rate_limit" mapstructure:"creds_rate_limit"`
+	CredsRateLimitInterval int `json:"creds_rate_limit_interval" structs:"creds_rate_limit_interval" mapstructure:"creds_rate_limit_interval"`
+	// StatementVariables are substituted into the statements of every role
+	// using this connection, unless the role sets its own.
+	StatementVariables map[string]string `json:"statement_variables" structs:"statement_variables" mapstructure:"statement_variables"`
+	// LogStatements logs the statements run to issue and revoke credentials
+	// at debug level, with passwords redacted.
+	LogStatements bool `json:"log_statements" structs:"log_statements" mapstructure:"log_statements"`
+	// IssuancePingTimeout is the number of seconds the database has to answer
+	// a ping before credentials are issued against it. Zero doesn't ping.
+	IssuancePingTimeout int `json:"issuance_ping_timeout" structs:"issuance_ping_timeout" mapstructure:"issuance_ping_timeout"`
 }
 
 // pathResetConnection configures a path to reset a plugin.
@@ -50,7 +111,7 @@ func pathResetConnection(b *databaseBackend) *framework.Path {
 // creating a new one.
 func (b *databaseBackend) pathConnectionReset() framework.OperationFunc {
 	return func(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
-		name := data.Get("name").(string)
+		name := b.canonicalName(data.Get("name").(string))
 		if name == "" {
 			return logical.ErrorResponse(respErrEmptyName), nil
 		}
@@ -59,19 +120,88 @@ func (b *databaseBackend) pathConnectionReset() framework.OperationFunc {
 		b.Lock()
 		defer b.Unlock()
 
-		// Close plugin and delete the entry in the connections cache.
+		// Close plugin and delete the entry in the connections cache. A reset
+		// always verifies the new connection and lifts any quarantine.
 		b.clearConnection(name)
+		delete(b.lastVerified, name)
+		delete(b.failures, name)
 
 		// Execute plugin again, we don't need the object so throw away.
-		_, err := b.createDBObj(ctx, req.Storage, name)
+		dbi, err := b.createDBObj(ctx, req.Storage, name)
 		if err != nil {
 			return nil, err
 		}
+		if dbi.uncached {
+			dbi.Close()
+		}
 
 		return nil, nil
 	}
 }
 
+// pathVerifyConnection configures a path to verify a connection on demand.
+func pathVerifyConnection(b *databaseBackend) *framework.Path {
+	return &framework.Path{
+		Pattern: fmt.Sprintf("config/%s/verify$", framework.GenericNameRegex("name")),
+		Fields: map[string]*framework.FieldSchema{
+			"name": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Name of this database connection",
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathConnectionVerify(),
+		},
+
+		HelpSynopsis:    pathVerifyConnectionHelpSyn,
+		HelpDescription: pathVerifyConnectionHelpDesc,
+	}
+}
+
+// pathConnectionVerify connects to the database of a connection, starting its
+// plugin if needed, and pings it. Unlike a reset, a working connection is kept.
+func (b *databaseBackend) pathConnectionVerify() framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+		name := b.canonicalName(data.Get("name").(string))
+		if name == "" {
+			return logical.ErrorResponse(respErrEmptyName), nil
+		}
+
+		entry, err := req.Storage.Get(ctx, fmt.Sprintf("config/%s", name))
+		if err != nil {
+			return nil, errors.New("failed to read connection configuration")
+		}
+		if entry == nil {
+			return logical.ErrorResponse(fmt.Sprintf("no connection named %q", name)), nil
+		}
+
+		dbi, err := b.pluginInstance(ctx, req.Storage, name)
+		if err != nil {
+			return logical.ErrorResponse(fmt.Sprintf("error verifying connection: %s", err)), nil
+		}
+		defer dbi.release()
+
+		// Plugins that can't ping are verified once they have started
+		if dbi.supports(dbplugin.CapabilityPing) == nil {
+			if err := dbplugin.Ping(ctx, dbi.Database); err != nil {
+				b.closeIfShutdown(name, dbi, err)
+				return logical.ErrorResponse(fmt.Sprintf("error verifying connection: %s", redactutil.Error(err))), nil
+			}
+		}
+
+		b.Lock()
+		b.lastVerified[name] = time.Now()
+		b.Unlock()
+
+		return &logical.Response{
+			Data: map[string]interface{}{
+				"verified": true,
+			},
+		}, nil
+	}
+}
+
 // pathConfigurePluginConnection returns a configured framework.Path setup to
 // operate on plugins.
 func pathConfigurePluginConnection(b *databaseBackend) *framework.Path {
@@ -103,6 +233,134 @@ func pathConfigurePluginConnection(b *databaseBackend) *framework.Path {
 				allowed to get creds from this database connection. If empty no
 				roles are allowed. If "*" all roles are allowed.`,
 			},
+
+			"username_prefix": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `A string prepended to every username generated for
+				roles using this connection. Roles may override it with their
+				own username_prefix.`,
+			},
+
+			"environment": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `A tag for the environment of the database, such as
+				"prod" or "staging", included in every username generated for
+				roles using this connection. Must be 1 to 12 lowercase letters or
+				digits, and one of the allowed_environments of the mount if set.`,
+			},
+
+			"environment_prefix": &framework.FieldSchema{
+				Type: framework.TypeBool,
+				Description: `If true, generated usernames start with the
+				environment, ahead of any username_prefix, rather than having it
+				added to the display name, where it may be truncated.`,
+			},
+
+			"max_concurrent_creations": &framework.FieldSchema{
+				Type: framework.TypeInt,
+				Description: `The maximum number of credential creations that may
+				run against this connection at once. Defaults to 0, which is
+				unbounded.`,
+			},
+
+			"revocation_retries": &framework.FieldSchema{
+				Type: framework.TypeInt,
+				Description: `The number of times a failed revocation is retried,
+				with exponential backoff, before it is queued to be retried
+				periodically. Defaults to 0, and can't exceed 10.`,
+			},
+
+			"recycle_errors": &framework.FieldSchema{
+				Type: framework.TypeCommaStringSlice,
+				Description: `Comma separated list of substrings of errors after
+				which the connection is closed and re-established. Replaces the
+				built-in list for the plugin's database type.`,
+			},
+
+			"verification_freshness": &framework.FieldSchema{
+				Type: framework.TypeDurationSecond,
+				Description: `How long after the connection was last verified
+				that re-establishing it, for example after the plugin exits,
+				skips verification. Defaults to 0, which always verifies.`,
+			},
+
+			"quarantine_threshold": &framework.FieldSchema{
+				Type:    framework.TypeInt,
+				Default: 5,
+				Description: `The number of consecutive failures to establish the
+				connection after which it is quarantined, failing requests
+				immediately until the cooldown expires. Set to 0 to never
+				quarantine the connection.`,
+			},
+
+			"quarantine_cooldown": &framework.FieldSchema{
+				Type:        framework.TypeDurationSecond,
+				Default:     60,
+				Description: `How long a quarantine lasts. Defaults to 60 seconds.`,
+			},
+
+			"client_certificate_ca": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `PEM bundle with a CA certificate and its private
+				key, used to sign client certificates for roles with the
+				client_certificate credential type. Setting it declares that
+				the database accepts certificate authentication.`,
+			},
+
+			"reload_grace_period": &framework.FieldSchema{
+				Type: framework.TypeDurationSecond,
+				Description: `How long the previous connection may keep serving
+				the operations using it after the configuration changes, while
+				new requests use a connection with the new configuration.
+				Defaults to 0, which closes it immediately.`,
+			},
+
+			"statement_timeout": &framework.FieldSchema{
+				Type: framework.TypeDurationSecond,
+				Description: `How long a role's creation or renewal statements may
+				run before they are cancelled, unless the role sets its own
+				statement_timeout. Defaults to 0, which is unbounded.`,
+			},
+
+			"max_statement_timeout": &framework.FieldSchema{
+				Type: framework.TypeDurationSecond,
+				Description: `The longest statement_timeout roles using this
+				connection may set. Defaults to 0, which is unbounded.`,
+			},
+
+			"creds_rate_limit": &framework.FieldSchema{
+				Type: framework.TypeInt,
+				Description: `The number of credentials that may be requested
+				against this connection per creds_rate_limit_interval. Requests
+				past it fail until the rate drops. Defaults to 0, which is
+				unbounded.`,
+			},
+
+			"creds_rate_limit_interval": &framework.FieldSchema{
+				Type:        framework.TypeDurationSecond,
+				Default:     defaultCredsRateLimitInterval,
+				Description: `The interval creds_rate_limit applies to. Defaults to 60 seconds.`,
+			},
+
+			"statement_variables": &framework.FieldSchema{
+				Type: framework.TypeKVPairs,
+				Description: `Values substituted into the statements of every
+				role using this connection, written as "{{key}}". Roles may set
+				their own to override them.`,
+			},
+
+			"log_statements": &framework.FieldSchema{
+				Type: framework.TypeBool,
+				Description: `If set, the statements run to issue and revoke
+				credentials are logged at debug level, with passwords redacted.`,
+			},
+
+			"issuance_ping_timeout": &framework.FieldSchema{
+				Type: framework.TypeInt,
+				Description: `If set, the database is pinged before credentials are
+				issued against it, and issuance is refused if it doesn't answer
+				within this many seconds. Defaults to 0, which doesn't ping.`,
+			},
 		},
 
 		Callbacks: map[logical.Operation]framework.OperationFunc{
@@ -116,6 +374,25 @@ func pathConfigurePluginConnection(b *databaseBackend) *framework.Path {
 	}
 }
 
+func pathConfigConnectionEffective(b *databaseBackend) *framework.Path {
+	return &framework.Path{
+		Pattern: fmt.Sprintf("config/%s/effective$", framework.GenericNameRegex("name")),
+		Fields: map[string]*framework.FieldSchema{
+			"name": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Name of this database connection",
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation: b.connectionEffectiveReadHandler(),
+		},
+
+		HelpSynopsis:    pathConfigConnectionEffectiveHelpSyn,
+		HelpDescription: pathConfigConnectionEffectiveHelpDesc,
+	}
+}
+
 func pathListPluginConnection(b *databaseBackend) *framework.Path {
 	return &framework.Path{
 		Pattern: fmt.Sprintf("config/?$"),
@@ -143,7 +420,7 @@ func (b *databaseBackend) connectionListHandler() framework.OperationFunc {
 // connectionReadHandler reads out the connection configuration
 func (b *databaseBackend) connectionReadHandler() framework.OperationFunc {
 	return func(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
-		name := data.Get("name").(string)
+		name := b.canonicalName(data.Get("name").(string))
 		if name == "" {
 			return logical.ErrorResponse(respErrEmptyName), nil
 		}
@@ -160,16 +437,74 @@ func (b *databaseBackend) connectionReadHandler() framework.OperationFunc {
 		if err := entry.DecodeJSON(&config); err != nil {
 			return nil, err
 		}
-		return &logical.Response{
+
+		resp := &logical.Response{
 			Data: structs.New(config).Map(),
-		}, nil
+		}
+		resp.Data["client_certificate_ca"] = clientCertificateCAPEM(config.ClientCertificateCA)
+
+		// Capabilities and the server and plugin versions are only known once
+		// the plugin has been started
+		b.RLock()
+		if dbi, ok := b.connections[name]; ok {
+			resp.Data["plugin_capabilities"] = dbi.capabilities
+			resp.Data["server_version"] = dbi.version(ctx)
+			resp.Data["plugin_version"], resp.Data["plugin_build_info"] = dbi.pluginVersion()
+		}
+		b.RUnlock()
+
+		return resp, nil
+	}
+}
+
+// connectionEffectiveReadHandler reads out the configuration the connection
+// actually uses, after backend defaults and plugin-specific defaults have been
+// applied. The plugin is started if it isn't running.
+func (b *databaseBackend) connectionEffectiveReadHandler() framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+		name := b.canonicalName(data.Get("name").(string))
+		if name == "" {
+			return logical.ErrorResponse(respErrEmptyName), nil
+		}
+
+		entry, err := req.Storage.Get(ctx, fmt.Sprintf("config/%s", name))
+		if err != nil {
+			return nil, errors.New("failed to read connection configuration")
+		}
+		if entry == nil {
+			return nil, nil
+		}
+
+		var config DatabaseConfig
+		if err := entry.DecodeJSON(&config); err != nil {
+			return nil, err
+		}
+
+		dbi, err := b.pluginInstance(ctx, req.Storage, name)
+		if err != nil {
+			return logical.ErrorResponse(fmt.Sprintf("error starting plugin: %s", err)), nil
+		}
+		defer dbi.release()
+
+		resp := &logical.Response{
+			Data: structs.New(config).Map(),
+		}
+		resp.Data["connection_details"] = b.connectionDetails(&config)
+		resp.Data["client_certificate_ca"] = clientCertificateCAPEM(config.ClientCertificateCA)
+		resp.Data["recycle_errors"] = dbi.recycleErrors
+		resp.Data["db_type"] = dbi.dbType
+		resp.Data["plugin_capabilities"] = dbi.capabilities
+		resp.Data["server_version"] = dbi.version(ctx)
+		resp.Data["plugin_version"], resp.Data["plugin_build_info"] = dbi.pluginVersion()
+
+		return resp, nil
 	}
 }
 
 // connectionDeleteHandler deletes the connection configuration
 func (b *databaseBackend) connectionDeleteHandler() framework.OperationFunc {
 	return func(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
-		name := data.Get("name").(string)
+		name := b.canonicalName(data.Get("name").(string))
 		if name == "" {
 			return logical.ErrorResponse(respErrEmptyName), nil
 		}
@@ -178,10 +513,18 @@ func (b *databaseBackend) connectionDeleteHandler() framework.OperationFunc {
 		if err != nil {
 			return nil, errors.New("failed to delete connection configuration")
 		}
+		if err := req.Storage.Delete(ctx, previousConfigPath+name); err != nil {
+			return nil, errors.New("failed to delete previous connection configuration")
+		}
 
 		b.Lock()
 		defer b.Unlock()
 
+		delete(b.lastVerified, name)
+		delete(b.failures, name)
+		b.credsLimitersLock.Lock()
+		delete(b.credsLimiters, name)
+		b.credsLimitersLock.Unlock()
 		if _, ok := b.connections[name]; ok {
 			err = b.connections[name].Close()
 			if err != nil {
@@ -195,6 +538,52 @@ func (b *databaseBackend) connectionDeleteHandler() framework.OperationFunc {
 	}
 }
 
+// updatePoolSettings applies the pool settings in config to the cached
+// connection for name. It returns false if the connection has to be
+// re-established instead, because it isn't cached, the plugin can't update
+// its pool, or settings other than the pool settings changed.
+func (b *databaseBackend) updatePoolSettings(ctx context.Context, s logical.Storage, name string, config *DatabaseConfig) (bool, error) {
+	b.Lock()
+	defer b.Unlock()
+
+	dbi, ok := b.connections[name]
+	if !ok || dbi.supports(dbplugin.CapabilityUpdatePoolSettings) != nil {
+		return false, nil
+	}
+
+	oldConfig, err := b.DatabaseConfig(ctx, s, name)
+	if err != nil || oldConfig.PluginName != config.PluginName {
+		return false, nil
+	}
+
+	// A username prefix the plugin refuses is reported when reconnecting,
+	// leaving the live connection untouched
+	if dbi.checkUsernamePrefix(ctx, connectionUsernamePrefix(config)) != nil {
+		return false, nil
+	}
+
+	err = dbplugin.UpdatePoolSettings(ctx, dbi.Database, b.connectionDetails(config))
+	switch {
+	case err == nil:
+	case strings.Contains(err.Error(), dbplugin.ErrConnectionIdentityChanged.Error()):
+		// Errors lose their identity crossing the plugin boundary
+		return false, nil
+	default:
+		return false, err
+	}
+
+	// Rebuild the instance around the same plugin so settings held by the
+	// backend, such as the creation limit, take effect too. Holding the lock
+	// guarantees no creation is using the old semaphore.
+	updated, err := newDBPluginInstance(ctx, dbi.Database, config)
+	if err != nil {
+		return false, err
+	}
+	b.connections[name] = updated
+
+	return true, nil
+}
+
 // connectionWriteHandler returns a handler function for creating and updating
 // both builtin and plugin database types.
 func (b *databaseBackend) connectionWriteHandler() framework.OperationFunc {
@@ -204,14 +593,111 @@ func (b *databaseBackend) connectionWriteHandler() framework.OperationFunc {
 			return logical.ErrorResponse(respErrEmptyPluginName), nil
 		}
 
-		name := data.Get("name").(string)
+		// Catch unknown plugins now rather than the first time the
+		// connection is used.
+		if _, err := b.System().LookupPlugin(ctx, pluginName); err != nil {
+			return logical.ErrorResponse(fmt.Sprintf("error looking up plugin %q: %s; builtin database plugins are: %s", pluginName, err, strings.Join(builtinDatabasePlugins(), ", "))), nil
+		}
+
+		name := b.canonicalName(data.Get("name").(string))
 		if name == "" {
 			return logical.ErrorResponse(respErrEmptyName), nil
 		}
 
+		collisions, err := b.caseCollisions(ctx, req.Storage, "config/", name)
+		if err != nil {
+			return nil, err
+		}
+		if err := b.collisionError("connection", name, collisions); err != nil {
+			return logical.ErrorResponse(err.Error()), nil
+		}
+
 		verifyConnection := data.Get("verify_connection").(bool)
 
 		allowedRoles := data.Get("allowed_roles").([]string)
+		for i, role := range allowedRoles {
+			allowedRoles[i] = b.canonicalName(role)
+		}
+
+		usernamePrefix := data.Get("username_prefix").(string)
+
+		environment := data.Get("environment").(string)
+		environmentPrefix := data.Get("environment_prefix").(bool)
+		if environment != "" {
+			if err := b.validateEnvironment(environment); err != nil {
+				return logical.ErrorResponse(err.Error()), nil
+			}
+		} else if environmentPrefix {
+			return logical.ErrorResponse("environment_prefix requires an environment"), nil
+		}
+
+		maxConcurrentCreations := data.Get("max_concurrent_creations").(int)
+		if maxConcurrentCreations < 0 {
+			return logical.ErrorResponse("max_concurrent_creations cannot be negative"), nil
+		}
+
+		revocationRetries := data.Get("revocation_retries").(int)
+		if revocationRetries < 0 || revocationRetries > maxRevocationRetries {
+			return logical.ErrorResponse(fmt.Sprintf("revocation_retries must be between 0 and %d", maxRevocationRetries)), nil
+		}
+
+		verificationFreshness := data.Get("verification_freshness").(int)
+		if verificationFreshness < 0 {
+			return logical.ErrorResponse("verification_freshness cannot be negative"), nil
+		}
+
+		quarantineThreshold := data.Get("quarantine_threshold").(int)
+		if quarantineThreshold < 0 {
+			return logical.ErrorResponse("quarantine_threshold cannot be negative"), nil
+		}
+
+		quarantineCooldown := data.Get("quarantine_cooldown").(int)
+		if quarantineCooldown < 0 {
+			return logical.ErrorResponse("quarantine_cooldown cannot be negative"), nil
+		}
+
+		reloadGracePeriod := data.Get("reload_grace_period").(int)
+		if reloadGracePeriod < 0 {
+			return logical.ErrorResponse("reload_grace_period cannot be negative"), nil
+		}
+
+		statementTimeout := data.Get("statement_timeout").(int)
+		if statementTimeout < 0 {
+			return logical.ErrorResponse("statement_timeout cannot be negative"), nil
+		}
+		maxStatementTimeout := data.Get("max_statement_timeout").(int)
+		if maxStatementTimeout < 0 {
+			return logical.ErrorResponse("max_statement_timeout cannot be negative"), nil
+		}
+		if maxStatementTimeout > 0 && statementTimeout > maxStatementTimeout {
+			return logical.ErrorResponse("statement_timeout cannot be greater than max_statement_timeout"), nil
+		}
+
+		credsRateLimit := data.Get("creds_rate_limit").(int)
+		if credsRateLimit < 0 {
+			return logical.ErrorResponse("creds_rate_limit cannot be negative"), nil
+		}
+		credsRateLimitInterval := data.Get("creds_rate_limit_interval").(int)
+		if credsRateLimitInterval <= 0 {
+			return logical.ErrorResponse("creds_rate_limit_interval must be positive"), nil
+		}
+
+		issuancePingTimeout := data.Get("issuance_ping_timeout").(int)
+		if issuancePingTimeout < 0 {
+			return logical.ErrorResponse("issuance_ping_timeout cannot be negative"), nil
+		}
+
+		statementVariables := data.Get("statement_variables").(map[string]string)
+		if err := dbutil.ValidateVariables(statementVariables); err != nil {
+			return logical.ErrorResponse(err.Error()), nil
+		}
+
+		clientCertificateCA := data.Get("client_certificate_ca").(string)
+		if clientCertificateCA != "" {
+			if _, err := parseClientCertificateCA(clientCertificateCA); err != nil {
+				return logical.ErrorResponse(fmt.Sprintf("invalid client_certificate_ca: %s", err)), nil
+			}
+		}
 
 		// Remove these entries from the data before we store it keyed under
 		// ConnectionDetails.
@@ -219,33 +705,117 @@ func (b *databaseBackend) connectionWriteHandler() framework.OperationFunc {
 		delete(data.Raw, "plugin_name")
 		delete(data.Raw, "allowed_roles")
 		delete(data.Raw, "verify_connection")
+		delete(data.Raw, "username_prefix")
+		delete(data.Raw, "environment")
+		delete(data.Raw, "environment_prefix")
+		delete(data.Raw, "max_concurrent_creations")
+		delete(data.Raw, "revocation_retries")
+		delete(data.Raw, "recycle_errors")
+		delete(data.Raw, "verification_freshness")
+		delete(data.Raw, "quarantine_threshold")
+		delete(data.Raw, "quarantine_cooldown")
+		delete(data.Raw, "client_certificate_ca")
+		delete(data.Raw, "reload_grace_period")
+		delete(data.Raw, "statement_timeout")
+		delete(data.Raw, "max_statement_timeout")
+		delete(data.Raw, "creds_rate_limit")
+		delete(data.Raw, "creds_rate_limit_interval")
+		delete(data.Raw, "statement_variables")
+		delete(data.Raw, "log_statements")
+		delete(data.Raw, "issuance_ping_timeout")
 
 		config := &DatabaseConfig{
 			ConnectionDetails: data.Raw,
 			PluginName:        pluginName,
 			AllowedRoles:      allowedRoles,
+			UsernamePrefix:    usernamePrefix,
+			Environment:       environment,
+			EnvironmentPrefix: environmentPrefix,
+
+			MaxConcurrentCreations: maxConcurrentCreations,
+			RevocationRetries:      revocationRetries,
+			VerificationFreshness:  verificationFreshness,
+			QuarantineThreshold:    quarantineThreshold,
+			QuarantineCooldown:     quarantineCooldown,
+			ClientCertificateCA:    clientCertificateCA,
+			ReloadGracePeriod:      reloadGracePeriod,
+			StatementTimeout:       statementTimeout,
+			MaxStatementTimeout:    maxStatementTimeout,
+			CredsRateLimit:         credsRateLimit,
+			CredsRateLimitInterval: credsRateLimitInterval,
+			StatementVariables:     statementVariables,
+			LogStatements:          data.Get("log_statements").(bool),
+			IssuancePingTimeout:    issuancePingTimeout,
+		}
+		if recycleErrors, ok := data.GetOk("recycle_errors"); ok {
+			config.RecycleErrors = recycleErrors.([]string)
 		}
 
-		db, err := dbplugin.PluginFactory(ctx, config.PluginName, b.System(), b.logger)
+		// Edits that only tune the connection pool are applied to the live
+		// connection so in-flight operations aren't interrupted.
+		updated, err := b.updatePoolSettings(ctx, req.Storage, name, config)
 		if err != nil {
-			return logical.ErrorResponse(fmt.Sprintf("error creating database object: %s", err)), nil
+			return logical.ErrorResponse(fmt.Sprintf("error updating pool settings: %s", redactutil.Error(err))), nil
 		}
 
-		err = db.Initialize(ctx, config.ConnectionDetails, verifyConnection)
-		if err != nil {
-			db.Close()
-			return logical.ErrorResponse(fmt.Sprintf("error creating database object: %s", err)), nil
+		var dbi *dbPluginInstance
+		if !updated {
+			incrConnectionCounter("spawn", name)
+			db, err := dbplugin.PluginFactory(ctx, config.PluginName, b.System(), b.logger)
+			if err != nil {
+				return logical.ErrorResponse(fmt.Sprintf("error creating database object: %s", redactutil.Error(err))), nil
+			}
+
+			err = db.Initialize(ctx, b.connectionDetails(config), verifyConnection)
+			if err != nil {
+				db.Close()
+				return logical.ErrorResponse(fmt.Sprintf("error creating database object: %s", redactutil.Error(err))), nil
+			}
+
+			dbi, err = newDBPluginInstance(ctx, db, config)
+			if err != nil {
+				db.Close()
+				return logical.ErrorResponse(fmt.Sprintf("error creating database object: %s", redactutil.Error(err))), nil
+			}
+
+			if err := dbi.checkUsernamePrefix(ctx, connectionUsernamePrefix(config)); err != nil {
+				dbi.Close()
+				return logical.ErrorResponse(fmt.Sprintf("error checking username_prefix: %s", err)), nil
+			}
 		}
 
 		// Grab the mutex lock
 		b.Lock()
 		defer b.Unlock()
 
-		// Close and remove the old connection
-		b.clearConnection(name)
+		if !updated {
+			// Retire the old connection, letting it drain if configured to
+			b.retireConnection(name, time.Duration(config.ReloadGracePeriod)*time.Second)
+
+			// Save the new connection, or close it if it was only needed
+			// to verify the configuration
+			if b.cachesPlugin(config.PluginName) {
+				b.cacheConnection(name, dbi)
+			} else {
+				dbi.Close()
+			}
 
-		// Save the new connection
-		b.connections[name] = db
+			if verifyConnection {
+				b.lastVerified[name] = time.Now()
+			} else {
+				delete(b.lastVerified, name)
+			}
+		}
+		delete(b.failures, name)
+
+		// Keep the configuration being replaced so it can be rolled back to,
+		// but only once the new one is known to work. Pool settings applied
+		// to the live connection aren't verified.
+		if !updated && verifyConnection {
+			if err := savePreviousConfig(ctx, req.Storage, name); err != nil {
+				return nil, err
+			}
+		}
 
 		// Store it
 		entry, err := logical.StorageEntryJSON(fmt.Sprintf("config/%s", name), config)
@@ -256,6 +826,19 @@ func (b *databaseBackend) connectionWriteHandler() framework.OperationFunc {
 			return nil, err
 		}
 
+		// Merge connections that differ only by case into this one
+		for _, collision := range collisions {
+			if err := req.Storage.Delete(ctx, "config/"+collision); err != nil {
+				return nil, err
+			}
+			if err := req.Storage.Delete(ctx, previousConfigPath+collision); err != nil {
+				return nil, err
+			}
+			b.clearConnection(collision)
+			delete(b.lastVerified, collision)
+			delete(b.failures, collision)
+		}
+
 		resp := &logical.Response{}
 		resp.AddWarning("Read access to this endpoint should be controlled via ACLs as it will return the connection details as is, including passwords, if any.")
 
@@ -263,6 +846,20 @@ func (b *databaseBackend) connectionWriteHandler() framework.OperationFunc {
 	}
 }
 
+// builtinDatabasePlugins returns the sorted names of the builtin database
+// plugins, for suggesting a valid plugin_name.
+func builtinDatabasePlugins() []string {
+	var names []string
+	for _, name := range builtinplugins.Keys() {
+		if strings.HasSuffix(name, "-database-plugin") {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	return names
+}
+
 const pathConfigConnectionHelpSyn = `
 Configure connection details to a database plugin.
 `
@@ -283,6 +880,82 @@ accepts:
 	* "verify_connection" (default: true) - A boolean value denoting if the plugin should verify
 	   it is able to connect to the database using the provided connection
        details.
+
+	* "username_prefix" (optional) - A string prepended to every username
+	   generated for roles using this connection, unless overridden by the
+	   role.
+
+	* "environment" (optional) - A tag for the environment of the database,
+	   such as "prod", included in every username generated for roles using
+	   this connection.
+
+	* "environment_prefix" (default: false) - Whether generated usernames
+	   start with the environment rather than having it added to the display
+	   name.
+
+	* "max_concurrent_creations" (default: 0) - The maximum number of
+	   credential creations that may run against this connection at once.
+	   Zero means unbounded.
+
+	* "revocation_retries" (default: 0) - The number of times a failed
+	   revocation is retried, with exponential backoff capped at 30 seconds,
+	   before it is queued to be retried periodically. At most 10.
+
+	* "recycle_errors" (optional) - A comma separated list of substrings of
+	   errors after which the connection is closed and re-established.
+	   Replaces the built-in list for the plugin's database type.
+
+	* "client_certificate_ca" (optional) - A PEM bundle with a CA certificate
+	   and its private key, used to sign client certificates for roles with
+	   the client_certificate credential type. Only the certificate is
+	   returned when the connection is read.
+
+	* "reload_grace_period" (default: 0) - How long the previous connection
+	   may keep serving the operations using it after the configuration
+	   changes. New requests use a connection with the new configuration.
+
+	* "statement_timeout" (default: 0) - How long a role's creation or
+	   renewal statements may run before they are cancelled. Roles may set
+	   their own statement_timeout.
+
+	* "max_statement_timeout" (default: 0) - The longest statement_timeout
+	   roles using this connection may set.
+
+	* "creds_rate_limit" (default: 0) - The number of credentials that may be
+	   requested against this connection per "creds_rate_limit_interval".
+	   Short bursts up to the limit are allowed. Requests past it fail with
+	   the time after which to retry. Zero means unbounded.
+
+	* "creds_rate_limit_interval" (default: 60) - The interval, in seconds,
+	   that creds_rate_limit applies to.
+
+	* "statement_variables" (default: none) - Values, such as a schema name,
+	   substituted into the statements of every role using the connection
+	   wherever "{{key}}" appears. Roles may set their own statement_variables
+	   to override them. The names of the values plugins substitute, such as
+	   "name", "password" and "expiration", can't be used.
+
+	* "log_statements" (default: false) - Whether to log the statements run
+	   to issue and revoke credentials, at debug level, for debugging them.
+	   Passwords are always redacted.
+
+	* "issuance_ping_timeout" (default: 0) - If set, the number of seconds
+	   the database has to answer a ping before credentials are issued
+	   against it. Issuance is refused if it doesn't. Zero doesn't ping.
+`
+
+const pathConfigConnectionEffectiveHelpSyn = `
+Read the resolved configuration of a connection.
+`
+
+const pathConfigConnectionEffectiveHelpDesc = `
+This path returns the configuration a connection actually uses, as opposed to
+the values stored by "config/<name>". The connection details include any
+default_params set on the mount, and recycle_errors is the list in effect,
+which is the plugin's built-in list when the connection doesn't set one. The
+database type, capabilities, server version and plugin version and build info
+reported by the plugin are included too; the plugin is started if it isn't
+already running.
 `
 
 const pathResetConnectionHelpSyn = `
@@ -293,3 +966,16 @@ const pathResetConnectionHelpDesc = `
 This path resets the database connection by closing the existing database plugin
 instance and running a new one.
 `
+
+const pathVerifyConnectionHelpSyn = `
+Verifies a database connection.
+`
+
+const pathVerifyConnectionHelpDesc = `
+This path connects to the database of the connection, starting its plugin if
+it isn't running, and checks that the database answers a ping. It's meant for
+connections written with "verify_connection" set to false, such as while the
+database isn't reachable yet, to verify them once it is. A working connection
+is kept, unlike with "reset/<name>". An error is returned if the connection
+can't be verified.
+`
@@ -0,0 +1,258 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/vault/builtin/logical/database/dbplugin"
+	"github.com/hashicorp/vault/logical"
+)
+
+// fakeUpdatePoolSettings returns an updatePoolSettings function for a
+// fakeDatabase that stores the settings in applied as long as connection_url
+// is unchanged.
+func fakeUpdatePoolSettings(applied *map[string]interface{}) func(context.Context, map[string]interface{}) error {
+	return func(_ context.Context, config map[string]interface{}) error {
+		if config["connection_url"] != "fake://db" {
+			return dbplugin.ErrConnectionIdentityChanged
+		}
+		*applied = config
+		return nil
+	}
+}
+
+func TestBackend_updatePoolSettings(t *testing.T) {
+	b, storage := getBackend(t)
+
+	var poolConfig map[string]interface{}
+	db := &fakeDatabase{updatePoolSettings: fakeUpdatePoolSettings(&poolConfig)}
+	dbi, err := newDBPluginInstance(context.Background(), db, &DatabaseConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	testFakeConnection(t, b, storage, dbi, &roleEntry{})
+
+	req := &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "config/fake",
+		Storage:   storage,
+		Data: map[string]interface{}{
+			"plugin_name":              "fake",
+			"connection_url":           "fake://db",
+			"max_open_connections":     10,
+			"max_concurrent_creations": 2,
+		},
+	}
+
+	// Pool changes are applied to the running plugin
+	resp, err := b.HandleRequest(context.Background(), req)
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%s resp:%#v\n", err, resp)
+	}
+	if poolConfig["max_open_connections"] != 10 {
+		t.Fatalf("expected pool settings to be applied, got: %#v", poolConfig)
+	}
+	if b.connections["fake"].Database != db {
+		t.Fatal("expected the running plugin to be kept")
+	}
+	if cap(b.connections["fake"].creationSem) != 2 {
+		t.Fatal("expected the creation limit to be updated")
+	}
+	if db.isClosed() {
+		t.Fatal("expected the connection to stay open")
+	}
+
+	stored, err := b.DatabaseConfig(context.Background(), storage, "fake")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.MaxConcurrentCreations != 2 {
+		t.Fatalf("expected updated config to be stored, got: %#v", stored)
+	}
+
+	// Other changes require a new plugin, which can't be created for the
+	// fake plugin, so the running one is left in place.
+	req.Data = map[string]interface{}{
+		"plugin_name":    "fake",
+		"connection_url": "fake://other",
+	}
+	resp, err = b.HandleRequest(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp == nil || !resp.IsError() || !strings.Contains(resp.Data["error"].(string), "error creating database object") {
+		t.Fatalf("expected the plugin to be recreated, got: %#v", resp)
+	}
+	if b.connections["fake"].Database != db {
+		t.Fatal("expected the running plugin to be kept")
+	}
+
+	// Unknown plugins are rejected before anything is changed
+	req.Data = map[string]interface{}{
+		"plugin_name":    "fake-database-plugin",
+		"connection_url": "fake://db",
+	}
+	resp, err = b.HandleRequest(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp == nil || !resp.IsError() || !strings.Contains(resp.Data["error"].(string), "postgresql-database-plugin") {
+		t.Fatalf("expected error listing the builtin plugins, got: %#v", resp)
+	}
+	stored, err = b.DatabaseConfig(context.Background(), storage, "fake")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.PluginName != "fake" {
+		t.Fatalf("expected the stored config to be unchanged, got: %#v", stored)
+	}
+}
+
+func TestBackend_effectiveConfig(t *testing.T) {
+	config := logical.TestBackendConfig()
+	config.StorageView = &logical.InmemStorage{}
+	config.Config = map[string]string{"default_params": "connect_timeout=5"}
+	b, err := Factory(context.Background(), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := b.(*databaseBackend)
+
+	dbi, err := newDBPluginInstance(context.Background(), &fakeDatabase{}, &DatabaseConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	testFakeConnection(t, db, config.StorageView, dbi, &roleEntry{})
+	dbi.recycleErrors = []string{"connection reset"}
+
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "config/fake/effective",
+		Storage:   config.StorageView,
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%s resp:%#v\n", err, resp)
+	}
+
+	expected := map[string]interface{}{
+		"plugin_name": "fake",
+		"connection_details": map[string]interface{}{
+			"default_params": map[string]string{
+				"connect_timeout": "5",
+			},
+		},
+		"allowed_roles":             []string{"*"},
+		"username_prefix":           "",
+		"environment":               "",
+		"environment_prefix":        false,
+		"issuance_ping_timeout":     0,
+		"max_concurrent_creations":  0,
+		"revocation_retries":        0,
+		"recycle_errors":            []string{"connection reset"},
+		"verification_freshness":    0,
+		"quarantine_threshold":      0,
+		"quarantine_cooldown":       0,
+		"client_certificate_ca":     "",
+		"reload_grace_period":       0,
+		"statement_timeout":         0,
+		"max_statement_timeout":     0,
+		"creds_rate_limit":          0,
+		"creds_rate_limit_interval": 0,
+		"statement_variables":       map[string]string(nil),
+		"log_statements":            false,
+		"db_type":                   "fake",
+		"plugin_capabilities":       dbplugin.DefaultCapabilities,
+		"server_version":            "unknown",
+		"plugin_version":            "unknown",
+		"plugin_build_info": map[string]string{
+			"revision":   "unknown",
+			"go_version": "unknown",
+		},
+	}
+	if !reflect.DeepEqual(resp.Data, expected) {
+		t.Fatalf("expected %#v, got %#v", expected, resp.Data)
+	}
+
+	// The stored configuration is unchanged
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "config/fake",
+		Storage:   config.StorageView,
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%s resp:%#v\n", err, resp)
+	}
+	if resp.Data["recycle_errors"].([]string) != nil {
+		t.Fatalf("expected stored recycle_errors to be unset, got: %#v", resp.Data["recycle_errors"])
+	}
+	if _, ok := resp.Data["connection_details"].(map[string]interface{})["default_params"]; ok {
+		t.Fatal("expected stored connection details not to include default_params")
+	}
+}
+
+func TestBackend_verifyConnection(t *testing.T) {
+	b, storage := getBackend(t)
+
+	connections := map[string]dbplugin.Database{
+		"up":      &fakeDatabase{ping: fakePing(nil)},
+		"noping":  &fakeDatabase{},
+		"down":    &fakeDatabase{ping: fakePing(errors.New("connection refused"))},
+		"missing": nil,
+	}
+	for name, db := range connections {
+		entry, err := logical.StorageEntryJSON("config/"+name, &DatabaseConfig{
+			PluginName: "missing-database-plugin",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := storage.Put(context.Background(), entry); err != nil {
+			t.Fatal(err)
+		}
+
+		if db == nil {
+			continue
+		}
+		dbi, err := newDBPluginInstance(context.Background(), db, &DatabaseConfig{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		b.connections[name] = dbi
+	}
+
+	verify := func(name string) *logical.Response {
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "config/" + name + "/verify",
+			Storage:   storage,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	for _, name := range []string{"up", "noping"} {
+		resp := verify(name)
+		if resp == nil || resp.IsError() || resp.Data["verified"] != true {
+			t.Fatalf("%s: expected the connection to be verified, got: %#v", name, resp)
+		}
+		if _, ok := b.lastVerified[name]; !ok {
+			t.Fatalf("%s: expected the verification to be recorded", name)
+		}
+	}
+
+	// The connection is kept after verifying it
+	if b.connections["up"].Database != connections["up"] {
+		t.Fatal("expected the connection to be kept")
+	}
+
+	for _, name := range []string{"down", "missing", "unknown"} {
+		if resp := verify(name); resp == nil || !resp.IsError() {
+			t.Fatalf("%s: expected error, got: %#v", name, resp)
+		}
+	}
+}
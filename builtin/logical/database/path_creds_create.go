@@ -3,9 +3,11 @@ package database
 import (
 	"context"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/hashicorp/vault/builtin/logical/database/dbplugin"
+	"github.com/hashicorp/vault/helper/redactutil"
 	"github.com/hashicorp/vault/helper/strutil"
 	"github.com/hashicorp/vault/logical"
 	"github.com/hashicorp/vault/logical/framework"
@@ -19,6 +21,12 @@ func pathCredsCreate(b *databaseBackend) *framework.Path {
 				Type:        framework.TypeString,
 				Description: "Name of the role.",
 			},
+			"format": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `The format in which the credential is returned,
+				overriding the role's credential_format. "all" returns every
+				format that can be derived from the connection at once.`,
+			},
 		},
 
 		Callbacks: map[logical.Operation]framework.OperationFunc{
@@ -32,7 +40,7 @@ func pathCredsCreate(b *databaseBackend) *framework.Path {
 
 func (b *databaseBackend) pathCredsCreateRead() framework.OperationFunc {
 	return func(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
-		name := data.Get("name").(string)
+		name := b.canonicalName(data.Get("name").(string))
 
 		// Get the role
 		role, err := b.Role(ctx, req.Storage, name)
@@ -43,6 +51,14 @@ func (b *databaseBackend) pathCredsCreateRead() framework.OperationFunc {
 			return logical.ErrorResponse(fmt.Sprintf("unknown role: %s", name)), nil
 		}
 
+		credentialFormat := role.CredentialFormat
+		if format := data.Get("format").(string); format != "" {
+			if _, ok := credentialFormatters[format]; !ok {
+				return logical.ErrorResponse(fmt.Sprintf("unknown format %q, must be one of: %s", format, strings.Join(credentialFormatNames(), ", "))), nil
+			}
+			credentialFormat = format
+		}
+
 		dbConfig, err := b.DatabaseConfig(ctx, req.Storage, role.DBName)
 		if err != nil {
 			return nil, err
@@ -54,23 +70,12 @@ func (b *databaseBackend) pathCredsCreateRead() framework.OperationFunc {
 			return nil, logical.ErrPermissionDenied
 		}
 
-		// Grab the read lock
-		b.RLock()
-		unlockFunc := b.RUnlock
-
-		// Get the Database object
-		db, ok := b.getDBObj(role.DBName)
-		if !ok {
-			// Upgrade lock
-			b.RUnlock()
-			b.Lock()
-			unlockFunc = b.Unlock
-
-			// Create a new DB object
-			db, err = b.createDBObj(ctx, req.Storage, role.DBName)
-			if err != nil {
-				unlockFunc()
-				return nil, fmt.Errorf("cound not retrieve db with name: %s, got error: %s", role.DBName, err)
+		if limiter := b.credsRateLimiter(role.DBName, dbConfig); limiter != nil {
+			if ok, wait := limiter.take(time.Now()); !ok {
+				incrConnectionCounter("rate_limited", role.DBName)
+				// Rounded up, so retrying after it succeeds
+				wait = (wait + time.Second - 1).Truncate(time.Second)
+				return logical.ErrorResponse(fmt.Sprintf("rate limited, retry after %s", wait)), nil
 			}
 		}
 
@@ -88,29 +93,215 @@ func (b *databaseBackend) pathCredsCreateRead() framework.OperationFunc {
 
 		expiration := time.Now().Add(ttl)
 
-		usernameConfig := dbplugin.UsernameConfig{
-			DisplayName: req.DisplayName,
-			RoleName:    name,
+		usernameConfig := role.usernameConfig(req.DisplayName, name, dbConfig)
+		statements := role.statements(dbConfig)
+
+		// If the plugin shut down, the connection is re-established and the
+		// creation retried once, so that a plugin restart doesn't fail the
+		// request
+		var db *dbPluginInstance
+		var unlockFunc func()
+		var username, password string
+		for attempt := 0; ; attempt++ {
+			db, unlockFunc, err = b.lockedDBObj(ctx, req.Storage, role.DBName)
+			if err != nil {
+				return nil, err
+			}
+
+			if err := db.supports(dbplugin.CapabilityCreateUser); err != nil {
+				unlockFunc()
+				return nil, err
+			}
+
+			// Refuse roles whose statement lists the plugin would ignore,
+			// as may be the case if the connection's plugin was changed
+			if len(statements.CreateStatements) > 0 || len(statements.GrantStatements) > 0 {
+				if err := db.supports(dbplugin.CapabilityStatementLists); err != nil {
+					unlockFunc()
+					return nil, fmt.Errorf("create_statements and grant_statements of role %q: %s", name, err)
+				}
+			}
+
+			// Refuse to issue against a database that isn't answering,
+			// rather than failing on the creation statements
+			err = b.checkIssuanceHealth(ctx, db, role.DBName, dbConfig)
+			if err == nil {
+				if err := db.acquireCreation(ctx); err != nil {
+					unlockFunc()
+					return nil, err
+				}
+
+				// Create the user
+				stmtCtx, cancel := role.statementContext(ctx, dbConfig)
+				username, password, err = db.CreateUser(stmtCtx, statements, usernameConfig, expiration)
+				cancel()
+				db.releaseCreation()
+			}
+			if err == nil {
+				break
+			}
+
+			unlockFunc()
+			b.closeIfShutdown(role.DBName, db, err)
+			if attempt > 0 || !isPluginShutdown(err) {
+				return nil, err
+			}
+			b.logger.Warn("database: retrying credential creation after the plugin shut down", "name", role.DBName)
 		}
+		b.logCreationStatements(dbConfig, role.DBName, statements, username, password, expiration)
 
-		// Create the user
-		username, password, err := db.CreateUser(ctx, role.Statements, usernameConfig, expiration)
+		respData, err := credentialFormatters[credentialFormat](username, password, db.dbType, dbConfig)
 		if err != nil {
+			// The credential can't be returned, so don't leave the user behind
+			// without a lease.
+			if revokeErr := db.RevokeUser(ctx, statements, username); revokeErr != nil {
+				b.logger.Error("database: failed to revoke user after formatting error", "username", username, "error", revokeErr)
+			}
 			unlockFunc()
-			b.closeIfShutdown(role.DBName, err)
 			return nil, err
 		}
 
-		resp := b.Secret(SecretCredsType).Response(map[string]interface{}{
-			"username": username,
-			"password": password,
-		}, map[string]interface{}{
-			"username": username,
-			"role":     name,
+		if role.CredentialType == credentialTypeClientCertificate {
+			cert, err := issueClientCertificate(dbConfig.ClientCertificateCA, username, ttl)
+			if err != nil {
+				if revokeErr := db.RevokeUser(ctx, statements, username); revokeErr != nil {
+					b.logger.Error("database: failed to revoke user after client certificate error", "username", username, "error", revokeErr)
+				}
+				unlockFunc()
+				return nil, fmt.Errorf("error issuing client certificate: %s", err)
+			}
+			for k, v := range cert {
+				respData[k] = v
+			}
+		}
+
+		var grantsWarning string
+		if role.IntrospectGrants {
+			grants, err := b.userGrants(ctx, db, username)
+			if err != nil {
+				grantsWarning = fmt.Sprintf("unable to introspect grants for the new user: %s", err)
+			} else {
+				respData["grants"] = grants
+			}
+		}
+
+		// Record where the credential came from, both in the lease and for
+		// the caller. It only names what the caller already knows or was
+		// just given.
+		createdAt := time.Now().UTC()
+
+		// Index the user so that it can be found by username
+		if err := putIssuedUser(ctx, req.Storage, &issuedUser{
+			Username:   username,
+			Role:       name,
+			Connection: role.DBName,
+			CreatedAt:  createdAt,
+			ExpiresAt:  expiration.UTC(),
+		}); err != nil {
+			if revokeErr := db.RevokeUser(ctx, statements, username); revokeErr != nil {
+				b.logger.Error("database: failed to revoke user after indexing error", "username", username, "error", revokeErr)
+			}
+			unlockFunc()
+			return nil, err
+		}
+		respData["metadata"] = map[string]interface{}{
+			"connection": role.DBName,
+			"role":       name,
+			"username":   username,
+			"created_at": createdAt.Format(time.RFC3339),
+		}
+
+		resp := b.Secret(SecretCredsType).Response(respData, map[string]interface{}{
+			"username":   username,
+			"role":       name,
+			"connection": role.DBName,
+			"created_at": createdAt.Format(time.RFC3339),
 		})
 		resp.Secret.TTL = ttl
+		if grantsWarning != "" {
+			resp.AddWarning(grantsWarning)
+		}
 
 		unlockFunc()
+
+		b.notify(&credentialEvent{
+			Type:       credentialEventIssued,
+			Connection: role.DBName,
+			Role:       name,
+			Username:   username,
+			Timestamp:  createdAt,
+		})
+		return resp, nil
+	}
+}
+
+func pathCredsPreview(b *databaseBackend) *framework.Path {
+	return &framework.Path{
+		Pattern: "creds/" + framework.GenericNameRegex("name") + "/preview$",
+		Fields: map[string]*framework.FieldSchema{
+			"name": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Name of the role.",
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation: b.pathCredsPreviewRead(),
+		},
+
+		HelpSynopsis:    pathCredsPreviewHelpSyn,
+		HelpDescription: pathCredsPreviewHelpDesc,
+	}
+}
+
+func (b *databaseBackend) pathCredsPreviewRead() framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+		name := b.canonicalName(data.Get("name").(string))
+
+		role, err := b.Role(ctx, req.Storage, name)
+		if err != nil {
+			return nil, err
+		}
+		if role == nil {
+			return logical.ErrorResponse(fmt.Sprintf("unknown role: %s", name)), nil
+		}
+
+		dbConfig, err := b.DatabaseConfig(ctx, req.Storage, role.DBName)
+		if err != nil {
+			return nil, err
+		}
+
+		// Previews are subject to the same allowed roles as credentials
+		if !strutil.StrListContains(dbConfig.AllowedRoles, "*") && !strutil.StrListContainsGlob(dbConfig.AllowedRoles, name) {
+			return nil, logical.ErrPermissionDenied
+		}
+
+		db, err := b.pluginInstance(ctx, req.Storage, role.DBName)
+		if err != nil {
+			return nil, err
+		}
+		defer db.release()
+
+		if err := db.supports(dbplugin.CapabilityGenerateCredentials); err != nil {
+			return logical.ErrorResponse(fmt.Sprintf("database %q can't preview credentials: %s", role.DBName, err)), nil
+		}
+
+		usernameConfig := role.usernameConfig(req.DisplayName, name, dbConfig)
+		username, password, err := dbplugin.GenerateCredentials(ctx, db.Database, usernameConfig)
+		if err != nil {
+			return nil, redactutil.Error(err)
+		}
+
+		respData, err := credentialFormatters[role.CredentialFormat](username, password, db.dbType, dbConfig)
+		if err != nil {
+			return nil, err
+		}
+		respData["provisioned"] = false
+
+		resp := &logical.Response{
+			Data: respData,
+		}
+		resp.AddWarning("these credentials were not created in the database and can't be used; reading creds issues new ones")
 		return resp, nil
 	}
 }
@@ -124,3 +315,34 @@ This path reads database credentials for a certain role. The
 database credentials will be generated on demand and will be automatically
 revoked when the lease is up.
 `
+
+const pathCredsPreviewHelpSyn = `
+Preview the credentials a role would generate, without creating them.
+`
+
+const pathCredsPreviewHelpDesc = `
+This path returns a username and password generated the way the role's
+credentials are, following its username prefix and the database's naming
+rules. Nothing is created in the database, no lease is issued and nothing is
+stored; the response sets "provisioned" to false to make that clear. Each read
+generates a new username and password, and credentials later read from
+creds/<role> will differ from the preview.
+`
+
+// userGrants summarizes the privileges held by username. It never fails the
+// credential creation; callers surface any error as a warning.
+func (b *databaseBackend) userGrants(ctx context.Context, db *dbPluginInstance, username string) ([]string, error) {
+	if err := db.supports(dbplugin.CapabilityUserGrants); err != nil {
+		return nil, err
+	}
+
+	grants, err := dbplugin.UserGrants(ctx, db.Database, username)
+	if err != nil {
+		return nil, redactutil.Error(err)
+	}
+	if grants == nil {
+		grants = []string{}
+	}
+
+	return grants, nil
+}
@@ -0,0 +1,182 @@
+package database
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/builtin/logical/database/dbplugin"
+	"github.com/hashicorp/vault/logical"
+)
+
+func TestBackend_introspectGrants(t *testing.T) {
+	b, storage := getBackend(t)
+
+	credsReq := &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "creds/readonly",
+		Storage:   storage,
+	}
+
+	dbi, err := newDBPluginInstance(context.Background(), &fakeDatabase{
+		userGrants: func(_ context.Context, username string) ([]string, error) {
+			return []string{"SELECT ON public." + username}, nil
+		},
+	}, &DatabaseConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	testFakeConnection(t, b, storage, dbi, &roleEntry{IntrospectGrants: true})
+
+	resp, err := b.HandleRequest(context.Background(), credsReq)
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%s resp:%#v\n", err, resp)
+	}
+	if !reflect.DeepEqual(resp.Data["grants"], []string{"SELECT ON public.user"}) {
+		t.Fatalf("bad grants: %#v", resp.Data["grants"])
+	}
+
+	// Plugins that can't introspect grants still issue the credential
+	dbi, err = newDBPluginInstance(context.Background(), &fakeDatabase{}, &DatabaseConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	testFakeConnection(t, b, storage, dbi, &roleEntry{IntrospectGrants: true})
+
+	resp, err = b.HandleRequest(context.Background(), credsReq)
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%s resp:%#v\n", err, resp)
+	}
+	if _, ok := resp.Data["grants"]; ok {
+		t.Fatalf("expected no grants, got: %#v", resp.Data["grants"])
+	}
+	if resp.Data["username"] != "user" || len(resp.Warnings) != 1 {
+		t.Fatalf("expected credential with a warning, got: %#v", resp)
+	}
+}
+
+func TestBackend_credsPreview(t *testing.T) {
+	b, storage := getBackend(t)
+
+	previewReq := &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "creds/readonly/preview",
+		Storage:   storage,
+	}
+
+	created := 0
+	db := &fakeDatabase{
+		createUser: func(_ context.Context, _ dbplugin.Statements, _ dbplugin.UsernameConfig, _ time.Time) (string, string, error) {
+			created++
+			return "user", "password", nil
+		},
+		generateCredentials: fakeGenerateCredentials,
+	}
+	dbi, err := newDBPluginInstance(context.Background(), db, &DatabaseConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	testFakeConnection(t, b, storage, dbi, &roleEntry{UsernamePrefix: "app-"})
+
+	keys, err := logical.CollectKeys(context.Background(), storage)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := b.HandleRequest(context.Background(), previewReq)
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%s resp:%#v\n", err, resp)
+	}
+	if resp.Data["username"] != "app-readonly" || resp.Data["password"] != "password" {
+		t.Fatalf("bad credentials: %#v", resp.Data)
+	}
+	if resp.Data["provisioned"] != false || len(resp.Warnings) != 1 {
+		t.Fatalf("expected the preview to be marked as not provisioned, got: %#v", resp)
+	}
+	if resp.Secret != nil {
+		t.Fatalf("expected no lease, got: %#v", resp.Secret)
+	}
+	if created != 0 {
+		t.Fatalf("expected no user to be created, got %d", created)
+	}
+
+	after, err := logical.CollectKeys(context.Background(), storage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(keys, after) {
+		t.Fatalf("expected storage to be unchanged, got keys %v, expected %v", after, keys)
+	}
+
+	// Plugins that can't generate credentials on their own can't preview them
+	dbi, err = newDBPluginInstance(context.Background(), &fakeDatabase{}, &DatabaseConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	testFakeConnection(t, b, storage, dbi, &roleEntry{})
+
+	resp, err = b.HandleRequest(context.Background(), previewReq)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp == nil || !resp.IsError() {
+		t.Fatalf("expected error response, got: %#v", resp)
+	}
+}
+
+func TestBackend_credsMetadata(t *testing.T) {
+	b, storage := getBackend(t)
+
+	dbi, err := newDBPluginInstance(context.Background(), &fakeDatabase{}, &DatabaseConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	testFakeConnection(t, b, storage, dbi, &roleEntry{})
+
+	before := time.Now().UTC().Truncate(time.Second)
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "creds/readonly",
+		Storage:   storage,
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%s resp:%#v\n", err, resp)
+	}
+
+	metadata, ok := resp.Data["metadata"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("expected metadata, got: %#v", resp.Data)
+	}
+	createdAt, err := time.Parse(time.RFC3339, metadata["created_at"].(string))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if createdAt.Before(before) || createdAt.After(time.Now()) {
+		t.Fatalf("bad created_at: %s", createdAt)
+	}
+	expected := map[string]interface{}{
+		"connection": "fake",
+		"role":       "readonly",
+		"username":   "user",
+		"created_at": metadata["created_at"],
+	}
+	if !reflect.DeepEqual(metadata, expected) {
+		t.Fatalf("bad metadata: expected %#v, got %#v", expected, metadata)
+	}
+	if _, ok := metadata["password"]; ok {
+		t.Fatal("expected the metadata not to hold the password")
+	}
+
+	// The lease carries the same provenance
+	internal := map[string]interface{}{
+		"secret_type": SecretCredsType,
+		"connection":  "fake",
+		"role":        "readonly",
+		"username":    "user",
+		"created_at":  metadata["created_at"],
+	}
+	if !reflect.DeepEqual(resp.Secret.InternalData, internal) {
+		t.Fatalf("bad internal data: expected %#v, got %#v", internal, resp.Secret.InternalData)
+	}
+}
@@ -0,0 +1,459 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/vault/helper/jsonutil"
+	"github.com/hashicorp/vault/helper/redactutil"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+	"github.com/hashicorp/vault/plugins/helper/database/dbutil"
+)
+
+// importNameRe matches the names connections and roles may be imported under,
+// the same names their endpoints accept.
+var importNameRe = regexp.MustCompile("^" + framework.GenericNameRegex("name") + "$")
+
+// sensitiveConnectionFields are connection details whose values are always
+// redacted from exports, in addition to any field containing "password".
+var sensitiveConnectionFields = []string{
+	"pem_bundle",
+	"pem_json",
+	"private_key",
+	"ssh_private_key",
+}
+
+func pathExport(b *databaseBackend) *framework.Path {
+	return &framework.Path{
+		Pattern: "export/?$",
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation: b.pathExportRead(),
+		},
+
+		HelpSynopsis:    pathExportHelpSyn,
+		HelpDescription: pathExportHelpDesc,
+	}
+}
+
+func pathExportEncrypted(b *databaseBackend) *framework.Path {
+	return &framework.Path{
+		Pattern: "export/encrypted$",
+		Fields: map[string]*framework.FieldSchema{
+			"key": {
+				Type:        framework.TypeString,
+				Description: "Base64 encoded 256-bit key to encrypt the export with, such as the plaintext of a transit data key.",
+			},
+			"passphrase": {
+				Type:        framework.TypeString,
+				Description: "Passphrase to derive the key to encrypt the export with.",
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathExportEncryptedWrite(),
+		},
+
+		HelpSynopsis:    pathExportEncryptedHelpSyn,
+		HelpDescription: pathExportEncryptedHelpDesc,
+	}
+}
+
+func pathImport(b *databaseBackend) *framework.Path {
+	return &framework.Path{
+		Pattern: "import/?$",
+		Fields: map[string]*framework.FieldSchema{
+			"connections": {
+				Type:        framework.TypeMap,
+				Description: "Connections to create, keyed by name, as returned by the export endpoint.",
+			},
+			"roles": {
+				Type:        framework.TypeMap,
+				Description: "Roles to create, keyed by name, as returned by the export endpoint.",
+			},
+			"blob": {
+				Type:        framework.TypeString,
+				Description: "Encrypted export to import, as returned by the export/encrypted endpoint.",
+			},
+			"key": {
+				Type:        framework.TypeString,
+				Description: "Base64 encoded 256-bit key the blob was encrypted with.",
+			},
+			"passphrase": {
+				Type:        framework.TypeString,
+				Description: "Passphrase the blob was encrypted with.",
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathImportWrite(),
+		},
+
+		HelpSynopsis:    pathImportHelpSyn,
+		HelpDescription: pathImportHelpDesc,
+	}
+}
+
+func (b *databaseBackend) pathExportRead() framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+		export, err := b.export(ctx, req.Storage, true)
+		if err != nil {
+			return nil, err
+		}
+
+		return &logical.Response{
+			Data: export,
+		}, nil
+	}
+}
+
+func (b *databaseBackend) pathExportEncryptedWrite() framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+		secret, err := parseExportSecret(data.Get("key").(string), data.Get("passphrase").(string))
+		if err != nil {
+			return logical.ErrorResponse(err.Error()), nil
+		}
+
+		export, err := b.export(ctx, req.Storage, false)
+		if err != nil {
+			return nil, err
+		}
+
+		plaintext, err := jsonutil.EncodeJSON(export)
+		if err != nil {
+			return nil, err
+		}
+
+		blob, err := encryptExport(secret, plaintext)
+		if err != nil {
+			return nil, err
+		}
+
+		return &logical.Response{
+			Data: map[string]interface{}{
+				"blob": blob,
+			},
+		}, nil
+	}
+}
+
+// export returns every connection and role, with sensitive connection
+// details redacted if redact is set.
+func (b *databaseBackend) export(ctx context.Context, s logical.Storage, redact bool) (map[string]interface{}, error) {
+	names, err := s.List(ctx, "config/")
+	if err != nil {
+		return nil, err
+	}
+
+	connections := make(map[string]interface{}, len(names))
+	for _, name := range names {
+		config, err := b.DatabaseConfig(ctx, s, name)
+		if err != nil {
+			return nil, err
+		}
+		connections[name] = exportConnection(config, redact)
+	}
+
+	names, err = s.List(ctx, "role/")
+	if err != nil {
+		return nil, err
+	}
+
+	roles := make(map[string]interface{}, len(names))
+	for _, name := range names {
+		role, err := b.Role(ctx, s, name)
+		if err != nil {
+			return nil, err
+		}
+		if role == nil {
+			continue
+		}
+		roles[name] = exportRole(role)
+	}
+
+	return map[string]interface{}{
+		"connections": connections,
+		"roles":       roles,
+	}, nil
+}
+
+// exportConnection returns config in the format accepted by the connection
+// write endpoint, with sensitive values redacted if redact is set.
+func exportConnection(config *DatabaseConfig, redact bool) map[string]interface{} {
+	export := make(map[string]interface{}, len(config.ConnectionDetails)+20)
+	for k, v := range config.ConnectionDetails {
+		if redact {
+			v = redactConnectionDetail(k, v)
+		}
+		export[k] = v
+	}
+
+	export["plugin_name"] = config.PluginName
+	export["allowed_roles"] = config.AllowedRoles
+	export["username_prefix"] = config.UsernamePrefix
+	export["environment"] = config.Environment
+	export["environment_prefix"] = config.EnvironmentPrefix
+	export["max_concurrent_creations"] = config.MaxConcurrentCreations
+	export["revocation_retries"] = config.RevocationRetries
+	export["verification_freshness"] = config.VerificationFreshness
+	export["quarantine_threshold"] = config.QuarantineThreshold
+	export["quarantine_cooldown"] = config.QuarantineCooldown
+	export["reload_grace_period"] = config.ReloadGracePeriod
+	export["statement_timeout"] = config.StatementTimeout
+	export["max_statement_timeout"] = config.MaxStatementTimeout
+	export["creds_rate_limit"] = config.CredsRateLimit
+	export["creds_rate_limit_interval"] = config.CredsRateLimitInterval
+	export["statement_variables"] = config.StatementVariables
+	export["log_statements"] = config.LogStatements
+	export["issuance_ping_timeout"] = config.IssuancePingTimeout
+	if config.RecycleErrors != nil {
+		export["recycle_errors"] = config.RecycleErrors
+	}
+	if config.ClientCertificateCA != "" {
+		export["client_certificate_ca"] = config.ClientCertificateCA
+		if redact {
+			export["client_certificate_ca"] = redactutil.Redacted
+		}
+	}
+
+	return export
+}
+
+func redactConnectionDetail(key string, value interface{}) interface{} {
+	switch v := value.(type) {
+	case string:
+		if strings.Contains(strings.ToLower(key), "password") {
+			return redactutil.Redacted
+		}
+		for _, field := range sensitiveConnectionFields {
+			if key == field {
+				return redactutil.Redacted
+			}
+		}
+		return redactutil.String(v)
+	case map[string]interface{}:
+		redacted := make(map[string]interface{}, len(v))
+		for k, nested := range v {
+			redacted[k] = redactConnectionDetail(k, nested)
+		}
+		return redacted
+	case []interface{}:
+		// Lists, such as replica_connection_urls, are redacted like their key
+		redacted := make([]interface{}, len(v))
+		for i, item := range v {
+			redacted[i] = redactConnectionDetail(key, item)
+		}
+		return redacted
+	}
+
+	return value
+}
+
+// exportRole returns role in the format accepted by the role write endpoint.
+func exportRole(role *roleEntry) map[string]interface{} {
+	return map[string]interface{}{
+		"db_name":                role.DBName,
+		"creation_statements":    role.Statements.CreationStatements,
+		"allow_empty_creation":   len(dbutil.CreationQueries(role.Statements)) == 0,
+		"revocation_statements":  role.Statements.RevocationStatements,
+		"rollback_statements":    role.Statements.RollbackStatements,
+		"renew_statements":       role.Statements.RenewStatements,
+		"create_statements":      role.Statements.CreateStatements,
+		"grant_statements":       role.Statements.GrantStatements,
+		"revoke_statements":      role.Statements.RevokeStatements,
+		"revocation_steps":       revocationStepsData(role.Statements.RevocationSteps),
+		"statement_variables":    role.StatementVariables,
+		"username_prefix":        role.UsernamePrefix,
+		"pool_class":             role.PoolClass,
+		"password_min_uppercase": role.PasswordComplexity.MinUppercase,
+		"password_min_lowercase": role.PasswordComplexity.MinLowercase,
+		"password_min_digits":    role.PasswordComplexity.MinDigits,
+		"password_min_special":   role.PasswordComplexity.MinSpecial,
+		"credential_format":      role.CredentialFormat,
+		"credential_type":        role.CredentialType,
+		"introspect_grants":      role.IntrospectGrants,
+		"allowed_db_type":        role.AllowedDBType,
+		"statement_timeout":      int64(role.StatementTimeout.Seconds()),
+		"default_ttl":            int64(role.DefaultTTL.Seconds()),
+		"max_ttl":                int64(role.MaxTTL.Seconds()),
+	}
+}
+
+func (b *databaseBackend) pathImportWrite() framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+		connections := data.Get("connections").(map[string]interface{})
+		roles := data.Get("roles").(map[string]interface{})
+
+		if blob := data.Get("blob").(string); blob != "" {
+			if len(connections) > 0 || len(roles) > 0 {
+				return logical.ErrorResponse("blob cannot be given with connections or roles"), nil
+			}
+
+			secret, err := parseExportSecret(data.Get("key").(string), data.Get("passphrase").(string))
+			if err != nil {
+				return logical.ErrorResponse(err.Error()), nil
+			}
+
+			plaintext, err := decryptExport(secret, blob)
+			if err != nil {
+				return logical.ErrorResponse(err.Error()), nil
+			}
+
+			var export struct {
+				Connections map[string]interface{} `json:"connections"`
+				Roles       map[string]interface{} `json:"roles"`
+			}
+			if err := jsonutil.DecodeJSON(plaintext, &export); err != nil {
+				return logical.ErrorResponse(fmt.Sprintf("blob is invalid: %s", err)), nil
+			}
+			connections, roles = export.Connections, export.Roles
+		}
+
+		// Connections are imported first since roles may be checked against
+		// the connection they use.
+		return &logical.Response{
+			Data: map[string]interface{}{
+				"connections": b.importEntries(ctx, req.Storage, "config/", connections),
+				"roles":       b.importEntries(ctx, req.Storage, "roles/", roles),
+			},
+		}, nil
+	}
+}
+
+// importEntries writes each entry through the endpoint under prefix so it is
+// validated exactly as if it had been written directly. Names are checked
+// first, so that one like "name/verify" can't reach another endpoint. The
+// result of each write is reported by name, and a failure doesn't stop the
+// remaining entries from being imported.
+func (b *databaseBackend) importEntries(ctx context.Context, s logical.Storage, prefix string, entries map[string]interface{}) map[string]interface{} {
+	names := make([]string, 0, len(entries))
+	for name := range entries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	results := make(map[string]interface{}, len(entries))
+	for _, name := range names {
+		var err error
+		if importNameRe.MatchString(name) {
+			err = b.importEntry(ctx, s, prefix+name, entries[name])
+		} else {
+			err = fmt.Errorf("invalid name %q", name)
+		}
+		if err != nil {
+			results[name] = map[string]interface{}{
+				"success": false,
+				"error":   err.Error(),
+			}
+			continue
+		}
+
+		results[name] = map[string]interface{}{
+			"success": true,
+		}
+	}
+
+	return results
+}
+
+func (b *databaseBackend) importEntry(ctx context.Context, s logical.Storage, path string, entry interface{}) error {
+	raw, ok := entry.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("entry must be an object")
+	}
+
+	// The handlers modify the data they're given
+	data := make(map[string]interface{}, len(raw))
+	for k, v := range raw {
+		if containsRedacted(v) {
+			return fmt.Errorf("%q contains a redacted value that must be replaced before importing", k)
+		}
+		data[k] = v
+	}
+
+	resp, err := b.HandleRequest(ctx, &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      path,
+		Storage:   s,
+		Data:      data,
+	})
+	if err != nil {
+		return err
+	}
+	if resp != nil && resp.IsError() {
+		return resp.Error()
+	}
+
+	return nil
+}
+
+func containsRedacted(value interface{}) bool {
+	switch v := value.(type) {
+	case string:
+		return strings.Contains(v, redactutil.Redacted)
+	case map[string]interface{}:
+		for _, nested := range v {
+			if containsRedacted(nested) {
+				return true
+			}
+		}
+	case []interface{}:
+		for _, item := range v {
+			if containsRedacted(item) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+const pathExportHelpSyn = `
+Export all connections and roles.
+`
+
+const pathExportHelpDesc = `
+This path returns every connection and role of the backend as a single
+document that can be written to the "import" path of another database backend.
+Entries are in the format accepted by the "config/<name>" and "roles/<name>"
+paths. Passwords and other sensitive connection details are redacted and must
+be filled in before importing; use the "export/encrypted" path to keep them.
+`
+
+const pathExportEncryptedHelpSyn = `
+Export all connections and roles, encrypted with a key or passphrase.
+`
+
+const pathExportEncryptedHelpDesc = `
+This path returns every connection and role of the backend like the "export"
+path, but without redacting sensitive connection details. Instead, the whole
+document is encrypted with AES-256-GCM and returned as a single blob that can
+be written to the "import" path of another database backend along with the
+same key or passphrase.
+
+Exactly one of "key" or "passphrase" must be given. The key is a base64
+encoded 256-bit key, such as the plaintext of a data key generated by the
+transit secrets engine, from which the encryption key is derived with
+HKDF-SHA256. A passphrase must be at least 12 characters long, and the
+encryption key is derived from it with PBKDF2-HMAC-SHA256.
+`
+
+const pathImportHelpSyn = `
+Import connections and roles.
+`
+
+const pathImportHelpDesc = `
+This path recreates the connections and roles of a document returned by the
+"export" path. Each entry is written through the "config/<name>" or
+"roles/<name>" path and validated as if it had been written directly;
+connections are imported before roles. The response reports the success or
+failure of each entry, and a failed entry does not prevent the others from
+being imported. Entries that still contain redacted values are rejected.
+
+Instead of connections and roles, a blob returned by the "export/encrypted"
+path can be given along with the key or passphrase it was encrypted with.
+`
@@ -0,0 +1,290 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/hashicorp/vault/builtin/logical/database/dbplugin"
+	"github.com/hashicorp/vault/helper/redactutil"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+var (
+	// healthCheckConcurrency bounds the number of connections checked at once.
+	healthCheckConcurrency = 8
+
+	// healthCheckTimeout is how long a health report may take by default.
+	// Connections that haven't been checked by then are reported as unknown.
+	healthCheckTimeout = 10 * time.Second
+)
+
+// Statuses of a connection in a health report.
+const (
+	healthStatusHealthy     = "healthy"
+	healthStatusUnhealthy   = "unhealthy"
+	healthStatusQuarantined = "quarantined"
+	healthStatusUnknown     = "unknown"
+)
+
+func pathHealth(b *databaseBackend) *framework.Path {
+	return &framework.Path{
+		Pattern: "health/?$",
+		Fields: map[string]*framework.FieldSchema{
+			"timeout": &framework.FieldSchema{
+				Type: framework.TypeDurationSecond,
+				Description: `How long to spend checking connections. Connections
+				that haven't been checked by then are reported as unknown.
+				Defaults to 10 seconds.`,
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation: b.pathHealthRead(),
+		},
+
+		HelpSynopsis:    pathHealthHelpSyn,
+		HelpDescription: pathHealthHelpDesc,
+	}
+}
+
+// connectionHealth is the result of checking a single connection.
+type connectionHealth struct {
+	name             string
+	err              error
+	unknown          bool
+	quarantinedUntil time.Time
+
+	// initialized is whether the connection's plugin was already running
+	// when the check started.
+	initialized bool
+
+	// inFlight and maxInFlight are the credential creations running against
+	// the connection and the limit set by max_concurrent_creations.
+	inFlight    int
+	maxInFlight int
+
+	// rateLimit and rateRemaining are the connection's creds_rate_limit and
+	// the number of credentials that may be requested right away.
+	rateLimit     int
+	rateRemaining int
+}
+
+func (h connectionHealth) status() string {
+	switch {
+	case !h.quarantinedUntil.IsZero():
+		return healthStatusQuarantined
+	case h.unknown:
+		return healthStatusUnknown
+	case h.err != nil:
+		return healthStatusUnhealthy
+	default:
+		return healthStatusHealthy
+	}
+}
+
+func (h connectionHealth) summary() map[string]interface{} {
+	summary := map[string]interface{}{
+		"name":                     h.name,
+		"status":                   h.status(),
+		"initialized":              h.initialized,
+		"in_flight_creations":      h.inFlight,
+		"max_concurrent_creations": h.maxInFlight,
+		"creds_rate_limit":         h.rateLimit,
+	}
+	if h.rateLimit > 0 {
+		summary["creds_rate_limit_remaining"] = h.rateRemaining
+	}
+	if h.err != nil {
+		summary["error"] = h.err.Error()
+	}
+	if !h.quarantinedUntil.IsZero() {
+		summary["quarantined_until"] = h.quarantinedUntil.UTC().Format(time.RFC3339)
+	}
+
+	return summary
+}
+
+func (b *databaseBackend) pathHealthRead() framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+		timeout := healthCheckTimeout
+		if seconds := data.Get("timeout").(int); seconds > 0 {
+			timeout = time.Duration(seconds) * time.Second
+		}
+
+		names, err := req.Storage.List(ctx, "config/")
+		if err != nil {
+			return nil, err
+		}
+
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+
+		// Buffered so that checks still running after the timeout don't block
+		results := make(chan connectionHealth, len(names))
+		sem := make(chan struct{}, healthCheckConcurrency)
+		go func() {
+			for _, name := range names {
+				select {
+				case sem <- struct{}{}:
+				case <-ctx.Done():
+					return
+				}
+
+				go func(name string) {
+					defer func() { <-sem }()
+					results <- b.checkHealth(ctx, req.Storage, name)
+				}(name)
+			}
+		}()
+
+		checked := make(map[string]connectionHealth, len(names))
+	COLLECT:
+		for len(checked) < len(names) {
+			select {
+			case result := <-results:
+				checked[result.name] = result
+			case <-ctx.Done():
+				break COLLECT
+			}
+		}
+
+		healthy := []string{}
+		unknown := []string{}
+		unhealthy := map[string]interface{}{}
+		quarantined := map[string]interface{}{}
+		connections := make([]map[string]interface{}, 0, len(names))
+		sort.Strings(names)
+		for _, name := range names {
+			result, ok := checked[name]
+			if !ok {
+				result = connectionHealth{name: name, unknown: true}
+			}
+
+			switch result.status() {
+			case healthStatusHealthy:
+				healthy = append(healthy, name)
+			case healthStatusUnhealthy:
+				unhealthy[name] = result.err.Error()
+			case healthStatusQuarantined:
+				quarantined[name] = result.quarantinedUntil.UTC().Format(time.RFC3339)
+			case healthStatusUnknown:
+				unknown = append(unknown, name)
+			}
+			connections = append(connections, result.summary())
+		}
+
+		return &logical.Response{
+			Data: map[string]interface{}{
+				"total":       len(names),
+				"healthy":     healthy,
+				"unhealthy":   unhealthy,
+				"quarantined": quarantined,
+				"unknown":     unknown,
+				"connections": connections,
+			},
+		}, nil
+	}
+}
+
+// checkHealth starts the named connection's plugin if needed and pings the
+// database. Quarantined connections aren't checked, so they aren't kept in
+// quarantine by the health check itself.
+func (b *databaseBackend) checkHealth(ctx context.Context, s logical.Storage, name string) connectionHealth {
+	b.RLock()
+	var until time.Time
+	if f, ok := b.failures[name]; ok && time.Now().Before(f.quarantinedUntil) {
+		until = f.quarantinedUntil
+	}
+	_, initialized := b.connections[name]
+	b.RUnlock()
+	if !until.IsZero() {
+		return connectionHealth{name: name, quarantinedUntil: until}
+	}
+
+	result := connectionHealth{name: name, initialized: initialized}
+
+	dbi, err := b.pluginInstance(ctx, s, name)
+	if err != nil {
+		result.err = err
+		result.unknown = ctx.Err() != nil
+		return result
+	}
+	defer dbi.release()
+
+	result.inFlight = len(dbi.creationSem)
+	result.maxInFlight = cap(dbi.creationSem)
+
+	if config, err := b.DatabaseConfig(ctx, s, name); err == nil {
+		if limiter := b.credsRateLimiter(name, config); limiter != nil {
+			result.rateLimit = limiter.limit
+			result.rateRemaining = limiter.remaining(time.Now())
+		}
+	}
+
+	// Plugins that can't ping are healthy once they have started
+	if dbi.supports(dbplugin.CapabilityPing) != nil {
+		return result
+	}
+
+	if err := dbplugin.Ping(ctx, dbi.Database); err != nil {
+		// A ping cut short by the timeout says nothing about the database
+		if ctx.Err() != nil {
+			result.unknown = true
+			return result
+		}
+
+		b.closeIfShutdown(name, dbi, err)
+		result.err = redactutil.Error(err)
+	}
+
+	return result
+}
+
+// checkIssuanceHealth pings the database of dbi, the named connection
+// configured by config, before credentials are issued against it, if the
+// connection sets issuance_ping_timeout. An error is returned if the database
+// doesn't answer within the timeout. Plugins that can't ping aren't checked.
+func (b *databaseBackend) checkIssuanceHealth(ctx context.Context, dbi *dbPluginInstance, name string, config *DatabaseConfig) error {
+	if config.IssuancePingTimeout <= 0 || dbi.supports(dbplugin.CapabilityPing) != nil {
+		return nil
+	}
+
+	pingCtx, cancel := context.WithTimeout(ctx, time.Duration(config.IssuancePingTimeout)*time.Second)
+	defer cancel()
+
+	err := dbplugin.Ping(pingCtx, dbi.Database)
+	switch {
+	case err == nil:
+		return nil
+	case isPluginShutdown(err) || ctx.Err() != nil:
+		// Left to the caller, which reconnects to plugins that shut down
+		return err
+	case pingCtx.Err() != nil:
+		err = fmt.Errorf("no answer to a ping within %ds", config.IssuancePingTimeout)
+	}
+
+	incrConnectionCounter("unhealthy", name)
+	return fmt.Errorf("connection unhealthy, refusing to issue credentials: %s", redactutil.Error(err))
+}
+
+const pathHealthHelpSyn = `
+Report the health of all connections.
+`
+
+const pathHealthHelpDesc = `
+This path checks every configured connection and returns a summary: the total
+number of connections, the names of the healthy ones, the unhealthy ones along
+with their last error, the quarantined ones along with when their quarantine
+ends, and the ones whose health is unknown. The "connections" field holds the
+details of each connection, including whether its plugin was already running
+how many credential creations are in flight against it and, for connections
+setting creds_rate_limit, how many credentials may be requested right away.
+
+A connection is healthy if its plugin can be started and, for plugins that
+support it, the database answers a ping. Quarantined connections are not
+checked. Connections are checked concurrently, and any that haven't answered
+before the "timeout" parameter, 10 seconds by default, are reported as unknown.
+`
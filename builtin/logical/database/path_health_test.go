@@ -0,0 +1,232 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/builtin/logical/database/dbplugin"
+	"github.com/hashicorp/vault/logical"
+)
+
+// fakePing returns a ping function for a fakeDatabase that returns err, nil
+// being a healthy database.
+func fakePing(err error) func(context.Context) error {
+	return func(_ context.Context) error {
+		return err
+	}
+}
+
+// fakePingBlock is a ping function for a fakeDatabase that waits until its
+// context is done.
+func fakePingBlock(ctx context.Context) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestBackend_healthReplacedDuringPing(t *testing.T) {
+	b, storage := getBackend(t)
+
+	// The connection is replaced while the failing ping runs
+	var replacementDBI *dbPluginInstance
+	stale := &fakeDatabase{
+		ping: func(_ context.Context) error {
+			b.Lock()
+			b.clearConnection("fake")
+			b.connections["fake"] = replacementDBI
+			b.Unlock()
+			return dbplugin.ErrPluginShutdown
+		},
+	}
+	staleDBI, err := newDBPluginInstance(context.Background(), stale, &DatabaseConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	testFakeConnection(t, b, storage, staleDBI, &roleEntry{})
+
+	replacement := &fakeDatabase{}
+	replacementDBI, err = newDBPluginInstance(context.Background(), replacement, &DatabaseConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result := b.checkHealth(context.Background(), storage, "fake")
+	if result.err == nil {
+		t.Fatal("expected the ping to fail")
+	}
+	if b.connections["fake"] != replacementDBI || replacement.isClosed() {
+		t.Fatal("expected the replacement connection to be kept")
+	}
+
+	b.drains.Wait()
+	if !stale.isClosed() {
+		t.Fatal("expected the pinged connection to be closed")
+	}
+}
+
+func TestBackend_health(t *testing.T) {
+	b, storage := getBackend(t)
+
+	oldTimeout := healthCheckTimeout
+	healthCheckTimeout = 100 * time.Millisecond
+	defer func() { healthCheckTimeout = oldTimeout }()
+
+	connections := map[string]dbplugin.Database{
+		"healthy":  &fakeDatabase{ping: fakePing(nil)},
+		"noping":   &fakeDatabase{},
+		"down":     &fakeDatabase{ping: fakePing(errors.New("connection refused"))},
+		"hanging":  &fakeDatabase{ping: fakePingBlock},
+		"missing":  nil,
+		"isolated": nil,
+	}
+	for name, db := range connections {
+		entry, err := logical.StorageEntryJSON("config/"+name, &DatabaseConfig{
+			PluginName: "missing-database-plugin",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := storage.Put(context.Background(), entry); err != nil {
+			t.Fatal(err)
+		}
+
+		if db == nil {
+			continue
+		}
+		dbi, err := newDBPluginInstance(context.Background(), db, &DatabaseConfig{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		b.connections[name] = dbi
+	}
+	quarantinedUntil := time.Now().Add(time.Hour)
+	b.failures["isolated"] = &connectionFailures{count: 5, quarantinedUntil: quarantinedUntil}
+
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "health",
+		Storage:   storage,
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%s resp:%#v\n", err, resp)
+	}
+
+	if resp.Data["total"] != 6 {
+		t.Fatalf("bad total: %#v", resp.Data["total"])
+	}
+	if !reflect.DeepEqual(resp.Data["healthy"], []string{"healthy", "noping"}) {
+		t.Fatalf("bad healthy: %#v", resp.Data["healthy"])
+	}
+
+	unhealthy := resp.Data["unhealthy"].(map[string]interface{})
+	if len(unhealthy) != 2 {
+		t.Fatalf("bad unhealthy: %#v", unhealthy)
+	}
+	if unhealthy["down"] != "connection refused" {
+		t.Fatalf("bad error for down: %#v", unhealthy["down"])
+	}
+	if unhealthy["missing"] == nil {
+		t.Fatalf("bad unhealthy: %#v", unhealthy)
+	}
+	if !reflect.DeepEqual(resp.Data["unknown"], []string{"hanging"}) {
+		t.Fatalf("bad unknown: %#v", resp.Data["unknown"])
+	}
+
+	expected := map[string]interface{}{
+		"isolated": quarantinedUntil.UTC().Format(time.RFC3339),
+	}
+	if !reflect.DeepEqual(resp.Data["quarantined"], expected) {
+		t.Fatalf("bad quarantined: %#v", resp.Data["quarantined"])
+	}
+
+	connectionsResp := resp.Data["connections"].([]map[string]interface{})
+	if len(connectionsResp) != 6 {
+		t.Fatalf("bad connections: %#v", connectionsResp)
+	}
+	summary := connectionsResp[2]
+	expected = map[string]interface{}{
+		"name":                     "healthy",
+		"status":                   "healthy",
+		"initialized":              true,
+		"in_flight_creations":      0,
+		"max_concurrent_creations": 0,
+		"creds_rate_limit":         0,
+	}
+	if !reflect.DeepEqual(summary, expected) {
+		t.Fatalf("bad summary: %#v", summary)
+	}
+	if connectionsResp[4]["name"] != "missing" || connectionsResp[4]["initialized"] != false || connectionsResp[4]["error"] == nil {
+		t.Fatalf("bad summary: %#v", connectionsResp[4])
+	}
+
+	// The timeout can be set per request
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "health",
+		Storage:   storage,
+		Data: map[string]interface{}{
+			"timeout": "1s",
+		},
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%s resp:%#v\n", err, resp)
+	}
+	if !reflect.DeepEqual(resp.Data["unknown"], []string{"hanging"}) {
+		t.Fatalf("bad unknown: %#v", resp.Data["unknown"])
+	}
+}
+
+func TestBackend_credsIssuanceHealth(t *testing.T) {
+	b, storage := getBackend(t)
+
+	credsReq := &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "creds/readonly",
+		Storage:   storage,
+	}
+
+	testCases := []struct {
+		db          dbplugin.Database
+		pingTimeout int
+		expectErr   string
+	}{
+		{&fakeDatabase{ping: fakePing(nil)}, 1, ""},
+		{&fakeDatabase{ping: fakePing(errors.New("connection refused"))}, 1, "connection unhealthy, refusing to issue credentials: connection refused"},
+		{&fakeDatabase{ping: fakePingBlock}, 1, "connection unhealthy, refusing to issue credentials: no answer to a ping within 1s"},
+		{&fakeDatabase{}, 1, ""},
+		// Off by default
+		{&fakeDatabase{ping: fakePing(errors.New("connection refused"))}, 0, ""},
+	}
+
+	for i, tc := range testCases {
+		dbi, err := newDBPluginInstance(context.Background(), tc.db, &DatabaseConfig{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		testFakeConnection(t, b, storage, dbi, &roleEntry{})
+		entry, err := logical.StorageEntryJSON("config/fake", &DatabaseConfig{
+			PluginName:          "fake",
+			AllowedRoles:        []string{"*"},
+			IssuancePingTimeout: tc.pingTimeout,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := storage.Put(context.Background(), entry); err != nil {
+			t.Fatal(err)
+		}
+
+		resp, err := b.HandleRequest(context.Background(), credsReq)
+		if tc.expectErr == "" {
+			if err != nil || (resp != nil && resp.IsError()) {
+				t.Fatalf("%d: err:%s resp:%#v\n", i, err, resp)
+			}
+			continue
+		}
+		if err == nil || err.Error() != tc.expectErr {
+			t.Fatalf("%d: expected error %q, got: %v", i, tc.expectErr, err)
+		}
+	}
+}
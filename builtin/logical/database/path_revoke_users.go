@@ -0,0 +1,247 @@
+package database
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/vault/helper/redactutil"
+	"github.com/hashicorp/vault/helper/strutil"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+// issuedUserPath is the storage prefix of the index of issued users, which
+// maps the usernames of outstanding credentials back to their role and
+// connection. Like leases, it is local to each cluster.
+const issuedUserPath = "issued-user/"
+
+// Results of revoking a user matched by revoke-users.
+const (
+	revokeUserStatusMatched        = "matched"
+	revokeUserStatusRevoked        = "revoked"
+	revokeUserStatusAlreadyRevoked = "already_revoked"
+	revokeUserStatusFailed         = "failed"
+)
+
+// issuedUser records a user created for a lease. ExpiresAt is when the lease
+// ends, kept up to date as it's renewed; it's zero for users indexed before it
+// was recorded. Revoked is set once the user has been revoked through
+// revoke-users, ahead of its lease.
+type issuedUser struct {
+	Username   string    `json:"username"`
+	Role       string    `json:"role"`
+	Connection string    `json:"connection"`
+	CreatedAt  time.Time `json:"created_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	Revoked    bool      `json:"revoked"`
+}
+
+// issuedUserKey returns the storage key of username's index entry. Usernames
+// are hashed since they may hold characters that aren't valid in keys.
+func issuedUserKey(username string) string {
+	sum := sha256.Sum256([]byte(username))
+	return issuedUserPath + hex.EncodeToString(sum[:])
+}
+
+func putIssuedUser(ctx context.Context, s logical.Storage, user *issuedUser) error {
+	entry, err := logical.StorageEntryJSON(issuedUserKey(user.Username), user)
+	if err != nil {
+		return err
+	}
+
+	return s.Put(ctx, entry)
+}
+
+// getIssuedUser returns the index entry of username, or nil if the user was
+// issued before the index existed or has since been revoked by its lease.
+func getIssuedUser(ctx context.Context, s logical.Storage, username string) (*issuedUser, error) {
+	entry, err := s.Get(ctx, issuedUserKey(username))
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var user issuedUser
+	if err := entry.DecodeJSON(&user); err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+func deleteIssuedUser(ctx context.Context, s logical.Storage, username string) error {
+	return s.Delete(ctx, issuedUserKey(username))
+}
+
+// listIssuedUsers returns every entry of the index, sorted by username.
+func listIssuedUsers(ctx context.Context, s logical.Storage) ([]*issuedUser, error) {
+	keys, err := s.List(ctx, issuedUserPath)
+	if err != nil {
+		return nil, err
+	}
+
+	users := make([]*issuedUser, 0, len(keys))
+	for _, key := range keys {
+		entry, err := s.Get(ctx, issuedUserPath+key)
+		if err != nil {
+			return nil, err
+		}
+		if entry == nil {
+			continue
+		}
+
+		var user issuedUser
+		if err := entry.DecodeJSON(&user); err != nil {
+			return nil, err
+		}
+		users = append(users, &user)
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].Username < users[j].Username })
+
+	return users, nil
+}
+
+func pathRevokeUsers(b *databaseBackend) *framework.Path {
+	return &framework.Path{
+		Pattern: "revoke-users/?$",
+		Fields: map[string]*framework.FieldSchema{
+			"username": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `The username of the credentials to revoke. A
+				leading or trailing "*" matches any prefix or suffix.`,
+			},
+
+			"dry_run": &framework.FieldSchema{
+				Type:        framework.TypeBool,
+				Description: "If set, the matching users are listed but not revoked.",
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathRevokeUsersWrite(),
+		},
+
+		HelpSynopsis:    pathRevokeUsersHelpSyn,
+		HelpDescription: pathRevokeUsersHelpDesc,
+	}
+}
+
+func (b *databaseBackend) pathRevokeUsersWrite() framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+		pattern := data.Get("username").(string)
+		if strings.Trim(pattern, "*") == "" {
+			return logical.ErrorResponse("username must be given and cannot match every user"), nil
+		}
+		dryRun := data.Get("dry_run").(bool)
+
+		users, err := listIssuedUsers(ctx, req.Storage)
+		if err != nil {
+			return nil, err
+		}
+
+		var matches []*issuedUser
+		for _, user := range users {
+			if strutil.GlobbedStringsMatch(pattern, user.Username) {
+				matches = append(matches, user)
+			}
+		}
+
+		results := make([]map[string]interface{}, 0, len(matches))
+		for _, user := range matches {
+			result := map[string]interface{}{
+				"username":   user.Username,
+				"role":       user.Role,
+				"connection": user.Connection,
+				"created_at": user.CreatedAt.Format(time.RFC3339),
+			}
+			results = append(results, result)
+
+			switch {
+			case user.Revoked:
+				result["status"] = revokeUserStatusAlreadyRevoked
+				continue
+			case dryRun:
+				result["status"] = revokeUserStatusMatched
+				continue
+			}
+
+			if err := b.revokeIssuedUser(ctx, req.Storage, user); err != nil {
+				b.logger.Warn("database: failed to revoke matched user", "name", user.Connection, "error", redactutil.Error(err))
+				result["status"] = revokeUserStatusFailed
+				result["error"] = redactutil.Error(err).Error()
+				continue
+			}
+			result["status"] = revokeUserStatusRevoked
+		}
+
+		return &logical.Response{
+			Data: map[string]interface{}{
+				"dry_run": dryRun,
+				"users":   results,
+			},
+		}, nil
+	}
+}
+
+// revokeIssuedUser runs the revocation statements of user's role and marks it
+// revoked, so that its lease doesn't revoke it again when it ends. Nothing is
+// done if the user was revoked since it was listed.
+func (b *databaseBackend) revokeIssuedUser(ctx context.Context, s logical.Storage, user *issuedUser) error {
+	lock := b.issuedUserLock(user.Username)
+	lock.Lock()
+	defer lock.Unlock()
+
+	user, err := getIssuedUser(ctx, s, user.Username)
+	if err != nil || user == nil || user.Revoked {
+		return err
+	}
+
+	role, err := b.Role(ctx, s, user.Role)
+	if err != nil {
+		return err
+	}
+	if role == nil {
+		return fmt.Errorf("could not find role with name: %s", user.Role)
+	}
+
+	config, err := b.DatabaseConfig(ctx, s, user.Connection)
+	if err != nil {
+		return err
+	}
+
+	statements := role.statements(config)
+	b.logRevocationStatements(config, user.Connection, statements, user.Username)
+	if err := b.revokeUser(ctx, s, user.Connection, statements, user.Username); err != nil {
+		return err
+	}
+
+	user.Revoked = true
+	return putIssuedUser(ctx, s, user)
+}
+
+const pathRevokeUsersHelpSyn = `
+Revoke outstanding credentials by username.
+`
+
+const pathRevokeUsersHelpDesc = `
+This path finds the outstanding credentials whose username matches the
+"username" parameter, across every role and connection of the mount, and
+revokes them by running their role's revocation statements. A leading or
+trailing "*" in the username matches any prefix or suffix. The response lists
+each matching user along with its role, connection and whether it was revoked,
+and the error if it couldn't be.
+
+The leases of revoked users are kept until they end, but can no longer be
+renewed, and ending them doesn't run the revocation statements again. With
+"dry_run" set, the matching users are listed without being revoked.
+
+Users are found through an index kept as credentials are issued, so
+credentials issued before the index existed aren't found.
+`
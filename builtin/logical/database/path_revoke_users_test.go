@@ -0,0 +1,143 @@
+package database
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/vault/builtin/logical/database/dbplugin"
+	"github.com/hashicorp/vault/logical"
+)
+
+func TestBackend_revokeUsers(t *testing.T) {
+	b, storage := getBackend(t)
+
+	var revoked []string
+	db := &fakeDatabase{
+		revokeUser: func(_ context.Context, _ dbplugin.Statements, username string) error {
+			revoked = append(revoked, username)
+			return nil
+		},
+	}
+	dbi, err := newDBPluginInstance(context.Background(), db, &DatabaseConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	testFakeConnection(t, b, storage, dbi, &roleEntry{})
+
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "creds/readonly",
+		Storage:   storage,
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%s resp:%#v\n", err, resp)
+	}
+	// Index a second user directly
+	if err := putIssuedUser(context.Background(), storage, &issuedUser{
+		Username:   "other",
+		Role:       "readonly",
+		Connection: "fake",
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	revokeUsersReq := func(username string, dryRun bool) *logical.Response {
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "revoke-users",
+			Storage:   storage,
+			Data: map[string]interface{}{
+				"username": username,
+				"dry_run":  dryRun,
+			},
+		})
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("err:%s resp:%#v\n", err, resp)
+		}
+		return resp
+	}
+	statuses := func(resp *logical.Response) map[string]string {
+		statuses := make(map[string]string)
+		for _, user := range resp.Data["users"].([]map[string]interface{}) {
+			statuses[user["username"].(string)] = user["status"].(string)
+		}
+		return statuses
+	}
+
+	// A dry run only lists the matches
+	resp = revokeUsersReq("us*", true)
+	if !reflect.DeepEqual(statuses(resp), map[string]string{"user": revokeUserStatusMatched}) {
+		t.Fatalf("bad users: %#v", resp.Data)
+	}
+	if len(revoked) != 0 {
+		t.Fatalf("expected nothing to be revoked, got: %v", revoked)
+	}
+
+	resp = revokeUsersReq("us*", false)
+	if !reflect.DeepEqual(statuses(resp), map[string]string{"user": revokeUserStatusRevoked}) {
+		t.Fatalf("bad users: %#v", resp.Data)
+	}
+	if !reflect.DeepEqual(revoked, []string{"user"}) {
+		t.Fatalf("expected user to be revoked, got: %v", revoked)
+	}
+
+	// Revoked users are reported but not revoked again
+	resp = revokeUsersReq("*er", false)
+	if !reflect.DeepEqual(statuses(resp), map[string]string{"user": revokeUserStatusAlreadyRevoked, "other": revokeUserStatusRevoked}) {
+		t.Fatalf("bad users: %#v", resp.Data)
+	}
+
+	// Matching every user is refused
+	for _, pattern := range []string{"", "*", "**", "***"} {
+		resp, err = b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "revoke-users",
+			Storage:   storage,
+			Data:      map[string]interface{}{"username": pattern},
+		})
+		if err != nil || resp == nil || !resp.IsError() {
+			t.Fatalf("expected error for %q, got: %#v", pattern, resp)
+		}
+	}
+
+	secret := &logical.Secret{
+		InternalData: map[string]interface{}{
+			"secret_type": "creds",
+			"username":    "user",
+			"role":        "readonly",
+		},
+	}
+
+	// The lease of a revoked user can't be renewed
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.RenewOperation,
+		Storage:   storage,
+		Secret:    secret,
+	})
+	if err != nil || resp == nil || !resp.IsError() {
+		t.Fatalf("expected error, got: %#v", resp)
+	}
+
+	// Ending the lease doesn't revoke the user again, and drops it from the
+	// index
+	revoked = nil
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.RevokeOperation,
+		Storage:   storage,
+		Secret:    secret,
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%s resp:%#v\n", err, resp)
+	}
+	if len(revoked) != 0 {
+		t.Fatalf("expected nothing to be revoked, got: %v", revoked)
+	}
+	issued, err := getIssuedUser(context.Background(), storage, "user")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if issued != nil {
+		t.Fatalf("expected the user to be dropped from the index, got: %#v", issued)
+	}
+}
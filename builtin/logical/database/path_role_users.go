@@ -0,0 +1,96 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+// issuedUserStaleAfter is how long past the end of its lease an issued user's
+// index entry is considered stale. Vault retries failed revocations for a
+// while after a lease ends, so entries are only stale once no revocation is
+// coming that would remove them, for example because the lease was forcibly
+// revoked or Vault stopped part way through a revocation.
+const issuedUserStaleAfter = time.Hour
+
+func pathRoleUsers(b *databaseBackend) *framework.Path {
+	return &framework.Path{
+		Pattern: "roles/" + framework.GenericNameRegex("name") + "/users$",
+		Fields: map[string]*framework.FieldSchema{
+			"name": {
+				Type:        framework.TypeString,
+				Description: "Name of the role.",
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation: b.pathRoleUsersRead(),
+		},
+
+		HelpSynopsis:    pathRoleUsersHelpSyn,
+		HelpDescription: pathRoleUsersHelpDesc,
+	}
+}
+
+func (b *databaseBackend) pathRoleUsersRead() framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+		name := b.canonicalName(data.Get("name").(string))
+
+		users, err := listIssuedUsers(ctx, req.Storage)
+		if err != nil {
+			return nil, err
+		}
+
+		now := time.Now()
+		live := []map[string]interface{}{}
+		stale := []map[string]interface{}{}
+		for _, user := range users {
+			// Users revoked through revoke-users are already gone from the
+			// database, even though their lease remains
+			if user.Role != name || user.Revoked {
+				continue
+			}
+
+			info := map[string]interface{}{
+				"username":   user.Username,
+				"connection": user.Connection,
+				"created_at": user.CreatedAt.Format(time.RFC3339),
+			}
+			if !user.ExpiresAt.IsZero() {
+				info["expires_at"] = user.ExpiresAt.Format(time.RFC3339)
+				if now.After(user.ExpiresAt.Add(issuedUserStaleAfter)) {
+					stale = append(stale, info)
+					continue
+				}
+			}
+			live = append(live, info)
+		}
+
+		return &logical.Response{
+			Data: map[string]interface{}{
+				"users": live,
+				"stale": stale,
+			},
+		}, nil
+	}
+}
+
+const pathRoleUsersHelpSyn = `
+List the users currently issued for a role.
+`
+
+const pathRoleUsersHelpDesc = `
+This path lists the users created for the outstanding credentials of a role,
+with their connection, when they were created and when their lease ends. Users
+are found through an index kept as credentials are issued and revoked, so
+credentials issued before the index existed aren't listed, and users revoked
+through the "revoke-users" path are left out.
+
+Users whose lease ended more than an hour ago without being revoked, for
+example because the lease was forcibly revoked, are listed separately as
+"stale": their index entry was never removed, and the user may remain in the
+database. They are pruned from the index once the issued_user_retention mount
+option has passed since their lease ended.
+`
@@ -0,0 +1,118 @@
+package database
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/logical"
+)
+
+func TestBackend_roleUsers(t *testing.T) {
+	b, storage := getBackend(t)
+
+	dbi, err := newDBPluginInstance(context.Background(), &fakeDatabase{}, &DatabaseConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	testFakeConnection(t, b, storage, dbi, &roleEntry{DefaultTTL: time.Hour})
+
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "creds/readonly",
+		Storage:   storage,
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%s resp:%#v\n", err, resp)
+	}
+
+	for _, user := range []*issuedUser{
+		// A user whose lease ended long ago without being revoked
+		{Username: "crashed", Role: "readonly", Connection: "fake", ExpiresAt: time.Now().Add(-2 * time.Hour)},
+		// A user indexed before expirations were recorded
+		{Username: "legacy", Role: "readonly", Connection: "fake"},
+		{Username: "revoked", Role: "readonly", Connection: "fake", Revoked: true},
+		{Username: "other", Role: "writer", Connection: "fake"},
+	} {
+		if err := putIssuedUser(context.Background(), storage, user); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	readUsers := func() (live, stale []map[string]interface{}) {
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      "roles/readonly/users",
+			Storage:   storage,
+		})
+		if err != nil || resp == nil || resp.IsError() {
+			t.Fatalf("err:%s resp:%#v\n", err, resp)
+		}
+		return resp.Data["users"].([]map[string]interface{}), resp.Data["stale"].([]map[string]interface{})
+	}
+	usernames := func(users []map[string]interface{}) []string {
+		names := []string{}
+		for _, user := range users {
+			names = append(names, user["username"].(string))
+		}
+		return names
+	}
+
+	live, stale := readUsers()
+	if !reflect.DeepEqual(usernames(live), []string{"legacy", "user"}) || !reflect.DeepEqual(usernames(stale), []string{"crashed"}) {
+		t.Fatalf("bad users: live %#v, stale %#v", live, stale)
+	}
+	expiresAt, err := time.Parse(time.RFC3339, live[1]["expires_at"].(string))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := time.Until(expiresAt); d < 59*time.Minute || d > time.Hour {
+		t.Fatalf("expected the lease to end in an hour, got %s", d)
+	}
+	if _, ok := live[0]["expires_at"]; ok {
+		t.Fatalf("expected no expiration for a legacy entry, got: %#v", live[0])
+	}
+
+	secret := &logical.Secret{
+		LeaseOptions: logical.LeaseOptions{
+			TTL:       time.Hour,
+			IssueTime: time.Now().Add(-30 * time.Minute),
+		},
+		InternalData: map[string]interface{}{
+			"secret_type": "creds",
+			"username":    "user",
+			"role":        "readonly",
+		},
+	}
+
+	// Renewing the lease moves the expiration along
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.RenewOperation,
+		Storage:   storage,
+		Secret:    secret,
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%s resp:%#v\n", err, resp)
+	}
+	issued, err := getIssuedUser(context.Background(), storage, "user")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !issued.ExpiresAt.After(expiresAt) {
+		t.Fatalf("expected the expiration to move past %s, got %s", expiresAt, issued.ExpiresAt)
+	}
+
+	// Revoking the lease removes the user
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.RevokeOperation,
+		Storage:   storage,
+		Secret:    secret,
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%s resp:%#v\n", err, resp)
+	}
+	if live, _ := readUsers(); !reflect.DeepEqual(usernames(live), []string{"legacy"}) {
+		t.Fatalf("bad users: %#v", live)
+	}
+}
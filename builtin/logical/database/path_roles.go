@@ -2,11 +2,17 @@ package database
 
 import (
 	"context"
+	"fmt"
+	"strings"
 	"time"
 
 	"github.com/hashicorp/vault/builtin/logical/database/dbplugin"
+	"github.com/hashicorp/vault/helper/parseutil"
 	"github.com/hashicorp/vault/logical"
 	"github.com/hashicorp/vault/logical/framework"
+	"github.com/hashicorp/vault/plugins/helper/database/credsutil"
+	"github.com/hashicorp/vault/plugins/helper/database/dbutil"
+	"github.com/mitchellh/mapstructure"
 )
 
 func pathListRoles(b *databaseBackend) *framework.Path {
@@ -41,6 +47,12 @@ func pathRoles(b *databaseBackend) *framework.Path {
 				create and configure a user. See the plugin's API page for more
 				information on support and formatting for this parameter.`,
 			},
+			"allow_empty_creation": {
+				Type: framework.TypeBool,
+				Description: `If true, the role is written without creation
+				statements, for plugins that create users without them or
+				have default statements of their own.`,
+			},
 			"revocation_statements": {
 				Type: framework.TypeString,
 				Description: `Specifies the database statements to be executed
@@ -62,6 +74,119 @@ func pathRoles(b *databaseBackend) *framework.Path {
 				API page for more information on support and formatting for this
 				parameter.`,
 			},
+			"create_statements": {
+				Type: framework.TypeStringSlice,
+				Description: `Specifies an ordered list of database statements
+				executed to create a user. These run after any
+				creation_statements and before grant_statements, within the
+				same transaction.`,
+			},
+			"grant_statements": {
+				Type: framework.TypeStringSlice,
+				Description: `Specifies an ordered list of database statements
+				executed to grant privileges to a newly created user. These run
+				after creation_statements and create_statements, within the same
+				transaction.`,
+			},
+			"revoke_statements": {
+				Type: framework.TypeStringSlice,
+				Description: `Specifies an ordered list of database statements
+				executed to revoke a user's privileges. These run before any
+				revocation_statements, within the same transaction.`,
+			},
+			"revocation_steps": {
+				Type: framework.TypeSlice,
+				Description: `Specifies an ordered list of steps executed to
+				revoke a user, each an object with a "statement" and an
+				optional "continue_on_error" flag. A failing step whose flag is
+				set doesn't stop the revocation. These run before
+				revoke_statements, within the same transaction.`,
+			},
+
+			"statement_variables": {
+				Type: framework.TypeKVPairs,
+				Description: `Values substituted into the role's statements,
+				written as "{{key}}". Overrides the statement_variables of the
+				connection with the same keys.`,
+			},
+
+			"username_prefix": {
+				Type: framework.TypeString,
+				Description: `A string prepended to every username generated for
+				this role. Overrides the username_prefix of the connection.`,
+			},
+
+			"pool_class": {
+				Type: framework.TypeString,
+				Description: `The class of the connection pool credentials for this
+				role are created through, as named in the pool_classes of the
+				connection. Defaults to the connection's main pool.`,
+			},
+
+			"password_min_uppercase": {
+				Type: framework.TypeInt,
+				Description: `The minimum number of upper case letters in
+				passwords generated for this role.`,
+			},
+			"password_min_lowercase": {
+				Type: framework.TypeInt,
+				Description: `The minimum number of lower case letters in
+				passwords generated for this role.`,
+			},
+			"password_min_digits": {
+				Type: framework.TypeInt,
+				Description: `The minimum number of digits in passwords
+				generated for this role.`,
+			},
+			"password_min_special": {
+				Type: framework.TypeInt,
+				Description: `The minimum number of special characters in
+				passwords generated for this role.`,
+			},
+
+			"credential_format": {
+				Type: framework.TypeString,
+				Description: `The format in which issued credentials are returned.
+				One of "default", "jdbc", "env" or "all". Defaults to returning the
+				plain username and password.`,
+			},
+
+			"credential_type": {
+				Type: framework.TypeString,
+				Description: `The type of credential issued. One of "password" or
+				"client_certificate". Client certificates are signed by the
+				connection's client_certificate_ca and returned along with
+				the password. Defaults to "password".`,
+			},
+
+			"introspect_grants": {
+				Type: framework.TypeBool,
+				Description: `If true, the privileges held by each newly created
+				user are looked up and summarized in the "grants" field of the
+				response. Only supported by some plugins.`,
+			},
+
+			"allowed_db_type": {
+				Type: framework.TypeString,
+				Description: `The database type the role's statements are written
+				for, such as "postgres" or "mysql". If set, the role can only be
+				associated with connections whose plugin reports this type.`,
+			},
+
+			"allow_db_type_mismatch": {
+				Type: framework.TypeBool,
+				Description: `If true, the role is written even if the connection's
+				database type doesn't match allowed_db_type, for statements that
+				are compatible across database types.`,
+			},
+
+			"statement_timeout": {
+				Type: framework.TypeDurationSecond,
+				Description: `How long the role's creation or renewal statements
+				may run before they are cancelled. Overrides the
+				statement_timeout of the connection and can't exceed its
+				max_statement_timeout.`,
+			},
 
 			"default_ttl": {
 				Type:        framework.TypeDurationSecond,
@@ -85,9 +210,28 @@ func pathRoles(b *databaseBackend) *framework.Path {
 	}
 }
 
+func pathRoleMigrate(b *databaseBackend) *framework.Path {
+	return &framework.Path{
+		Pattern: "roles/" + framework.GenericNameRegex("name") + "/migrate$",
+		Fields: map[string]*framework.FieldSchema{
+			"name": {
+				Type:        framework.TypeString,
+				Description: "Name of the role.",
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathRoleMigrateWrite(),
+		},
+
+		HelpSynopsis:    pathRoleMigrateHelpSyn,
+		HelpDescription: pathRoleMigrateHelpDesc,
+	}
+}
+
 func (b *databaseBackend) pathRoleDelete() framework.OperationFunc {
 	return func(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
-		err := req.Storage.Delete(ctx, "role/"+data.Get("name").(string))
+		err := req.Storage.Delete(ctx, "role/"+b.canonicalName(data.Get("name").(string)))
 		if err != nil {
 			return nil, err
 		}
@@ -106,15 +250,37 @@ func (b *databaseBackend) pathRoleRead() framework.OperationFunc {
 			return nil, nil
 		}
 
+		effectiveTimeout := role.StatementTimeout
+		if config, err := b.DatabaseConfig(ctx, req.Storage, role.DBName); err == nil {
+			effectiveTimeout = role.statementTimeout(config)
+		}
+
 		return &logical.Response{
 			Data: map[string]interface{}{
-				"db_name":               role.DBName,
-				"creation_statements":   role.Statements.CreationStatements,
-				"revocation_statements": role.Statements.RevocationStatements,
-				"rollback_statements":   role.Statements.RollbackStatements,
-				"renew_statements":      role.Statements.RenewStatements,
-				"default_ttl":           role.DefaultTTL.Seconds(),
-				"max_ttl":               role.MaxTTL.Seconds(),
+				"db_name":                     role.DBName,
+				"creation_statements":         role.Statements.CreationStatements,
+				"revocation_statements":       role.Statements.RevocationStatements,
+				"rollback_statements":         role.Statements.RollbackStatements,
+				"renew_statements":            role.Statements.RenewStatements,
+				"create_statements":           role.Statements.CreateStatements,
+				"grant_statements":            role.Statements.GrantStatements,
+				"revoke_statements":           role.Statements.RevokeStatements,
+				"revocation_steps":            revocationStepsData(role.Statements.RevocationSteps),
+				"statement_variables":         role.StatementVariables,
+				"username_prefix":             role.UsernamePrefix,
+				"pool_class":                  role.PoolClass,
+				"password_min_uppercase":      role.PasswordComplexity.MinUppercase,
+				"password_min_lowercase":      role.PasswordComplexity.MinLowercase,
+				"password_min_digits":         role.PasswordComplexity.MinDigits,
+				"password_min_special":        role.PasswordComplexity.MinSpecial,
+				"credential_format":           role.CredentialFormat,
+				"credential_type":             role.CredentialType,
+				"introspect_grants":           role.IntrospectGrants,
+				"allowed_db_type":             role.AllowedDBType,
+				"statement_timeout":           role.StatementTimeout.Seconds(),
+				"effective_statement_timeout": effectiveTimeout.Seconds(),
+				"default_ttl":                 role.DefaultTTL.Seconds(),
+				"max_ttl":                     role.MaxTTL.Seconds(),
 			},
 		}, nil
 	}
@@ -133,12 +299,20 @@ func (b *databaseBackend) pathRoleList() framework.OperationFunc {
 
 func (b *databaseBackend) pathRoleCreate() framework.OperationFunc {
 	return func(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
-		name := data.Get("name").(string)
+		name := b.canonicalName(data.Get("name").(string))
 		if name == "" {
 			return logical.ErrorResponse("empty role name attribute given"), nil
 		}
 
-		dbName := data.Get("db_name").(string)
+		collisions, err := b.caseCollisions(ctx, req.Storage, "role/", name)
+		if err != nil {
+			return nil, err
+		}
+		if err := b.collisionError("role", name, collisions); err != nil {
+			return logical.ErrorResponse(err.Error()), nil
+		}
+
+		dbName := b.canonicalName(data.Get("db_name").(string))
 		if dbName == "" {
 			return logical.ErrorResponse("empty database name attribute given"), nil
 		}
@@ -148,6 +322,111 @@ func (b *databaseBackend) pathRoleCreate() framework.OperationFunc {
 		revocationStmts := data.Get("revocation_statements").(string)
 		rollbackStmts := data.Get("rollback_statements").(string)
 		renewStmts := data.Get("renew_statements").(string)
+		createStmts := data.Get("create_statements").([]string)
+		grantStmts := data.Get("grant_statements").([]string)
+		revokeStmts := data.Get("revoke_statements").([]string)
+		revocationSteps, err := parseRevocationSteps(data.Get("revocation_steps").([]interface{}))
+		if err != nil {
+			return logical.ErrorResponse(err.Error()), nil
+		}
+
+		// Plugins that don't run the statement lists would silently ignore
+		// them, creating users without the privileges they grant
+		var listsSupported bool
+		if len(createStmts) > 0 || len(grantStmts) > 0 || len(revokeStmts) > 0 {
+			listsSupported, err = b.connectionSupports(ctx, req.Storage, dbName, dbplugin.CapabilityStatementLists)
+			if err != nil {
+				return logical.ErrorResponse(fmt.Sprintf("error checking plugin of database %q: %s", dbName, err)), nil
+			}
+			if !listsSupported {
+				return logical.ErrorResponse(fmt.Sprintf("the plugin of database %q does not support create_statements, grant_statements or revoke_statements; use creation_statements and revocation_statements instead", dbName)), nil
+			}
+		}
+		if len(revocationSteps) > 0 {
+			supported, err := b.connectionSupports(ctx, req.Storage, dbName, dbplugin.CapabilityRevocationSteps)
+			if err != nil {
+				return logical.ErrorResponse(fmt.Sprintf("error checking plugin of database %q: %s", dbName, err)), nil
+			}
+			if !supported {
+				return logical.ErrorResponse(fmt.Sprintf("the plugin of database %q does not support revocation_steps; use revocation_statements instead", dbName)), nil
+			}
+		}
+
+		usernamePrefix := data.Get("username_prefix").(string)
+		if usernamePrefix != "" {
+			config, err := b.DatabaseConfig(ctx, req.Storage, dbName)
+			if err != nil {
+				return logical.ErrorResponse(fmt.Sprintf("error reading database %q: %s", dbName, err)), nil
+			}
+			prefix := (&roleEntry{UsernamePrefix: usernamePrefix}).usernameConfig("", name, config).UsernamePrefix
+			if err := b.checkUsernamePrefix(ctx, req.Storage, dbName, prefix); err != nil {
+				return logical.ErrorResponse(fmt.Sprintf("error checking username_prefix against database %q: %s", dbName, err)), nil
+			}
+		}
+
+		passwordComplexity := credsutil.PasswordComplexity{
+			MinUppercase: data.Get("password_min_uppercase").(int),
+			MinLowercase: data.Get("password_min_lowercase").(int),
+			MinDigits:    data.Get("password_min_digits").(int),
+			MinSpecial:   data.Get("password_min_special").(int),
+		}
+		if !passwordComplexity.IsZero() {
+			config, err := b.DatabaseConfig(ctx, req.Storage, dbName)
+			if err != nil {
+				return logical.ErrorResponse(fmt.Sprintf("error reading database %q: %s", dbName, err)), nil
+			}
+			if err := passwordComplexity.Validate(passwordLength(config)); err != nil {
+				return logical.ErrorResponse(fmt.Sprintf("%s; lower the password minimums of the role or raise the max_password_length of database %q", err, dbName)), nil
+			}
+		}
+
+		credentialFormat := data.Get("credential_format").(string)
+		if _, ok := credentialFormatters[credentialFormat]; !ok {
+			return logical.ErrorResponse(fmt.Sprintf("unknown credential_format %q, must be one of: %s", credentialFormat, strings.Join(credentialFormatNames(), ", "))), nil
+		}
+
+		credentialType := data.Get("credential_type").(string)
+		switch credentialType {
+		case "", credentialTypePassword:
+		case credentialTypeClientCertificate:
+			config, err := b.DatabaseConfig(ctx, req.Storage, dbName)
+			if err != nil {
+				return logical.ErrorResponse(fmt.Sprintf("error reading database %q: %s", dbName, err)), nil
+			}
+			if config.ClientCertificateCA == "" {
+				return logical.ErrorResponse(fmt.Sprintf("database %q does not support client certificates; set client_certificate_ca on the connection", dbName)), nil
+			}
+		default:
+			return logical.ErrorResponse(fmt.Sprintf("unknown credential_type %q, must be one of: %s, %s", credentialType, credentialTypePassword, credentialTypeClientCertificate)), nil
+		}
+
+		introspectGrants := data.Get("introspect_grants").(bool)
+
+		allowedDBType := data.Get("allowed_db_type").(string)
+		if allowedDBType != "" && !data.Get("allow_db_type_mismatch").(bool) {
+			dbType, err := b.connectionType(ctx, req.Storage, dbName)
+			if err != nil {
+				return logical.ErrorResponse(fmt.Sprintf("error checking type of database %q: %s", dbName, err)), nil
+			}
+			if dbType != allowedDBType {
+				return logical.ErrorResponse(fmt.Sprintf("database %q is of type %q but the role only allows %q; set allow_db_type_mismatch to override", dbName, dbType, allowedDBType)), nil
+			}
+		}
+
+		statementTimeout := time.Duration(data.Get("statement_timeout").(int)) * time.Second
+		if statementTimeout < 0 {
+			return logical.ErrorResponse("statement_timeout cannot be negative"), nil
+		}
+		if statementTimeout > 0 {
+			config, err := b.DatabaseConfig(ctx, req.Storage, dbName)
+			if err != nil {
+				return logical.ErrorResponse(fmt.Sprintf("error reading database %q: %s", dbName, err)), nil
+			}
+			maxTimeout := time.Duration(config.MaxStatementTimeout) * time.Second
+			if maxTimeout > 0 && statementTimeout > maxTimeout {
+				return logical.ErrorResponse(fmt.Sprintf("statement_timeout cannot be greater than the max_statement_timeout of database %q (%s)", dbName, maxTimeout)), nil
+			}
+		}
 
 		// Get TTLs
 		defaultTTLRaw := data.Get("default_ttl").(int)
@@ -160,14 +439,52 @@ func (b *databaseBackend) pathRoleCreate() framework.OperationFunc {
 			RevocationStatements: revocationStmts,
 			RollbackStatements:   rollbackStmts,
 			RenewStatements:      renewStmts,
+			CreateStatements:     createStmts,
+			GrantStatements:      grantStmts,
+			RevokeStatements:     revokeStmts,
+			RevocationSteps:      revocationSteps,
+		}
+		if err := dbutil.ValidateTemplates(statements); err != nil {
+			return logical.ErrorResponse(err.Error()), nil
+		}
+
+		// Most plugins fail to create users without creation statements, so
+		// catch them missing now rather than when credentials are requested.
+		// Only the statements the plugin runs count. The other statements are
+		// optional, falling back to the plugin's defaults.
+		consumed := dbplugin.Statements{CreationStatements: creationStmts}
+		if listsSupported {
+			consumed.CreateStatements = createStmts
+			consumed.GrantStatements = grantStmts
+		}
+		if len(dbutil.CreationQueries(consumed)) == 0 && !data.Get("allow_empty_creation").(bool) {
+			fields := "creation_statements, create_statements or grant_statements"
+			if supported, err := b.connectionSupports(ctx, req.Storage, dbName, dbplugin.CapabilityStatementLists); err == nil && !supported {
+				fields = "creation_statements"
+			}
+			return logical.ErrorResponse(fmt.Sprintf("%s must be given; set allow_empty_creation if the plugin creates users without them", fields)), nil
+		}
+
+		statementVariables := data.Get("statement_variables").(map[string]string)
+		if err := dbutil.ValidateVariables(statementVariables); err != nil {
+			return logical.ErrorResponse(err.Error()), nil
 		}
 
 		// Store it
 		entry, err := logical.StorageEntryJSON("role/"+name, &roleEntry{
-			DBName:     dbName,
-			Statements: statements,
-			DefaultTTL: defaultTTL,
-			MaxTTL:     maxTTL,
+			DBName:             dbName,
+			Statements:         statements,
+			StatementVariables: statementVariables,
+			UsernamePrefix:     usernamePrefix,
+			PoolClass:          data.Get("pool_class").(string),
+			PasswordComplexity: passwordComplexity,
+			CredentialFormat:   credentialFormat,
+			CredentialType:     credentialType,
+			IntrospectGrants:   introspectGrants,
+			AllowedDBType:      allowedDBType,
+			StatementTimeout:   statementTimeout,
+			DefaultTTL:         defaultTTL,
+			MaxTTL:             maxTTL,
 		})
 		if err != nil {
 			return nil, err
@@ -176,15 +493,253 @@ func (b *databaseBackend) pathRoleCreate() framework.OperationFunc {
 			return nil, err
 		}
 
+		// Merge roles that differ only by case into this one
+		for _, collision := range collisions {
+			if err := req.Storage.Delete(ctx, "role/"+collision); err != nil {
+				return nil, err
+			}
+		}
+
 		return nil, nil
 	}
 }
 
+// pathRoleMigrateWrite rewrites a role stored with the legacy misspelled
+// statement fields in the current format, so that reading it no longer needs
+// to upgrade it.
+func (b *databaseBackend) pathRoleMigrateWrite() framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+		name := b.canonicalName(data.Get("name").(string))
+
+		entry, err := req.Storage.Get(ctx, "role/"+name)
+		if err != nil {
+			return nil, err
+		}
+		if entry == nil {
+			return logical.ErrorResponse(fmt.Sprintf("unknown role: %s", name)), nil
+		}
+
+		var upgradeCh upgradeCheck
+		if err := entry.DecodeJSON(&upgradeCh); err != nil {
+			return nil, err
+		}
+		if upgradeCh == (upgradeCheck{}) {
+			return &logical.Response{
+				Data: map[string]interface{}{
+					"migrated": false,
+				},
+			}, nil
+		}
+
+		role, err := b.Role(ctx, req.Storage, name)
+		if err != nil {
+			return nil, err
+		}
+
+		entry, err = logical.StorageEntryJSON("role/"+name, role)
+		if err != nil {
+			return nil, err
+		}
+		if err := req.Storage.Put(ctx, entry); err != nil {
+			return nil, err
+		}
+
+		return &logical.Response{
+			Data: map[string]interface{}{
+				"migrated": true,
+			},
+		}, nil
+	}
+}
+
+// parseRevocationSteps parses the "revocation_steps" of a role, a list of
+// objects with a required "statement" and an optional "continue_on_error".
+func parseRevocationSteps(raw []interface{}) ([]*dbplugin.RevocationStep, error) {
+	var steps []*dbplugin.RevocationStep
+	for i, item := range raw {
+		step := &dbplugin.RevocationStep{}
+		decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+			Result:           step,
+			TagName:          "json",
+			WeaklyTypedInput: true,
+			ErrorUnused:      true,
+		})
+		if err != nil {
+			return nil, err
+		}
+		if err := decoder.Decode(item); err != nil {
+			return nil, fmt.Errorf("invalid revocation_steps entry %d: %s", i, err)
+		}
+		if strings.TrimSpace(step.Statement) == "" {
+			return nil, fmt.Errorf("revocation_steps entry %d has no statement", i)
+		}
+
+		steps = append(steps, step)
+	}
+
+	return steps, nil
+}
+
+// revocationStepsData returns steps as they're given to the role endpoint.
+func revocationStepsData(steps []*dbplugin.RevocationStep) []map[string]interface{} {
+	data := make([]map[string]interface{}, 0, len(steps))
+	for _, step := range steps {
+		data = append(data, map[string]interface{}{
+			"statement":         step.Statement,
+			"continue_on_error": step.ContinueOnError,
+		})
+	}
+
+	return data
+}
+
+// connectionType returns the database type reported by the plugin of the named
+// connection, starting the plugin if needed.
+func (b *databaseBackend) connectionType(ctx context.Context, s logical.Storage, name string) (string, error) {
+	dbi, err := b.pluginInstance(ctx, s, name)
+	if err != nil {
+		return "", err
+	}
+	defer dbi.release()
+
+	return dbi.dbType, nil
+}
+
+// checkUsernamePrefix returns an error if the plugin of the named connection
+// refuses to generate usernames starting with prefix.
+func (b *databaseBackend) checkUsernamePrefix(ctx context.Context, s logical.Storage, name, prefix string) error {
+	dbi, err := b.pluginInstance(ctx, s, name)
+	if err != nil {
+		return err
+	}
+	defer dbi.release()
+
+	return dbi.checkUsernamePrefix(ctx, prefix)
+}
+
+// connectionSupports reports whether the plugin of the named connection
+// supports capability.
+func (b *databaseBackend) connectionSupports(ctx context.Context, s logical.Storage, name, capability string) (bool, error) {
+	dbi, err := b.pluginInstance(ctx, s, name)
+	if err != nil {
+		return false, err
+	}
+	defer dbi.release()
+
+	return dbi.supports(capability) == nil, nil
+}
+
 type roleEntry struct {
-	DBName     string              `json:"db_name" mapstructure:"db_name" structs:"db_name"`
-	Statements dbplugin.Statements `json:"statements" mapstructure:"statements" structs:"statements"`
-	DefaultTTL time.Duration       `json:"default_ttl" mapstructure:"default_ttl" structs:"default_ttl"`
-	MaxTTL     time.Duration       `json:"max_ttl" mapstructure:"max_ttl" structs:"max_ttl"`
+	DBName             string                       `json:"db_name" mapstructure:"db_name" structs:"db_name"`
+	Statements         dbplugin.Statements          `json:"statements" mapstructure:"statements" structs:"statements"`
+	StatementVariables map[string]string            `json:"statement_variables" mapstructure:"statement_variables" structs:"statement_variables"`
+	UsernamePrefix     string                       `json:"username_prefix" mapstructure:"username_prefix" structs:"username_prefix"`
+	PoolClass          string                       `json:"pool_class" mapstructure:"pool_class" structs:"pool_class"`
+	PasswordComplexity credsutil.PasswordComplexity `json:"password_complexity" mapstructure:"password_complexity" structs:"password_complexity"`
+	CredentialFormat   string                       `json:"credential_format" mapstructure:"credential_format" structs:"credential_format"`
+	CredentialType     string                       `json:"credential_type" mapstructure:"credential_type" structs:"credential_type"`
+	IntrospectGrants   bool                         `json:"introspect_grants" mapstructure:"introspect_grants" structs:"introspect_grants"`
+	AllowedDBType      string                       `json:"allowed_db_type" mapstructure:"allowed_db_type" structs:"allowed_db_type"`
+	StatementTimeout   time.Duration                `json:"statement_timeout" mapstructure:"statement_timeout" structs:"statement_timeout"`
+	DefaultTTL         time.Duration                `json:"default_ttl" mapstructure:"default_ttl" structs:"default_ttl"`
+	MaxTTL             time.Duration                `json:"max_ttl" mapstructure:"max_ttl" structs:"max_ttl"`
+}
+
+// usernameConfig returns the settings usernames are generated from for the
+// role, named roleName, when requested by displayName, along with the pool
+// class users are created through and the complexity of their passwords. The
+// role's username prefix overrides the one of the connection configured by
+// config. The connection's environment either starts the username or the
+// display name, which plugins may truncate.
+func (r *roleEntry) usernameConfig(displayName, roleName string, config *DatabaseConfig) dbplugin.UsernameConfig {
+	usernamePrefix := config.UsernamePrefix
+	if r.UsernamePrefix != "" {
+		usernamePrefix = r.UsernamePrefix
+	}
+
+	switch {
+	case config.Environment == "":
+	case config.EnvironmentPrefix:
+		usernamePrefix = config.Environment + "-" + usernamePrefix
+	default:
+		displayName = config.Environment + "-" + displayName
+	}
+
+	return dbplugin.UsernameConfig{
+		DisplayName:    displayName,
+		RoleName:       roleName,
+		UsernamePrefix: usernamePrefix,
+		PoolClass:      r.PoolClass,
+
+		PasswordMinUppercase: int32(r.PasswordComplexity.MinUppercase),
+		PasswordMinLowercase: int32(r.PasswordComplexity.MinLowercase),
+		PasswordMinDigits:    int32(r.PasswordComplexity.MinDigits),
+		PasswordMinSpecial:   int32(r.PasswordComplexity.MinSpecial),
+	}
+}
+
+// connectionUsernamePrefix returns the prefix of the usernames generated for
+// the roles of the connection configured by config that don't set their own.
+func connectionUsernamePrefix(config *DatabaseConfig) string {
+	return (&roleEntry{}).usernameConfig("", "", config).UsernamePrefix
+}
+
+// passwordLength returns the length of the passwords generated for the
+// connection configured by config: that of the SQL plugins, shortened to
+// the connection's max_password_length if it's lower.
+func passwordLength(config *DatabaseConfig) int {
+	length := credsutil.GeneratedPasswordLength
+	if raw, ok := config.ConnectionDetails["max_password_length"]; ok {
+		if maxLength, err := parseutil.ParseInt(raw); err == nil && maxLength > 0 && int(maxLength) < length {
+			length = int(maxLength)
+		}
+	}
+	return length
+}
+
+// statements returns the role's statements as they are run against the
+// connection configured by config, with the statement variables of both
+// substituted. The role's variables override the connection's.
+func (r *roleEntry) statements(config *DatabaseConfig) dbplugin.Statements {
+	if len(r.StatementVariables) == 0 {
+		return dbutil.SubstituteVariables(r.Statements, config.StatementVariables)
+	}
+
+	vars := make(map[string]string, len(config.StatementVariables)+len(r.StatementVariables))
+	for k, v := range config.StatementVariables {
+		vars[k] = v
+	}
+	for k, v := range r.StatementVariables {
+		vars[k] = v
+	}
+	return dbutil.SubstituteVariables(r.Statements, vars)
+}
+
+// statementTimeout returns how long the role's creation or renewal statements
+// may run against the connection configured by config, or zero if they are
+// unbounded. The role's own timeout overrides the connection's, and both are
+// capped by the connection's max_statement_timeout in case it was lowered
+// after the role was written.
+func (r *roleEntry) statementTimeout(config *DatabaseConfig) time.Duration {
+	timeout := r.StatementTimeout
+	if timeout == 0 {
+		timeout = time.Duration(config.StatementTimeout) * time.Second
+	}
+
+	maxTimeout := time.Duration(config.MaxStatementTimeout) * time.Second
+	if maxTimeout > 0 && (timeout == 0 || timeout > maxTimeout) {
+		timeout = maxTimeout
+	}
+	return timeout
+}
+
+// statementContext returns a context for running the role's creation or
+// renewal statements, bounded by the role's statement timeout.
+func (r *roleEntry) statementContext(ctx context.Context, config *DatabaseConfig) (context.Context, context.CancelFunc) {
+	if timeout := r.statementTimeout(config); timeout > 0 {
+		return context.WithTimeout(ctx, timeout)
+	}
+	return context.WithCancel(ctx)
 }
 
 const pathRoleHelpSyn = `
@@ -209,6 +764,25 @@ and "}}" to be replaced.
 
   * "expiration" - The timestamp when this user will expire.
 
+  * "timestamp" - The time the user was created, in RFC 3339 format.
+
+  * "random" - A random alphanumeric token, the same in every statement run
+    for the user.
+
+Statements may also call a few functions, written as "{{function argument}}":
+
+  * "upper name" and "lower name" - The value of a variable, such as "name",
+    in upper or lower case.
+
+  * "timestamp \"2006-01-02\"" - The time the user was created, formatted
+    with the given Go time layout.
+
+The "statement_variables" parameter sets values substituted into the role's
+statements like the variables above, such as a schema name used as
+"{{schema}}" or "{{upper schema}}". They override the statement_variables of
+the connection with the same keys. The names of the variables above can't be
+used.
+
 Example of a decent creation_statements for a postgresql database plugin:
 
 	CREATE ROLE "{{name}}" WITH
@@ -226,8 +800,105 @@ database plugin:
 	REVOKE USAGE ON SCHEMA public FROM {{name}};
 	DROP ROLE IF EXISTS {{name}};
 
+The "create_statements", "grant_statements" and "revoke_statements" parameters
+allow the steps of a user's lifecycle to be tracked as separate, ordered lists
+of statements rather than a single blob. They are supported by the SQL based
+database plugins and are executed within a single transaction in the following
+order:
+
+  * Creation: "creation_statements", then "create_statements", then
+    "grant_statements".
+
+  * Revocation: "revocation_steps", then "revoke_statements", then
+    "revocation_statements".
+
+The legacy "creation_statements" and "revocation_statements" parameters
+continue to work as before and may be combined with the lists above.
+
+The "revocation_steps" parameter is an ordered list of objects, each with a
+"statement" and an optional "continue_on_error" flag. When a step with the flag
+set fails, for example because the user was already dropped, the error is
+ignored and the remaining steps still run. Any other failure aborts the
+revocation as usual. Example for a postgresql database plugin:
+
+	[
+	  {"statement": "REVOKE ALL PRIVILEGES ON ALL TABLES IN SCHEMA public FROM \"{{name}}\";", "continue_on_error": true},
+	  {"statement": "DROP ROLE \"{{name}}\";"}
+	]
+
+The "username_prefix" parameter is prepended to every username generated for
+this role, overriding any username_prefix set on the connection. The plugin
+rejects prefixes that leave too little room for the generated portion of the
+username within the database's length limit.
+
+The "pool_class" parameter selects the connection pool credentials for this
+role are created through, for plugins whose connections define pool_classes.
+Each class has a pool of its own, so that busy roles can't take every
+connection from roles of another class. Without it, the connection's main pool
+is used.
+
+The "password_min_uppercase", "password_min_lowercase", "password_min_digits"
+and "password_min_special" parameters set the minimum number of characters of
+each class in passwords generated for this role, on top of those the database
+type requires by default. They are supported by the SQL based database
+plugins, and must fit within the length of the generated passwords, shortened
+by the connection's max_password_length.
+
+The "credential_format" parameter selects how issued credentials are returned:
+
+  * "default" - The plain "username" and "password".
+
+  * "jdbc" - The "username" and a "jdbc_url" derived from the connection's
+    "connection_url" with the credential embedded.
+
+  * "env" - The "username" and an "env" block of DATABASE_USERNAME and
+    DATABASE_PASSWORD lines.
+
+  * "all" - The plain "username" and "password", along with a "dsn" and a
+    "jdbc_url" for the credential where they can be derived from the
+    connection's "connection_url".
+
+The "credential_type" parameter selects what is issued for each lease:
+
+  * "password" - A database user with a generated password. This is the
+    default.
+
+  * "client_certificate" - A database user whose name is the common name of a
+    client certificate signed by the connection's "client_certificate_ca". The
+    "certificate", "private_key" and "ca_certificate" are returned along with
+    the username and password. The certificate expires with the lease, and
+    revoking the lease drops the user. The connection must have a
+    "client_certificate_ca" set.
+
+The "introspect_grants" parameter adds a "grants" summary of the privileges
+held by each newly created user to the response. If the plugin doesn't support
+introspection or the lookup fails, the credential is still returned without the
+summary and a warning is added instead.
+
+The "allowed_db_type" parameter records the database type the role's
+statements are written for, such as "postgres" or "mysql". Writing the role
+fails if the connection named by "db_name" reports a different type, unless
+"allow_db_type_mismatch" is set for statements that work across database types.
+
+The "statement_timeout" parameter bounds how long the role's creation and
+renewal statements may run, overriding the connection's "statement_timeout". It
+can't exceed the connection's "max_statement_timeout". Reading the role returns
+the timeout actually applied as "effective_statement_timeout".
+
 The "renew_statements" parameter customizes the statement string used to renew a
 user.
 The "rollback_statements' parameter customizes the statement string used to
 rollback a change if needed.
 `
+
+const pathRoleMigrateHelpSyn = `
+Rewrite a role in the current storage format.
+`
+
+const pathRoleMigrateHelpDesc = `
+Roles written by old versions of Vault store their statements under misspelled
+field names and are upgraded every time they are read. Writing to this path
+stores the upgraded role so the legacy fields are removed. The "migrated" field
+of the response reports whether the role needed to be rewritten. Writing a role
+through the "roles/<name>" path also stores it in the current format.
+`
@@ -0,0 +1,732 @@
+package database
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/builtin/logical/database/dbplugin"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/plugins/helper/database/credsutil"
+)
+
+func TestBackend_roleRevocationSteps(t *testing.T) {
+	b, storage := getBackend(t)
+
+	dbi, err := newDBPluginInstance(context.Background(), &fakeDatabase{
+		caps: []string{dbplugin.CapabilityCreateUser, dbplugin.CapabilityRevocationSteps},
+	}, &DatabaseConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	testFakeConnection(t, b, storage, dbi, &roleEntry{})
+
+	roleReq := &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "roles/readonly",
+		Storage:   storage,
+		Data: map[string]interface{}{
+			"db_name":             "fake",
+			"creation_statements": "CREATE USER",
+			"revocation_steps": []interface{}{
+				map[string]interface{}{"statement": `REVOKE ALL ON ALL TABLES IN SCHEMA public FROM "{{name}}";`, "continue_on_error": true},
+				map[string]interface{}{"statement": `DROP ROLE "{{name}}";`},
+			},
+		},
+	}
+	resp, err := b.HandleRequest(context.Background(), roleReq)
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%s resp:%#v\n", err, resp)
+	}
+
+	role, err := b.Role(context.Background(), storage, "readonly")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []*dbplugin.RevocationStep{
+		{Statement: `REVOKE ALL ON ALL TABLES IN SCHEMA public FROM "{{name}}";`, ContinueOnError: true},
+		{Statement: `DROP ROLE "{{name}}";`},
+	}
+	if !reflect.DeepEqual(role.Statements.RevocationSteps, expected) {
+		t.Fatalf("bad steps: %#v", role.Statements.RevocationSteps)
+	}
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "roles/readonly",
+		Storage:   storage,
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%s resp:%#v\n", err, resp)
+	}
+	steps := []map[string]interface{}{
+		{"statement": `REVOKE ALL ON ALL TABLES IN SCHEMA public FROM "{{name}}";`, "continue_on_error": true},
+		{"statement": `DROP ROLE "{{name}}";`, "continue_on_error": false},
+	}
+	if !reflect.DeepEqual(resp.Data["revocation_steps"], steps) {
+		t.Fatalf("bad steps: %#v", resp.Data["revocation_steps"])
+	}
+
+	for _, invalid := range []interface{}{
+		"DROP ROLE foo",
+		map[string]interface{}{"continue_on_error": true},
+		map[string]interface{}{"statement": "DROP ROLE foo", "ignore_errors": true},
+		map[string]interface{}{"statement": "DROP ROLE {{unknown name}}"},
+	} {
+		roleReq.Data = map[string]interface{}{
+			"db_name":             "fake",
+			"creation_statements": "CREATE USER",
+			"revocation_steps":    []interface{}{invalid},
+		}
+		resp, err := b.HandleRequest(context.Background(), roleReq)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp == nil || !resp.IsError() {
+			t.Fatalf("expected error for %#v, got: %#v", invalid, resp)
+		}
+	}
+
+	// Plugins that don't report revocation steps, such as Cassandra and
+	// MongoDB, reject them
+	dbi, err = newDBPluginInstance(context.Background(), &fakeDatabase{}, &DatabaseConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	testFakeConnection(t, b, storage, dbi, &roleEntry{})
+	roleReq.Data = map[string]interface{}{
+		"db_name":             "fake",
+		"creation_statements": "CREATE USER",
+		"revocation_steps":    []interface{}{map[string]interface{}{"statement": "DROP ROLE foo"}},
+	}
+	resp, err = b.HandleRequest(context.Background(), roleReq)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp == nil || !resp.IsError() || !strings.Contains(resp.Error().Error(), "does not support revocation_steps") {
+		t.Fatalf("expected error for an unsupported plugin, got: %#v", resp)
+	}
+}
+
+func TestBackend_usernamePrefixLength(t *testing.T) {
+	// The plugin refuses username prefixes longer than 4 characters
+	generateCredentials := func(ctx context.Context, usernameConfig dbplugin.UsernameConfig) (string, string, error) {
+		if len(usernameConfig.UsernamePrefix) > 4 {
+			return "", "", credsutil.ErrUsernamePrefixTooLong
+		}
+		return fakeGenerateCredentials(ctx, usernameConfig)
+	}
+
+	config := testFakePluginBackendConfig()
+	config.System = fakePluginSystemView{
+		StaticSystemView: logical.TestSystemView(),
+		factory: func() (interface{}, error) {
+			return &fakeDatabase{generateCredentials: generateCredentials}, nil
+		},
+	}
+	b := Backend(config)
+	if err := b.Setup(context.Background(), config); err != nil {
+		t.Fatal(err)
+	}
+
+	db := &fakeDatabase{generateCredentials: generateCredentials}
+	dbi, err := newDBPluginInstance(context.Background(), db, &DatabaseConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	testFakeConnection(t, b, config.StorageView, dbi, &roleEntry{})
+
+	write := func(path string, data map[string]interface{}) *logical.Response {
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      path,
+			Storage:   config.StorageView,
+			Data:      data,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	// Prefixes the plugin can't fit are refused when writing the role
+	roleData := map[string]interface{}{
+		"db_name":             "fake",
+		"creation_statements": "CREATE USER",
+		"username_prefix":     "toolong-",
+	}
+	if resp := write("roles/prefixed", roleData); resp == nil || !resp.IsError() || !strings.Contains(resp.Error().Error(), "username_prefix") {
+		t.Fatalf("expected error for too long username_prefix, got: %#v", resp)
+	}
+	roleData["username_prefix"] = "app-"
+	if resp := write("roles/prefixed", roleData); resp != nil && resp.IsError() {
+		t.Fatalf("bad: %#v", resp)
+	}
+
+	// and when writing the connection, which keeps its configuration
+	resp := write("config/fake", map[string]interface{}{
+		"plugin_name":     "fake",
+		"allowed_roles":   "*",
+		"username_prefix": "toolong-",
+	})
+	if resp == nil || !resp.IsError() || !strings.Contains(resp.Error().Error(), "username_prefix") {
+		t.Fatalf("expected error for too long username_prefix, got: %#v", resp)
+	}
+	stored, err := b.DatabaseConfig(context.Background(), config.StorageView, "fake")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.UsernamePrefix != "" {
+		t.Fatalf("expected the configuration to be kept, got prefix %q", stored.UsernamePrefix)
+	}
+	if b.connections["fake"] != dbi || db.isClosed() {
+		t.Fatal("expected the live connection to be kept")
+	}
+}
+
+func TestBackend_statementTimeout(t *testing.T) {
+	b, storage := getBackend(t)
+
+	// The deadline of the context each credential is created with
+	var deadline time.Time
+	db := &fakeDatabase{
+		createUser: func(ctx context.Context, _ dbplugin.Statements, _ dbplugin.UsernameConfig, _ time.Time) (string, string, error) {
+			deadline, _ = ctx.Deadline()
+			return "user", "pass", nil
+		},
+	}
+	dbi, err := newDBPluginInstance(context.Background(), db, &DatabaseConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	testFakeConnection(t, b, storage, dbi, &roleEntry{})
+
+	entry, err := logical.StorageEntryJSON("config/fake", &DatabaseConfig{
+		PluginName:          "fake",
+		AllowedRoles:        []string{"*"},
+		StatementTimeout:    30,
+		MaxStatementTimeout: 300,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := storage.Put(context.Background(), entry); err != nil {
+		t.Fatal(err)
+	}
+
+	writeRole := func(timeout int) *logical.Response {
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "roles/readonly",
+			Storage:   storage,
+			Data: map[string]interface{}{
+				"db_name":             "fake",
+				"creation_statements": "CREATE USER",
+				"statement_timeout":   timeout,
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	checkTimeout := func(expected time.Duration) {
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      "roles/readonly",
+			Storage:   storage,
+		})
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("err:%s resp:%#v\n", err, resp)
+		}
+		if resp.Data["effective_statement_timeout"] != expected.Seconds() {
+			t.Fatalf("expected effective timeout %s, got %v", expected, resp.Data["effective_statement_timeout"])
+		}
+
+		start := time.Now()
+		resp, err = b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      "creds/readonly",
+			Storage:   storage,
+		})
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("err:%s resp:%#v\n", err, resp)
+		}
+		if deadline.Before(start.Add(expected)) || deadline.After(time.Now().Add(expected)) {
+			t.Fatalf("expected a deadline %s from now, got %s", expected, deadline.Sub(start))
+		}
+	}
+
+	// The role uses the connection's timeout unless it sets its own
+	if resp := writeRole(0); resp != nil && resp.IsError() {
+		t.Fatalf("unexpected error: %#v", resp)
+	}
+	checkTimeout(30 * time.Second)
+
+	if resp := writeRole(120); resp != nil && resp.IsError() {
+		t.Fatalf("unexpected error: %#v", resp)
+	}
+	checkTimeout(120 * time.Second)
+
+	// The role can't exceed the connection's max
+	if resp := writeRole(600); resp == nil || !resp.IsError() {
+		t.Fatalf("expected error for statement_timeout over the max, got: %#v", resp)
+	}
+}
+
+func TestBackend_roleAllowedDBType(t *testing.T) {
+	b, storage := getBackend(t)
+
+	dbi, err := newDBPluginInstance(context.Background(), &fakeDatabase{}, &DatabaseConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	testFakeConnection(t, b, storage, dbi, &roleEntry{})
+
+	writeRole := func(data map[string]interface{}) *logical.Response {
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "roles/typed",
+			Storage:   storage,
+			Data:      data,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	resp := writeRole(map[string]interface{}{
+		"db_name":             "fake",
+		"creation_statements": "CREATE USER",
+		"allowed_db_type":     "fake",
+	})
+	if resp != nil && resp.IsError() {
+		t.Fatalf("expected matching type to be accepted, got: %#v", resp)
+	}
+
+	resp = writeRole(map[string]interface{}{
+		"db_name":             "fake",
+		"creation_statements": "CREATE USER",
+		"allowed_db_type":     "postgres",
+	})
+	if resp == nil || !resp.IsError() || !strings.Contains(resp.Data["error"].(string), `database "fake" is of type "fake"`) {
+		t.Fatalf("expected type mismatch error, got: %#v", resp)
+	}
+
+	resp = writeRole(map[string]interface{}{
+		"db_name":                "fake",
+		"creation_statements":    "CREATE USER",
+		"allowed_db_type":        "postgres",
+		"allow_db_type_mismatch": true,
+	})
+	if resp != nil && resp.IsError() {
+		t.Fatalf("expected mismatch to be allowed, got: %#v", resp)
+	}
+
+	resp = writeRole(map[string]interface{}{
+		"db_name":         "missing",
+		"allowed_db_type": "postgres",
+	})
+	if resp == nil || !resp.IsError() {
+		t.Fatalf("expected error for unknown connection, got: %#v", resp)
+	}
+
+	// Roles without a type aren't checked
+	resp = writeRole(map[string]interface{}{
+		"db_name":             "missing",
+		"creation_statements": "CREATE USER",
+	})
+	if resp != nil && resp.IsError() {
+		t.Fatalf("expected untyped role to be accepted, got: %#v", resp)
+	}
+}
+
+func TestBackend_roleStatementLists(t *testing.T) {
+	b, storage := getBackend(t)
+
+	writeRole := func(dbName string, data map[string]interface{}) *logical.Response {
+		data["db_name"] = dbName
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "roles/lists",
+			Storage:   storage,
+			Data:      data,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	// The plugin has to be checked for statement lists
+	resp := writeRole("missing", map[string]interface{}{"grant_statements": []string{"GRANT ALL"}})
+	if resp == nil || !resp.IsError() || !strings.Contains(resp.Error().Error(), "error checking plugin") {
+		t.Fatalf("expected error for a missing connection, got: %#v", resp)
+	}
+
+	// Plugins that don't report statement lists, such as Cassandra and
+	// MongoDB, reject them
+	dbi, err := newDBPluginInstance(context.Background(), &fakeDatabase{}, &DatabaseConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	testFakeConnection(t, b, storage, dbi, &roleEntry{})
+
+	for _, field := range []string{"create_statements", "grant_statements", "revoke_statements"} {
+		resp := writeRole("fake", map[string]interface{}{
+			"creation_statements": "CREATE USER",
+			field:                 []string{"GRANT ALL"},
+		})
+		if resp == nil || !resp.IsError() || !strings.Contains(resp.Error().Error(), "does not support") {
+			t.Fatalf("expected error for %s, got: %#v", field, resp)
+		}
+	}
+	if resp := writeRole("fake", map[string]interface{}{"creation_statements": "CREATE USER"}); resp != nil && resp.IsError() {
+		t.Fatalf("expected role to be written, got: %#v", resp)
+	}
+
+	// Roles written before the connection's plugin changed aren't issued
+	// without their lists
+	testFakeConnection(t, b, storage, dbi, &roleEntry{
+		Statements: dbplugin.Statements{GrantStatements: []string{"GRANT ALL"}},
+	})
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "creds/readonly",
+		Storage:   storage,
+	})
+	if err == nil || !strings.Contains(err.Error(), dbplugin.CapabilityStatementLists) {
+		t.Fatalf("expected error issuing with statement lists, got: %#v, %v", resp, err)
+	}
+}
+
+func TestBackend_roleEmptyCreation(t *testing.T) {
+	b, storage := getBackend(t)
+
+	dbi, err := newDBPluginInstance(context.Background(), &fakeDatabase{
+		caps: []string{dbplugin.CapabilityCreateUser, dbplugin.CapabilityStatementLists},
+	}, &DatabaseConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	testFakeConnection(t, b, storage, dbi, &roleEntry{})
+
+	writeRole := func(data map[string]interface{}) *logical.Response {
+		data["db_name"] = "fake"
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "roles/readonly",
+			Storage:   storage,
+			Data:      data,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	for name, data := range map[string]map[string]interface{}{
+		"no statements":         {},
+		"only separators":       {"creation_statements": " ; ;"},
+		"only other statements": {"revocation_statements": "DROP USER"},
+	} {
+		resp := writeRole(data)
+		if resp == nil || !resp.IsError() || !strings.Contains(resp.Error().Error(), "allow_empty_creation") {
+			t.Fatalf("%s: expected error, got: %#v", name, resp)
+		}
+	}
+
+	for name, data := range map[string]map[string]interface{}{
+		"creation_statements": {"creation_statements": "CREATE USER"},
+		"create_statements":   {"create_statements": []string{"CREATE USER"}},
+		"grant_statements":    {"grant_statements": []string{"GRANT ALL"}},
+		"escape hatch":        {"allow_empty_creation": true},
+	} {
+		if resp := writeRole(data); resp != nil && resp.IsError() {
+			t.Fatalf("%s: expected role to be written, got: %#v", name, resp)
+		}
+	}
+
+	// Exported roles without creation statements can be imported again
+	if resp := writeRole(map[string]interface{}{"allow_empty_creation": true}); resp != nil && resp.IsError() {
+		t.Fatalf("expected role to be written, got: %#v", resp)
+	}
+	role, err := b.Role(context.Background(), storage, "readonly")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if export := exportRole(role); export["allow_empty_creation"] != true {
+		t.Fatalf("expected allow_empty_creation to be exported, got: %#v", export)
+	}
+
+	// Plugins that don't run the statement lists need creation_statements
+	dbi, err = newDBPluginInstance(context.Background(), &fakeDatabase{}, &DatabaseConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	testFakeConnection(t, b, storage, dbi, &roleEntry{})
+	resp := writeRole(map[string]interface{}{})
+	if resp == nil || !resp.IsError() || !strings.HasPrefix(resp.Error().Error(), "creation_statements must be given") {
+		t.Fatalf("expected error naming only creation_statements, got: %#v", resp)
+	}
+}
+
+func TestBackend_statementVariables(t *testing.T) {
+	b, storage := getBackend(t)
+
+	var statements []dbplugin.Statements
+	db := &fakeDatabase{
+		createUser: func(_ context.Context, s dbplugin.Statements, _ dbplugin.UsernameConfig, _ time.Time) (string, string, error) {
+			statements = append(statements, s)
+			return "user", "password", nil
+		},
+		revokeUser: func(_ context.Context, s dbplugin.Statements, _ string) error {
+			statements = append(statements, s)
+			return nil
+		},
+	}
+	dbi, err := newDBPluginInstance(context.Background(), db, &DatabaseConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	testFakeConnection(t, b, storage, dbi, &roleEntry{})
+	entry, err := logical.StorageEntryJSON("config/fake", &DatabaseConfig{
+		PluginName:         "fake",
+		AllowedRoles:       []string{"*"},
+		StatementVariables: map[string]string{"schema": "app", "tablespace": "fast"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := storage.Put(context.Background(), entry); err != nil {
+		t.Fatal(err)
+	}
+
+	roleReq := &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "roles/readonly",
+		Storage:   storage,
+		Data: map[string]interface{}{
+			"db_name":               "fake",
+			"creation_statements":   `CREATE ROLE "{{name}}" TABLESPACE {{tablespace}}; GRANT USAGE ON SCHEMA {{upper schema}} TO "{{name}}";`,
+			"revocation_statements": `REVOKE USAGE ON SCHEMA {{schema}} FROM "{{name}}";`,
+			"statement_variables":   map[string]interface{}{"schema": "reporting"},
+		},
+	}
+	resp, err := b.HandleRequest(context.Background(), roleReq)
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%s resp:%#v\n", err, resp)
+	}
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "creds/readonly",
+		Storage:   storage,
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%s resp:%#v\n", err, resp)
+	}
+
+	// The role's variables override the connection's, and the values the
+	// plugin substitutes are left to it
+	expected := `CREATE ROLE "{{name}}" TABLESPACE fast; GRANT USAGE ON SCHEMA REPORTING TO "{{name}}";`
+	if len(statements) != 1 || statements[0].CreationStatements != expected {
+		t.Fatalf("expected %q, got: %#v", expected, statements)
+	}
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.RevokeOperation,
+		Storage:   storage,
+		Secret: &logical.Secret{
+			InternalData: map[string]interface{}{
+				"secret_type": "creds",
+				"username":    "user",
+				"role":        "readonly",
+			},
+		},
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%s resp:%#v\n", err, resp)
+	}
+	expected = `REVOKE USAGE ON SCHEMA reporting FROM "{{name}}";`
+	if len(statements) != 2 || statements[1].RevocationStatements != expected {
+		t.Fatalf("expected %q, got: %#v", expected, statements)
+	}
+
+	// The stored role is left as it was written
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "roles/readonly",
+		Storage:   storage,
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%s resp:%#v\n", err, resp)
+	}
+	if resp.Data["revocation_statements"] != roleReq.Data["revocation_statements"] {
+		t.Fatalf("bad revocation_statements: %#v", resp.Data["revocation_statements"])
+	}
+	if !reflect.DeepEqual(resp.Data["statement_variables"], map[string]string{"schema": "reporting"}) {
+		t.Fatalf("bad statement_variables: %#v", resp.Data["statement_variables"])
+	}
+
+	// Reserved names can't be shadowed
+	roleReq.Data["statement_variables"] = map[string]interface{}{"password": "hunter2"}
+	resp, err = b.HandleRequest(context.Background(), roleReq)
+	if err != nil || resp == nil || !resp.IsError() {
+		t.Fatalf("expected error, got: %#v", resp)
+	}
+}
+
+func TestBackend_rolePoolClass(t *testing.T) {
+	b, storage := getBackend(t)
+
+	var usernameConfig dbplugin.UsernameConfig
+	db := &fakeDatabase{
+		createUser: func(_ context.Context, _ dbplugin.Statements, c dbplugin.UsernameConfig, _ time.Time) (string, string, error) {
+			usernameConfig = c
+			return "user", "password", nil
+		},
+	}
+	dbi, err := newDBPluginInstance(context.Background(), db, &DatabaseConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	testFakeConnection(t, b, storage, dbi, &roleEntry{})
+
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "roles/readonly",
+		Storage:   storage,
+		Data: map[string]interface{}{
+			"db_name":             "fake",
+			"creation_statements": "CREATE ROLE {{name}};",
+			"pool_class":          "batch",
+		},
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%s resp:%#v\n", err, resp)
+	}
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "roles/readonly",
+		Storage:   storage,
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%s resp:%#v\n", err, resp)
+	}
+	if resp.Data["pool_class"] != "batch" {
+		t.Fatalf("bad pool_class: %#v", resp.Data["pool_class"])
+	}
+
+	// The class is passed to the plugin along with the username settings
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "creds/readonly",
+		Storage:   storage,
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%s resp:%#v\n", err, resp)
+	}
+	if usernameConfig.PoolClass != "batch" || usernameConfig.RoleName != "readonly" {
+		t.Fatalf("bad username config: %#v", usernameConfig)
+	}
+}
+
+func TestBackend_rolePasswordComplexity(t *testing.T) {
+	b, storage := getBackend(t)
+
+	var usernameConfig dbplugin.UsernameConfig
+	db := &fakeDatabase{
+		createUser: func(_ context.Context, _ dbplugin.Statements, c dbplugin.UsernameConfig, _ time.Time) (string, string, error) {
+			usernameConfig = c
+			return "user", "password", nil
+		},
+	}
+	dbi, err := newDBPluginInstance(context.Background(), db, &DatabaseConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	testFakeConnection(t, b, storage, dbi, &roleEntry{})
+
+	entry, err := logical.StorageEntryJSON("config/fake", &DatabaseConfig{
+		PluginName:        "fake",
+		AllowedRoles:      []string{"*"},
+		ConnectionDetails: map[string]interface{}{"max_password_length": 12},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := storage.Put(context.Background(), entry); err != nil {
+		t.Fatal(err)
+	}
+
+	writeRole := func(data map[string]interface{}) *logical.Response {
+		data["db_name"] = "fake"
+		data["creation_statements"] = "CREATE ROLE {{name}};"
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "roles/readonly",
+			Storage:   storage,
+			Data:      data,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	// The minimums must fit in passwords shortened to max_password_length
+	resp := writeRole(map[string]interface{}{
+		"password_min_uppercase": 4,
+		"password_min_digits":    4,
+		"password_min_special":   5,
+	})
+	if resp == nil || !resp.IsError() || !strings.Contains(resp.Data["error"].(string), "requires at least 13 characters") {
+		t.Fatalf("expected complexity error, got: %#v", resp)
+	}
+
+	resp = writeRole(map[string]interface{}{"password_min_special": -1})
+	if resp == nil || !resp.IsError() {
+		t.Fatalf("expected error for a negative minimum, got: %#v", resp)
+	}
+
+	resp = writeRole(map[string]interface{}{
+		"password_min_uppercase": 4,
+		"password_min_digits":    4,
+		"password_min_special":   4,
+	})
+	if resp != nil && resp.IsError() {
+		t.Fatalf("bad: %#v", resp)
+	}
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "roles/readonly",
+		Storage:   storage,
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%s resp:%#v\n", err, resp)
+	}
+	if resp.Data["password_min_special"] != 4 || resp.Data["password_min_lowercase"] != 0 {
+		t.Fatalf("bad password complexity: %#v", resp.Data)
+	}
+
+	// The minimums are passed to the plugin along with the username settings
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "creds/readonly",
+		Storage:   storage,
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%s resp:%#v\n", err, resp)
+	}
+	if usernameConfig.PasswordMinUppercase != 4 || usernameConfig.PasswordMinDigits != 4 ||
+		usernameConfig.PasswordMinSpecial != 4 || usernameConfig.PasswordMinLowercase != 0 {
+		t.Fatalf("bad username config: %#v", usernameConfig)
+	}
+}
@@ -0,0 +1,103 @@
+package database
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+// previousConfigPath is the storage prefix of the configuration each
+// connection had before its last verified change. It is kept outside
+// "config/" so it isn't listed as a connection.
+const previousConfigPath = "previous-config/"
+
+func pathConfigConnectionRollback(b *databaseBackend) *framework.Path {
+	return &framework.Path{
+		Pattern: fmt.Sprintf("config/%s/rollback$", framework.GenericNameRegex("name")),
+		Fields: map[string]*framework.FieldSchema{
+			"name": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Name of this database connection",
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.connectionRollbackHandler(),
+		},
+
+		HelpSynopsis:    pathConfigConnectionRollbackHelpSyn,
+		HelpDescription: pathConfigConnectionRollbackHelpDesc,
+	}
+}
+
+// savePreviousConfig keeps the stored configuration of the named connection,
+// if any, so that it can be restored after the configuration is replaced.
+func savePreviousConfig(ctx context.Context, s logical.Storage, name string) error {
+	entry, err := s.Get(ctx, "config/"+name)
+	if err != nil {
+		return err
+	}
+	if entry == nil {
+		return nil
+	}
+
+	return s.Put(ctx, &logical.StorageEntry{
+		Key:      previousConfigPath + name,
+		Value:    entry.Value,
+		SealWrap: entry.SealWrap,
+	})
+}
+
+// connectionRollbackHandler restores the configuration a connection had before
+// its last verified change and closes the connection so that the next request
+// uses the restored configuration.
+func (b *databaseBackend) connectionRollbackHandler() framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+		name := b.canonicalName(data.Get("name").(string))
+		if name == "" {
+			return logical.ErrorResponse(respErrEmptyName), nil
+		}
+
+		previous, err := req.Storage.Get(ctx, previousConfigPath+name)
+		if err != nil {
+			return nil, err
+		}
+		if previous == nil {
+			return logical.ErrorResponse(fmt.Sprintf("no previous configuration for connection %q", name)), nil
+		}
+
+		b.Lock()
+		defer b.Unlock()
+
+		if err := req.Storage.Put(ctx, &logical.StorageEntry{
+			Key:      "config/" + name,
+			Value:    previous.Value,
+			SealWrap: previous.SealWrap,
+		}); err != nil {
+			return nil, err
+		}
+		if err := req.Storage.Delete(ctx, previousConfigPath+name); err != nil {
+			return nil, err
+		}
+
+		b.clearConnection(name)
+		delete(b.lastVerified, name)
+		delete(b.failures, name)
+
+		return nil, nil
+	}
+}
+
+const pathConfigConnectionRollbackHelpSyn = `
+Restore the previous configuration of a connection.
+`
+
+const pathConfigConnectionRollbackHelpDesc = `
+Each time a connection's configuration is replaced with one that was verified
+by connecting to the database, the configuration it replaced is kept. Writing
+to this path restores that configuration and closes the connection, so that the
+next request connects with the restored configuration. Only the last replaced
+configuration is kept, and it is discarded once restored.
+`
@@ -0,0 +1,115 @@
+package database
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/vault/logical"
+)
+
+func TestBackend_connectionRollback(t *testing.T) {
+	config := testFakePluginBackendConfig()
+	var spawned *fakeDatabase
+	config.System = fakePluginSystemView{
+		StaticSystemView: logical.TestSystemView(),
+		factory: func() (interface{}, error) {
+			spawned = &fakeDatabase{updatePoolSettings: fakeUpdatePoolSettings(new(map[string]interface{}))}
+			return spawned, nil
+		},
+	}
+	b := Backend(config)
+	if err := b.Setup(context.Background(), config); err != nil {
+		t.Fatal(err)
+	}
+
+	var poolConfig map[string]interface{}
+	db := &fakeDatabase{updatePoolSettings: fakeUpdatePoolSettings(&poolConfig)}
+	dbi, err := newDBPluginInstance(context.Background(), db, &DatabaseConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	testFakeConnection(t, b, config.StorageView, dbi, &roleEntry{})
+
+	writeConfig := func(data map[string]interface{}) {
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "config/fake",
+			Storage:   config.StorageView,
+			Data:      data,
+		})
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("err:%s resp:%#v\n", err, resp)
+		}
+	}
+	rollback := func() (*logical.Response, error) {
+		return b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "config/fake/rollback",
+			Storage:   config.StorageView,
+		})
+	}
+
+	// Pool settings applied to the live connection aren't verified, so they
+	// don't keep the configuration they replaced
+	writeConfig(map[string]interface{}{
+		"plugin_name":          "fake",
+		"connection_url":       "fake://db",
+		"max_open_connections": 10,
+	})
+	if poolConfig == nil {
+		t.Fatal("expected the pool settings to be applied to the live connection")
+	}
+	resp, err := rollback()
+	if err != nil || resp == nil || !resp.IsError() {
+		t.Fatalf("expected error, got err:%s resp:%#v", err, resp)
+	}
+
+	// A verified change keeps the configuration it replaced
+	writeConfig(map[string]interface{}{
+		"plugin_name":          "fake",
+		"connection_url":       "fake://other",
+		"max_open_connections": 10,
+	})
+	if spawned == nil || !db.isClosed() {
+		t.Fatal("expected the connection to be replaced")
+	}
+
+	// An unverified change doesn't replace it
+	writeConfig(map[string]interface{}{
+		"plugin_name":          "fake",
+		"connection_url":       "fake://other",
+		"max_open_connections": 20,
+		"verify_connection":    false,
+	})
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ListOperation,
+		Path:      "config/",
+		Storage:   config.StorageView,
+	})
+	if err != nil || !reflect.DeepEqual(resp.Data["keys"], []string{"fake"}) {
+		t.Fatalf("expected only the connection to be listed, got err:%s resp:%#v", err, resp)
+	}
+
+	resp, err = rollback()
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%s resp:%#v\n", err, resp)
+	}
+	stored, err := b.DatabaseConfig(context.Background(), config.StorageView, "fake")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.ConnectionDetails["connection_url"] != "fake://db" {
+		t.Fatalf("expected the pool settings config to be restored, got: %#v", stored)
+	}
+	if _, ok := b.connections["fake"]; ok || !spawned.isClosed() {
+		t.Fatal("expected the connection to be closed")
+	}
+
+	// The previous configuration is discarded once restored
+	resp, err = rollback()
+	if err != nil || resp == nil || !resp.IsError() {
+		t.Fatalf("expected error, got err:%s resp:%#v", err, resp)
+	}
+}
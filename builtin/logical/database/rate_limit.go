@@ -0,0 +1,93 @@
+package database
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// defaultCredsRateLimitInterval is the interval creds_rate_limit applies to
+// when the connection doesn't set creds_rate_limit_interval.
+const defaultCredsRateLimitInterval = 60
+
+// credsRateLimiter is a token bucket bounding how many credentials may be
+// requested against a connection over time. It holds up to limit tokens and
+// refills at a rate of limit tokens per interval, so bursts of up to limit
+// requests are allowed as long as the average rate stays under the limit.
+type credsRateLimiter struct {
+	limit    int
+	interval time.Duration
+
+	sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+func newCredsRateLimiter(limit int, interval time.Duration, now time.Time) *credsRateLimiter {
+	return &credsRateLimiter{
+		limit:    limit,
+		interval: interval,
+		tokens:   float64(limit),
+		last:     now,
+	}
+}
+
+// refill adds the tokens regained since the last refill. The caller needs to
+// hold the limiter's lock.
+func (l *credsRateLimiter) refill(now time.Time) {
+	if elapsed := now.Sub(l.last); elapsed > 0 {
+		l.tokens = math.Min(float64(l.limit), l.tokens+float64(l.limit)*float64(elapsed)/float64(l.interval))
+		l.last = now
+	}
+}
+
+// take takes a token, reporting false along with how long until one is
+// available if there are none left.
+func (l *credsRateLimiter) take(now time.Time) (bool, time.Duration) {
+	l.Lock()
+	defer l.Unlock()
+
+	l.refill(now)
+	if l.tokens >= 1 {
+		l.tokens--
+		return true, 0
+	}
+
+	wait := time.Duration((1 - l.tokens) * float64(l.interval) / float64(l.limit))
+	return false, wait
+}
+
+// remaining returns the number of requests that may be made right away.
+func (l *credsRateLimiter) remaining(now time.Time) int {
+	l.Lock()
+	defer l.Unlock()
+
+	l.refill(now)
+	return int(l.tokens)
+}
+
+// credsRateLimiter returns the limiter of the named connection, or nil if
+// config doesn't limit its rate. The limiter is kept across reconnects, and
+// replaced when the limit changes.
+func (b *databaseBackend) credsRateLimiter(name string, config *DatabaseConfig) *credsRateLimiter {
+	b.credsLimitersLock.Lock()
+	defer b.credsLimitersLock.Unlock()
+
+	if config.CredsRateLimit <= 0 {
+		delete(b.credsLimiters, name)
+		return nil
+	}
+
+	interval := time.Duration(config.CredsRateLimitInterval) * time.Second
+	if interval <= 0 {
+		interval = defaultCredsRateLimitInterval * time.Second
+	}
+
+	l, ok := b.credsLimiters[name]
+	if !ok || l.limit != config.CredsRateLimit || l.interval != interval {
+		l = newCredsRateLimiter(config.CredsRateLimit, interval, time.Now())
+		b.credsLimiters[name] = l
+	}
+
+	return l
+}
@@ -0,0 +1,84 @@
+package database
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/logical"
+)
+
+func TestBackend_credsRateLimit(t *testing.T) {
+	b, storage := getBackend(t)
+
+	dbi, err := newDBPluginInstance(context.Background(), &fakeDatabase{}, &DatabaseConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	testFakeConnection(t, b, storage, dbi, &roleEntry{})
+	entry, err := logical.StorageEntryJSON("config/fake", &DatabaseConfig{
+		PluginName:             "fake",
+		AllowedRoles:           []string{"*"},
+		CredsRateLimit:         2,
+		CredsRateLimitInterval: 60,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := storage.Put(context.Background(), entry); err != nil {
+		t.Fatal(err)
+	}
+
+	credsReq := &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "creds/readonly",
+		Storage:   storage,
+	}
+	for i := 0; i < 2; i++ {
+		resp, err := b.HandleRequest(context.Background(), credsReq)
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("err:%s resp:%#v\n", err, resp)
+		}
+	}
+
+	// The burst is used up, so the next request has to wait for a token
+	resp, err := b.HandleRequest(context.Background(), credsReq)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp == nil || !resp.IsError() || !strings.Contains(resp.Data["error"].(string), "rate limited, retry after 30s") {
+		t.Fatalf("expected rate limited error, got: %#v", resp)
+	}
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "health",
+		Storage:   storage,
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%s resp:%#v\n", err, resp)
+	}
+	summary := resp.Data["connections"].([]map[string]interface{})[0]
+	if summary["creds_rate_limit"] != 2 || summary["creds_rate_limit_remaining"] != 0 {
+		t.Fatalf("bad summary: %#v", summary)
+	}
+
+	// Tokens come back at the configured rate, up to the limit
+	now := time.Now()
+	limiter := newCredsRateLimiter(2, time.Minute, now)
+	for i := 0; i < 2; i++ {
+		if ok, _ := limiter.take(now); !ok {
+			t.Fatal("expected a token")
+		}
+	}
+	if ok, wait := limiter.take(now.Add(15 * time.Second)); ok || wait != 15*time.Second {
+		t.Fatalf("expected to wait 15s, got: %t %s", ok, wait)
+	}
+	if ok, _ := limiter.take(now.Add(30 * time.Second)); !ok {
+		t.Fatal("expected a token after 30s")
+	}
+	if remaining := limiter.remaining(now.Add(time.Hour)); remaining != 2 {
+		t.Fatalf("expected the bucket to be full, got: %d", remaining)
+	}
+}
@@ -3,13 +3,44 @@ package database
 import (
 	"context"
 	"fmt"
+	"time"
 
+	"github.com/hashicorp/go-uuid"
+	"github.com/hashicorp/vault/builtin/logical/database/dbplugin"
+	"github.com/hashicorp/vault/helper/redactutil"
 	"github.com/hashicorp/vault/logical"
 	"github.com/hashicorp/vault/logical/framework"
 )
 
 const SecretCredsType = "creds"
 
+// pendingRevocationPath is the storage prefix of revocations queued for the
+// periodic sweep.
+const pendingRevocationPath = "pending-revocation/"
+
+// revocationRetryBackoff is the delay before the first revocation retry; it
+// doubles with each further retry, up to maxRevocationRetryBackoff.
+var revocationRetryBackoff = time.Second
+
+const (
+	// maxRevocationRetryBackoff caps the delay between revocation retries.
+	maxRevocationRetryBackoff = 30 * time.Second
+
+	// maxRevocationRetries bounds revocation_retries. The retries run while
+	// holding the user's lock, so they mustn't hold it for long.
+	maxRevocationRetries = 10
+)
+
+// nextRevocationRetryBackoff returns the delay to wait before the revocation
+// retry following one that waited backoff.
+func nextRevocationRetryBackoff(backoff time.Duration) time.Duration {
+	backoff *= 2
+	if backoff > maxRevocationRetryBackoff {
+		return maxRevocationRetryBackoff
+	}
+	return backoff
+}
+
 func secretCreds(b *databaseBackend) *framework.Secret {
 	return &framework.Secret{
 		Type:   SecretCredsType,
@@ -34,6 +65,14 @@ func (b *databaseBackend) secretCredsRenew() framework.OperationFunc {
 			return nil, fmt.Errorf("could not find role with name: %s", req.Secret.InternalData["role"])
 		}
 
+		issued, err := getIssuedUser(ctx, req.Storage, username)
+		if err != nil {
+			return nil, err
+		}
+		if issued != nil && issued.Revoked {
+			return logical.ErrorResponse(fmt.Sprintf("user %q has been revoked", username)), nil
+		}
+
 		role, err := b.Role(ctx, req.Storage, roleNameRaw.(string))
 		if err != nil {
 			return nil, err
@@ -42,43 +81,59 @@ func (b *databaseBackend) secretCredsRenew() framework.OperationFunc {
 			return nil, fmt.Errorf("error during renew: could not find role with name %s", req.Secret.InternalData["role"])
 		}
 
+		config, err := b.DatabaseConfig(ctx, req.Storage, role.DBName)
+		if err != nil {
+			return nil, err
+		}
+
 		f := framework.LeaseExtend(role.DefaultTTL, role.MaxTTL, b.System())
 		resp, err := f(ctx, req, data)
 		if err != nil {
 			return nil, err
 		}
 
-		// Grab the read lock
-		b.RLock()
-		unlockFunc := b.RUnlock
-
-		// Get the Database object
-		db, ok := b.getDBObj(role.DBName)
-		if !ok {
-			// Upgrade lock
-			b.RUnlock()
-			b.Lock()
-			unlockFunc = b.Unlock
+		db, unlockFunc, err := b.lockedDBObj(ctx, req.Storage, role.DBName)
+		if err != nil {
+			return nil, err
+		}
 
-			// Create a new DB object
-			db, err = b.createDBObj(ctx, req.Storage, role.DBName)
-			if err != nil {
-				unlockFunc()
-				return nil, fmt.Errorf("cound not retrieve db with name: %s, got error: %s", role.DBName, err)
-			}
+		if err := db.supports(dbplugin.CapabilityRenewUser); err != nil {
+			unlockFunc()
+			return nil, err
 		}
 
 		// Make sure we increase the VALID UNTIL endpoint for this user.
 		if expireTime := resp.Secret.ExpirationTime(); !expireTime.IsZero() {
-			err := db.RenewUser(ctx, role.Statements, username, expireTime)
+			stmtCtx, cancel := role.statementContext(ctx, config)
+			err := db.RenewUser(stmtCtx, role.statements(config), username, expireTime)
+			cancel()
 			if err != nil {
 				unlockFunc()
-				b.closeIfShutdown(role.DBName, err)
+				b.closeIfShutdown(role.DBName, db, err)
 				return nil, err
 			}
 		}
 
 		unlockFunc()
+
+		// Keep the index in step with the lease, so the user isn't taken
+		// for a stale entry. The entry is read again under its lock, since
+		// the lease may have been revoked in the meantime.
+		lock := b.issuedUserLock(username)
+		lock.Lock()
+		defer lock.Unlock()
+
+		issued, err = getIssuedUser(ctx, req.Storage, username)
+		if err != nil {
+			return nil, err
+		}
+		if issued != nil {
+			issued.ExpiresAt = resp.Secret.ExpirationTime().UTC()
+			if err := putIssuedUser(ctx, req.Storage, issued); err != nil {
+				return nil, err
+			}
+		}
+
 		return resp, nil
 	}
 }
@@ -94,6 +149,21 @@ func (b *databaseBackend) secretCredsRevoke() framework.OperationFunc {
 
 		var resp *logical.Response
 
+		// Hold the user's index lock until the entry is removed, so that
+		// the index changes along with the database
+		lock := b.issuedUserLock(username)
+		lock.Lock()
+		defer lock.Unlock()
+
+		// Users revoked through revoke-users are already gone
+		issued, err := getIssuedUser(ctx, req.Storage, username)
+		if err != nil {
+			return nil, err
+		}
+		if issued != nil && issued.Revoked {
+			return resp, deleteIssuedUser(ctx, req.Storage, username)
+		}
+
 		roleNameRaw, ok := req.Secret.InternalData["role"]
 		if !ok {
 			return nil, fmt.Errorf("no role name was provided")
@@ -107,33 +177,150 @@ func (b *databaseBackend) secretCredsRevoke() framework.OperationFunc {
 			return nil, fmt.Errorf("error during revoke: could not find role with name %s", req.Secret.InternalData["role"])
 		}
 
-		// Grab the read lock
-		b.RLock()
-		unlockFunc := b.RUnlock
+		config, err := b.DatabaseConfig(ctx, req.Storage, role.DBName)
+		if err != nil {
+			return nil, err
+		}
 
-		// Get our connection
-		db, ok := b.getDBObj(role.DBName)
-		if !ok {
-			// Upgrade lock
-			b.RUnlock()
-			b.Lock()
-			unlockFunc = b.Unlock
+		statements := role.statements(config)
+		b.logRevocationStatements(config, role.DBName, statements, username)
+		backoff := revocationRetryBackoff
+		for attempt := 0; ; attempt++ {
+			err = b.revokeUser(ctx, req.Storage, role.DBName, statements, username)
+			if err == nil {
+				return resp, deleteIssuedUser(ctx, req.Storage, username)
+			}
+			if err == dbplugin.ErrUnsupportedOperation {
+				return resp, err
+			}
+			if attempt >= config.RevocationRetries {
+				break
+			}
 
-			// Create a new DB object
-			db, err = b.createDBObj(ctx, req.Storage, role.DBName)
-			if err != nil {
-				unlockFunc()
-				return nil, fmt.Errorf("cound not retrieve db with name: %s, got error: %s", role.DBName, err)
+			select {
+			case <-time.After(backoff):
+				backoff = nextRevocationRetryBackoff(backoff)
+			case <-ctx.Done():
+				return nil, err
 			}
 		}
 
-		if err := db.RevokeUser(ctx, role.Statements, username); err != nil {
-			unlockFunc()
-			b.closeIfShutdown(role.DBName, err)
+		// Queue the revocation rather than dropping it so the user doesn't
+		// outlive its lease once the database is reachable again.
+		b.logger.Warn("database: queueing failed revocation", "name", role.DBName, "error", redactutil.Error(err))
+		if err := b.queueRevocation(ctx, req.Storage, role.DBName, statements, username); err != nil {
 			return nil, err
 		}
 
+		return resp, deleteIssuedUser(ctx, req.Storage, username)
+	}
+}
+
+// revokeUser revokes username from the named connection, creating the
+// connection if it isn't cached. If the plugin shut down, the connection is
+// re-established and the revocation retried once.
+func (b *databaseBackend) revokeUser(ctx context.Context, s logical.Storage, dbName string, statements dbplugin.Statements, username string) error {
+	err := b.revokeUserOnce(ctx, s, dbName, statements, username)
+	if isPluginShutdown(err) {
+		b.logger.Warn("database: retrying revocation after the plugin shut down", "name", dbName)
+		err = b.revokeUserOnce(ctx, s, dbName, statements, username)
+	}
+
+	return err
+}
+
+func (b *databaseBackend) revokeUserOnce(ctx context.Context, s logical.Storage, dbName string, statements dbplugin.Statements, username string) error {
+	db, unlockFunc, err := b.lockedDBObj(ctx, s, dbName)
+	if err != nil {
+		return err
+	}
+
+	// Retrying can't help, which the caller tells by this value
+	if db.supports(dbplugin.CapabilityRevokeUser) != nil {
 		unlockFunc()
-		return resp, nil
+		return dbplugin.ErrUnsupportedOperation
+	}
+
+	if err := db.RevokeUser(ctx, statements, username); err != nil {
+		unlockFunc()
+		b.closeIfShutdown(dbName, db, err)
+		return err
+	}
+
+	unlockFunc()
+	return nil
+}
+
+// pendingRevocation is a revocation that failed every retry and is retried
+// by the periodic sweep until it succeeds.
+type pendingRevocation struct {
+	DBName     string              `json:"db_name"`
+	Statements dbplugin.Statements `json:"statements"`
+	Username   string              `json:"username"`
+	Attempts   int                 `json:"attempts"`
+}
+
+// queueRevocation persists a revocation for the periodic sweep.
+func (b *databaseBackend) queueRevocation(ctx context.Context, s logical.Storage, dbName string, statements dbplugin.Statements, username string) error {
+	id, err := uuid.GenerateUUID()
+	if err != nil {
+		return err
 	}
+
+	entry, err := logical.StorageEntryJSON(pendingRevocationPath+id, &pendingRevocation{
+		DBName:     dbName,
+		Statements: statements,
+		Username:   username,
+		Attempts:   1,
+	})
+	if err != nil {
+		return err
+	}
+
+	return s.Put(ctx, entry)
+}
+
+// sweepPendingRevocations retries every queued revocation once, removing
+// those that succeed.
+func (b *databaseBackend) sweepPendingRevocations(ctx context.Context, s logical.Storage) error {
+	ids, err := s.List(ctx, pendingRevocationPath)
+	if err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		entry, err := s.Get(ctx, pendingRevocationPath+id)
+		if err != nil {
+			return err
+		}
+		if entry == nil {
+			continue
+		}
+
+		var pending pendingRevocation
+		if err := entry.DecodeJSON(&pending); err != nil {
+			return err
+		}
+
+		err = b.revokeUser(ctx, s, pending.DBName, pending.Statements, pending.Username)
+		if err != nil {
+			pending.Attempts++
+			b.logger.Warn("database: pending revocation failed", "name", pending.DBName, "attempts", pending.Attempts, "error", redactutil.Error(err))
+
+			entry, err := logical.StorageEntryJSON(pendingRevocationPath+id, &pending)
+			if err != nil {
+				return err
+			}
+			if err := s.Put(ctx, entry); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := s.Delete(ctx, pendingRevocationPath+id); err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
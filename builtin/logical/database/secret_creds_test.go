@@ -0,0 +1,151 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/builtin/logical/database/dbplugin"
+	"github.com/hashicorp/vault/logical"
+)
+
+func TestBackend_revocationRetries(t *testing.T) {
+	b, storage := getBackend(t)
+
+	oldBackoff := revocationRetryBackoff
+	revocationRetryBackoff = time.Millisecond
+	defer func() { revocationRetryBackoff = oldBackoff }()
+
+	// Revocations fail until failures reaches zero
+	var failures int
+	var revoked []string
+	db := &fakeDatabase{
+		revokeUser: func(_ context.Context, _ dbplugin.Statements, username string) error {
+			if failures > 0 {
+				failures--
+				return errors.New("connection refused")
+			}
+			revoked = append(revoked, username)
+			return nil
+		},
+	}
+	dbi, err := newDBPluginInstance(context.Background(), db, &DatabaseConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	testFakeConnection(t, b, storage, dbi, &roleEntry{})
+
+	entry, err := logical.StorageEntryJSON("config/fake", &DatabaseConfig{
+		PluginName:        "fake",
+		AllowedRoles:      []string{"*"},
+		RevocationRetries: 2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := storage.Put(context.Background(), entry); err != nil {
+		t.Fatal(err)
+	}
+
+	revokeReq := func(username string) *logical.Request {
+		return &logical.Request{
+			Operation: logical.RevokeOperation,
+			Storage:   storage,
+			Secret: &logical.Secret{
+				InternalData: map[string]interface{}{
+					"secret_type": "creds",
+					"username":    username,
+					"role":        "readonly",
+				},
+			},
+		}
+	}
+
+	// Failures within the retry count are retried immediately
+	failures = 2
+	resp, err := b.HandleRequest(context.Background(), revokeReq("retried"))
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%s resp:%#v\n", err, resp)
+	}
+	if !reflect.DeepEqual(revoked, []string{"retried"}) {
+		t.Fatalf("expected user to be revoked, got: %v", revoked)
+	}
+
+	// Revocations that fail every retry are queued
+	failures = 3
+	resp, err = b.HandleRequest(context.Background(), revokeReq("queued"))
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%s resp:%#v\n", err, resp)
+	}
+	pending, err := storage.List(context.Background(), pendingRevocationPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != 1 {
+		t.Fatalf("expected one pending revocation, got: %v", pending)
+	}
+
+	// The sweep keeps the revocation queued while it keeps failing
+	failures = 1
+	if err := b.periodicFunc(context.Background(), &logical.Request{Storage: storage}); err != nil {
+		t.Fatal(err)
+	}
+	entry, err = storage.Get(context.Background(), pendingRevocationPath+pending[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	var queued pendingRevocation
+	if err := entry.DecodeJSON(&queued); err != nil {
+		t.Fatal(err)
+	}
+	if queued.Username != "queued" || queued.Attempts != 2 {
+		t.Fatalf("unexpected pending revocation: %#v", queued)
+	}
+
+	// and removes it once it succeeds
+	if err := b.periodicFunc(context.Background(), &logical.Request{Storage: storage}); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(revoked, []string{"retried", "queued"}) {
+		t.Fatalf("expected queued user to be revoked, got: %v", revoked)
+	}
+	pending, err = storage.List(context.Background(), pendingRevocationPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != 0 {
+		t.Fatalf("expected no pending revocations, got: %v", pending)
+	}
+}
+
+func TestBackend_revocationRetriesBounds(t *testing.T) {
+	b, storage := getBackend(t)
+
+	for _, retries := range []int{-1, maxRevocationRetries + 1} {
+		req := &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "config/fake",
+			Storage:   storage,
+			Data: map[string]interface{}{
+				"plugin_name":        "fake",
+				"verify_connection":  false,
+				"revocation_retries": retries,
+			},
+		}
+		resp, err := b.HandleRequest(context.Background(), req)
+		if err != nil || resp == nil || !resp.IsError() || !strings.Contains(resp.Data["error"].(string), "revocation_retries must be between") {
+			t.Fatalf("retries %d: expected error, got err:%s resp:%#v", retries, err, resp)
+		}
+	}
+
+	backoff := time.Second
+	for i := 0; i < maxRevocationRetries; i++ {
+		backoff = nextRevocationRetryBackoff(backoff)
+	}
+	if backoff != maxRevocationRetryBackoff {
+		t.Fatalf("expected backoff capped at %s, got %s", maxRevocationRetryBackoff, backoff)
+	}
+}
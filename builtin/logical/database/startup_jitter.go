@@ -0,0 +1,57 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/hashicorp/vault/helper/parseutil"
+)
+
+// parseStartupVerificationJitter parses the "startup_verification_jitter"
+// mount option, the longest random delay before a connection is first
+// established and verified. Zero, the default, disables the delay.
+func parseStartupVerificationJitter(raw string) (time.Duration, error) {
+	if raw == "" {
+		return 0, nil
+	}
+
+	jitter, err := parseutil.ParseDurationSecond(raw)
+	if err != nil || jitter < 0 {
+		return 0, fmt.Errorf("invalid startup_verification_jitter %q: must be a non-negative duration", raw)
+	}
+	return jitter, nil
+}
+
+// waitStartupJitter waits a random delay of up to the startup verification
+// jitter the first time the named connection is established since the mount
+// started. When Vault starts, every mount connects on the first requests it
+// receives; spreading these out keeps them from verifying their connections
+// against the same database all at once. It returns early with an error if
+// ctx is done first. It must be called without holding the backend's lock,
+// so that connections wait out their delays concurrently.
+func (b *databaseBackend) waitStartupJitter(ctx context.Context, name string) error {
+	if b.startupJitter <= 0 {
+		return nil
+	}
+
+	b.jitteredLock.Lock()
+	if b.jittered[name] {
+		b.jitteredLock.Unlock()
+		return nil
+	}
+	b.jittered[name] = true
+	b.jitteredLock.Unlock()
+
+	delay := time.Duration(rand.Int63n(int64(b.startupJitter)))
+	select {
+	case <-ctx.Done():
+		b.jitteredLock.Lock()
+		delete(b.jittered, name)
+		b.jitteredLock.Unlock()
+		return ctx.Err()
+	case <-time.After(delay):
+		return nil
+	}
+}
@@ -0,0 +1,94 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/logical"
+)
+
+func TestBackend_startupVerificationJitterMount(t *testing.T) {
+	cluster, mounted := testMountCluster(t)
+	defer cluster.Cleanup()
+
+	for _, jitter := range []string{"-1s", "soon"} {
+		if err := testMountOptions(t, cluster, "bad", map[string]string{"startup_verification_jitter": jitter}); err == nil {
+			t.Fatalf("expected error for startup_verification_jitter %q", jitter)
+		}
+	}
+
+	if err := testMountOptions(t, cluster, "db", map[string]string{"startup_verification_jitter": "90"}); err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := mounted(); b == nil || b.startupJitter != 90*time.Second {
+		t.Fatal("expected startup_verification_jitter to be set on the mounted backend")
+	}
+}
+
+func TestBackend_startupVerificationJitter(t *testing.T) {
+	for _, raw := range []string{"-1s", "soon"} {
+		if _, err := parseStartupVerificationJitter(raw); err == nil {
+			t.Fatalf("expected error for %q", raw)
+		}
+	}
+	if jitter, err := parseStartupVerificationJitter("30"); err != nil || jitter != 30*time.Second {
+		t.Fatalf("expected 30s, got %s (err: %v)", jitter, err)
+	}
+
+	config := logical.TestBackendConfig()
+	b := Backend(config)
+	b.startupJitter = 200 * time.Millisecond
+
+	// Simultaneous first connections are spread over the jitter
+	const connections = 40
+	delays := make([]time.Duration, connections)
+	var wg sync.WaitGroup
+	for i := 0; i < connections; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			start := time.Now()
+			if err := b.waitStartupJitter(context.Background(), fmt.Sprintf("db-%d", i)); err != nil {
+				t.Error(err)
+			}
+			delays[i] = time.Since(start)
+		}(i)
+	}
+	wg.Wait()
+
+	min, max := delays[0], delays[0]
+	for _, d := range delays {
+		if d < min {
+			min = d
+		}
+		if d > max {
+			max = d
+		}
+	}
+	if max > b.startupJitter+100*time.Millisecond {
+		t.Fatalf("expected delays within the jitter, got up to %s", max)
+	}
+	if max-min < b.startupJitter/2 {
+		t.Fatalf("expected delays to be spread out, got %s to %s", min, max)
+	}
+
+	// Only the first connection is delayed
+	start := time.Now()
+	if err := b.waitStartupJitter(context.Background(), "db-0"); err != nil || time.Since(start) > 10*time.Millisecond {
+		t.Fatalf("expected no delay, got %s (err: %v)", time.Since(start), err)
+	}
+
+	// A cancelled wait is taken again by the next connection attempt
+	b.startupJitter = time.Hour
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := b.waitStartupJitter(ctx, "cancelled"); err == nil {
+		t.Fatal("expected error once the context is done")
+	}
+	if b.jittered["cancelled"] {
+		t.Fatal("expected the cancelled wait to be forgotten")
+	}
+}
@@ -0,0 +1,54 @@
+package database
+
+import (
+	"time"
+
+	"github.com/hashicorp/vault/builtin/logical/database/dbplugin"
+	"github.com/hashicorp/vault/helper/redactutil"
+	"github.com/hashicorp/vault/plugins/helper/database/dbutil"
+)
+
+// logCreationStatements logs the statements run to create username if the
+// connection has log_statements set.
+func (b *databaseBackend) logCreationStatements(config *DatabaseConfig, dbName string, statements dbplugin.Statements, username, password string, expiration time.Time) {
+	if !config.LogStatements {
+		return
+	}
+
+	data := map[string]string{
+		"name":       username,
+		"password":   redactutil.Redacted,
+		"expiration": expiration.UTC().Format(time.RFC3339),
+	}
+	b.logStatements("creation", dbName, username, dbutil.CreationQueries(statements), data, password)
+}
+
+// logRevocationStatements logs the statements run to revoke username if the
+// connection has log_statements set.
+func (b *databaseBackend) logRevocationStatements(config *DatabaseConfig, dbName string, statements dbplugin.Statements, username string) {
+	if !config.LogStatements {
+		return
+	}
+
+	var queries []string
+	for _, step := range dbutil.RevocationSteps(statements) {
+		queries = append(queries, step.Query)
+	}
+	b.logStatements("revocation", dbName, username, queries, map[string]string{"name": username})
+}
+
+// logStatements logs queries at debug level with the values in data
+// substituted. The values the plugin generates itself, such as "timestamp"
+// and "random", are left as placeholders. The password is never substituted,
+// and the given secrets are redacted should they appear in a query anyway.
+func (b *databaseBackend) logStatements(kind, dbName, username string, queries []string, data map[string]string, secrets ...string) {
+	if len(queries) == 0 {
+		b.logger.Debug("database: no "+kind+" statements given, the plugin's defaults are run", "name", dbName, "username", username)
+		return
+	}
+
+	for i, query := range queries {
+		query = redactutil.String(dbutil.QueryHelper(query, data), secrets...)
+		b.logger.Debug("database: running "+kind+" statement", "name", dbName, "username", username, "index", i, "statement", query)
+	}
+}
@@ -0,0 +1,98 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/vault/builtin/logical/database/dbplugin"
+	"github.com/hashicorp/vault/helper/logformat"
+	"github.com/hashicorp/vault/logical"
+	logxi "github.com/mgutz/logxi/v1"
+)
+
+func TestBackend_logStatements(t *testing.T) {
+	var logs bytes.Buffer
+	config := logical.TestBackendConfig()
+	config.StorageView = &logical.InmemStorage{}
+	config.Logger = logformat.NewVaultLoggerWithWriter(&logs, logxi.LevelTrace)
+	b := Backend(config)
+	if err := b.Setup(context.Background(), config); err != nil {
+		t.Fatal(err)
+	}
+
+	dbi, err := newDBPluginInstance(context.Background(), &fakeDatabase{}, &DatabaseConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	testFakeConnection(t, b, config.StorageView, dbi, &roleEntry{
+		Statements: dbplugin.Statements{
+			CreationStatements:   `CREATE ROLE "{{name}}" WITH PASSWORD '{{password}}'; COMMENT ON ROLE "{{name}}" IS '{{upper password}}';`,
+			RevocationStatements: `DROP ROLE "{{name}}";`,
+		},
+	})
+
+	setLogStatements := func(enabled bool) {
+		entry, err := logical.StorageEntryJSON("config/fake", &DatabaseConfig{
+			PluginName:    "fake",
+			AllowedRoles:  []string{"*"},
+			LogStatements: enabled,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := config.StorageView.Put(context.Background(), entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+	issueAndRevoke := func() {
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      "creds/readonly",
+			Storage:   config.StorageView,
+		})
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("err:%s resp:%#v\n", err, resp)
+		}
+
+		resp, err = b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.RevokeOperation,
+			Storage:   config.StorageView,
+			Secret: &logical.Secret{
+				InternalData: map[string]interface{}{
+					"secret_type": "creds",
+					"username":    "user",
+					"role":        "readonly",
+				},
+			},
+		})
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("err:%s resp:%#v\n", err, resp)
+		}
+	}
+
+	// Nothing is logged by default
+	setLogStatements(false)
+	issueAndRevoke()
+	if strings.Contains(logs.String(), "statement") {
+		t.Fatalf("expected no statements to be logged, got: %s", logs.String())
+	}
+
+	setLogStatements(true)
+	issueAndRevoke()
+	for _, expected := range []string{
+		`CREATE ROLE "user" WITH PASSWORD '[redacted]'`,
+		`COMMENT ON ROLE "user" IS '[REDACTED]'`,
+		`DROP ROLE "user"`,
+	} {
+		if !strings.Contains(logs.String(), expected) {
+			t.Fatalf("expected %q to be logged, got: %s", expected, logs.String())
+		}
+	}
+
+	// The password the plugin returned never appears
+	if strings.Contains(logs.String(), "'password'") || strings.Contains(logs.String(), "'PASSWORD'") {
+		t.Fatalf("expected the password to be redacted, got: %s", logs.String())
+	}
+}
@@ -11,6 +11,7 @@ import (
 
 	"github.com/hashicorp/vault/api"
 	"github.com/hashicorp/vault/audit"
+	"github.com/hashicorp/vault/builtin/logical/database"
 	"github.com/hashicorp/vault/builtin/logical/pki"
 	"github.com/hashicorp/vault/builtin/logical/ssh"
 	"github.com/hashicorp/vault/builtin/logical/transit"
@@ -37,6 +38,7 @@ var (
 	}
 
 	defaultVaultLogicalBackends = map[string]logical.Factory{
+		"database":       database.Factory,
 		"generic-leased": vault.LeasedPassthroughBackendFactory,
 		"pki":            pki.Factory,
 		"ssh":            ssh.Factory,
@@ -27,6 +27,7 @@ type SecretsEnableCommand struct {
 	flagPluginName               string
 	flagLocal                    bool
 	flagSealWrap                 bool
+	flagOptions                  map[string]string
 }
 
 func (c *SecretsEnableCommand) Synopsis() string {
@@ -56,6 +57,10 @@ Usage: vault secrets enable [options] TYPE
 
       $ vault secrets enable -max-lease-ttl=30m database
 
+  Enable the database secrets engine, capping the connections it keeps open:
+
+      $ vault secrets enable -options=max_cached_connections=10 database
+
   Enable a custom plugin (after it is registered in the plugin registry):
 
       $ vault secrets enable -path=my-secrets -plugin-name=my-plugin plugin
@@ -153,6 +158,15 @@ func (c *SecretsEnableCommand) Flags() *FlagSets {
 		Usage:   "Enable seal wrapping of critical values in the secrets engine.",
 	})
 
+	f.StringMapVar(&StringMapVar{
+		Name:       "options",
+		Target:     &c.flagOptions,
+		Completion: complete.PredictAnything,
+		Usage: "Key-value pair provided as key=value for the options of the " +
+			"secrets engine. Which options are accepted depends on the type of " +
+			"the engine. This can be specified multiple times.",
+	})
+
 	return set
 }
 
@@ -211,6 +225,7 @@ func (c *SecretsEnableCommand) Run(args []string) int {
 		Description: c.flagDescription,
 		Local:       c.flagLocal,
 		SealWrap:    c.flagSealWrap,
+		Options:     c.flagOptions,
 		Config: api.MountConfigInput{
 			DefaultLeaseTTL: c.flagDefaultLeaseTTL.String(),
 			MaxLeaseTTL:     c.flagMaxLeaseTTL.String(),
@@ -140,6 +140,50 @@ func TestSecretsEnableCommand_Run(t *testing.T) {
 		}
 	})
 
+	t.Run("options", func(t *testing.T) {
+		t.Parallel()
+
+		client, closer := testVaultServer(t)
+		defer closer()
+
+		ui, cmd := testSecretsEnableCommand(t)
+		cmd.client = client
+
+		code := cmd.Run([]string{
+			"-options", "max_cached_connections=10",
+			"database",
+		})
+		if exp := 0; code != exp {
+			t.Errorf("expected %d to be %d: %s", code, exp, ui.ErrorWriter.String())
+		}
+
+		mounts, err := client.Sys().ListMounts()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if exp := "10"; mounts["database/"].Options["max_cached_connections"] != exp {
+			t.Errorf("expected %q to be %q", mounts["database/"].Options["max_cached_connections"], exp)
+		}
+
+		// Secrets engines that take no options refuse them
+		ui, cmd = testSecretsEnableCommand(t)
+		cmd.client = client
+
+		code = cmd.Run([]string{
+			"-options", "foo=bar",
+			"pki",
+		})
+		if exp := 2; code != exp {
+			t.Errorf("expected %d to be %d", code, exp)
+		}
+
+		expected := `backend does not accept option "foo"`
+		combined := ui.OutputWriter.String() + ui.ErrorWriter.String()
+		if !strings.Contains(combined, expected) {
+			t.Errorf("expected %q to contain %q", combined, expected)
+		}
+	})
+
 	t.Run("communication_failure", func(t *testing.T) {
 		t.Parallel()
 
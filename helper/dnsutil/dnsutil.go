@@ -0,0 +1,64 @@
+package dnsutil
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+)
+
+// ValidateResolver checks that addr is the "ip:port" address of a DNS server.
+// The host must be an IP address since the resolver can't be used to look up
+// its own name.
+func ValidateResolver(addr string) error {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return fmt.Errorf("invalid resolver address %q: %s", addr, err)
+	}
+
+	if net.ParseIP(host) == nil {
+		return fmt.Errorf("invalid resolver address %q: host must be an IP address", addr)
+	}
+
+	p, err := strconv.Atoi(port)
+	if err != nil || p <= 0 || p > 65535 {
+		return fmt.Errorf("invalid resolver address %q: invalid port", addr)
+	}
+
+	return nil
+}
+
+// NewResolver returns a resolver that sends all queries to the DNS server at
+// addr. If addr is empty, nil is returned, which the net package treats as the
+// system resolver.
+func NewResolver(addr string) *net.Resolver {
+	if addr == "" {
+		return nil
+	}
+
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, network, addr)
+		},
+	}
+}
+
+// LookupHost returns the first address found for host using the given
+// resolver. IP addresses are returned unchanged.
+func LookupHost(ctx context.Context, resolver *net.Resolver, host string) (string, error) {
+	if net.ParseIP(host) != nil {
+		return host, nil
+	}
+
+	addrs, err := resolver.LookupHost(ctx, host)
+	if err != nil {
+		return "", err
+	}
+	if len(addrs) == 0 {
+		return "", fmt.Errorf("no addresses found for host %q", host)
+	}
+
+	return addrs[0], nil
+}
@@ -0,0 +1,92 @@
+package dnsutil
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+// testStubResolver starts a DNS server answering A queries from records and
+// returns its address along with a function to stop it.
+func testStubResolver(t *testing.T, records map[string]string) (string, func()) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := &dns.Server{
+		PacketConn: pc,
+		Handler: dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
+			m := new(dns.Msg)
+			m.SetReply(r)
+			for _, q := range r.Question {
+				ip, ok := records[q.Name]
+				if !ok || q.Qtype != dns.TypeA {
+					continue
+				}
+				rr, err := dns.NewRR(q.Name + " 60 IN A " + ip)
+				if err != nil {
+					t.Error(err)
+					continue
+				}
+				m.Answer = append(m.Answer, rr)
+			}
+			w.WriteMsg(m)
+		}),
+	}
+	go server.ActivateAndServe()
+
+	return pc.LocalAddr().String(), func() { server.Shutdown() }
+}
+
+func TestValidateResolver(t *testing.T) {
+	valid := []string{"127.0.0.1:53", "[::1]:5353"}
+	for _, addr := range valid {
+		if err := ValidateResolver(addr); err != nil {
+			t.Fatalf("expected %q to be valid, got: %s", addr, err)
+		}
+	}
+
+	invalid := []string{"", "127.0.0.1", "dns.example.com:53", "127.0.0.1:dns", "127.0.0.1:0", "127.0.0.1:70000"}
+	for _, addr := range invalid {
+		if err := ValidateResolver(addr); err == nil {
+			t.Fatalf("expected %q to be invalid", addr)
+		}
+	}
+}
+
+func TestLookupHost(t *testing.T) {
+	addr, cleanup := testStubResolver(t, map[string]string{
+		"db.internal.": "10.1.2.3",
+	})
+	defer cleanup()
+
+	resolver := NewResolver(addr)
+
+	ip, err := LookupHost(context.Background(), resolver, "db.internal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ip != "10.1.2.3" {
+		t.Fatalf("expected 10.1.2.3, got %q", ip)
+	}
+
+	if _, err := LookupHost(context.Background(), resolver, "missing.internal"); err == nil {
+		t.Fatal("expected error resolving unknown host")
+	}
+
+	// IP addresses are never looked up
+	ip, err = LookupHost(context.Background(), resolver, "192.168.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ip != "192.168.0.1" {
+		t.Fatalf("expected 192.168.0.1, got %q", ip)
+	}
+
+	if NewResolver("") != nil {
+		t.Fatal("expected the system resolver when no address is given")
+	}
+}
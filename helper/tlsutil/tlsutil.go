@@ -1,7 +1,11 @@
 package tlsutil
 
 import (
+	"bytes"
 	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
 	"fmt"
 
 	"github.com/hashicorp/vault/helper/strutil"
@@ -52,3 +56,41 @@ func ParseCiphers(cipherStr string) ([]uint16, error) {
 
 	return suites, nil
 }
+
+// ErrPinnedCertificateMismatch is returned by the handshake of a TLS
+// configuration with a pinned certificate when the server presents another
+// certificate.
+var ErrPinnedCertificateMismatch = errors.New("server certificate does not match the pinned certificate")
+
+// ParsePinnedCertificate parses a single PEM encoded certificate to pin.
+func ParsePinnedCertificate(pemCert string) (*x509.Certificate, error) {
+	block, rest := pem.Decode([]byte(pemCert))
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, fmt.Errorf("failed to decode PEM block in the pinned certificate")
+	}
+	if len(bytes.TrimSpace(rest)) != 0 {
+		return nil, fmt.Errorf("the pinned certificate must be a single certificate")
+	}
+
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the pinned certificate: %s", err)
+	}
+	return cert, nil
+}
+
+// PinCertificate configures tlsConfig to only accept servers presenting cert
+// as their certificate, such as a self-signed certificate. Since the pinned
+// certificate is trusted as is, the chain and host name aren't verified, but
+// unlike InsecureSkipVerify the handshake fails unless the server presents
+// exactly this certificate.
+func PinCertificate(tlsConfig *tls.Config, cert *x509.Certificate) {
+	// The standard verification is replaced rather than skipped
+	tlsConfig.InsecureSkipVerify = true
+	tlsConfig.VerifyPeerCertificate = func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+		if len(rawCerts) == 0 || !bytes.Equal(rawCerts[0], cert.Raw) {
+			return ErrPinnedCertificateMismatch
+		}
+		return nil
+	}
+}
@@ -1,9 +1,20 @@
 package tlsutil
 
 import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
 	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestParseCiphers(t *testing.T) {
@@ -28,3 +39,57 @@ func TestParseCiphers(t *testing.T) {
 		t.Fatal("cipher order is not preserved")
 	}
 }
+
+func TestPinCertificate(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	defer server.Close()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "other"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	otherDER, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pemCert := func(der []byte) string {
+		return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+	}
+	handshake := func(pinned string) error {
+		cert, err := ParsePinnedCertificate(pinned)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tlsConfig := &tls.Config{}
+		PinCertificate(tlsConfig, cert)
+
+		conn, err := tls.Dial("tcp", server.Listener.Addr().String(), tlsConfig)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	}
+
+	// The self-signed certificate is accepted without a CA
+	if err := handshake(pemCert(server.Certificate().Raw)); err != nil {
+		t.Fatal(err)
+	}
+
+	// Any other certificate is refused
+	if err := handshake(pemCert(otherDER)); err == nil || !strings.Contains(err.Error(), ErrPinnedCertificateMismatch.Error()) {
+		t.Fatalf("expected mismatch error, got: %v", err)
+	}
+
+	for _, invalid := range []string{"", "not a certificate", pemCert(server.Certificate().Raw) + pemCert(otherDER)} {
+		if _, err := ParsePinnedCertificate(invalid); err == nil {
+			t.Fatalf("expected error for %q", invalid)
+		}
+	}
+}
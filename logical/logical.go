@@ -84,6 +84,13 @@ type Backend interface {
 	Type() BackendType
 }
 
+// OptionsBackend is implemented by backends that take options when they're
+// mounted. Mounting with options is refused for other backends.
+type OptionsBackend interface {
+	// MountOptions returns the names of the options the backend accepts.
+	MountOptions() []string
+}
+
 // BackendConfig is provided to the factory to initialize the backend
 type BackendConfig struct {
 	// View should not be stored, and should only be used for initialization
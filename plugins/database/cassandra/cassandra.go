@@ -40,6 +40,7 @@ func New() (interface{}, error) {
 		RoleNameLen:    15,
 		UsernameLen:    100,
 		Separator:      "_",
+		Type:           cassandraTypeName,
 	}
 
 	dbType := &Cassandra{
@@ -76,6 +77,30 @@ func (c *Cassandra) getConnection(ctx context.Context) (*gocql.Session, error) {
 	return session.(*gocql.Session), nil
 }
 
+// PluginInfo returns the version and build of the plugin.
+func (c *Cassandra) PluginInfo(ctx context.Context) (*dbplugin.BuildInfo, error) {
+	return dbutil.BuildInfo(), nil
+}
+
+// GenerateCredentials generates a username and password the way CreateUser
+// does, without creating the user.
+func (c *Cassandra) GenerateCredentials(ctx context.Context, usernameConfig dbplugin.UsernameConfig) (username string, password string, err error) {
+	username, err = c.GenerateUsername(usernameConfig)
+	username = strings.Replace(username, "-", "_", -1)
+	if err != nil {
+		return "", "", err
+	}
+	// Cassandra doesn't like the uppercase usernames
+	username = strings.ToLower(username)
+
+	password, err = c.GeneratePassword()
+	if err != nil {
+		return "", "", err
+	}
+
+	return username, password, nil
+}
+
 // CreateUser generates the username/password on the underlying Cassandra secret backend as instructed by
 // the CreationStatement provided.
 func (c *Cassandra) CreateUser(ctx context.Context, statements dbplugin.Statements, usernameConfig dbplugin.UsernameConfig, expiration time.Time) (username string, password string, err error) {
@@ -98,15 +123,16 @@ func (c *Cassandra) CreateUser(ctx context.Context, statements dbplugin.Statemen
 		rollbackCQL = defaultUserDeletionCQL
 	}
 
-	username, err = c.GenerateUsername(usernameConfig)
-	username = strings.Replace(username, "-", "_", -1)
+	username, password, err = c.GenerateCredentials(ctx, usernameConfig)
 	if err != nil {
 		return "", "", err
 	}
-	// Cassandra doesn't like the uppercase usernames
-	username = strings.ToLower(username)
 
-	password, err = c.GeneratePassword()
+	// Values substituted into the creation statements
+	data, err := dbutil.CreationData(map[string]string{
+		"username": username,
+		"password": password,
+	})
 	if err != nil {
 		return "", "", err
 	}
@@ -118,10 +144,7 @@ func (c *Cassandra) CreateUser(ctx context.Context, statements dbplugin.Statemen
 			continue
 		}
 
-		err = session.Query(dbutil.QueryHelper(query, map[string]string{
-			"username": username,
-			"password": password,
-		})).Exec()
+		err = session.Query(dbutil.QueryHelper(query, data)).Exec()
 		if err != nil {
 			for _, query := range strutil.ParseArbitraryStringSlice(rollbackCQL, ";") {
 				query = strings.TrimSpace(query)
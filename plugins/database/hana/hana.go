@@ -10,7 +10,6 @@ import (
 	_ "github.com/SAP/go-hdb/driver"
 	"github.com/hashicorp/vault/api"
 	"github.com/hashicorp/vault/builtin/logical/database/dbplugin"
-	"github.com/hashicorp/vault/helper/strutil"
 	"github.com/hashicorp/vault/plugins"
 	"github.com/hashicorp/vault/plugins/helper/database/connutil"
 	"github.com/hashicorp/vault/plugins/helper/database/credsutil"
@@ -28,6 +27,9 @@ type HANA struct {
 }
 
 var _ dbplugin.Database = &HANA{}
+var _ dbplugin.PoolSettingsUpdater = &HANA{}
+var _ dbplugin.VersionReporter = &HANA{}
+var _ dbplugin.Pinger = &HANA{}
 
 // New implements builtinplugins.BuiltinFactory
 func New() (interface{}, error) {
@@ -39,6 +41,7 @@ func New() (interface{}, error) {
 		RoleNameLen:    20,
 		UsernameLen:    128,
 		Separator:      "_",
+		Type:           hanaTypeName,
 	}
 
 	dbType := &HANA{
@@ -66,32 +69,57 @@ func (h *HANA) Type() (string, error) {
 	return hanaTypeName, nil
 }
 
-func (h *HANA) getConnection(ctx context.Context) (*sql.DB, error) {
-	db, err := h.Connection(ctx)
-	if err != nil {
-		return nil, err
-	}
+// UpdatePoolSettings applies changed pool settings to the live connection.
+func (h *HANA) UpdatePoolSettings(ctx context.Context, conf map[string]interface{}) error {
+	return connutil.UpdatePoolSettings(ctx, h.ConnectionProducer, conf)
+}
 
-	return db.(*sql.DB), nil
+// Ping checks that the database server is reachable.
+func (h *HANA) Ping(ctx context.Context) error {
+	return connutil.Ping(ctx, h.ConnectionProducer)
 }
 
-// CreateUser generates the username/password on the underlying HANA secret backend
-// as instructed by the CreationStatement provided.
-func (h *HANA) CreateUser(ctx context.Context, statements dbplugin.Statements, usernameConfig dbplugin.UsernameConfig, expiration time.Time) (username string, password string, err error) {
-	// Grab the lock
+// ServerVersion returns the version reported by the database server, or by
+// one of its replicas if any are configured.
+func (h *HANA) ServerVersion(ctx context.Context) (string, error) {
 	h.Lock()
 	defer h.Unlock()
 
-	// Get the connection
-	db, err := h.getConnection(ctx)
+	db, err := h.ConnectionProducer.(*connutil.SQLConnectionProducer).ReadConnection(ctx)
 	if err != nil {
-		return "", "", err
+		return "", err
 	}
 
-	if statements.CreationStatements == "" {
-		return "", "", dbutil.ErrEmptyCreationStatement
+	var version string
+	if err := db.QueryRowContext(ctx, "SELECT VERSION FROM SYS.M_DATABASE;").Scan(&version); err != nil {
+		return "", err
+	}
+
+	return version, nil
+}
+
+func (h *HANA) getConnection(ctx context.Context) (*sql.DB, error) {
+	db, err := h.Connection(ctx)
+	if err != nil {
+		return nil, err
 	}
 
+	return db.(*sql.DB), nil
+}
+
+// Capabilities returns the operations the plugin supports.
+func (h *HANA) Capabilities(ctx context.Context) ([]string, error) {
+	return dbutil.SQLCapabilities(h), nil
+}
+
+// PluginInfo returns the version and build of the plugin.
+func (h *HANA) PluginInfo(ctx context.Context) (*dbplugin.BuildInfo, error) {
+	return dbutil.BuildInfo(), nil
+}
+
+// GenerateCredentials generates a username and password the way CreateUser
+// does, without creating the user.
+func (h *HANA) GenerateCredentials(ctx context.Context, usernameConfig dbplugin.UsernameConfig) (username string, password string, err error) {
 	// Generate username
 	username, err = h.GenerateUsername(usernameConfig)
 	if err != nil {
@@ -103,7 +131,7 @@ func (h *HANA) CreateUser(ctx context.Context, statements dbplugin.Statements, u
 	username = strings.ToUpper(username)
 
 	// Generate password
-	password, err = h.GeneratePassword()
+	password, err = credsutil.GeneratePasswordWithComplexity(h.CredentialsProducer, connutil.MaxPasswordLength(h.ConnectionProducer), credsutil.ComplexityFromUsernameConfig(usernameConfig))
 	if err != nil {
 		return "", "", err
 	}
@@ -112,6 +140,32 @@ func (h *HANA) CreateUser(ctx context.Context, statements dbplugin.Statements, u
 	password = strings.Replace(password, "-", "_", -1)
 	password = "A1a" + password
 
+	return username, password, nil
+}
+
+// CreateUser generates the username/password on the underlying HANA secret backend
+// as instructed by the CreationStatement provided.
+func (h *HANA) CreateUser(ctx context.Context, statements dbplugin.Statements, usernameConfig dbplugin.UsernameConfig, expiration time.Time) (username string, password string, err error) {
+	// Grab the lock
+	h.Lock()
+	defer h.Unlock()
+
+	// Get a connection from the pool of the role's class
+	db, err := h.ConnectionProducer.(*connutil.SQLConnectionProducer).PoolConnection(ctx, usernameConfig.PoolClass)
+	if err != nil {
+		return "", "", err
+	}
+
+	queries := dbutil.CreationQueries(statements)
+	if len(queries) == 0 {
+		return "", "", dbutil.ErrEmptyCreationStatement
+	}
+
+	username, password, err = h.GenerateCredentials(ctx, usernameConfig)
+	if err != nil {
+		return "", "", err
+	}
+
 	// If expiration is in the role SQL, HANA will deactivate the user when time is up,
 	// regardless of whether vault is alive to revoke lease
 	expirationStr, err := h.GenerateExpiration(expiration)
@@ -119,25 +173,34 @@ func (h *HANA) CreateUser(ctx context.Context, statements dbplugin.Statements, u
 		return "", "", err
 	}
 
+	// Values substituted into the creation statements
+	data, err := dbutil.CreationData(map[string]string{
+		"name":       username,
+		"password":   password,
+		"expiration": expirationStr,
+	})
+	if err != nil {
+		return "", "", err
+	}
+
+	// Take a connection from the pool, failing if none frees up within
+	// the connection acquire timeout
+	conn, err := h.ConnectionProducer.(*connutil.SQLConnectionProducer).AcquireConn(ctx, db)
+	if err != nil {
+		return "", "", err
+	}
+	defer conn.Close()
+
 	// Start a transaction
-	tx, err := db.BeginTx(ctx, nil)
+	tx, err := conn.BeginTx(ctx, nil)
 	if err != nil {
 		return "", "", err
 	}
 	defer tx.Rollback()
 
 	// Execute each query
-	for _, query := range strutil.ParseArbitraryStringSlice(statements.CreationStatements, ";") {
-		query = strings.TrimSpace(query)
-		if len(query) == 0 {
-			continue
-		}
-
-		stmt, err := tx.PrepareContext(ctx, dbutil.QueryHelper(query, map[string]string{
-			"name":       username,
-			"password":   password,
-			"expiration": expirationStr,
-		}))
+	for _, query := range queries {
+		stmt, err := tx.PrepareContext(ctx, dbutil.QueryHelper(query, data))
 		if err != nil {
 			return "", "", err
 		}
@@ -163,8 +226,14 @@ func (h *HANA) RenewUser(ctx context.Context, statements dbplugin.Statements, us
 		return err
 	}
 
+	conn, err := h.ConnectionProducer.(*connutil.SQLConnectionProducer).AcquireConn(ctx, db)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
 	// Start a transaction
-	tx, err := db.BeginTx(ctx, nil)
+	tx, err := conn.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
@@ -198,7 +267,8 @@ func (h *HANA) RenewUser(ctx context.Context, statements dbplugin.Statements, us
 // Revoking hana user will deactivate user and try to perform a soft drop
 func (h *HANA) RevokeUser(ctx context.Context, statements dbplugin.Statements, username string) error {
 	// default revoke will be a soft drop on user
-	if statements.RevocationStatements == "" {
+	queries := dbutil.RevocationSteps(statements)
+	if len(queries) == 0 {
 		return h.revokeUserDefault(ctx, username)
 	}
 
@@ -208,29 +278,41 @@ func (h *HANA) RevokeUser(ctx context.Context, statements dbplugin.Statements, u
 		return err
 	}
 
+	conn, err := h.ConnectionProducer.(*connutil.SQLConnectionProducer).AcquireConn(ctx, db)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
 	// Start a transaction
-	tx, err := db.BeginTx(ctx, nil)
+	tx, err := conn.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
 	defer tx.Rollback()
 
-	// Execute each query
-	for _, query := range strutil.ParseArbitraryStringSlice(statements.RevocationStatements, ";") {
-		query = strings.TrimSpace(query)
-		if len(query) == 0 {
-			continue
+	for _, query := range queries {
+		// A failed statement may leave the transaction unusable, so
+		// statements that may fail run in a savepoint that is rolled back if
+		// they do.
+		if query.ContinueOnError {
+			if _, err := tx.ExecContext(ctx, "SAVEPOINT revocation_step"); err != nil {
+				return err
+			}
 		}
 
-		stmt, err := tx.PrepareContext(ctx, dbutil.QueryHelper(query, map[string]string{
-			"name": username,
-		}))
-		if err != nil {
-			return err
-		}
-		defer stmt.Close()
-		if _, err := stmt.ExecContext(ctx); err != nil {
+		err := h.execRevocationQuery(ctx, tx, query.Query, username)
+		switch {
+		case err != nil && !query.ContinueOnError:
 			return err
+		case err != nil:
+			if _, err := tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT revocation_step"); err != nil {
+				return err
+			}
+		case query.ContinueOnError:
+			if _, err := tx.ExecContext(ctx, "RELEASE SAVEPOINT revocation_step"); err != nil {
+				return err
+			}
 		}
 	}
 
@@ -242,6 +324,19 @@ func (h *HANA) RevokeUser(ctx context.Context, statements dbplugin.Statements, u
 	return nil
 }
 
+func (h *HANA) execRevocationQuery(ctx context.Context, tx *sql.Tx, query, username string) error {
+	stmt, err := tx.PrepareContext(ctx, dbutil.QueryHelper(query, map[string]string{
+		"name": username,
+	}))
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	_, err = stmt.ExecContext(ctx)
+	return err
+}
+
 func (h *HANA) revokeUserDefault(ctx context.Context, username string) error {
 	// Get connection
 	db, err := h.getConnection(ctx)
@@ -249,8 +344,14 @@ func (h *HANA) revokeUserDefault(ctx context.Context, username string) error {
 		return err
 	}
 
+	conn, err := h.ConnectionProducer.(*connutil.SQLConnectionProducer).AcquireConn(ctx, db)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
 	// Start a transaction
-	tx, err := db.BeginTx(ctx, nil)
+	tx, err := conn.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
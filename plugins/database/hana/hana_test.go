@@ -147,6 +147,28 @@ func TestHANA_RevokeUser(t *testing.T) {
 	if err := testCredsExist(t, connURL, username, password); err == nil {
 		t.Fatal("Credentials were not revoked")
 	}
+
+	// Test revocation steps going on past a failing step
+	username, password, err = db.CreateUser(context.Background(), statements, usernameConfig, time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err = testCredsExist(t, connURL, username, password); err != nil {
+		t.Fatalf("Could not connect with new credentials: %s", err)
+	}
+
+	statements.RevocationStatements = ""
+	statements.RevocationSteps = []*dbplugin.RevocationStep{
+		{Statement: "DROP USER VAULT_MISSING_USER CASCADE;", ContinueOnError: true},
+		{Statement: testHANADrop},
+	}
+	err = db.RevokeUser(context.Background(), statements, username)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := testCredsExist(t, connURL, username, password); err == nil {
+		t.Fatal("Credentials were not revoked")
+	}
 }
 
 func testCredsExist(t testing.TB, connURL, username, password string) error {
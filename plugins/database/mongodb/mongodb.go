@@ -40,6 +40,7 @@ func New() (interface{}, error) {
 		RoleNameLen:    15,
 		UsernameLen:    100,
 		Separator:      "-",
+		Type:           mongoDBTypeName,
 	}
 
 	dbType := &MongoDB{
@@ -75,6 +76,27 @@ func (m *MongoDB) getConnection(ctx context.Context) (*mgo.Session, error) {
 	return session.(*mgo.Session), nil
 }
 
+// PluginInfo returns the version and build of the plugin.
+func (m *MongoDB) PluginInfo(ctx context.Context) (*dbplugin.BuildInfo, error) {
+	return dbutil.BuildInfo(), nil
+}
+
+// GenerateCredentials generates a username and password the way CreateUser
+// does, without creating the user.
+func (m *MongoDB) GenerateCredentials(ctx context.Context, usernameConfig dbplugin.UsernameConfig) (username string, password string, err error) {
+	username, err = m.GenerateUsername(usernameConfig)
+	if err != nil {
+		return "", "", err
+	}
+
+	password, err = m.GeneratePassword()
+	if err != nil {
+		return "", "", err
+	}
+
+	return username, password, nil
+}
+
 // CreateUser generates the username/password on the underlying secret backend as instructed by
 // the CreationStatement provided. The creation statement is a JSON blob that has a db value,
 // and an array of roles that accepts a role, and an optional db value pair. This array will
@@ -97,12 +119,7 @@ func (m *MongoDB) CreateUser(ctx context.Context, statements dbplugin.Statements
 		return "", "", err
 	}
 
-	username, err = m.GenerateUsername(usernameConfig)
-	if err != nil {
-		return "", "", err
-	}
-
-	password, err = m.GeneratePassword()
+	username, password, err = m.GenerateCredentials(ctx, usernameConfig)
 	if err != nil {
 		return "", "", err
 	}
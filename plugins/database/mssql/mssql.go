@@ -4,13 +4,11 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
-	"strings"
 	"time"
 
 	_ "github.com/denisenkom/go-mssqldb"
 	"github.com/hashicorp/vault/api"
 	"github.com/hashicorp/vault/builtin/logical/database/dbplugin"
-	"github.com/hashicorp/vault/helper/strutil"
 	"github.com/hashicorp/vault/plugins"
 	"github.com/hashicorp/vault/plugins/helper/database/connutil"
 	"github.com/hashicorp/vault/plugins/helper/database/credsutil"
@@ -20,6 +18,9 @@ import (
 const msSQLTypeName = "mssql"
 
 var _ dbplugin.Database = &MSSQL{}
+var _ dbplugin.PoolSettingsUpdater = &MSSQL{}
+var _ dbplugin.VersionReporter = &MSSQL{}
+var _ dbplugin.Pinger = &MSSQL{}
 
 // MSSQL is an implementation of Database interface
 type MSSQL struct {
@@ -36,6 +37,7 @@ func New() (interface{}, error) {
 		RoleNameLen:    20,
 		UsernameLen:    128,
 		Separator:      "-",
+		Type:           msSQLTypeName,
 	}
 
 	dbType := &MSSQL{
@@ -63,6 +65,35 @@ func (m *MSSQL) Type() (string, error) {
 	return msSQLTypeName, nil
 }
 
+// UpdatePoolSettings applies changed pool settings to the live connection.
+func (m *MSSQL) UpdatePoolSettings(ctx context.Context, conf map[string]interface{}) error {
+	return connutil.UpdatePoolSettings(ctx, m.ConnectionProducer, conf)
+}
+
+// Ping checks that the database server is reachable.
+func (m *MSSQL) Ping(ctx context.Context) error {
+	return connutil.Ping(ctx, m.ConnectionProducer)
+}
+
+// ServerVersion returns the version reported by the database server, or by
+// one of its replicas if any are configured.
+func (m *MSSQL) ServerVersion(ctx context.Context) (string, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	db, err := m.ConnectionProducer.(*connutil.SQLConnectionProducer).ReadConnection(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	var version string
+	if err := db.QueryRowContext(ctx, "SELECT @@VERSION;").Scan(&version); err != nil {
+		return "", err
+	}
+
+	return version, nil
+}
+
 func (m *MSSQL) getConnection(ctx context.Context) (*sql.DB, error) {
 	db, err := m.Connection(ctx)
 	if err != nil {
@@ -72,6 +103,32 @@ func (m *MSSQL) getConnection(ctx context.Context) (*sql.DB, error) {
 	return db.(*sql.DB), nil
 }
 
+// Capabilities returns the operations the plugin supports.
+func (m *MSSQL) Capabilities(ctx context.Context) ([]string, error) {
+	return dbutil.SQLCapabilities(m), nil
+}
+
+// PluginInfo returns the version and build of the plugin.
+func (m *MSSQL) PluginInfo(ctx context.Context) (*dbplugin.BuildInfo, error) {
+	return dbutil.BuildInfo(), nil
+}
+
+// GenerateCredentials generates a username and password the way CreateUser
+// does, without creating the user.
+func (m *MSSQL) GenerateCredentials(ctx context.Context, usernameConfig dbplugin.UsernameConfig) (username string, password string, err error) {
+	username, err = m.GenerateUsername(usernameConfig)
+	if err != nil {
+		return "", "", err
+	}
+
+	password, err = credsutil.GeneratePasswordWithComplexity(m.CredentialsProducer, connutil.MaxPasswordLength(m.ConnectionProducer), credsutil.ComplexityFromUsernameConfig(usernameConfig))
+	if err != nil {
+		return "", "", err
+	}
+
+	return username, password, nil
+}
+
 // CreateUser generates the username/password on the underlying MSSQL secret backend as instructed by
 // the CreationStatement provided.
 func (m *MSSQL) CreateUser(ctx context.Context, statements dbplugin.Statements, usernameConfig dbplugin.UsernameConfig, expiration time.Time) (username string, password string, err error) {
@@ -79,50 +136,55 @@ func (m *MSSQL) CreateUser(ctx context.Context, statements dbplugin.Statements,
 	m.Lock()
 	defer m.Unlock()
 
-	// Get the connection
-	db, err := m.getConnection(ctx)
+	// Get a connection from the pool of the role's class
+	db, err := m.ConnectionProducer.(*connutil.SQLConnectionProducer).PoolConnection(ctx, usernameConfig.PoolClass)
 	if err != nil {
 		return "", "", err
 	}
 
-	if statements.CreationStatements == "" {
+	queries := dbutil.CreationQueries(statements)
+	if len(queries) == 0 {
 		return "", "", dbutil.ErrEmptyCreationStatement
 	}
 
-	username, err = m.GenerateUsername(usernameConfig)
+	username, password, err = m.GenerateCredentials(ctx, usernameConfig)
 	if err != nil {
 		return "", "", err
 	}
 
-	password, err = m.GeneratePassword()
+	expirationStr, err := m.GenerateExpiration(expiration)
 	if err != nil {
 		return "", "", err
 	}
 
-	expirationStr, err := m.GenerateExpiration(expiration)
+	// Values substituted into the creation statements
+	data, err := dbutil.CreationData(map[string]string{
+		"name":       username,
+		"password":   password,
+		"expiration": expirationStr,
+	})
+	if err != nil {
+		return "", "", err
+	}
+
+	// Take a connection from the pool, failing if none frees up within
+	// the connection acquire timeout
+	conn, err := m.ConnectionProducer.(*connutil.SQLConnectionProducer).AcquireConn(ctx, db)
 	if err != nil {
 		return "", "", err
 	}
+	defer conn.Close()
 
 	// Start a transaction
-	tx, err := db.BeginTx(ctx, nil)
+	tx, err := conn.BeginTx(ctx, nil)
 	if err != nil {
 		return "", "", err
 	}
 	defer tx.Rollback()
 
 	// Execute each query
-	for _, query := range strutil.ParseArbitraryStringSlice(statements.CreationStatements, ";") {
-		query = strings.TrimSpace(query)
-		if len(query) == 0 {
-			continue
-		}
-
-		stmt, err := tx.PrepareContext(ctx, dbutil.QueryHelper(query, map[string]string{
-			"name":       username,
-			"password":   password,
-			"expiration": expirationStr,
-		}))
+	for _, query := range queries {
+		stmt, err := tx.PrepareContext(ctx, dbutil.QueryHelper(query, data))
 		if err != nil {
 			return "", "", err
 		}
@@ -150,7 +212,8 @@ func (m *MSSQL) RenewUser(ctx context.Context, statements dbplugin.Statements, u
 // then kill pending connections from that user, and finally drop the user and login from the
 // database instance.
 func (m *MSSQL) RevokeUser(ctx context.Context, statements dbplugin.Statements, username string) error {
-	if statements.RevocationStatements == "" {
+	queries := dbutil.RevocationSteps(statements)
+	if len(queries) == 0 {
 		return m.revokeUserDefault(ctx, username)
 	}
 
@@ -160,29 +223,36 @@ func (m *MSSQL) RevokeUser(ctx context.Context, statements dbplugin.Statements,
 		return err
 	}
 
+	conn, err := m.ConnectionProducer.(*connutil.SQLConnectionProducer).AcquireConn(ctx, db)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
 	// Start a transaction
-	tx, err := db.BeginTx(ctx, nil)
+	tx, err := conn.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
 	defer tx.Rollback()
 
-	// Execute each query
-	for _, query := range strutil.ParseArbitraryStringSlice(statements.RevocationStatements, ";") {
-		query = strings.TrimSpace(query)
-		if len(query) == 0 {
-			continue
+	for _, query := range queries {
+		// A failed statement may doom the transaction, so statements that
+		// may fail run after a savepoint that is rolled back to if they do.
+		if query.ContinueOnError {
+			if _, err := tx.ExecContext(ctx, "SAVE TRANSACTION revocation_step"); err != nil {
+				return err
+			}
 		}
 
-		stmt, err := tx.PrepareContext(ctx, dbutil.QueryHelper(query, map[string]string{
-			"name": username,
-		}))
-		if err != nil {
-			return err
-		}
-		defer stmt.Close()
-		if _, err := stmt.ExecContext(ctx); err != nil {
+		err := m.execRevocationQuery(ctx, tx, query.Query, username)
+		switch {
+		case err != nil && !query.ContinueOnError:
 			return err
+		case err != nil:
+			if _, err := tx.ExecContext(ctx, "ROLLBACK TRANSACTION revocation_step"); err != nil {
+				return err
+			}
 		}
 	}
 
@@ -194,6 +264,19 @@ func (m *MSSQL) RevokeUser(ctx context.Context, statements dbplugin.Statements,
 	return nil
 }
 
+func (m *MSSQL) execRevocationQuery(ctx context.Context, tx *sql.Tx, query, username string) error {
+	stmt, err := tx.PrepareContext(ctx, dbutil.QueryHelper(query, map[string]string{
+		"name": username,
+	}))
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	_, err = stmt.ExecContext(ctx)
+	return err
+}
+
 func (m *MSSQL) revokeUserDefault(ctx context.Context, username string) error {
 	// Get connection
 	db, err := m.getConnection(ctx)
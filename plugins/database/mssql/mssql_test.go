@@ -164,6 +164,30 @@ func TestMSSQL_RevokeUser(t *testing.T) {
 	if err := testCredsExist(t, connURL, username, password); err == nil {
 		t.Fatal("Credentials were not revoked")
 	}
+
+	username, password, err = db.CreateUser(context.Background(), statements, usernameConfig, time.Now().Add(2*time.Second))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if err = testCredsExist(t, connURL, username, password); err != nil {
+		t.Fatalf("Could not connect with new credentials: %s", err)
+	}
+
+	// Test revocation steps going on past a failing step
+	statements.RevocationStatements = ""
+	statements.RevocationSteps = []*dbplugin.RevocationStep{
+		{Statement: "DROP USER [vault-missing-user];", ContinueOnError: true},
+		{Statement: testMSSQLDrop},
+	}
+	err = db.RevokeUser(context.Background(), statements, username)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if err := testCredsExist(t, connURL, username, password); err == nil {
+		t.Fatal("Credentials were not revoked")
+	}
 }
 
 func testCredsExist(t testing.TB, connURL, username, password string) error {
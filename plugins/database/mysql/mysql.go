@@ -9,7 +9,6 @@ import (
 	stdmysql "github.com/go-sql-driver/mysql"
 	"github.com/hashicorp/vault/api"
 	"github.com/hashicorp/vault/builtin/logical/database/dbplugin"
-	"github.com/hashicorp/vault/helper/strutil"
 	"github.com/hashicorp/vault/plugins"
 	"github.com/hashicorp/vault/plugins/helper/database/connutil"
 	"github.com/hashicorp/vault/plugins/helper/database/credsutil"
@@ -32,6 +31,9 @@ var (
 )
 
 var _ dbplugin.Database = &MySQL{}
+var _ dbplugin.PoolSettingsUpdater = &MySQL{}
+var _ dbplugin.VersionReporter = &MySQL{}
+var _ dbplugin.Pinger = &MySQL{}
 
 type MySQL struct {
 	connutil.ConnectionProducer
@@ -49,6 +51,7 @@ func New(displayNameLen, roleNameLen, usernameLen int) func() (interface{}, erro
 			RoleNameLen:    roleNameLen,
 			UsernameLen:    usernameLen,
 			Separator:      "-",
+			Type:           mySQLTypeName,
 		}
 
 		dbType := &MySQL{
@@ -91,6 +94,35 @@ func (m *MySQL) Type() (string, error) {
 	return mySQLTypeName, nil
 }
 
+// UpdatePoolSettings applies changed pool settings to the live connection.
+func (m *MySQL) UpdatePoolSettings(ctx context.Context, conf map[string]interface{}) error {
+	return connutil.UpdatePoolSettings(ctx, m.ConnectionProducer, conf)
+}
+
+// Ping checks that the database server is reachable.
+func (m *MySQL) Ping(ctx context.Context) error {
+	return connutil.Ping(ctx, m.ConnectionProducer)
+}
+
+// ServerVersion returns the version reported by the database server, or by
+// one of its replicas if any are configured.
+func (m *MySQL) ServerVersion(ctx context.Context) (string, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	db, err := m.ConnectionProducer.(*connutil.SQLConnectionProducer).ReadConnection(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	var version string
+	if err := db.QueryRowContext(ctx, "SELECT VERSION();").Scan(&version); err != nil {
+		return "", err
+	}
+
+	return version, nil
+}
+
 func (m *MySQL) getConnection(ctx context.Context) (*sql.DB, error) {
 	db, err := m.Connection(ctx)
 	if err != nil {
@@ -100,54 +132,86 @@ func (m *MySQL) getConnection(ctx context.Context) (*sql.DB, error) {
 	return db.(*sql.DB), nil
 }
 
+// Capabilities returns the operations the plugin supports.
+func (m *MySQL) Capabilities(ctx context.Context) ([]string, error) {
+	return dbutil.SQLCapabilities(m), nil
+}
+
+// PluginInfo returns the version and build of the plugin.
+func (m *MySQL) PluginInfo(ctx context.Context) (*dbplugin.BuildInfo, error) {
+	return dbutil.BuildInfo(), nil
+}
+
+// GenerateCredentials generates a username and password the way CreateUser
+// does, without creating the user.
+func (m *MySQL) GenerateCredentials(ctx context.Context, usernameConfig dbplugin.UsernameConfig) (username string, password string, err error) {
+	username, err = m.GenerateUsername(usernameConfig)
+	if err != nil {
+		return "", "", err
+	}
+
+	password, err = credsutil.GeneratePasswordWithComplexity(m.CredentialsProducer, connutil.MaxPasswordLength(m.ConnectionProducer), credsutil.ComplexityFromUsernameConfig(usernameConfig))
+	if err != nil {
+		return "", "", err
+	}
+
+	return username, password, nil
+}
+
 func (m *MySQL) CreateUser(ctx context.Context, statements dbplugin.Statements, usernameConfig dbplugin.UsernameConfig, expiration time.Time) (username string, password string, err error) {
 	// Grab the lock
 	m.Lock()
 	defer m.Unlock()
 
-	// Get the connection
-	db, err := m.getConnection(ctx)
+	// Get a connection from the pool of the role's class
+	db, err := m.ConnectionProducer.(*connutil.SQLConnectionProducer).PoolConnection(ctx, usernameConfig.PoolClass)
 	if err != nil {
 		return "", "", err
 	}
 
-	if statements.CreationStatements == "" {
+	queries := dbutil.CreationQueries(statements)
+	if len(queries) == 0 {
 		return "", "", dbutil.ErrEmptyCreationStatement
 	}
 
-	username, err = m.GenerateUsername(usernameConfig)
+	username, password, err = m.GenerateCredentials(ctx, usernameConfig)
 	if err != nil {
 		return "", "", err
 	}
 
-	password, err = m.GeneratePassword()
+	expirationStr, err := m.GenerateExpiration(expiration)
 	if err != nil {
 		return "", "", err
 	}
 
-	expirationStr, err := m.GenerateExpiration(expiration)
+	// Values substituted into the creation statements
+	data, err := dbutil.CreationData(map[string]string{
+		"name":       username,
+		"password":   password,
+		"expiration": expirationStr,
+	})
+	if err != nil {
+		return "", "", err
+	}
+
+	// Take a connection from the pool, failing if none frees up within
+	// the connection acquire timeout
+	conn, err := m.ConnectionProducer.(*connutil.SQLConnectionProducer).AcquireConn(ctx, db)
 	if err != nil {
 		return "", "", err
 	}
+	defer conn.Close()
 
 	// Start a transaction
-	tx, err := db.BeginTx(ctx, nil)
+	tx, err := conn.BeginTx(ctx, nil)
 	if err != nil {
 		return "", "", err
 	}
 	defer tx.Rollback()
 
 	// Execute each query
-	for _, query := range strutil.ParseArbitraryStringSlice(statements.CreationStatements, ";") {
-		query = strings.TrimSpace(query)
-		if len(query) == 0 {
-			continue
-		}
-		query = dbutil.QueryHelper(query, map[string]string{
-			"name":       username,
-			"password":   password,
-			"expiration": expirationStr,
-		})
+	for _, query := range queries {
+		query = dbutil.QueryHelper(query, data)
 
 		stmt, err := tx.PrepareContext(ctx, query)
 		if err != nil {
@@ -197,39 +261,32 @@ func (m *MySQL) RevokeUser(ctx context.Context, statements dbplugin.Statements,
 		return err
 	}
 
-	revocationStmts := statements.RevocationStatements
+	queries := dbutil.RevocationSteps(statements)
 	// Use a default SQL statement for revocation if one cannot be fetched from the role
-	if revocationStmts == "" {
-		revocationStmts = defaultMysqlRevocationStmts
+	if len(queries) == 0 {
+		queries = dbutil.RevocationSteps(dbplugin.Statements{
+			RevocationStatements: defaultMysqlRevocationStmts,
+		})
 	}
 
-	// Start a transaction
-	tx, err := db.BeginTx(ctx, nil)
+	conn, err := m.ConnectionProducer.(*connutil.SQLConnectionProducer).AcquireConn(ctx, db)
 	if err != nil {
 		return err
 	}
-	defer tx.Rollback()
-
-	for _, query := range strutil.ParseArbitraryStringSlice(revocationStmts, ";") {
-		query = strings.TrimSpace(query)
-		if len(query) == 0 {
-			continue
-		}
+	defer conn.Close()
 
+	// The statements aren't run in a transaction: MySQL implicitly commits
+	// account management statements such as DROP USER, which would also
+	// discard any savepoint protecting a step that may fail. A failed
+	// statement is rolled back on its own, so the steps after it still run.
+	for _, query := range queries {
 		// This is not a prepared statement because not all commands are supported
 		// 1295: This command is not supported in the prepared statement protocol yet
 		// Reference https://mariadb.com/kb/en/mariadb/prepare-statement/
-		query = strings.Replace(query, "{{name}}", username, -1)
-		_, err = tx.ExecContext(ctx, query)
-		if err != nil {
+		_, err = conn.ExecContext(ctx, strings.Replace(query.Query, "{{name}}", username, -1))
+		if err != nil && !query.ContinueOnError {
 			return err
 		}
-
-	}
-
-	// Commit the transaction
-	if err := tx.Commit(); err != nil {
-		return err
 	}
 
 	return nil
@@ -26,6 +26,10 @@ ALTER ROLE "{{name}}" VALID UNTIL '{{expiration}}';
 )
 
 var _ dbplugin.Database = &PostgreSQL{}
+var _ dbplugin.PoolSettingsUpdater = &PostgreSQL{}
+var _ dbplugin.VersionReporter = &PostgreSQL{}
+var _ dbplugin.Pinger = &PostgreSQL{}
+var _ dbplugin.GrantIntrospector = &PostgreSQL{}
 
 // New implements builtinplugins.BuiltinFactory
 func New() (interface{}, error) {
@@ -37,6 +41,7 @@ func New() (interface{}, error) {
 		RoleNameLen:    8,
 		UsernameLen:    63,
 		Separator:      "-",
+		Type:           postgreSQLTypeName,
 	}
 
 	dbType := &PostgreSQL{
@@ -68,6 +73,35 @@ func (p *PostgreSQL) Type() (string, error) {
 	return postgreSQLTypeName, nil
 }
 
+// UpdatePoolSettings applies changed pool settings to the live connection.
+func (p *PostgreSQL) UpdatePoolSettings(ctx context.Context, conf map[string]interface{}) error {
+	return connutil.UpdatePoolSettings(ctx, p.ConnectionProducer, conf)
+}
+
+// Ping checks that the database server is reachable.
+func (p *PostgreSQL) Ping(ctx context.Context) error {
+	return connutil.Ping(ctx, p.ConnectionProducer)
+}
+
+// ServerVersion returns the version reported by the database server, or by
+// one of its replicas if any are configured.
+func (p *PostgreSQL) ServerVersion(ctx context.Context) (string, error) {
+	p.Lock()
+	defer p.Unlock()
+
+	db, err := p.ConnectionProducer.(*connutil.SQLConnectionProducer).ReadConnection(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	var version string
+	if err := db.QueryRowContext(ctx, "SELECT version();").Scan(&version); err != nil {
+		return "", err
+	}
+
+	return version, nil
+}
+
 func (p *PostgreSQL) getConnection(ctx context.Context) (*sql.DB, error) {
 	db, err := p.Connection(ctx)
 	if err != nil {
@@ -77,8 +111,35 @@ func (p *PostgreSQL) getConnection(ctx context.Context) (*sql.DB, error) {
 	return db.(*sql.DB), nil
 }
 
+// Capabilities returns the operations the plugin supports.
+func (p *PostgreSQL) Capabilities(ctx context.Context) ([]string, error) {
+	return dbutil.SQLCapabilities(p), nil
+}
+
+// PluginInfo returns the version and build of the plugin.
+func (p *PostgreSQL) PluginInfo(ctx context.Context) (*dbplugin.BuildInfo, error) {
+	return dbutil.BuildInfo(), nil
+}
+
+// GenerateCredentials generates a username and password the way CreateUser
+// does, without creating the user.
+func (p *PostgreSQL) GenerateCredentials(ctx context.Context, usernameConfig dbplugin.UsernameConfig) (username string, password string, err error) {
+	username, err = p.GenerateUsername(usernameConfig)
+	if err != nil {
+		return "", "", err
+	}
+
+	password, err = credsutil.GeneratePasswordWithComplexity(p.CredentialsProducer, connutil.MaxPasswordLength(p.ConnectionProducer), credsutil.ComplexityFromUsernameConfig(usernameConfig))
+	if err != nil {
+		return "", "", err
+	}
+
+	return username, password, nil
+}
+
 func (p *PostgreSQL) CreateUser(ctx context.Context, statements dbplugin.Statements, usernameConfig dbplugin.UsernameConfig, expiration time.Time) (username string, password string, err error) {
-	if statements.CreationStatements == "" {
+	queries := dbutil.CreationQueries(statements)
+	if len(queries) == 0 {
 		return "", "", dbutil.ErrEmptyCreationStatement
 	}
 
@@ -86,30 +147,43 @@ func (p *PostgreSQL) CreateUser(ctx context.Context, statements dbplugin.Stateme
 	p.Lock()
 	defer p.Unlock()
 
-	username, err = p.GenerateUsername(usernameConfig)
+	username, password, err = p.GenerateCredentials(ctx, usernameConfig)
 	if err != nil {
 		return "", "", err
 	}
 
-	password, err = p.GeneratePassword()
+	expirationStr, err := p.GenerateExpiration(expiration)
 	if err != nil {
 		return "", "", err
 	}
 
-	expirationStr, err := p.GenerateExpiration(expiration)
+	// Values substituted into the creation statements
+	data, err := dbutil.CreationData(map[string]string{
+		"name":       username,
+		"password":   password,
+		"expiration": expirationStr,
+	})
 	if err != nil {
 		return "", "", err
 	}
 
-	// Get the connection
-	db, err := p.getConnection(ctx)
+	// Get a connection from the pool of the role's class
+	db, err := p.ConnectionProducer.(*connutil.SQLConnectionProducer).PoolConnection(ctx, usernameConfig.PoolClass)
 	if err != nil {
 		return "", "", err
 
 	}
 
+	// Take a connection from the pool, failing if none frees up within
+	// the connection acquire timeout
+	conn, err := p.ConnectionProducer.(*connutil.SQLConnectionProducer).AcquireConn(ctx, db)
+	if err != nil {
+		return "", "", err
+	}
+	defer conn.Close()
+
 	// Start a transaction
-	tx, err := db.BeginTx(ctx, nil)
+	tx, err := conn.BeginTx(ctx, nil)
 	if err != nil {
 		return "", "", err
 
@@ -120,17 +194,8 @@ func (p *PostgreSQL) CreateUser(ctx context.Context, statements dbplugin.Stateme
 	// Return the secret
 
 	// Execute each query
-	for _, query := range strutil.ParseArbitraryStringSlice(statements.CreationStatements, ";") {
-		query = strings.TrimSpace(query)
-		if len(query) == 0 {
-			continue
-		}
-
-		stmt, err := tx.PrepareContext(ctx, dbutil.QueryHelper(query, map[string]string{
-			"name":       username,
-			"password":   password,
-			"expiration": expirationStr,
-		}))
+	for _, query := range queries {
+		stmt, err := tx.PrepareContext(ctx, dbutil.QueryHelper(query, data))
 		if err != nil {
 			return "", "", err
 
@@ -165,7 +230,13 @@ func (p *PostgreSQL) RenewUser(ctx context.Context, statements dbplugin.Statemen
 		return err
 	}
 
-	tx, err := db.BeginTx(ctx, nil)
+	conn, err := p.ConnectionProducer.(*connutil.SQLConnectionProducer).AcquireConn(ctx, db)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	tx, err := conn.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
@@ -209,20 +280,49 @@ func (p *PostgreSQL) RevokeUser(ctx context.Context, statements dbplugin.Stateme
 	p.Lock()
 	defer p.Unlock()
 
-	if statements.RevocationStatements == "" {
+	queries := dbutil.RevocationSteps(statements)
+	if len(queries) == 0 {
 		return p.defaultRevokeUser(ctx, username)
 	}
 
-	return p.customRevokeUser(ctx, username, statements.RevocationStatements)
+	return p.customRevokeUser(ctx, username, queries)
 }
 
-func (p *PostgreSQL) customRevokeUser(ctx context.Context, username, revocationStmts string) error {
+// UserGrants summarizes the table privileges held by username, one entry per
+// privilege, e.g. "SELECT ON public.accounts".
+func (p *PostgreSQL) UserGrants(ctx context.Context, username string) ([]string, error) {
+	p.Lock()
+	defer p.Unlock()
+
+	// Grants are looked up in a read-only transaction so that introspection
+	// can never modify the database.
+	rows, err := p.ConnectionProducer.(*connutil.SQLConnectionProducer).ReadOnlyQuery(ctx, `SELECT privilege_type, table_schema, table_name FROM information_schema.role_table_grants
+WHERE grantee=$1 ORDER BY table_schema, table_name, privilege_type;`, username)
+	if err != nil {
+		return nil, err
+	}
+
+	var grants []string
+	for _, row := range rows {
+		grants = append(grants, fmt.Sprintf("%s ON %s.%s", row[0], row[1], row[2]))
+	}
+
+	return grants, nil
+}
+
+func (p *PostgreSQL) customRevokeUser(ctx context.Context, username string, queries []dbutil.RevocationQuery) error {
 	db, err := p.getConnection(ctx)
 	if err != nil {
 		return err
 	}
 
-	tx, err := db.BeginTx(ctx, nil)
+	conn, err := p.ConnectionProducer.(*connutil.SQLConnectionProducer).AcquireConn(ctx, db)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	tx, err := conn.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
@@ -230,22 +330,27 @@ func (p *PostgreSQL) customRevokeUser(ctx context.Context, username, revocationS
 		tx.Rollback()
 	}()
 
-	for _, query := range strutil.ParseArbitraryStringSlice(revocationStmts, ";") {
-		query = strings.TrimSpace(query)
-		if len(query) == 0 {
-			continue
+	for _, query := range queries {
+		// A failed statement aborts the transaction, so statements that
+		// may fail run in a savepoint that is rolled back if they do.
+		if query.ContinueOnError {
+			if _, err := tx.ExecContext(ctx, "SAVEPOINT revocation_step"); err != nil {
+				return err
+			}
 		}
 
-		stmt, err := tx.PrepareContext(ctx, dbutil.QueryHelper(query, map[string]string{
-			"name": username,
-		}))
-		if err != nil {
-			return err
-		}
-		defer stmt.Close()
-
-		if _, err := stmt.ExecContext(ctx); err != nil {
+		err := p.execRevocationQuery(ctx, tx, query.Query, username)
+		switch {
+		case err != nil && !query.ContinueOnError:
 			return err
+		case err != nil:
+			if _, err := tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT revocation_step"); err != nil {
+				return err
+			}
+		case query.ContinueOnError:
+			if _, err := tx.ExecContext(ctx, "RELEASE SAVEPOINT revocation_step"); err != nil {
+				return err
+			}
 		}
 	}
 
@@ -256,6 +361,19 @@ func (p *PostgreSQL) customRevokeUser(ctx context.Context, username, revocationS
 	return nil
 }
 
+func (p *PostgreSQL) execRevocationQuery(ctx context.Context, tx *sql.Tx, query, username string) error {
+	stmt, err := tx.PrepareContext(ctx, dbutil.QueryHelper(query, map[string]string{
+		"name": username,
+	}))
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	_, err = stmt.ExecContext(ctx)
+	return err
+}
+
 func (p *PostgreSQL) defaultRevokeUser(ctx context.Context, username string) error {
 	db, err := p.getConnection(ctx)
 	if err != nil {
@@ -0,0 +1,45 @@
+package connutil
+
+import (
+	"context"
+	"database/sql"
+)
+
+// AcquireConn takes a connection from db, a pool returned by Connection or
+// PoolConnection, for a credential operation. The caller must close it to
+// return it to the pool. Without a timeout, acquiring a connection from an
+// exhausted pool waits until one is returned or ctx is done; with
+// connection_acquire_timeout set, ErrConnectionAcquireTimeout is returned
+// once it has passed instead. Only the wait is bounded: the connection may
+// be used for as long as ctx allows.
+func (c *SQLConnectionProducer) AcquireConn(ctx context.Context, db *sql.DB) (*sql.Conn, error) {
+	var conn *sql.Conn
+	err := c.acquire(ctx, func(ctx context.Context) error {
+		var err error
+		conn, err = db.Conn(ctx)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return conn, nil
+}
+
+// acquire runs fn, which takes a connection from a pool, with ctx bounded by
+// the connection acquire timeout. ErrConnectionAcquireTimeout is returned if
+// the timeout rather than ctx ended it.
+func (c *SQLConnectionProducer) acquire(ctx context.Context, fn func(context.Context) error) error {
+	if c.connectionAcquireTimeout <= 0 {
+		return fn(ctx)
+	}
+
+	acquireCtx, cancel := context.WithTimeout(ctx, c.connectionAcquireTimeout)
+	defer cancel()
+
+	err := fn(acquireCtx)
+	if err != nil && acquireCtx.Err() == context.DeadlineExceeded && ctx.Err() == nil {
+		return ErrConnectionAcquireTimeout
+	}
+	return err
+}
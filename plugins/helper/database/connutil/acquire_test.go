@@ -0,0 +1,67 @@
+package connutil
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+)
+
+func TestSQLConnectionProducer_AcquireConn(t *testing.T) {
+	c := &SQLConnectionProducer{Type: "connutil-recording"}
+	err := c.Initialize(context.Background(), map[string]interface{}{
+		"connection_url":             "primary",
+		"max_open_connections":       1,
+		"connection_acquire_timeout": "50ms",
+	}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	raw, err := c.Connection(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := raw.(*sql.DB)
+
+	// Saturate the pool
+	held, err := c.AcquireConn(context.Background(), db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := c.AcquireConn(context.Background(), db); err != ErrConnectionAcquireTimeout {
+		t.Fatalf("expected pool exhausted error, got: %v", err)
+	}
+
+	// The busy pool is reported rather than replaced
+	if _, err := c.Connection(context.Background()); err != ErrConnectionAcquireTimeout {
+		t.Fatalf("expected pool exhausted error, got: %v", err)
+	}
+	if c.db != db {
+		t.Fatal("expected the exhausted pool to be kept")
+	}
+
+	// Cancellation by the caller isn't reported as an exhausted pool
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := c.AcquireConn(ctx, db); err == nil || err == ErrConnectionAcquireTimeout {
+		t.Fatalf("expected cancellation error, got: %v", err)
+	}
+
+	held.Close()
+	conn, err := c.AcquireConn(context.Background(), db)
+	if err != nil {
+		t.Fatalf("expected a connection once one was returned, got: %v", err)
+	}
+	conn.Close()
+
+	c = &SQLConnectionProducer{Type: "connutil-recording"}
+	err = c.Initialize(context.Background(), map[string]interface{}{
+		"connection_url":             "primary",
+		"connection_acquire_timeout": "-1s",
+	}, false)
+	if err == nil {
+		t.Fatal("expected error for a negative connection_acquire_timeout")
+	}
+}
@@ -0,0 +1,164 @@
+package connutil
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/mitchellh/mapstructure"
+)
+
+// defaultPorts are the ports database types listen on by default, used when
+// the connection URL is built without a port.
+var defaultPorts = map[string]int{
+	"postgres": 5432,
+	"mysql":    3306,
+	"mssql":    1433,
+	"hdb":      30015,
+}
+
+// The built connection URL holds these placeholders in place of the username
+// and password, which are substituted when connecting. This keeps the
+// credentials out of the URL, so that a rotated password doesn't change it.
+const (
+	usernamePlaceholder = "{{username}}"
+	passwordPlaceholder = "{{password}}"
+)
+
+// usesStructuredURL reports whether any of the fields the connection URL can
+// be built from are set.
+func (c *SQLConnectionProducer) usesStructuredURL() bool {
+	return c.Host != "" || c.Port != 0 || c.Database != "" || len(c.Params) > 0 || c.Username != "" || c.Password != ""
+}
+
+// setConnectionURL builds ConnectionURL from the host, port, database, params,
+// username and password fields when they are used instead of it. Exactly one
+// of the two forms must be given.
+func (c *SQLConnectionProducer) setConnectionURL() error {
+	structured := c.usesStructuredURL()
+
+	switch {
+	case c.ConnectionURL != "" && structured:
+		return fmt.Errorf("connection_url cannot be used with host, port, database, params, username or password")
+	case c.ConnectionURL != "":
+		return nil
+	case !structured:
+		return fmt.Errorf("connection_url cannot be empty")
+	case c.Host == "":
+		return fmt.Errorf("host is required when connection_url is not set")
+	case c.Port < 0 || c.Port > 65535:
+		return fmt.Errorf("invalid port %d", c.Port)
+	}
+
+	port := c.Port
+	if port == 0 {
+		port = defaultPorts[c.Type]
+	}
+	if port == 0 {
+		return fmt.Errorf("port is required for %s connections", c.Type)
+	}
+	addr := net.JoinHostPort(c.Host, strconv.Itoa(port))
+
+	var base string
+	params := []map[string]string{c.Params}
+	switch c.Type {
+	case "postgres":
+		u := &url.URL{Scheme: "postgres", Host: addr, Path: "/" + c.Database}
+		base = c.withUserPlaceholders(u)
+	case "mysql":
+		cfg := mysql.NewConfig()
+		if c.Username != "" {
+			cfg.User = usernamePlaceholder
+			cfg.Passwd = passwordPlaceholder
+		}
+		cfg.Net = "tcp"
+		cfg.Addr = addr
+		cfg.DBName = c.Database
+		base = cfg.FormatDSN()
+	case "mssql":
+		u := &url.URL{Scheme: "sqlserver", Host: addr}
+		base = c.withUserPlaceholders(u)
+		if c.Database != "" {
+			params = append(params, map[string]string{"database": c.Database})
+		}
+	case "hdb":
+		u := &url.URL{Scheme: "hdb", Host: addr}
+		base = c.withUserPlaceholders(u)
+		if c.Database != "" {
+			params = append(params, map[string]string{"databaseName": c.Database})
+		}
+	default:
+		return fmt.Errorf("connection_url is required for %s connections", c.Type)
+	}
+
+	connURL, err := MergeParams(c.Type, base, params...)
+	if err != nil {
+		return err
+	}
+	c.ConnectionURL = connURL
+	return nil
+}
+
+// withUserPlaceholders returns u with the credential placeholders as its user
+// info if a username is set. They're inserted after u is formatted, since
+// url.URL would escape their braces.
+func (c *SQLConnectionProducer) withUserPlaceholders(u *url.URL) string {
+	if c.Username == "" {
+		return u.String()
+	}
+	return strings.Replace(u.String(), "://", "://"+usernamePlaceholder+":"+passwordPlaceholder+"@", 1)
+}
+
+// withCredentials returns connURL with the credential placeholders replaced
+// by the connection's username and password, escaped as the URL form of the
+// database type requires. MySQL DSNs are parsed and formatted again with the
+// credentials, since they have no escaping. URLs not built by
+// setConnectionURL are returned unchanged.
+func (c *SQLConnectionProducer) withCredentials(connURL string) (string, error) {
+	if !c.usesStructuredURL() {
+		return connURL, nil
+	}
+
+	if c.Type == "mysql" {
+		cfg, err := mysql.ParseDSN(connURL)
+		if err != nil {
+			return "", err
+		}
+		if c.Username != "" {
+			cfg.User = c.Username
+			cfg.Passwd = c.Password
+		}
+		return cfg.FormatDSN(), nil
+	}
+
+	username := url.User(c.Username).String()
+	password := strings.TrimPrefix(url.UserPassword("", c.Password).String(), ":")
+	return strings.NewReplacer(usernamePlaceholder, username, passwordPlaceholder, password).Replace(connURL), nil
+}
+
+// StructuredConnectionURL returns the connection URL of a dbType database
+// configured by conf with the host, port and database fields, with username
+// and password as its credential. The connection's params are left out. It
+// returns an empty URL if conf doesn't use these fields.
+func StructuredConnectionURL(dbType string, conf map[string]interface{}, username, password string) (string, error) {
+	c := &SQLConnectionProducer{}
+	if err := mapstructure.WeakDecode(conf, c); err != nil {
+		return "", err
+	}
+	if c.ConnectionURL != "" || !c.usesStructuredURL() {
+		return "", nil
+	}
+
+	c.Type = dbType
+	c.Params = nil
+	c.Username = username
+	c.Password = password
+	if err := c.setConnectionURL(); err != nil {
+		return "", err
+	}
+
+	return c.withCredentials(c.ConnectionURL)
+}
@@ -4,10 +4,20 @@ import (
 	"context"
 	"errors"
 	"sync"
+
+	"github.com/hashicorp/vault/builtin/logical/database/dbplugin"
 )
 
 var (
 	ErrNotInitialized = errors.New("connection has not been initalized")
+
+	// ErrReadOnlyUnsupported is returned by ReadOnlyQuery for database types
+	// that can't run read-only transactions.
+	ErrReadOnlyUnsupported = errors.New("read-only transactions are not supported by this database type")
+
+	// ErrConnectionAcquireTimeout is returned when no connection could be
+	// taken from the pool within the connection_acquire_timeout.
+	ErrConnectionAcquireTimeout = errors.New("timed out acquiring a database connection: the connection pool is exhausted")
 )
 
 // ConnectionProducer can be used as an embeded interface in the Database
@@ -20,3 +30,39 @@ type ConnectionProducer interface {
 
 	sync.Locker
 }
+
+// UpdatePoolSettings applies the pool settings in conf to the live connection
+// of producers that support it, and returns dbplugin.ErrUnsupportedOperation
+// for those that don't.
+func UpdatePoolSettings(ctx context.Context, producer ConnectionProducer, conf map[string]interface{}) error {
+	updater, ok := producer.(dbplugin.PoolSettingsUpdater)
+	if !ok {
+		return dbplugin.ErrUnsupportedOperation
+	}
+
+	return updater.UpdatePoolSettings(ctx, conf)
+}
+
+// Ping checks that the database of producers that support it is reachable,
+// and returns dbplugin.ErrUnsupportedOperation for those that don't.
+func Ping(ctx context.Context, producer ConnectionProducer) error {
+	pinger, ok := producer.(dbplugin.Pinger)
+	if !ok {
+		return dbplugin.ErrUnsupportedOperation
+	}
+
+	return pinger.Ping(ctx)
+}
+
+// MaxPasswordLength returns the length passwords generated for the database of
+// producer must not exceed, or zero if the producer doesn't limit it.
+func MaxPasswordLength(producer ConnectionProducer) int {
+	limiter, ok := producer.(interface {
+		PasswordLengthLimit() int
+	})
+	if !ok {
+		return 0
+	}
+
+	return limiter.PasswordLengthLimit()
+}
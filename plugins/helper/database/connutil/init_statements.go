@@ -0,0 +1,135 @@
+package connutil
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/hashicorp/vault/helper/redactutil"
+)
+
+// initDriverPrefix prefixes the names the init drivers are registered under.
+const initDriverPrefix = "vault-init-"
+
+var (
+	initDriversLock sync.Mutex
+	initDrivers     = map[string]bool{}
+)
+
+// validateInitStatements checks that none of the init statements is blank.
+func (c *SQLConnectionProducer) validateInitStatements() error {
+	for i, stmt := range c.InitStatements {
+		if strings.TrimSpace(stmt) == "" {
+			return fmt.Errorf("init_statements entry %d is blank", i)
+		}
+	}
+
+	return nil
+}
+
+// openDB opens a connection pool for conn. If the connection has init
+// statements, they run on every connection the pool opens, before it is
+// used, so that session settings apply to all of them.
+func (c *SQLConnectionProducer) openDB(conn string) (*sql.DB, error) {
+	if len(c.InitStatements) == 0 {
+		return sql.Open(c.driverName(), conn)
+	}
+
+	name, err := registerInitDriver(c.driverName())
+	if err != nil {
+		return nil, err
+	}
+
+	// The statements travel in the data source name, since the driver is
+	// shared by every connection of the database type
+	statements, err := json.Marshal(c.InitStatements)
+	if err != nil {
+		return nil, err
+	}
+
+	return sql.Open(name, base64.StdEncoding.EncodeToString(statements)+"\x00"+conn)
+}
+
+// registerInitDriver registers, once, an initDriver wrapping the named
+// driver and returns the name it is registered under.
+func registerInitDriver(driverName string) (string, error) {
+	initDriversLock.Lock()
+	defer initDriversLock.Unlock()
+
+	name := initDriverPrefix + driverName
+	if initDrivers[name] {
+		return name, nil
+	}
+
+	// Opening a pool doesn't connect, it only looks the driver up
+	db, err := sql.Open(driverName, "")
+	if err != nil {
+		return "", err
+	}
+	base := db.Driver()
+	db.Close()
+
+	sql.Register(name, &initDriver{Driver: base})
+	initDrivers[name] = true
+	return name, nil
+}
+
+// initDriver is a database/sql driver that runs the init statements given
+// with the data source name on every connection it opens.
+type initDriver struct {
+	driver.Driver
+}
+
+func (d *initDriver) Open(name string) (driver.Conn, error) {
+	i := strings.IndexByte(name, 0)
+	if i < 0 {
+		return nil, fmt.Errorf("missing init statements")
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(name[:i])
+	if err != nil {
+		return nil, err
+	}
+	var statements []string
+	if err := json.Unmarshal(raw, &statements); err != nil {
+		return nil, err
+	}
+
+	conn, err := d.Driver.Open(name[i+1:])
+	if err != nil {
+		return nil, err
+	}
+
+	for n, stmt := range statements {
+		if err := execStatement(conn, stmt); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("error running init_statements entry %d: %s", n, redactutil.Error(err))
+		}
+	}
+
+	return conn, nil
+}
+
+// execStatement runs stmt on conn, directly if the driver supports it and
+// through a prepared statement otherwise.
+func execStatement(conn driver.Conn, stmt string) error {
+	if execer, ok := conn.(driver.Execer); ok {
+		_, err := execer.Exec(stmt, nil)
+		if err != driver.ErrSkip {
+			return err
+		}
+	}
+
+	s, err := conn.Prepare(stmt)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	_, err = s.Exec(nil)
+	return err
+}
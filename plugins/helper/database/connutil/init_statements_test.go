@@ -0,0 +1,128 @@
+package connutil
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/hashicorp/vault/builtin/logical/database/dbplugin"
+)
+
+// recordingDriver is a database/sql driver that records the statements run on
+// each connection it opens, failing those containing "FAIL".
+type recordingDriver struct {
+	sync.Mutex
+	conns [][]string
+}
+
+func (d *recordingDriver) Open(name string) (driver.Conn, error) {
+	d.Lock()
+	defer d.Unlock()
+
+	d.conns = append(d.conns, nil)
+	return &recordingConn{driver: d, idx: len(d.conns) - 1}, nil
+}
+
+func (d *recordingDriver) reset() [][]string {
+	d.Lock()
+	defer d.Unlock()
+
+	conns := d.conns
+	d.conns = nil
+	return conns
+}
+
+type recordingConn struct {
+	countingConn
+	driver *recordingDriver
+	idx    int
+}
+
+func (c *recordingConn) Exec(query string, args []driver.Value) (driver.Result, error) {
+	if strings.Contains(query, "FAIL") {
+		return nil, errors.New("syntax error")
+	}
+
+	c.driver.Lock()
+	defer c.driver.Unlock()
+
+	c.driver.conns[c.idx] = append(c.driver.conns[c.idx], query)
+	return driver.RowsAffected(0), nil
+}
+
+var testRecordingDriver = &recordingDriver{}
+
+func init() {
+	sql.Register("connutil-recording", testRecordingDriver)
+}
+
+func TestSQLConnectionProducer_InitStatements(t *testing.T) {
+	testRecordingDriver.reset()
+
+	c := &SQLConnectionProducer{Type: "connutil-recording"}
+	err := c.Initialize(context.Background(), map[string]interface{}{
+		"connection_url":       "primary",
+		"min_idle_connections": 2,
+		"init_statements":      []string{"SET ROLE vault", "SET search_path TO app"},
+	}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	// Every connection of the pool is set up, not only the first one
+	expected := []string{"SET ROLE vault", "SET search_path TO app"}
+	conns := testRecordingDriver.reset()
+	if len(conns) != 2 {
+		t.Fatalf("expected 2 connections, got %d", len(conns))
+	}
+	for i, stmts := range conns {
+		if !reflect.DeepEqual(stmts, expected) {
+			t.Fatalf("expected init statements on connection %d, got %#v", i, stmts)
+		}
+	}
+
+	// Changing them requires reconnecting
+	err = c.UpdatePoolSettings(context.Background(), map[string]interface{}{
+		"connection_url":  "primary",
+		"init_statements": []string{"SET ROLE other"},
+	})
+	if err != dbplugin.ErrConnectionIdentityChanged {
+		t.Fatalf("expected identity change, got: %v", err)
+	}
+
+	// A failing statement fails verification
+	c = &SQLConnectionProducer{Type: "connutil-recording"}
+	err = c.Initialize(context.Background(), map[string]interface{}{
+		"connection_url":  "primary",
+		"init_statements": []string{"SET ROLE vault", "FAIL"},
+	}, true)
+	if err == nil || !strings.Contains(err.Error(), "init_statements entry 1") {
+		t.Fatalf("expected init statement error, got: %v", err)
+	}
+
+	c = &SQLConnectionProducer{Type: "connutil-recording"}
+	err = c.Initialize(context.Background(), map[string]interface{}{
+		"connection_url":  "primary",
+		"init_statements": []string{" "},
+	}, false)
+	if err == nil {
+		t.Fatal("expected error for a blank init statement")
+	}
+
+	// Without init statements nothing is run
+	testRecordingDriver.reset()
+	c = &SQLConnectionProducer{Type: "connutil-recording"}
+	if err := c.Initialize(context.Background(), map[string]interface{}{"connection_url": "primary"}, true); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if conns := testRecordingDriver.reset(); len(conns) != 1 || len(conns[0]) != 0 {
+		t.Fatalf("expected no statements to be run, got %#v", conns)
+	}
+}
@@ -0,0 +1,120 @@
+package connutil
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/vault/helper/redactutil"
+)
+
+// validatePoolClasses checks the pool classes and closes any pools opened
+// for them before. Pool classes can't be used through an SSH tunnel, which
+// is replaced whenever the primary connection is re-established.
+func (c *SQLConnectionProducer) validatePoolClasses() error {
+	c.closePools()
+	if len(c.PoolClasses) == 0 {
+		return nil
+	}
+
+	if c.SSHHost != "" {
+		return fmt.Errorf("pool_classes cannot be used with ssh_host")
+	}
+	for class, maxOpen := range c.PoolClasses {
+		if strings.TrimSpace(class) == "" {
+			return fmt.Errorf("pool_classes cannot contain empty class names")
+		}
+		if maxOpen <= 0 {
+			return fmt.Errorf("pool_classes must allow at least one connection for class %q", class)
+		}
+	}
+
+	return nil
+}
+
+// PoolConnection returns a connection from the pool of the named class, which
+// is kept apart from the pools of other classes so that roles of one class
+// can't take all the connections of another. The primary connection is
+// returned for the empty class. As with Connection, the caller must hold the
+// lock.
+func (c *SQLConnectionProducer) PoolConnection(ctx context.Context, class string) (*sql.DB, error) {
+	if class == "" {
+		db, err := c.Connection(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return db.(*sql.DB), nil
+	}
+
+	if !c.Initialized {
+		return nil, ErrNotInitialized
+	}
+	if _, ok := c.PoolClasses[class]; !ok {
+		return nil, fmt.Errorf("unknown pool class %q", class)
+	}
+
+	if db := c.pools[class]; db != nil {
+		err := c.acquire(ctx, db.PingContext)
+		if err == nil {
+			return db, nil
+		}
+		if err == ErrConnectionAcquireTimeout {
+			return nil, err
+		}
+		db.Close()
+		delete(c.pools, class)
+	}
+
+	conn, err := c.connectionString(ctx, c.ConnectionURL)
+	if err != nil {
+		return nil, redactutil.Error(err)
+	}
+
+	db, err := c.openDB(conn)
+	if err != nil {
+		return nil, redactutil.Error(err)
+	}
+	c.setClassPoolSettings(class, db)
+
+	if c.pools == nil {
+		c.pools = make(map[string]*sql.DB)
+	}
+	c.pools[class] = db
+	return db, nil
+}
+
+// setClassPoolSettings applies the connection pool settings to the pool of
+// class, with the class's limit on open connections.
+func (c *SQLConnectionProducer) setClassPoolSettings(class string, db *sql.DB) {
+	maxOpen := c.PoolClasses[class]
+	maxIdle := c.MaxIdleConnections
+	if maxIdle > maxOpen {
+		maxIdle = maxOpen
+	}
+
+	db.SetMaxOpenConns(maxOpen)
+	db.SetMaxIdleConns(maxIdle)
+	db.SetConnMaxLifetime(c.maxConnectionLifetime)
+}
+
+// updatePools applies the pool settings to the open class pools, closing the
+// pools of classes that were removed.
+func (c *SQLConnectionProducer) updatePools() {
+	for class, db := range c.pools {
+		if _, ok := c.PoolClasses[class]; !ok {
+			db.Close()
+			delete(c.pools, class)
+			continue
+		}
+		c.setClassPoolSettings(class, db)
+	}
+}
+
+// closePools closes the pools of every class.
+func (c *SQLConnectionProducer) closePools() {
+	for class, db := range c.pools {
+		db.Close()
+		delete(c.pools, class)
+	}
+}
@@ -0,0 +1,105 @@
+package connutil
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+)
+
+func TestSQLConnectionProducer_PoolConnection(t *testing.T) {
+	c := &SQLConnectionProducer{Type: "connutil-replica"}
+	err := c.Initialize(context.Background(), map[string]interface{}{
+		"connection_url":       "primary",
+		"max_open_connections": 5,
+		"pool_classes": map[string]interface{}{
+			"interactive": 3,
+			"batch":       1,
+		},
+	}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	pool := func(class string) *sql.DB {
+		db, err := c.PoolConnection(context.Background(), class)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return db
+	}
+
+	// The empty class uses the primary pool
+	if db := pool(""); db != c.db || db == nil {
+		t.Fatal("expected the primary pool to be used")
+	}
+
+	// Each class gets a pool of its own, limited separately
+	interactive, batch := pool("interactive"), pool("batch")
+	if interactive == c.db || batch == c.db || interactive == batch {
+		t.Fatal("expected separate pools")
+	}
+	if n := interactive.Stats().MaxOpenConnections; n != 3 {
+		t.Fatalf("expected 3 open connections for interactive, got %d", n)
+	}
+	if n := batch.Stats().MaxOpenConnections; n != 1 {
+		t.Fatalf("expected 1 open connection for batch, got %d", n)
+	}
+	if pool("batch") != batch {
+		t.Fatal("expected the batch pool to be reused")
+	}
+
+	if _, err := c.PoolConnection(context.Background(), "reporting"); err == nil {
+		t.Fatal("expected error for an unknown pool class")
+	}
+
+	// Updating the classes keeps the pools of remaining classes open
+	err = c.UpdatePoolSettings(context.Background(), map[string]interface{}{
+		"connection_url":       "primary",
+		"max_open_connections": 5,
+		"pool_classes":         map[string]interface{}{"batch": 2},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.pools["batch"] != batch || batch.Stats().MaxOpenConnections != 2 {
+		t.Fatal("expected the batch pool to be kept with its new limit")
+	}
+	if _, ok := c.pools["interactive"]; ok {
+		t.Fatal("expected the pool of the removed class to be closed")
+	}
+
+	c.Close()
+	if len(c.pools) != 0 {
+		t.Fatal("expected every pool to be closed")
+	}
+
+	for name, classes := range map[string]map[string]interface{}{
+		"empty class name": {"": 1},
+		"zero limit":       {"batch": 0},
+	} {
+		c := &SQLConnectionProducer{Type: "connutil-replica"}
+		err := c.Initialize(context.Background(), map[string]interface{}{
+			"connection_url": "primary",
+			"pool_classes":   classes,
+		}, false)
+		if err == nil {
+			t.Fatalf("%s: expected error", name)
+		}
+	}
+
+	// Pool classes can't be reached through an SSH tunnel
+	_, hostKey := testSSHServer(t)
+	c = &SQLConnectionProducer{Type: "connutil-replica"}
+	err = c.Initialize(context.Background(), map[string]interface{}{
+		"connection_url": "primary",
+		"pool_classes":   map[string]interface{}{"batch": 1},
+		"ssh_host":       "bastion.example.com",
+		"ssh_username":   "vault",
+		"ssh_password":   "s3cr3t",
+		"ssh_host_key":   hostKey,
+	}, false)
+	if err == nil {
+		t.Fatal("expected error for pool classes with ssh_host")
+	}
+}
@@ -0,0 +1,109 @@
+package connutil
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ProducerSchema describes a registered ConnectionProducer type and the
+// configuration fields it accepts.
+type ProducerSchema struct {
+	Name   string        `json:"name"`
+	Fields []FieldSchema `json:"fields"`
+}
+
+// FieldSchema describes a single configuration field of a ConnectionProducer.
+// Type is one of "string", "int", "bool", "map", "slice" or "any".
+type FieldSchema struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+var (
+	producersLock sync.RWMutex
+	producers     = map[string]reflect.Type{}
+)
+
+// RegisterProducer makes a ConnectionProducer type available for
+// introspection under name. It panics if name is registered twice.
+func RegisterProducer(name string, producer ConnectionProducer) {
+	producersLock.Lock()
+	defer producersLock.Unlock()
+
+	if _, ok := producers[name]; ok {
+		panic(fmt.Sprintf("connutil: producer %q registered twice", name))
+	}
+
+	t := reflect.TypeOf(producer)
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	producers[name] = t
+}
+
+// Producers returns the schemas of all registered ConnectionProducer types,
+// sorted by name. Fields are derived from the producers' mapstructure tags,
+// which name the keys accepted by Initialize.
+func Producers() []ProducerSchema {
+	producersLock.RLock()
+	defer producersLock.RUnlock()
+
+	schemas := make([]ProducerSchema, 0, len(producers))
+	for name, t := range producers {
+		schemas = append(schemas, ProducerSchema{
+			Name:   name,
+			Fields: fieldSchemas(t),
+		})
+	}
+
+	sort.Slice(schemas, func(i, j int) bool {
+		return schemas[i].Name < schemas[j].Name
+	})
+
+	return schemas
+}
+
+// fieldSchemas returns the configuration fields of the struct type t in
+// declaration order.
+func fieldSchemas(t reflect.Type) []FieldSchema {
+	var fields []FieldSchema
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+
+		name := strings.Split(f.Tag.Get("mapstructure"), ",")[0]
+		if name == "" || name == "-" {
+			continue
+		}
+
+		fields = append(fields, FieldSchema{
+			Name: name,
+			Type: fieldType(f.Type),
+		})
+	}
+
+	return fields
+}
+
+func fieldType(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.String:
+		return "string"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "int"
+	case reflect.Bool:
+		return "bool"
+	case reflect.Map:
+		return "map"
+	case reflect.Slice, reflect.Array:
+		return "slice"
+	}
+
+	return "any"
+}
@@ -0,0 +1,58 @@
+package connutil
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestProducers(t *testing.T) {
+	schemas := Producers()
+	if len(schemas) != 1 || schemas[0].Name != "sql" {
+		t.Fatalf("expected only the sql producer, got: %#v", schemas)
+	}
+
+	expected := []FieldSchema{
+		{Name: "connection_url", Type: "string"},
+		{Name: "max_open_connections", Type: "int"},
+		{Name: "max_idle_connections", Type: "int"},
+		{Name: "min_idle_connections", Type: "int"},
+		{Name: "max_connection_lifetime", Type: "any"},
+		{Name: "connection_acquire_timeout", Type: "any"},
+		{Name: "resolver", Type: "string"},
+		{Name: "extra_params", Type: "map"},
+		{Name: "default_params", Type: "map"},
+		{Name: "tls_server_name", Type: "string"},
+		{Name: "tls_min_version", Type: "string"},
+		{Name: "tls_pinned_certificate", Type: "string"},
+		{Name: "host", Type: "string"},
+		{Name: "port", Type: "int"},
+		{Name: "database", Type: "string"},
+		{Name: "params", Type: "map"},
+		{Name: "username", Type: "string"},
+		{Name: "password", Type: "string"},
+		{Name: "ssh_host", Type: "string"},
+		{Name: "ssh_username", Type: "string"},
+		{Name: "ssh_private_key", Type: "string"},
+		{Name: "ssh_password", Type: "string"},
+		{Name: "ssh_host_key", Type: "string"},
+		{Name: "replica_connection_urls", Type: "slice"},
+		{Name: "pool_classes", Type: "map"},
+		{Name: "init_statements", Type: "slice"},
+		{Name: "max_password_length", Type: "int"},
+		{Name: "verify_connection_retries", Type: "int"},
+		{Name: "verify_connection_retry_interval", Type: "any"},
+	}
+	if !reflect.DeepEqual(schemas[0].Fields, expected) {
+		t.Fatalf("expected %#v, got %#v", expected, schemas[0].Fields)
+	}
+}
+
+func TestRegisterProducer_Duplicate(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected registering a name twice to panic")
+		}
+	}()
+
+	RegisterProducer("sql", &SQLConnectionProducer{})
+}
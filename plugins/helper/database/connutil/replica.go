@@ -0,0 +1,106 @@
+package connutil
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/vault/helper/redactutil"
+)
+
+// validateReplicas checks the replica connection URLs and closes any replica
+// connections opened before. Replicas can't be reached through an SSH tunnel,
+// which only forwards to the primary.
+func (c *SQLConnectionProducer) validateReplicas() error {
+	c.closeReplicas()
+	c.replicas = nil
+	c.replica = 0
+	if len(c.ReplicaConnectionURLs) == 0 {
+		return nil
+	}
+
+	if c.SSHHost != "" {
+		return fmt.Errorf("replica_connection_urls cannot be used with ssh_host")
+	}
+	for _, connURL := range c.ReplicaConnectionURLs {
+		if strings.TrimSpace(connURL) == "" {
+			return fmt.Errorf("replica_connection_urls cannot contain empty URLs")
+		}
+	}
+
+	c.replicas = make([]*sql.DB, len(c.ReplicaConnectionURLs))
+	return nil
+}
+
+// ReadConnection returns a connection for operations that only read from the
+// database. If replicas are configured, it's a connection to the first one
+// answering a ping, starting with the one last used, so that reads fail over
+// to another replica when one goes down. The primary connection is returned
+// if there are no replicas or none of them answers. As with Connection, the
+// caller must hold the lock.
+func (c *SQLConnectionProducer) ReadConnection(ctx context.Context) (*sql.DB, error) {
+	if !c.Initialized {
+		return nil, ErrNotInitialized
+	}
+
+	for i := range c.replicas {
+		idx := (c.replica + i) % len(c.replicas)
+		db, err := c.replicaConnection(ctx, idx)
+		if err == nil {
+			c.replica = idx
+			return db, nil
+		}
+		if ctx.Err() != nil {
+			return nil, redactutil.Error(err)
+		}
+	}
+
+	db, err := c.Connection(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return db.(*sql.DB), nil
+}
+
+// replicaConnection returns a connection to the replica at idx, checking that
+// it answers a ping. A connection that no longer does is closed and reopened.
+func (c *SQLConnectionProducer) replicaConnection(ctx context.Context, idx int) (*sql.DB, error) {
+	if db := c.replicas[idx]; db != nil {
+		if err := db.PingContext(ctx); err == nil {
+			return db, nil
+		}
+		db.Close()
+		c.replicas[idx] = nil
+	}
+
+	conn, err := c.connectionString(ctx, c.ReplicaConnectionURLs[idx])
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := c.openDB(conn)
+	if err != nil {
+		return nil, err
+	}
+	c.setPoolSettings(db)
+
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	c.replicas[idx] = db
+	return db, nil
+}
+
+// closeReplicas closes the open connections to the replicas.
+func (c *SQLConnectionProducer) closeReplicas() {
+	for i, db := range c.replicas {
+		if db != nil {
+			db.Close()
+			c.replicas[i] = nil
+		}
+	}
+}
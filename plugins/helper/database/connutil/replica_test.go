@@ -0,0 +1,144 @@
+package connutil
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"sync"
+	"testing"
+)
+
+// replicaDriver is a database/sql driver that refuses connections to the
+// DSNs marked as down.
+type replicaDriver struct {
+	sync.Mutex
+	down map[string]bool
+}
+
+func (d *replicaDriver) Open(name string) (driver.Conn, error) {
+	if d.isDown(name) {
+		return nil, errors.New("connection refused")
+	}
+	return &replicaConn{driver: d, name: name}, nil
+}
+
+func (d *replicaDriver) isDown(name string) bool {
+	d.Lock()
+	defer d.Unlock()
+
+	return d.down[name]
+}
+
+func (d *replicaDriver) setDown(names ...string) {
+	d.Lock()
+	defer d.Unlock()
+
+	d.down = make(map[string]bool)
+	for _, name := range names {
+		d.down[name] = true
+	}
+}
+
+// replicaConn is a connection of replicaDriver whose pings fail once its
+// DSN is marked as down.
+type replicaConn struct {
+	countingConn
+	driver *replicaDriver
+	name   string
+}
+
+func (c *replicaConn) Ping(_ context.Context) error {
+	if c.driver.isDown(c.name) {
+		return errors.New("connection refused")
+	}
+	return nil
+}
+
+var testReplicaDriver = &replicaDriver{}
+
+func init() {
+	sql.Register("connutil-replica", testReplicaDriver)
+}
+
+func TestSQLConnectionProducer_ReadConnection(t *testing.T) {
+	c := &SQLConnectionProducer{Type: "connutil-replica"}
+	err := c.Initialize(context.Background(), map[string]interface{}{
+		"connection_url":          "primary",
+		"replica_connection_urls": []string{"replica-1", "replica-2"},
+	}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	read := func() *sql.DB {
+		db, err := c.ReadConnection(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return db
+	}
+
+	// The first live replica is used
+	testReplicaDriver.setDown("replica-1")
+	if db := read(); db != c.replicas[1] || db == nil {
+		t.Fatal("expected the second replica to be used")
+	}
+
+	// It keeps being used once the first one is back
+	testReplicaDriver.setDown()
+	if db := read(); db != c.replicas[1] {
+		t.Fatal("expected the second replica to still be used")
+	}
+
+	// Reads fail over when it goes down
+	testReplicaDriver.setDown("replica-2")
+	if db := read(); db != c.replicas[0] || db == nil {
+		t.Fatal("expected the first replica to be used")
+	}
+	if c.replicas[1] != nil {
+		t.Fatal("expected the connection to the down replica to be closed")
+	}
+
+	// The primary is used when no replica is up
+	testReplicaDriver.setDown("replica-1", "replica-2")
+	if db := read(); db != c.db || db == nil {
+		t.Fatal("expected the primary to be used")
+	}
+	testReplicaDriver.setDown()
+
+	// Replicas can't be reached through an SSH tunnel
+	_, hostKey := testSSHServer(t)
+	c = &SQLConnectionProducer{Type: "connutil-replica"}
+	err = c.Initialize(context.Background(), map[string]interface{}{
+		"connection_url":          "primary",
+		"replica_connection_urls": []string{"replica-1"},
+		"ssh_host":                "bastion.example.com",
+		"ssh_username":            "vault",
+		"ssh_password":            "s3cr3t",
+		"ssh_host_key":            hostKey,
+	}, false)
+	if err == nil {
+		t.Fatal("expected error for replicas with ssh_host")
+	}
+
+	c = &SQLConnectionProducer{Type: "connutil-replica"}
+	err = c.Initialize(context.Background(), map[string]interface{}{
+		"connection_url":          "primary",
+		"replica_connection_urls": []string{""},
+	}, false)
+	if err == nil {
+		t.Fatal("expected error for an empty replica URL")
+	}
+
+	// Without replicas the primary is used
+	c = &SQLConnectionProducer{Type: "connutil-replica"}
+	if err := c.Initialize(context.Background(), map[string]interface{}{"connection_url": "primary"}, false); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if db := read(); db != c.db || db == nil {
+		t.Fatal("expected the primary to be used")
+	}
+}
@@ -2,30 +2,108 @@ package connutil
 
 import (
 	"context"
+	"crypto/sha256"
+	"crypto/tls"
 	"database/sql"
+	"encoding/hex"
 	"fmt"
+	"net"
+	"net/url"
+	"reflect"
+	"regexp"
+	"sort"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/go-sql-driver/mysql"
+	"github.com/hashicorp/vault/builtin/logical/database/dbplugin"
+	"github.com/hashicorp/vault/helper/dnsutil"
 	"github.com/hashicorp/vault/helper/parseutil"
+	"github.com/hashicorp/vault/helper/redactutil"
+	"github.com/hashicorp/vault/helper/tlsutil"
+	"github.com/hashicorp/vault/plugins/helper/database/credsutil"
 	"github.com/mitchellh/mapstructure"
 )
 
 // SQLConnectionProducer implements ConnectionProducer and provides a generic producer for most sql databases
 type SQLConnectionProducer struct {
-	ConnectionURL            string      `json:"connection_url" structs:"connection_url" mapstructure:"connection_url"`
-	MaxOpenConnections       int         `json:"max_open_connections" structs:"max_open_connections" mapstructure:"max_open_connections"`
-	MaxIdleConnections       int         `json:"max_idle_connections" structs:"max_idle_connections" mapstructure:"max_idle_connections"`
-	MaxConnectionLifetimeRaw interface{} `json:"max_connection_lifetime" structs:"max_connection_lifetime" mapstructure:"max_connection_lifetime"`
-
-	Type                  string
-	maxConnectionLifetime time.Duration
-	Initialized           bool
-	db                    *sql.DB
+	ConnectionURL               string            `json:"connection_url" structs:"connection_url" mapstructure:"connection_url"`
+	MaxOpenConnections          int               `json:"max_open_connections" structs:"max_open_connections" mapstructure:"max_open_connections"`
+	MaxIdleConnections          int               `json:"max_idle_connections" structs:"max_idle_connections" mapstructure:"max_idle_connections"`
+	MinIdleConnections          int               `json:"min_idle_connections" structs:"min_idle_connections" mapstructure:"min_idle_connections"`
+	MaxConnectionLifetimeRaw    interface{}       `json:"max_connection_lifetime" structs:"max_connection_lifetime" mapstructure:"max_connection_lifetime"`
+	ConnectionAcquireTimeoutRaw interface{}       `json:"connection_acquire_timeout" structs:"connection_acquire_timeout" mapstructure:"connection_acquire_timeout"`
+	Resolver                    string            `json:"resolver" structs:"resolver" mapstructure:"resolver"`
+	ExtraParams                 map[string]string `json:"extra_params" structs:"extra_params" mapstructure:"extra_params"`
+	DefaultParams               map[string]string `json:"default_params" structs:"default_params" mapstructure:"default_params"`
+	TLSServerName               string            `json:"tls_server_name" structs:"tls_server_name" mapstructure:"tls_server_name"`
+	TLSMinVersion               string            `json:"tls_min_version" structs:"tls_min_version" mapstructure:"tls_min_version"`
+	TLSPinnedCertificate        string            `json:"tls_pinned_certificate" structs:"tls_pinned_certificate" mapstructure:"tls_pinned_certificate"`
+
+	// Host, Port, Database, Params, Username and Password are used to build
+	// the connection URL for the database type when ConnectionURL isn't set.
+	Host     string            `json:"host" structs:"host" mapstructure:"host"`
+	Port     int               `json:"port" structs:"port" mapstructure:"port"`
+	Database string            `json:"database" structs:"database" mapstructure:"database"`
+	Params   map[string]string `json:"params" structs:"params" mapstructure:"params"`
+	Username string            `json:"username" structs:"username" mapstructure:"username"`
+	Password string            `json:"password" structs:"password" mapstructure:"password"`
+
+	// SSHHost, if set, is the host[:port] of an SSH server, such as a
+	// bastion, that connections to the database are tunneled through.
+	SSHHost       string `json:"ssh_host" structs:"ssh_host" mapstructure:"ssh_host"`
+	SSHUsername   string `json:"ssh_username" structs:"ssh_username" mapstructure:"ssh_username"`
+	SSHPrivateKey string `json:"ssh_private_key" structs:"ssh_private_key" mapstructure:"ssh_private_key"`
+	SSHPassword   string `json:"ssh_password" structs:"ssh_password" mapstructure:"ssh_password"`
+	SSHHostKey    string `json:"ssh_host_key" structs:"ssh_host_key" mapstructure:"ssh_host_key"`
+
+	// ReplicaConnectionURLs are the connection URLs of read replicas of the
+	// database, which ReadConnection returns connections to.
+	ReplicaConnectionURLs []string `json:"replica_connection_urls" structs:"replica_connection_urls" mapstructure:"replica_connection_urls"`
+
+	// PoolClasses maps the names of role classes to the number of
+	// connections their pools may open. Each class gets a pool of its own,
+	// which PoolConnection returns connections from.
+	PoolClasses map[string]int `json:"pool_classes" structs:"pool_classes" mapstructure:"pool_classes"`
+
+	// InitStatements are run on every connection to the database when it is
+	// opened, before it's used, to set up the session.
+	InitStatements []string `json:"init_statements" structs:"init_statements" mapstructure:"init_statements"`
+
+	// MaxPasswordLength caps the length of generated passwords. It defaults
+	// to the limit of the database type, if it has one.
+	MaxPasswordLength int `json:"max_password_length" structs:"max_password_length" mapstructure:"max_password_length"`
+
+	// VerifyConnectionRetries and VerifyConnectionRetryIntervalRaw only
+	// apply to the connection verified by Initialize.
+	VerifyConnectionRetries          int         `json:"verify_connection_retries" structs:"verify_connection_retries" mapstructure:"verify_connection_retries"`
+	VerifyConnectionRetryIntervalRaw interface{} `json:"verify_connection_retry_interval" structs:"verify_connection_retry_interval" mapstructure:"verify_connection_retry_interval"`
+
+	Type                          string
+	maxConnectionLifetime         time.Duration
+	connectionAcquireTimeout      time.Duration
+	verifyConnectionRetryInterval time.Duration
+	sshConfig                     *sshTunnelConfig
+	tunnel                        *sshTunnel
+	Initialized                   bool
+	db                            *sql.DB
+
+	// replicas holds the open connections to the read replicas, indexed as
+	// ReplicaConnectionURLs, and replica is the index of the one last used.
+	replicas []*sql.DB
+	replica  int
+
+	// pools holds the open pools of the pool classes.
+	pools map[string]*sql.DB
+
 	sync.Mutex
 }
 
+func init() {
+	RegisterProducer("sql", &SQLConnectionProducer{})
+}
+
 func (c *SQLConnectionProducer) Initialize(ctx context.Context, conf map[string]interface{}, verifyConnection bool) error {
 	c.Lock()
 	defer c.Unlock()
@@ -35,10 +113,224 @@ func (c *SQLConnectionProducer) Initialize(ctx context.Context, conf map[string]
 		return err
 	}
 
-	if len(c.ConnectionURL) == 0 {
-		return fmt.Errorf("connection_url cannot be empty")
+	if err := c.setConnectionURL(); err != nil {
+		return err
+	}
+
+	if err := c.setPoolDefaults(); err != nil {
+		return err
+	}
+
+	if err := c.setMaxPasswordLength(); err != nil {
+		return err
+	}
+
+	if c.VerifyConnectionRetries < 0 {
+		return fmt.Errorf("verify_connection_retries cannot be negative")
+	}
+	if c.VerifyConnectionRetryIntervalRaw == nil {
+		c.VerifyConnectionRetryIntervalRaw = "1s"
+	}
+	c.verifyConnectionRetryInterval, err = parseutil.ParseDurationSecond(c.VerifyConnectionRetryIntervalRaw)
+	if err != nil {
+		return fmt.Errorf("invalid verify_connection_retry_interval: %s", err)
+	}
+
+	if c.Resolver != "" {
+		if err := dnsutil.ValidateResolver(c.Resolver); err != nil {
+			return err
+		}
+	}
+
+	if c.TLSServerName != "" {
+		if err := c.validateTLSServerName(); err != nil {
+			return err
+		}
+	}
+
+	if c.TLSMinVersion != "" {
+		if err := c.validateTLSMinVersion(); err != nil {
+			return err
+		}
+	}
+
+	if c.TLSPinnedCertificate != "" {
+		if err := c.validateTLSPinnedCertificate(); err != nil {
+			return err
+		}
+	}
+
+	if c.SSHHost != "" {
+		c.sshConfig, err = c.parseSSHTunnelConfig()
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := c.validateReplicas(); err != nil {
+		return err
+	}
+
+	if err := c.validatePoolClasses(); err != nil {
+		return err
+	}
+
+	if err := c.validateInitStatements(); err != nil {
+		return err
+	}
+
+	// Set initialized to true at this point since all fields are set,
+	// and the connection can be established at a later time.
+	c.Initialized = true
+
+	if verifyConnection {
+		if err := c.verifyConnection(ctx); err != nil {
+			return fmt.Errorf("error verifying connection: %s", redactutil.Error(err))
+		}
+	}
+
+	return nil
+}
+
+// verifyConnection connects to the database and pings it. A failed attempt
+// is retried up to VerifyConnectionRetries times, waiting
+// verifyConnectionRetryInterval between attempts, unless ctx is done first.
+func (c *SQLConnectionProducer) verifyConnection(ctx context.Context) error {
+	var err error
+	for attempt := 0; ; attempt++ {
+		if _, err = c.Connection(ctx); err == nil {
+			if err = c.db.PingContext(ctx); err == nil {
+				return nil
+			}
+		}
+
+		if attempt == c.VerifyConnectionRetries {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(c.verifyConnectionRetryInterval):
+		}
+	}
+}
+
+// passwordLengthLimits are the longest passwords accepted by the database
+// types that limit them. Longer passwords are rejected or truncated by the
+// database.
+var passwordLengthLimits = map[string]int{
+	"mssql": 128,
+}
+
+// setMaxPasswordLength defaults MaxPasswordLength to the limit of the database
+// type and checks that it is within that limit and long enough for generated
+// passwords.
+func (c *SQLConnectionProducer) setMaxPasswordLength() error {
+	limit := passwordLengthLimits[c.Type]
+
+	switch {
+	case c.MaxPasswordLength == 0:
+		c.MaxPasswordLength = limit
+	case c.MaxPasswordLength < credsutil.MinPasswordLength:
+		return fmt.Errorf("max_password_length must be at least %d", credsutil.MinPasswordLength)
+	case limit > 0 && c.MaxPasswordLength > limit:
+		return fmt.Errorf("max_password_length cannot exceed %d, the longest password %s accepts", limit, c.Type)
+	}
+
+	return nil
+}
+
+// PasswordLengthLimit returns the length generated passwords must not exceed,
+// or zero if it isn't limited. It is read without the lock since it's only
+// set by Initialize.
+func (c *SQLConnectionProducer) PasswordLengthLimit() int {
+	return c.MaxPasswordLength
+}
+
+// UpdatePoolSettings applies the pool settings in conf to the live connection
+// without closing it. If any other setting differs from the current
+// configuration, dbplugin.ErrConnectionIdentityChanged is returned and nothing
+// is changed.
+func (c *SQLConnectionProducer) UpdatePoolSettings(ctx context.Context, conf map[string]interface{}) error {
+	c.Lock()
+	defer c.Unlock()
+
+	if !c.Initialized {
+		return ErrNotInitialized
+	}
+
+	updated := &SQLConnectionProducer{Type: c.Type}
+	if err := mapstructure.WeakDecode(conf, updated); err != nil {
+		return err
+	}
+	if err := updated.setConnectionURL(); err != nil {
+		return err
+	}
+	if err := updated.setMaxPasswordLength(); err != nil {
+		return err
+	}
+	if err := updated.validatePoolClasses(); err != nil {
+		return err
+	}
+
+	if updated.ConnectionURL != c.ConnectionURL || updated.Resolver != c.Resolver || updated.TLSServerName != c.TLSServerName ||
+		updated.TLSMinVersion != c.TLSMinVersion || updated.TLSPinnedCertificate != c.TLSPinnedCertificate || updated.MaxPasswordLength != c.MaxPasswordLength || updated.SSHHost != c.SSHHost || updated.SSHUsername != c.SSHUsername ||
+		updated.SSHPrivateKey != c.SSHPrivateKey || updated.SSHPassword != c.SSHPassword || updated.SSHHostKey != c.SSHHostKey ||
+		updated.Username != c.Username || updated.Password != c.Password ||
+		!reflect.DeepEqual(updated.ExtraParams, c.ExtraParams) || !reflect.DeepEqual(updated.DefaultParams, c.DefaultParams) ||
+		!reflect.DeepEqual(updated.ReplicaConnectionURLs, c.ReplicaConnectionURLs) || !reflect.DeepEqual(updated.InitStatements, c.InitStatements) {
+		return dbplugin.ErrConnectionIdentityChanged
+	}
+
+	if err := updated.setPoolDefaults(); err != nil {
+		return err
+	}
+
+	c.MaxOpenConnections = updated.MaxOpenConnections
+	c.MaxIdleConnections = updated.MaxIdleConnections
+	c.MinIdleConnections = updated.MinIdleConnections
+	c.MaxConnectionLifetimeRaw = updated.MaxConnectionLifetimeRaw
+	c.maxConnectionLifetime = updated.maxConnectionLifetime
+	c.ConnectionAcquireTimeoutRaw = updated.ConnectionAcquireTimeoutRaw
+	c.connectionAcquireTimeout = updated.connectionAcquireTimeout
+	c.PoolClasses = updated.PoolClasses
+	c.updatePools()
+
+	for _, db := range c.replicas {
+		if db != nil {
+			c.setPoolSettings(db)
+		}
+	}
+
+	if c.db != nil {
+		c.setPoolSettings(c.db)
+
+		if err := c.warmPool(ctx); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Ping checks that the database is reachable, opening the connection pool if
+// needed.
+func (c *SQLConnectionProducer) Ping(ctx context.Context) error {
+	c.Lock()
+	defer c.Unlock()
+
+	db, err := c.Connection(ctx)
+	if err != nil {
+		return err
 	}
 
+	return redactutil.Error(db.(*sql.DB).PingContext(ctx))
+}
+
+// setPoolDefaults fills in unset pool settings and parses the connection
+// lifetime.
+func (c *SQLConnectionProducer) setPoolDefaults() error {
 	if c.MaxOpenConnections == 0 {
 		c.MaxOpenConnections = 2
 	}
@@ -49,27 +341,31 @@ func (c *SQLConnectionProducer) Initialize(ctx context.Context, conf map[string]
 	if c.MaxIdleConnections > c.MaxOpenConnections {
 		c.MaxIdleConnections = c.MaxOpenConnections
 	}
+	if c.MinIdleConnections < 0 {
+		c.MinIdleConnections = 0
+	}
+	if c.MinIdleConnections > c.MaxIdleConnections {
+		c.MinIdleConnections = c.MaxIdleConnections
+	}
 	if c.MaxConnectionLifetimeRaw == nil {
 		c.MaxConnectionLifetimeRaw = "0s"
 	}
 
+	var err error
 	c.maxConnectionLifetime, err = parseutil.ParseDurationSecond(c.MaxConnectionLifetimeRaw)
 	if err != nil {
 		return fmt.Errorf("invalid max_connection_lifetime: %s", err)
 	}
 
-	// Set initialized to true at this point since all fields are set,
-	// and the connection can be established at a later time.
-	c.Initialized = true
-
-	if verifyConnection {
-		if _, err := c.Connection(ctx); err != nil {
-			return fmt.Errorf("error verifying connection: %s", err)
-		}
-
-		if err := c.db.PingContext(ctx); err != nil {
-			return fmt.Errorf("error verifying connection: %s", err)
-		}
+	if c.ConnectionAcquireTimeoutRaw == nil {
+		c.ConnectionAcquireTimeoutRaw = "0s"
+	}
+	c.connectionAcquireTimeout, err = parseutil.ParseDurationSecond(c.ConnectionAcquireTimeoutRaw)
+	if err != nil {
+		return fmt.Errorf("invalid connection_acquire_timeout: %s", err)
+	}
+	if c.connectionAcquireTimeout < 0 {
+		return fmt.Errorf("connection_acquire_timeout cannot be negative")
 	}
 
 	return nil
@@ -82,22 +378,66 @@ func (c *SQLConnectionProducer) Connection(ctx context.Context) (interface{}, er
 
 	// If we already have a DB, test it and return
 	if c.db != nil {
-		if err := c.db.PingContext(ctx); err == nil {
+		err := c.acquire(ctx, c.db.PingContext)
+		if err == nil {
 			return c.db, nil
 		}
+		// A busy pool is still a working one
+		if err == ErrConnectionAcquireTimeout {
+			return nil, err
+		}
 		// If the ping was unsuccessful, close it and ignore errors as we'll be
 		// reestablishing anyways
 		c.db.Close()
 	}
 
+	// Otherwise, attempt to make connection
+	conn, err := c.connectionString(ctx, c.ConnectionURL)
+	if err != nil {
+		return nil, redactutil.Error(err)
+	}
+
+	if c.sshConfig != nil {
+		conn, err = c.tunnelConnectionURL(ctx, conn)
+		if err != nil {
+			return nil, redactutil.Error(err)
+		}
+	}
+
+	c.db, err = c.openDB(conn)
+	if err != nil {
+		return nil, redactutil.Error(err)
+	}
+	c.setPoolSettings(c.db)
+
+	if err := c.warmPool(ctx); err != nil {
+		return nil, err
+	}
+
+	return c.db, nil
+}
+
+// driverName returns the name of the database/sql driver for the database
+// type.
+func (c *SQLConnectionProducer) driverName() string {
 	// For mssql backend, switch to sqlserver instead
-	dbType := c.Type
 	if c.Type == "mssql" {
-		dbType = "sqlserver"
+		return "sqlserver"
 	}
+	return c.Type
+}
 
-	// Otherwise, attempt to make connection
-	conn := c.ConnectionURL
+// connectionString returns connURL with the connection's credentials,
+// parameters, TLS settings and resolver applied.
+func (c *SQLConnectionProducer) connectionString(ctx context.Context, connURL string) (string, error) {
+	conn, err := c.withCredentials(connURL)
+	if err != nil {
+		return "", err
+	}
+	conn, err = MergeParams(c.Type, conn, c.ExtraParams, c.DefaultParams)
+	if err != nil {
+		return "", err
+	}
 
 	// Ensure timezone is set to UTC for all the conenctions
 	if strings.HasPrefix(conn, "postgres://") || strings.HasPrefix(conn, "postgresql://") {
@@ -108,19 +448,119 @@ func (c *SQLConnectionProducer) Connection(ctx context.Context) (interface{}, er
 		}
 	}
 
-	var err error
-	c.db, err = sql.Open(dbType, conn)
+	if c.TLSServerName != "" || c.TLSMinVersion != "" || c.TLSPinnedCertificate != "" {
+		conn, err = c.withTLSConfig(conn)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	if c.Resolver != "" {
+		conn, err = resolveConnectionURL(ctx, conn, dnsutil.NewResolver(c.Resolver))
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return conn, nil
+}
+
+// setPoolSettings applies the connection pool settings to db. We don't need
+// much of this, since the request rate shouldn't be high.
+func (c *SQLConnectionProducer) setPoolSettings(db *sql.DB) {
+	db.SetMaxOpenConns(c.MaxOpenConnections)
+	db.SetMaxIdleConns(c.MaxIdleConnections)
+	db.SetConnMaxLifetime(c.maxConnectionLifetime)
+}
+
+// warmPool opens MinIdleConnections connections and returns them to the pool,
+// so that they are idle and ready for the first requests instead of being
+// opened on demand.
+func (c *SQLConnectionProducer) warmPool(ctx context.Context) error {
+	conns := make([]*sql.Conn, 0, c.MinIdleConnections)
+	defer func() {
+		for _, conn := range conns {
+			conn.Close()
+		}
+	}()
+
+	for len(conns) < c.MinIdleConnections {
+		conn, err := c.db.Conn(ctx)
+		if err != nil {
+			return fmt.Errorf("error opening idle connections: %s", redactutil.Error(err))
+		}
+		conns = append(conns, conn)
+	}
+
+	return nil
+}
+
+// readOnlyTypes are the database types whose drivers can start read-only
+// transactions.
+var readOnlyTypes = map[string]bool{
+	"postgres": true,
+	"mysql":    true,
+}
+
+// ReadOnlyQuery runs query inside a read-only transaction and returns every
+// row as strings, with NULL values returned as empty strings. It's intended
+// for verification queries that must never modify the database, even if the
+// statement is malformed. ErrReadOnlyUnsupported is returned for database
+// types that don't support read-only transactions. As with Connection, the
+// caller must hold the lock.
+//
+// The query runs against the primary rather than a replica, since it's used
+// to inspect users just created there.
+func (c *SQLConnectionProducer) ReadOnlyQuery(ctx context.Context, query string, args ...interface{}) ([][]string, error) {
+	if !readOnlyTypes[c.Type] {
+		return nil, ErrReadOnlyUnsupported
+	}
+
+	db, err := c.Connection(ctx)
 	if err != nil {
 		return nil, err
 	}
 
-	// Set some connection pool settings. We don't need much of this,
-	// since the request rate shouldn't be high.
-	c.db.SetMaxOpenConns(c.MaxOpenConnections)
-	c.db.SetMaxIdleConns(c.MaxIdleConnections)
-	c.db.SetConnMaxLifetime(c.maxConnectionLifetime)
+	tx, err := db.(*sql.DB).BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	// Nothing is ever committed
+	defer tx.Rollback()
 
-	return c.db, nil
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+
+	var result [][]string
+	for rows.Next() {
+		values := make([]sql.NullString, len(columns))
+		dest := make([]interface{}, len(columns))
+		for i := range values {
+			dest[i] = &values[i]
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+
+		row := make([]string, len(columns))
+		for i, v := range values {
+			row[i] = v.String
+		}
+		result = append(result, row)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return result, nil
 }
 
 // Close attempts to close the connection
@@ -134,6 +574,366 @@ func (c *SQLConnectionProducer) Close() error {
 	}
 
 	c.db = nil
+	c.closeReplicas()
+	c.closePools()
+
+	if c.tunnel != nil {
+		c.tunnel.Close()
+		c.tunnel = nil
+	}
+
+	return nil
+}
+
+// tunnelConnectionURL opens a new SSH tunnel to the database host in connURL
+// and returns connURL connecting to the tunnel instead. Any previous tunnel
+// is closed, since it is only replaced when the connection failed.
+func (c *SQLConnectionProducer) tunnelConnectionURL(ctx context.Context, connURL string) (string, error) {
+	if c.tunnel != nil {
+		c.tunnel.Close()
+		c.tunnel = nil
+	}
+
+	return rewriteConnectionAddr(connURL, "ssh_host", func(addr string) (string, error) {
+		if _, _, err := net.SplitHostPort(addr); err != nil {
+			return "", fmt.Errorf("ssh_host requires the database port in connection_url")
+		}
+
+		tunnel, err := openSSHTunnel(ctx, c.sshConfig, addr)
+		if err != nil {
+			return "", err
+		}
+		c.tunnel = tunnel
+
+		return tunnel.Addr(), nil
+	})
+}
+
+// validateTLSServerName checks that TLSServerName can be applied to the
+// connection. It's supported by MySQL, where connection_url must enable TLS
+// with tls=true, and by MSSQL.
+func (c *SQLConnectionProducer) validateTLSServerName() error {
+	if strings.TrimSpace(c.TLSServerName) == "" {
+		return fmt.Errorf("tls_server_name cannot be blank")
+	}
+
+	switch c.Type {
+	case "mysql":
+		return c.requireMySQLTLS("tls_server_name")
+	case "mssql":
+	default:
+		return fmt.Errorf("tls_server_name is not supported for %s connections", c.Type)
+	}
+
+	return nil
+}
+
+// validateTLSMinVersion checks that TLSMinVersion is a known TLS version and
+// can be applied to the connection. It's only supported by MySQL, whose driver
+// accepts a TLS configuration, and connection_url must enable TLS with
+// tls=true.
+func (c *SQLConnectionProducer) validateTLSMinVersion() error {
+	if _, ok := tlsutil.TLSLookup[c.TLSMinVersion]; !ok {
+		return fmt.Errorf("invalid tls_min_version %q", c.TLSMinVersion)
+	}
+
+	if c.Type != "mysql" {
+		return fmt.Errorf("tls_min_version is not supported for %s connections", c.Type)
+	}
+
+	return c.requireMySQLTLS("tls_min_version")
+}
+
+// validateTLSPinnedCertificate checks that TLSPinnedCertificate is a single
+// PEM encoded certificate and can be applied to the connection. Like
+// tls_min_version, it's only supported by MySQL with tls=true.
+func (c *SQLConnectionProducer) validateTLSPinnedCertificate() error {
+	if _, err := tlsutil.ParsePinnedCertificate(c.TLSPinnedCertificate); err != nil {
+		return fmt.Errorf("invalid tls_pinned_certificate: %s", err)
+	}
+
+	if c.Type != "mysql" {
+		return fmt.Errorf("tls_pinned_certificate is not supported for %s connections", c.Type)
+	}
+
+	return c.requireMySQLTLS("tls_pinned_certificate")
+}
+
+// requireMySQLTLS returns an error if connection_url doesn't enable TLS, which
+// the named setting requires.
+func (c *SQLConnectionProducer) requireMySQLTLS(setting string) error {
+	cfg, err := mysql.ParseDSN(c.ConnectionURL)
+	if err != nil {
+		return redactutil.Error(err)
+	}
+	if cfg.TLSConfig != "true" {
+		return fmt.Errorf("%s requires TLS to be enabled with tls=true in connection_url", setting)
+	}
 
 	return nil
 }
+
+// withTLSConfig returns conn configured to verify the server's certificate
+// against TLSServerName rather than the host it connects to, to refuse TLS
+// versions older than TLSMinVersion, and to only accept TLSPinnedCertificate
+// as the server's certificate.
+func (c *SQLConnectionProducer) withTLSConfig(conn string) (string, error) {
+	switch c.Type {
+	case "mysql":
+		cfg, err := mysql.ParseDSN(conn)
+		if err != nil {
+			return "", err
+		}
+
+		// The driver only accepts TLS configs registered by name, so the
+		// name must differ for every combination of settings
+		name := "vault"
+		tlsConfig := &tls.Config{}
+		if c.TLSServerName != "" {
+			name += "-" + c.TLSServerName
+			tlsConfig.ServerName = c.TLSServerName
+		}
+		if c.TLSMinVersion != "" {
+			name += "-" + c.TLSMinVersion
+			tlsConfig.MinVersion = tlsutil.TLSLookup[c.TLSMinVersion]
+		}
+		if c.TLSPinnedCertificate != "" {
+			cert, err := tlsutil.ParsePinnedCertificate(c.TLSPinnedCertificate)
+			if err != nil {
+				return "", err
+			}
+			fingerprint := sha256.Sum256(cert.Raw)
+			name += "-" + hex.EncodeToString(fingerprint[:])
+			tlsutil.PinCertificate(tlsConfig, cert)
+		}
+		if err := mysql.RegisterTLSConfig(name, tlsConfig); err != nil {
+			return "", err
+		}
+		cfg.TLSConfig = name
+
+		return cfg.FormatDSN(), nil
+	case "mssql":
+		if c.TLSMinVersion != "" {
+			return "", fmt.Errorf("tls_min_version is not supported for %s connections", c.Type)
+		}
+		if c.TLSPinnedCertificate != "" {
+			return "", fmt.Errorf("tls_pinned_certificate is not supported for %s connections", c.Type)
+		}
+		return MergeParams(c.Type, conn, map[string]string{"hostNameInCertificate": c.TLSServerName})
+	}
+
+	return "", fmt.Errorf("tls_server_name is not supported for %s connections", c.Type)
+}
+
+// mysqlAddrRe matches the address in MySQL style DSNs, e.g.
+// "user:pass@tcp(host:3306)/db".
+var mysqlAddrRe = regexp.MustCompile(`tcp\(([^)]+)\)`)
+
+// resolveConnectionURL replaces the database host in connURL with the address
+// returned by resolver. URL style connection strings and MySQL DSNs are
+// supported.
+func resolveConnectionURL(ctx context.Context, connURL string, resolver *net.Resolver) (string, error) {
+	return rewriteConnectionAddr(connURL, "resolver", func(addr string) (string, error) {
+		return resolveAddr(ctx, resolver, addr)
+	})
+}
+
+// rewriteConnectionAddr replaces the database address in connURL with the one
+// returned by rewrite. URL style connection strings and MySQL DSNs are
+// supported; setting names the option requiring the rewrite in errors.
+func rewriteConnectionAddr(connURL, setting string, rewrite func(addr string) (string, error)) (string, error) {
+	if m := mysqlAddrRe.FindStringSubmatchIndex(connURL); m != nil {
+		addr, err := rewrite(connURL[m[2]:m[3]])
+		if err != nil {
+			return "", err
+		}
+		return connURL[:m[2]] + addr + connURL[m[3]:], nil
+	}
+
+	u, err := url.Parse(connURL)
+	if err != nil || u.Host == "" {
+		return "", fmt.Errorf("%s requires a URL style connection_url", setting)
+	}
+
+	u.Host, err = rewrite(u.Host)
+	if err != nil {
+		return "", err
+	}
+
+	return u.String(), nil
+}
+
+// resolveAddr resolves the host portion of addr, keeping any port.
+func resolveAddr(ctx context.Context, resolver *net.Resolver, addr string) (string, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		host, port = addr, ""
+	}
+
+	ip, err := dnsutil.LookupHost(ctx, resolver, host)
+	if err != nil {
+		return "", fmt.Errorf("error resolving host %q: %s", host, err)
+	}
+
+	if port == "" {
+		if strings.Contains(ip, ":") {
+			return "[" + ip + "]", nil
+		}
+		return ip, nil
+	}
+
+	return net.JoinHostPort(ip, port), nil
+}
+
+// kvParamRe matches the keys of key/value style DSNs, e.g.
+// "host=localhost dbname=db".
+var kvParamRe = regexp.MustCompile(`(?:^|\s)(\w+)\s*=`)
+
+// adoParamRe matches the keys of ADO and ODBC style connection strings, e.g.
+// "server=localhost;user id=sa".
+var adoParamRe = regexp.MustCompile(`(?:^|;)\s*([^;={}]+?)\s*=`)
+
+// paramStyle is the way parameters are written in a connection string.
+type paramStyle int
+
+const (
+	// paramStyleURL is a query string, used by URLs and MySQL DSNs.
+	paramStyleURL paramStyle = iota
+
+	// paramStyleKeyValue is space separated key=value pairs, used by
+	// PostgreSQL DSNs that aren't URLs.
+	paramStyleKeyValue
+
+	// paramStyleADO is semicolon separated key=value pairs with
+	// case-insensitive keys, used by SQL Server.
+	paramStyleADO
+
+	// paramStyleODBC is like paramStyleADO, but values may be braced. SQL
+	// Server connection strings starting with "odbc:" use it.
+	paramStyleODBC
+)
+
+// paramStyleFor returns the way parameters are written in the connection
+// string connURL of a dbType database.
+func paramStyleFor(dbType, connURL string) paramStyle {
+	switch dbType {
+	case "postgres":
+		if !strings.HasPrefix(connURL, "postgres://") && !strings.HasPrefix(connURL, "postgresql://") {
+			return paramStyleKeyValue
+		}
+	case "mssql":
+		switch {
+		case strings.HasPrefix(connURL, "odbc:"):
+			return paramStyleODBC
+		case !strings.HasPrefix(connURL, "sqlserver://"):
+			return paramStyleADO
+		}
+	}
+
+	return paramStyleURL
+}
+
+// MergeParams adds the given parameter sets to the connection string connURL
+// of a dbType database, skipping any parameter that is already set. Sets are
+// given in decreasing order of precedence and parameters already in connURL
+// take precedence over all of them. URL style connection strings, MySQL DSNs,
+// PostgreSQL key/value DSNs and SQL Server ADO and ODBC connection strings are
+// supported.
+func MergeParams(dbType, connURL string, params ...map[string]string) (string, error) {
+	style := paramStyleFor(dbType, connURL)
+
+	// ADO and ODBC keys are case-insensitive
+	normalize := func(k string) string {
+		if style == paramStyleADO || style == paramStyleODBC {
+			return strings.ToLower(k)
+		}
+		return k
+	}
+
+	existing := map[string]bool{}
+	switch style {
+	case paramStyleKeyValue:
+		for _, m := range kvParamRe.FindAllStringSubmatch(connURL, -1) {
+			existing[m[1]] = true
+		}
+	case paramStyleADO, paramStyleODBC:
+		for _, m := range adoParamRe.FindAllStringSubmatch(strings.TrimPrefix(connURL, "odbc:"), -1) {
+			existing[normalize(m[1])] = true
+		}
+	default:
+		if i := strings.LastIndex(connURL, "?"); i > strings.LastIndex(connURL, "/") {
+			query, _ := url.ParseQuery(connURL[i+1:])
+			for k := range query {
+				existing[k] = true
+			}
+		}
+	}
+
+	for _, set := range params {
+		keys := make([]string, 0, len(set))
+		for k := range set {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			if existing[normalize(k)] {
+				continue
+			}
+			existing[normalize(k)] = true
+
+			switch style {
+			case paramStyleKeyValue:
+				if connURL != "" {
+					connURL += " "
+				}
+				connURL += k + "=" + quoteKeyValue(set[k])
+			case paramStyleADO, paramStyleODBC:
+				if strings.ContainsAny(k, ";={}") {
+					return "", fmt.Errorf("invalid parameter name %q", k)
+				}
+				v := set[k]
+				if style == paramStyleODBC {
+					v = quoteODBC(v)
+				} else if strings.Contains(v, ";") {
+					return "", fmt.Errorf("value of parameter %q cannot contain ';'", k)
+				}
+				if connURL != "" && connURL != "odbc:" && !strings.HasSuffix(connURL, ";") {
+					connURL += ";"
+				}
+				connURL += k + "=" + v
+			default:
+				if strings.LastIndex(connURL, "?") > strings.LastIndex(connURL, "/") {
+					connURL += "&"
+				} else {
+					connURL += "?"
+				}
+				connURL += url.QueryEscape(k) + "=" + url.QueryEscape(set[k])
+			}
+		}
+	}
+
+	return connURL, nil
+}
+
+// quoteODBC braces v for use as a value in an ODBC connection string if
+// needed.
+func quoteODBC(v string) string {
+	if !strings.ContainsAny(v, ";{}") && strings.TrimSpace(v) == v {
+		return v
+	}
+
+	return "{" + strings.Replace(v, "}", "}}", -1) + "}"
+}
+
+// quoteKeyValue quotes v for use as a value in a key/value style DSN if
+// needed.
+func quoteKeyValue(v string) string {
+	if v != "" && !strings.ContainsAny(v, ` '\`) {
+		return v
+	}
+
+	v = strings.Replace(v, `\`, `\\`, -1)
+	v = strings.Replace(v, `'`, `\'`, -1)
+	return "'" + v + "'"
+}
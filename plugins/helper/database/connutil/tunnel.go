@@ -0,0 +1,173 @@
+package connutil
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// sshDialTimeout bounds how long connecting to the SSH host may take when the
+// context has no deadline.
+const sshDialTimeout = 30 * time.Second
+
+// sshTunnelConfig holds the parsed SSH settings of a SQLConnectionProducer.
+type sshTunnelConfig struct {
+	host   string
+	client *ssh.ClientConfig
+}
+
+// parseSSHTunnelConfig validates the SSH settings of c and builds the client
+// configuration used to connect to the SSH host. The host's key must be given,
+// as well as a private key, a password or both.
+func (c *SQLConnectionProducer) parseSSHTunnelConfig() (*sshTunnelConfig, error) {
+	if c.SSHUsername == "" {
+		return nil, fmt.Errorf("ssh_username is required with ssh_host")
+	}
+	if c.SSHHostKey == "" {
+		return nil, fmt.Errorf("ssh_host_key is required with ssh_host")
+	}
+	if c.Resolver != "" {
+		return nil, fmt.Errorf("resolver cannot be used with ssh_host, the SSH host resolves the database host")
+	}
+
+	hostKey, _, _, _, err := ssh.ParseAuthorizedKey([]byte(c.SSHHostKey))
+	if err != nil {
+		return nil, fmt.Errorf("invalid ssh_host_key: %s", err)
+	}
+
+	var auth []ssh.AuthMethod
+	if c.SSHPrivateKey != "" {
+		signer, err := ssh.ParsePrivateKey([]byte(c.SSHPrivateKey))
+		if err != nil {
+			// The error may quote the key, so it isn't returned
+			return nil, fmt.Errorf("invalid ssh_private_key")
+		}
+		auth = append(auth, ssh.PublicKeys(signer))
+	}
+	if c.SSHPassword != "" {
+		auth = append(auth, ssh.Password(c.SSHPassword))
+	}
+	if len(auth) == 0 {
+		return nil, fmt.Errorf("ssh_private_key or ssh_password is required with ssh_host")
+	}
+
+	host := c.SSHHost
+	if _, _, err := net.SplitHostPort(host); err != nil {
+		host = net.JoinHostPort(host, "22")
+	}
+
+	return &sshTunnelConfig{
+		host: host,
+		client: &ssh.ClientConfig{
+			User:            c.SSHUsername,
+			Auth:            auth,
+			HostKeyCallback: ssh.FixedHostKey(hostKey),
+		},
+	}, nil
+}
+
+// sshTunnel forwards the connections accepted on a local address to target
+// through an SSH host, so that databases only reachable from that host can be
+// connected to.
+type sshTunnel struct {
+	client   *ssh.Client
+	listener net.Listener
+	target   string
+
+	wg sync.WaitGroup
+}
+
+// openSSHTunnel connects to the SSH host of config and starts forwarding
+// connections to target from a local address on the loopback interface.
+func openSSHTunnel(ctx context.Context, config *sshTunnelConfig, target string) (*sshTunnel, error) {
+	dialer := &net.Dialer{}
+	if _, ok := ctx.Deadline(); !ok {
+		dialer.Timeout = sshDialTimeout
+	}
+
+	conn, err := dialer.DialContext(ctx, "tcp", config.host)
+	if err != nil {
+		return nil, fmt.Errorf("error connecting to ssh host: %s", err)
+	}
+
+	sshConn, chans, reqs, err := ssh.NewClientConn(conn, config.host, config.client)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("error connecting to ssh host: %s", err)
+	}
+	client := ssh.NewClient(sshConn, chans, reqs)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		client.Close()
+		return nil, err
+	}
+
+	t := &sshTunnel{
+		client:   client,
+		listener: listener,
+		target:   target,
+	}
+
+	t.wg.Add(1)
+	go t.serve()
+
+	return t, nil
+}
+
+// Addr returns the local address connections to the target are made to.
+func (t *sshTunnel) Addr() string {
+	return t.listener.Addr().String()
+}
+
+func (t *sshTunnel) serve() {
+	defer t.wg.Done()
+
+	for {
+		local, err := t.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		go t.forward(local)
+	}
+}
+
+// forward copies data between local and a new connection to the target
+// until either side is closed.
+func (t *sshTunnel) forward(local net.Conn) {
+	remote, err := t.client.Dial("tcp", t.target)
+	if err != nil {
+		local.Close()
+		return
+	}
+
+	done := make(chan struct{}, 2)
+	copyConn := func(dst, src net.Conn) {
+		io.Copy(dst, src)
+		done <- struct{}{}
+	}
+	go copyConn(remote, local)
+	go copyConn(local, remote)
+
+	// Once one direction is done, close both so the other returns too
+	<-done
+	local.Close()
+	remote.Close()
+	<-done
+}
+
+// Close stops accepting connections and disconnects from the SSH host, which
+// closes the connections being forwarded.
+func (t *sshTunnel) Close() error {
+	err := t.listener.Close()
+	t.client.Close()
+	t.wg.Wait()
+
+	return err
+}
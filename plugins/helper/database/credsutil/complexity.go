@@ -0,0 +1,186 @@
+package credsutil
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/hashicorp/vault/builtin/logical/database/dbplugin"
+)
+
+const (
+	passwordUppercase = "ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	passwordLowercase = "abcdefghijklmnopqrstuvwxyz"
+	passwordDigits    = "0123456789"
+
+	// passwordSpecial are the special characters generated passwords may
+	// contain. They are limited to those that need no quoting in the
+	// connection URLs and statements of the builtin plugins.
+	passwordSpecial = "-_."
+)
+
+// PasswordComplexity is the minimum number of characters of each class a
+// generated password must contain.
+type PasswordComplexity struct {
+	MinUppercase int `json:"min_uppercase" mapstructure:"min_uppercase" structs:"min_uppercase"`
+	MinLowercase int `json:"min_lowercase" mapstructure:"min_lowercase" structs:"min_lowercase"`
+	MinDigits    int `json:"min_digits" mapstructure:"min_digits" structs:"min_digits"`
+	MinSpecial   int `json:"min_special" mapstructure:"min_special" structs:"min_special"`
+}
+
+// complexityDefaults maps database types to the complexity their default
+// password policies require. Types not listed here require none.
+var complexityDefaults = map[string]PasswordComplexity{
+	// The Windows policy SQL Server enforces requires three of the four
+	// character classes
+	"mssql": {MinUppercase: 1, MinLowercase: 1, MinDigits: 1},
+	"hdb":   {MinUppercase: 1, MinLowercase: 1, MinDigits: 1},
+}
+
+// ComplexityFor returns the password complexity required by default for the
+// database type.
+func ComplexityFor(dbType string) PasswordComplexity {
+	return complexityDefaults[dbType]
+}
+
+// ComplexityFromUsernameConfig returns the password complexity requested by
+// the role credentials are generated for.
+func ComplexityFromUsernameConfig(config dbplugin.UsernameConfig) PasswordComplexity {
+	return PasswordComplexity{
+		MinUppercase: int(config.PasswordMinUppercase),
+		MinLowercase: int(config.PasswordMinLowercase),
+		MinDigits:    int(config.PasswordMinDigits),
+		MinSpecial:   int(config.PasswordMinSpecial),
+	}
+}
+
+// IsZero reports whether c requires nothing.
+func (c PasswordComplexity) IsZero() bool {
+	return c == PasswordComplexity{}
+}
+
+// Length returns the shortest password that can satisfy c.
+func (c PasswordComplexity) Length() int {
+	return c.MinUppercase + c.MinLowercase + c.MinDigits + c.MinSpecial
+}
+
+// Merge returns the complexity satisfying both c and other.
+func (c PasswordComplexity) Merge(other PasswordComplexity) PasswordComplexity {
+	max := func(a, b int) int {
+		if a > b {
+			return a
+		}
+		return b
+	}
+
+	return PasswordComplexity{
+		MinUppercase: max(c.MinUppercase, other.MinUppercase),
+		MinLowercase: max(c.MinLowercase, other.MinLowercase),
+		MinDigits:    max(c.MinDigits, other.MinDigits),
+		MinSpecial:   max(c.MinSpecial, other.MinSpecial),
+	}
+}
+
+// Validate checks that none of the minimums of c is negative and that a
+// password of length characters can satisfy them.
+func (c PasswordComplexity) Validate(length int) error {
+	if c.MinUppercase < 0 || c.MinLowercase < 0 || c.MinDigits < 0 || c.MinSpecial < 0 {
+		return fmt.Errorf("password complexity minimums cannot be negative")
+	}
+	if c.Length() > length {
+		return fmt.Errorf("password complexity requires at least %d characters, but generated passwords are %d characters long", c.Length(), length)
+	}
+
+	return nil
+}
+
+// Satisfied reports whether password contains enough characters of each
+// class. Any character other than an ASCII letter or digit is special.
+func (c PasswordComplexity) Satisfied(password string) bool {
+	var have PasswordComplexity
+	for _, r := range password {
+		switch {
+		case strings.ContainsRune(passwordUppercase, r):
+			have.MinUppercase++
+		case strings.ContainsRune(passwordLowercase, r):
+			have.MinLowercase++
+		case strings.ContainsRune(passwordDigits, r):
+			have.MinDigits++
+		default:
+			have.MinSpecial++
+		}
+	}
+
+	return have.MinUppercase >= c.MinUppercase && have.MinLowercase >= c.MinLowercase &&
+		have.MinDigits >= c.MinDigits && have.MinSpecial >= c.MinSpecial
+}
+
+// RandomComplexPassword returns a random password of the provided length
+// satisfying c. The required characters of each class are placed at random
+// positions, and the remaining characters are any of [A-Za-z0-9].
+func RandomComplexPassword(length int, c PasswordComplexity) (string, error) {
+	return RandomComplexPasswordFrom(rand.Reader, length, c)
+}
+
+// RandomComplexPasswordFrom is like RandomComplexPassword but reads its
+// randomness from r. r must be a cryptographically secure source everywhere
+// but in tests.
+func RandomComplexPasswordFrom(r io.Reader, length int, c PasswordComplexity) (string, error) {
+	if length < minStrLen {
+		return "", fmt.Errorf("minimum length of %d is required", minStrLen)
+	}
+	if err := c.Validate(length); err != nil {
+		return "", err
+	}
+
+	classes := []struct {
+		chars string
+		count int
+	}{
+		{passwordUppercase, c.MinUppercase},
+		{passwordLowercase, c.MinLowercase},
+		{passwordDigits, c.MinDigits},
+		{passwordSpecial, c.MinSpecial},
+		{passwordUppercase + passwordLowercase + passwordDigits, length - c.Length()},
+	}
+
+	password := make([]byte, 0, length)
+	for _, class := range classes {
+		for i := 0; i < class.count; i++ {
+			n, err := randomIndex(r, len(class.chars))
+			if err != nil {
+				return "", err
+			}
+			password = append(password, class.chars[n])
+		}
+	}
+
+	// Shuffle so that the required characters aren't always in front
+	for i := len(password) - 1; i > 0; i-- {
+		j, err := randomIndex(r, i+1)
+		if err != nil {
+			return "", err
+		}
+		password[i], password[j] = password[j], password[i]
+	}
+
+	return string(password), nil
+}
+
+// randomIndex returns a uniformly random integer in [0, n) read from r.
+func randomIndex(r io.Reader, n int) (int, error) {
+	// Reject values from the incomplete last range, which would bias the
+	// result towards low indexes
+	limit := 65536 - 65536%n
+	var b [2]byte
+	for {
+		if _, err := io.ReadFull(r, b[:]); err != nil {
+			return 0, err
+		}
+		if v := int(binary.BigEndian.Uint16(b[:])); v < limit {
+			return v % n, nil
+		}
+	}
+}
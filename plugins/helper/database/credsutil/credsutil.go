@@ -2,6 +2,7 @@ package credsutil
 
 import (
 	"crypto/rand"
+	"io"
 	"time"
 
 	"fmt"
@@ -23,11 +24,71 @@ const (
 	minStrLen = 10
 )
 
+// MinPasswordLength is the shortest password GeneratePassword can return.
+const MinPasswordLength = minStrLen
+
+// GeneratePassword generates a password with producer and shortens it to
+// maxLength, if positive, so that databases limiting the length of passwords
+// don't silently truncate it themselves. maxLength may not be less than
+// MinPasswordLength.
+func GeneratePassword(producer CredentialsProducer, maxLength int) (string, error) {
+	return GeneratePasswordWithComplexity(producer, maxLength, PasswordComplexity{})
+}
+
+// complexPasswordProducer is implemented by credentials producers that can
+// generate passwords satisfying a PasswordComplexity.
+type complexPasswordProducer interface {
+	// PasswordComplexity returns the complexity the database requires of
+	// passwords by default.
+	PasswordComplexity() PasswordComplexity
+	GenerateComplexPassword(length int, complexity PasswordComplexity) (string, error)
+}
+
+// GeneratePasswordWithComplexity is like GeneratePassword, but the password
+// also satisfies complexity along with the complexity the database requires
+// by default. Passwords generated by producer that already satisfy both are
+// returned as is; otherwise a password of the same length is generated with
+// the required characters, failing if that length is too short for them.
+func GeneratePasswordWithComplexity(producer CredentialsProducer, maxLength int, complexity PasswordComplexity) (string, error) {
+	if maxLength > 0 && maxLength < MinPasswordLength {
+		return "", fmt.Errorf("maximum password length of %d is less than the minimum of %d", maxLength, MinPasswordLength)
+	}
+
+	complexProducer, ok := producer.(complexPasswordProducer)
+	if ok {
+		complexity = complexity.Merge(complexProducer.PasswordComplexity())
+	} else if !complexity.IsZero() {
+		return "", fmt.Errorf("password complexity is not supported by this database")
+	}
+
+	password, err := producer.GeneratePassword()
+	if err != nil {
+		return "", err
+	}
+
+	if maxLength > 0 && len(password) > maxLength {
+		password = password[:maxLength]
+	}
+
+	if complexity.Satisfied(password) {
+		return password, nil
+	}
+
+	return complexProducer.GenerateComplexPassword(len(password), complexity)
+}
+
 // RandomAlphaNumeric returns a random string of characters [A-Za-z0-9-]
 // of the provided length. The string generated takes up to 4 characters
 // of space that are predefined and prepended to ensure password
 // character requirements. It also requires a min length of 10 characters.
 func RandomAlphaNumeric(length int, prependA1a bool) (string, error) {
+	return RandomAlphaNumericFrom(rand.Reader, length, prependA1a)
+}
+
+// RandomAlphaNumericFrom is like RandomAlphaNumeric but reads its randomness
+// from r. It allows tests to generate predictable strings; r must be a
+// cryptographically secure source everywhere else.
+func RandomAlphaNumericFrom(r io.Reader, length int, prependA1a bool) (string, error) {
 	if length < minStrLen {
 		return "", fmt.Errorf("minimum length of %d is required", minStrLen)
 	}
@@ -48,7 +109,7 @@ func RandomAlphaNumeric(length int, prependA1a bool) (string, error) {
 		// re-roll.
 		c := length + len(reqStr)
 		bArr := make([]byte, c)
-		_, err := rand.Read(bArr)
+		_, err := io.ReadFull(r, bArr)
 		if err != nil {
 			return "", err
 		}
@@ -1,8 +1,11 @@
 package credsutil
 
 import (
+	"bytes"
 	"strings"
 	"testing"
+
+	"github.com/hashicorp/vault/builtin/logical/database/dbplugin"
 )
 
 func TestRandomAlphaNumeric(t *testing.T) {
@@ -38,3 +41,215 @@ func TestRandomAlphaNumeric(t *testing.T) {
 		t.Fatalf("Expected %s not to contain %s", s, reqStr)
 	}
 }
+
+func TestRandomAlphaNumericFrom(t *testing.T) {
+	// Every byte maps to '0' ((0x60 >> 1) | 0x30)
+	r := bytes.NewReader(bytes.Repeat([]byte{0x60}, 64))
+	s, err := RandomAlphaNumericFrom(r, 12, true)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if s != reqStr+"00000000" {
+		t.Fatalf("Unexpected string: %s", s)
+	}
+
+	// A source that runs out fails rather than returning a short string
+	r = bytes.NewReader([]byte{0x60})
+	if _, err := RandomAlphaNumericFrom(r, 12, false); err == nil {
+		t.Fatal("Expected error from exhausted source")
+	}
+}
+
+func TestGeneratePassword_Rand(t *testing.T) {
+	scp := &SQLCredentialsProducer{
+		Rand: bytes.NewReader(bytes.Repeat([]byte{0xc2}, 64)),
+	}
+
+	// 0xc2 >> 1 | 0x30 is 'q'
+	password, err := scp.GeneratePassword()
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if password != reqStr+strings.Repeat("q", 16) {
+		t.Fatalf("Unexpected password: %s", password)
+	}
+}
+
+func TestGeneratePassword_MaxLength(t *testing.T) {
+	scp := &SQLCredentialsProducer{}
+
+	password, err := GeneratePassword(scp, 12)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if len(password) != 12 || !strings.HasPrefix(password, reqStr) {
+		t.Fatalf("Unexpected password: %s", password)
+	}
+
+	// Unlimited or longer limits leave the password as generated
+	for _, maxLength := range []int{0, 64} {
+		password, err := GeneratePassword(scp, maxLength)
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		if len(password) != 20 {
+			t.Fatalf("Unexpected password for limit %d: %s", maxLength, password)
+		}
+	}
+
+	if _, err := GeneratePassword(scp, MinPasswordLength-1); err == nil {
+		t.Fatal("Expected error for a limit below the minimum")
+	}
+}
+
+func TestGeneratePasswordWithComplexity(t *testing.T) {
+	scp := &SQLCredentialsProducer{}
+
+	// Passwords already satisfying the complexity are kept as generated
+	password, err := GeneratePasswordWithComplexity(scp, 0, PasswordComplexity{MinUppercase: 1, MinSpecial: 1})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if !strings.HasPrefix(password, reqStr) {
+		t.Fatalf("Unexpected password: %s", password)
+	}
+
+	complexity := PasswordComplexity{MinUppercase: 3, MinLowercase: 2, MinDigits: 4, MinSpecial: 3}
+	for _, maxLength := range []int{0, 12} {
+		password, err := GeneratePasswordWithComplexity(scp, maxLength, complexity)
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		expected := GeneratedPasswordLength
+		if maxLength > 0 {
+			expected = maxLength
+		}
+		if len(password) != expected || !complexity.Satisfied(password) {
+			t.Fatalf("Unexpected password for limit %d: %s", maxLength, password)
+		}
+		if strings.Trim(password, passwordUppercase+passwordLowercase+passwordDigits+passwordSpecial) != "" {
+			t.Fatalf("Unexpected characters in password: %s", password)
+		}
+	}
+
+	// The database type's defaults apply on top of the requested complexity
+	scp.Type = "mssql"
+	password, err = GeneratePasswordWithComplexity(scp, 0, PasswordComplexity{MinDigits: 10})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if !(PasswordComplexity{MinUppercase: 1, MinLowercase: 1, MinDigits: 10}).Satisfied(password) {
+		t.Fatalf("Unexpected password: %s", password)
+	}
+
+	_, err = GeneratePasswordWithComplexity(scp, 12, PasswordComplexity{MinDigits: 11})
+	if err == nil || !strings.Contains(err.Error(), "requires at least 13 characters") {
+		t.Fatalf("Expected complexity error, got: %v", err)
+	}
+}
+
+func TestPasswordComplexity_Validate(t *testing.T) {
+	if err := (PasswordComplexity{MinUppercase: 5, MinSpecial: 5}).Validate(10); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if err := (PasswordComplexity{MinUppercase: 5, MinSpecial: 6}).Validate(10); err == nil {
+		t.Fatal("Expected error for minimums longer than the password")
+	}
+	if err := (PasswordComplexity{MinDigits: -1}).Validate(10); err == nil {
+		t.Fatal("Expected error for a negative minimum")
+	}
+}
+
+func TestGenerateUsername_Prefix(t *testing.T) {
+	scp := &SQLCredentialsProducer{
+		DisplayNameLen: 8,
+		RoleNameLen:    8,
+		UsernameLen:    20,
+		Separator:      "-",
+	}
+
+	username, err := scp.GenerateUsername(dbplugin.UsernameConfig{
+		DisplayName:    "token",
+		RoleName:       "role",
+		UsernamePrefix: "v_",
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if !strings.HasPrefix(username, "v_v-token-role-") {
+		t.Fatalf("Expected username to start with the prefix, got: %s", username)
+	}
+	if len(username) != 20 {
+		t.Fatalf("Unexpected length of username, expected 20, got: %s", username)
+	}
+
+	_, err = scp.GenerateUsername(dbplugin.UsernameConfig{
+		UsernamePrefix: "this-prefix-is-too-long",
+	})
+	if err != ErrUsernamePrefixTooLong {
+		t.Fatalf("Expected ErrUsernamePrefixTooLong, got: %v", err)
+	}
+}
+
+func TestIdentifierRules_Apply(t *testing.T) {
+	postgres, ok := IdentifierRulesFor("postgres")
+	if !ok {
+		t.Fatal("expected rules for postgres")
+	}
+	username, err := postgres.Apply("v-user@example.com-role")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if username != "v-user-example.com-role" {
+		t.Fatalf("Unexpected username: %s", username)
+	}
+
+	username, err = postgres.Apply(strings.Repeat("a", 70))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if len(username) != 63 {
+		t.Fatalf("Expected username truncated to 63 characters, got: %s", username)
+	}
+
+	if _, err := postgres.Apply("public"); err != ErrReservedIdentifier {
+		t.Fatalf("Expected ErrReservedIdentifier, got: %v", err)
+	}
+
+	hana, _ := IdentifierRulesFor("hdb")
+	username, err = hana.Apply("v_token.name_role")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if username != "v_token_name_role" {
+		t.Fatalf("Unexpected username: %s", username)
+	}
+	if _, err := hana.Apply("1_token"); err == nil {
+		t.Fatal("Expected error for username not starting with a letter")
+	}
+
+	if _, ok := IdentifierRulesFor("mongodb"); ok {
+		t.Fatal("Expected no rules for mongodb")
+	}
+}
+
+func TestGenerateUsername_Type(t *testing.T) {
+	scp := &SQLCredentialsProducer{
+		DisplayNameLen: 20,
+		RoleNameLen:    8,
+		UsernameLen:    63,
+		Separator:      "-",
+		Type:           "postgres",
+	}
+
+	username, err := scp.GenerateUsername(dbplugin.UsernameConfig{
+		DisplayName: "ldap-user@example",
+		RoleName:    "role",
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if !strings.HasPrefix(username, "v-ldap-user-example-role-") {
+		t.Fatalf("Expected disallowed characters to be replaced, got: %s", username)
+	}
+}
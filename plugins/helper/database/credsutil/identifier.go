@@ -0,0 +1,111 @@
+package credsutil
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	ErrReservedIdentifier = errors.New("generated username is a reserved word")
+)
+
+// IdentifierRules describes the usernames a database type accepts, as they
+// are used in the statements documented for its plugin. Databases whose
+// statements quote the username accept more characters than those that don't.
+type IdentifierRules struct {
+	// MaxLength is the maximum length of a username. Zero is unbounded.
+	MaxLength int
+
+	// Extra are the characters allowed besides ASCII letters and digits.
+	// Other characters are replaced with Replacement, or removed if it is
+	// zero.
+	Extra       string
+	Replacement rune
+
+	// LeadingLetter requires usernames to start with a letter.
+	LeadingLetter bool
+
+	// Reserved lists upper case words that can't be used as a username.
+	Reserved []string
+}
+
+// identifierRules maps database types to the rules for their usernames.
+// Types not listed here have no rules applied.
+var identifierRules = map[string]IdentifierRules{
+	"postgres": {
+		MaxLength:   63,
+		Extra:       "-_.",
+		Replacement: '-',
+		Reserved:    []string{"ALL", "PUBLIC", "SESSION_USER", "CURRENT_USER", "CURRENT_ROLE", "NONE"},
+	},
+	"mysql": {
+		MaxLength:   32,
+		Extra:       "-_.",
+		Replacement: '-',
+	},
+	"mssql": {
+		MaxLength:   128,
+		Extra:       "-_.",
+		Replacement: '-',
+		Reserved:    []string{"PUBLIC", "GUEST", "DBO", "SA", "SYS", "INFORMATION_SCHEMA"},
+	},
+	"hdb": {
+		MaxLength:     127,
+		Extra:         "_",
+		Replacement:   '_',
+		LeadingLetter: true,
+		Reserved:      []string{"PUBLIC", "SYS", "SYSTEM", "USER", "ROLE", "SELECT", "TABLE"},
+	},
+	"cassandra": {
+		MaxLength:   100,
+		Extra:       "_",
+		Replacement: '_',
+		Reserved:    []string{"ALL", "CASSANDRA", "SUPERUSER", "NOSUPERUSER"},
+	},
+}
+
+// IdentifierRulesFor returns the username rules for dbType.
+func IdentifierRulesFor(dbType string) (IdentifierRules, bool) {
+	rules, ok := identifierRules[dbType]
+	return rules, ok
+}
+
+// Apply adjusts username to the rules by replacing disallowed characters and
+// truncating it to the maximum length. It returns an error if the result still
+// isn't valid, for example because it is a reserved word.
+func (r IdentifierRules) Apply(username string) (string, error) {
+	username = strings.Map(func(c rune) rune {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+			return c
+		case strings.ContainsRune(r.Extra, c):
+			return c
+		case r.Replacement != 0:
+			return r.Replacement
+		default:
+			return -1
+		}
+	}, username)
+
+	if r.MaxLength > 0 && len(username) > r.MaxLength {
+		username = username[:r.MaxLength]
+	}
+
+	if username == "" {
+		return "", errors.New("generated username is empty")
+	}
+	if r.LeadingLetter {
+		c := username[0]
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z') {
+			return "", fmt.Errorf("generated username %q must start with a letter", username)
+		}
+	}
+	for _, word := range r.Reserved {
+		if strings.EqualFold(username, word) {
+			return "", ErrReservedIdentifier
+		}
+	}
+
+	return username, nil
+}
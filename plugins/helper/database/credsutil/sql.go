@@ -1,7 +1,10 @@
 package credsutil
 
 import (
+	"crypto/rand"
+	"errors"
 	"fmt"
+	"io"
 	"time"
 
 	"github.com/hashicorp/vault/builtin/logical/database/dbplugin"
@@ -9,6 +12,14 @@ import (
 
 const (
 	NoneLength int = -1
+
+	// GeneratedPasswordLength is the length of the passwords generated by
+	// SQLCredentialsProducer, before any maximum length is applied.
+	GeneratedPasswordLength = 20
+)
+
+var (
+	ErrUsernamePrefixTooLong = errors.New("username prefix leaves too little room for the generated username")
 )
 
 // SQLCredentialsProducer implements CredentialsProducer and provides a generic credentials producer for most sql database types.
@@ -17,9 +28,32 @@ type SQLCredentialsProducer struct {
 	RoleNameLen    int
 	UsernameLen    int
 	Separator      string
+
+	// Type is the database type the usernames are generated for. Generated
+	// usernames are adjusted to the identifier rules of the type, if any.
+	Type string
+
+	// Rand is the source of randomness for generated usernames and
+	// passwords. It defaults to crypto/rand and should only be set by tests.
+	Rand io.Reader
+}
+
+func (scp *SQLCredentialsProducer) randomAlphaNumeric(length int, prependA1a bool) (string, error) {
+	r := scp.Rand
+	if r == nil {
+		r = rand.Reader
+	}
+
+	return RandomAlphaNumericFrom(r, length, prependA1a)
 }
 
 func (scp *SQLCredentialsProducer) GenerateUsername(config dbplugin.UsernameConfig) (string, error) {
+	// Ensure the prefix still leaves room for a meaningful generated portion
+	// within the database's username length limit.
+	if scp.UsernameLen > 0 && len(config.UsernamePrefix) > scp.UsernameLen-minStrLen {
+		return "", ErrUsernamePrefixTooLong
+	}
+
 	username := "v"
 
 	displayName := config.DisplayName
@@ -44,22 +78,27 @@ func (scp *SQLCredentialsProducer) GenerateUsername(config dbplugin.UsernameConf
 		username = fmt.Sprintf("%s%s%s", username, scp.Separator, roleName)
 	}
 
-	userUUID, err := RandomAlphaNumeric(20, false)
+	userUUID, err := scp.randomAlphaNumeric(20, false)
 	if err != nil {
 		return "", err
 	}
 
 	username = fmt.Sprintf("%s%s%s", username, scp.Separator, userUUID)
 	username = fmt.Sprintf("%s%s%s", username, scp.Separator, fmt.Sprint(time.Now().UTC().Unix()))
+	username = config.UsernamePrefix + username
 	if scp.UsernameLen > 0 && len(username) > scp.UsernameLen {
 		username = username[:scp.UsernameLen]
 	}
 
+	if rules, ok := IdentifierRulesFor(scp.Type); ok {
+		return rules.Apply(username)
+	}
+
 	return username, nil
 }
 
 func (scp *SQLCredentialsProducer) GeneratePassword() (string, error) {
-	password, err := RandomAlphaNumeric(20, true)
+	password, err := scp.randomAlphaNumeric(GeneratedPasswordLength, true)
 	if err != nil {
 		return "", err
 	}
@@ -67,6 +106,23 @@ func (scp *SQLCredentialsProducer) GeneratePassword() (string, error) {
 	return password, nil
 }
 
+// PasswordComplexity returns the complexity the database type requires of
+// passwords by default.
+func (scp *SQLCredentialsProducer) PasswordComplexity() PasswordComplexity {
+	return ComplexityFor(scp.Type)
+}
+
+// GenerateComplexPassword generates a password of the provided length that
+// satisfies complexity.
+func (scp *SQLCredentialsProducer) GenerateComplexPassword(length int, complexity PasswordComplexity) (string, error) {
+	r := scp.Rand
+	if r == nil {
+		r = rand.Reader
+	}
+
+	return RandomComplexPasswordFrom(r, length, complexity)
+}
+
 func (scp *SQLCredentialsProducer) GenerateExpiration(ttl time.Time) (string, error) {
 	return ttl.Format("2006-01-02 15:04:05-0700"), nil
 }
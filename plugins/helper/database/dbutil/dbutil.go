@@ -3,18 +3,308 @@ package dbutil
 import (
 	"errors"
 	"fmt"
+	"regexp"
+	"runtime"
+	"strconv"
 	"strings"
+	"time"
+
+	"github.com/hashicorp/vault/builtin/logical/database/dbplugin"
+	"github.com/hashicorp/vault/helper/strutil"
+	"github.com/hashicorp/vault/plugins/helper/database/credsutil"
+	"github.com/hashicorp/vault/version"
 )
 
 var (
 	ErrEmptyCreationStatement = errors.New("empty creation statements")
 )
 
-// Query templates a query for us.
+// randomTokenLength is the length of the "random" value substituted into
+// creation statements.
+const randomTokenLength = 16
+
+// templateCallRe matches a template function call, such as {{upper name}} or
+// {{timestamp "2006-01-02"}}, capturing the function and its argument.
+var templateCallRe = regexp.MustCompile(`\{\{\s*([A-Za-z_]+)\s+([^{}\s][^{}]*?)\s*\}\}`)
+
+// templateKeyRe matches the name of a value substituted into statements.
+var templateKeyRe = regexp.MustCompile(`^[A-Za-z_]+$`)
+
+// templateFuncs are the functions statements may call. Each takes a single
+// argument and the values being substituted, and reports false if the
+// argument is invalid.
+var templateFuncs = map[string]func(arg string, data map[string]string) (string, bool){
+	// upper uppercases the value of the named key, as in {{upper name}}.
+	"upper": func(arg string, data map[string]string) (string, bool) {
+		v, ok := data[arg]
+		return strings.ToUpper(v), ok
+	},
+	// lower lowercases the value of the named key, as in {{lower name}}.
+	"lower": func(arg string, data map[string]string) (string, bool) {
+		v, ok := data[arg]
+		return strings.ToLower(v), ok
+	},
+	// timestamp formats the "timestamp" value, or the current time if there
+	// is none, with a Go time layout, as in {{timestamp "2006-01-02"}}.
+	"timestamp": func(arg string, data map[string]string) (string, bool) {
+		layout, err := strconv.Unquote(arg)
+		if err != nil {
+			return "", false
+		}
+		now, err := time.Parse(time.RFC3339, data["timestamp"])
+		if err != nil {
+			now = time.Now().UTC()
+		}
+		return now.Format(layout), true
+	},
+}
+
+// Query templates a query for us. Each {{key}} is replaced with the value of
+// key in data, and each call of one of the template functions with its result.
+// Placeholders that don't match either are left as they are.
 func QueryHelper(tpl string, data map[string]string) string {
+	tpl = templateCallRe.ReplaceAllStringFunc(tpl, func(call string) string {
+		m := templateCallRe.FindStringSubmatch(call)
+		fn, ok := templateFuncs[m[1]]
+		if !ok {
+			return call
+		}
+		v, ok := fn(m[2], data)
+		if !ok {
+			return call
+		}
+		return v
+	})
+
 	for k, v := range data {
 		tpl = strings.Replace(tpl, fmt.Sprintf("{{%s}}", k), v, -1)
 	}
 
 	return tpl
 }
+
+// CreationData returns the values substituted into the statements that create
+// a user: those in data, plus "timestamp", the current time in RFC 3339
+// format, and "random", a random alphanumeric token. They are generated once
+// so that every statement run for the user sees the same values.
+func CreationData(data map[string]string) (map[string]string, error) {
+	random, err := credsutil.RandomAlphaNumeric(randomTokenLength, false)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := make(map[string]string, len(data)+2)
+	ret["timestamp"] = time.Now().UTC().Format(time.RFC3339)
+	ret["random"] = random
+	for k, v := range data {
+		ret[k] = v
+	}
+
+	return ret, nil
+}
+
+// ValidateTemplates checks that every template function called by statements
+// exists and is given a valid argument, so that mistakes are caught when the
+// statements are written rather than when they are run.
+func ValidateTemplates(statements dbplugin.Statements) error {
+	stmts := []string{
+		statements.CreationStatements,
+		statements.RevocationStatements,
+		statements.RollbackStatements,
+		statements.RenewStatements,
+	}
+	stmts = append(stmts, statements.CreateStatements...)
+	stmts = append(stmts, statements.GrantStatements...)
+	stmts = append(stmts, statements.RevokeStatements...)
+	for _, step := range statements.RevocationSteps {
+		stmts = append(stmts, step.Statement)
+	}
+
+	for _, stmt := range stmts {
+		for _, m := range templateCallRe.FindAllStringSubmatch(stmt, -1) {
+			switch m[1] {
+			case "upper", "lower":
+				if !templateKeyRe.MatchString(m[2]) {
+					return fmt.Errorf("invalid template %q: %s takes the name of a value, such as name", m[0], m[1])
+				}
+			case "timestamp":
+				if _, err := strconv.Unquote(m[2]); err != nil {
+					return fmt.Errorf("invalid template %q: timestamp takes a quoted time layout, such as \"2006-01-02\"", m[0])
+				}
+			default:
+				return fmt.Errorf("invalid template %q: unknown function %q", m[0], m[1])
+			}
+		}
+	}
+
+	return nil
+}
+
+// ReservedVariables are the names of the values plugins substitute into
+// statements, which statement variables can't shadow.
+var ReservedVariables = []string{"name", "username", "password", "expiration", "timestamp", "random"}
+
+// ValidateVariables checks that the names of statement variables can be
+// used in templates and don't shadow any of the ReservedVariables.
+func ValidateVariables(vars map[string]string) error {
+	for k := range vars {
+		if !templateKeyRe.MatchString(k) {
+			return fmt.Errorf("invalid statement variable %q: names may only contain letters and underscores", k)
+		}
+		if strutil.StrListContains(ReservedVariables, k) {
+			return fmt.Errorf("invalid statement variable %q: the name is reserved", k)
+		}
+	}
+
+	return nil
+}
+
+// SubstituteVariables returns a copy of statements in which each {{key}} of
+// vars, and each call of a template function on it such as {{upper key}}, is
+// replaced. Every other template is left for the plugin to substitute.
+func SubstituteVariables(statements dbplugin.Statements, vars map[string]string) dbplugin.Statements {
+	if len(vars) == 0 {
+		return statements
+	}
+
+	substitute := func(tpl string) string {
+		tpl = templateCallRe.ReplaceAllStringFunc(tpl, func(call string) string {
+			m := templateCallRe.FindStringSubmatch(call)
+			if _, ok := vars[m[2]]; !ok {
+				return call
+			}
+			fn, ok := templateFuncs[m[1]]
+			if !ok {
+				return call
+			}
+			v, ok := fn(m[2], vars)
+			if !ok {
+				return call
+			}
+			return v
+		})
+
+		for k, v := range vars {
+			tpl = strings.Replace(tpl, fmt.Sprintf("{{%s}}", k), v, -1)
+		}
+
+		return tpl
+	}
+	substituteAll := func(tpls []string) []string {
+		if tpls == nil {
+			return nil
+		}
+		ret := make([]string, len(tpls))
+		for i, tpl := range tpls {
+			ret[i] = substitute(tpl)
+		}
+		return ret
+	}
+
+	ret := dbplugin.Statements{
+		CreationStatements:   substitute(statements.CreationStatements),
+		RevocationStatements: substitute(statements.RevocationStatements),
+		RollbackStatements:   substitute(statements.RollbackStatements),
+		RenewStatements:      substitute(statements.RenewStatements),
+		CreateStatements:     substituteAll(statements.CreateStatements),
+		GrantStatements:      substituteAll(statements.GrantStatements),
+		RevokeStatements:     substituteAll(statements.RevokeStatements),
+	}
+	for _, step := range statements.RevocationSteps {
+		ret.RevocationSteps = append(ret.RevocationSteps, &dbplugin.RevocationStep{
+			Statement:       substitute(step.Statement),
+			ContinueOnError: step.ContinueOnError,
+		})
+	}
+
+	return ret
+}
+
+// CreationQueries returns the individual queries used to create a user, in
+// the order they must be executed: the legacy creation_statements blob first,
+// followed by the create_statements list and finally the grant_statements
+// list.
+func CreationQueries(statements dbplugin.Statements) []string {
+	var stmts []string
+	stmts = append(stmts, statements.CreationStatements)
+	stmts = append(stmts, statements.CreateStatements...)
+	stmts = append(stmts, statements.GrantStatements...)
+
+	return splitQueries(stmts)
+}
+
+// RevocationQueries returns the individual queries used to revoke a user, in
+// the order they must be executed: the revoke_statements list first, followed
+// by the legacy revocation_statements blob.
+func RevocationQueries(statements dbplugin.Statements) []string {
+	var stmts []string
+	stmts = append(stmts, statements.RevokeStatements...)
+	stmts = append(stmts, statements.RevocationStatements)
+
+	return splitQueries(stmts)
+}
+
+// RevocationQuery is a single query run to revoke a user.
+type RevocationQuery struct {
+	Query string
+
+	// ContinueOnError is set if the revocation goes on when the query
+	// fails, such as when it drops something that is already gone.
+	ContinueOnError bool
+}
+
+// RevocationSteps returns the individual queries used to revoke a user, in the
+// order they must be executed: the revocation_steps first, followed by the
+// queries returned by RevocationQueries. Each query of a step is tolerant of
+// errors if the step is.
+func RevocationSteps(statements dbplugin.Statements) []RevocationQuery {
+	var queries []RevocationQuery
+	for _, step := range statements.RevocationSteps {
+		for _, query := range splitQueries([]string{step.Statement}) {
+			queries = append(queries, RevocationQuery{
+				Query:           query,
+				ContinueOnError: step.ContinueOnError,
+			})
+		}
+	}
+	for _, query := range RevocationQueries(statements) {
+		queries = append(queries, RevocationQuery{Query: query})
+	}
+
+	return queries
+}
+
+// splitQueries splits each of the given statements on ";" and returns the
+// non-empty, trimmed queries in order.
+func splitQueries(stmts []string) []string {
+	var queries []string
+	for _, stmt := range stmts {
+		for _, query := range strutil.ParseArbitraryStringSlice(stmt, ";") {
+			query = strings.TrimSpace(query)
+			if len(query) == 0 {
+				continue
+			}
+			queries = append(queries, query)
+		}
+	}
+
+	return queries
+}
+
+// BuildInfo returns the version and revision of the Vault source the plugin
+// was built from, along with the Go version it was built with.
+func BuildInfo() *dbplugin.BuildInfo {
+	v := version.GetVersion()
+	return &dbplugin.BuildInfo{
+		Version:   v.VersionNumber(),
+		Revision:  v.Revision,
+		GoVersion: runtime.Version(),
+	}
+}
+
+// SQLCapabilities returns the capabilities of db, a plugin for a SQL database
+// whose statements are run with the queries returned by CreationQueries and
+// RevocationSteps.
+func SQLCapabilities(db dbplugin.Database) []string {
+	return append(dbplugin.ImplementedCapabilities(db), dbplugin.CapabilityStatementLists, dbplugin.CapabilityRevocationSteps)
+}
@@ -0,0 +1,171 @@
+package dbutil
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/builtin/logical/database/dbplugin"
+)
+
+func TestCreationQueries(t *testing.T) {
+	statements := dbplugin.Statements{
+		CreationStatements: "CREATE ROLE foo; ALTER ROLE foo;",
+		CreateStatements:   []string{"SET foo", ""},
+		GrantStatements:    []string{"GRANT a TO foo;GRANT b TO foo"},
+	}
+
+	expected := []string{"CREATE ROLE foo", "ALTER ROLE foo", "SET foo", "GRANT a TO foo", "GRANT b TO foo"}
+	if actual := CreationQueries(statements); !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("bad: expected %#v, got %#v", expected, actual)
+	}
+
+	if actual := CreationQueries(dbplugin.Statements{}); len(actual) != 0 {
+		t.Fatalf("expected no queries, got %#v", actual)
+	}
+}
+
+func TestRevocationQueries(t *testing.T) {
+	statements := dbplugin.Statements{
+		RevocationStatements: "DROP ROLE foo;",
+		RevokeStatements:     []string{"REVOKE a FROM foo", "REVOKE b FROM foo"},
+	}
+
+	expected := []string{"REVOKE a FROM foo", "REVOKE b FROM foo", "DROP ROLE foo"}
+	if actual := RevocationQueries(statements); !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("bad: expected %#v, got %#v", expected, actual)
+	}
+}
+
+func TestRevocationSteps(t *testing.T) {
+	statements := dbplugin.Statements{
+		RevocationSteps: []*dbplugin.RevocationStep{
+			{Statement: "REVOKE a FROM foo; REVOKE b FROM foo"},
+			{Statement: "DROP OWNED BY foo;", ContinueOnError: true},
+		},
+		RevocationStatements: "DROP ROLE foo;",
+		RevokeStatements:     []string{"REVOKE c FROM foo"},
+	}
+
+	expected := []RevocationQuery{
+		{Query: "REVOKE a FROM foo"},
+		{Query: "REVOKE b FROM foo"},
+		{Query: "DROP OWNED BY foo", ContinueOnError: true},
+		{Query: "REVOKE c FROM foo"},
+		{Query: "DROP ROLE foo"},
+	}
+	if actual := RevocationSteps(statements); !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("bad: expected %#v, got %#v", expected, actual)
+	}
+
+	if actual := RevocationSteps(dbplugin.Statements{}); len(actual) != 0 {
+		t.Fatalf("expected no queries, got %#v", actual)
+	}
+}
+
+func TestQueryHelper(t *testing.T) {
+	data := map[string]string{
+		"name":      "v-Token-Foo",
+		"timestamp": "2018-03-04T05:06:07Z",
+	}
+
+	cases := map[string]string{
+		`CREATE ROLE "{{name}}"`:               `CREATE ROLE "v-Token-Foo"`,
+		`CREATE USER {{upper name}}`:           `CREATE USER V-TOKEN-FOO`,
+		`CREATE USER {{ lower name }}`:         `CREATE USER v-token-foo`,
+		`COMMENT '{{timestamp "2006-01-02"}}'`: `COMMENT '2018-03-04'`,
+		`COMMENT '{{timestamp}}'`:              `COMMENT '2018-03-04T05:06:07Z'`,
+		`SET {{upper missing}} {{other}}`:      `SET {{upper missing}} {{other}}`,
+		`SET {{unknown name}}`:                 `SET {{unknown name}}`,
+	}
+	for tpl, expected := range cases {
+		if actual := QueryHelper(tpl, data); actual != expected {
+			t.Fatalf("bad: %q: expected %q, got %q", tpl, expected, actual)
+		}
+	}
+}
+
+func TestCreationData(t *testing.T) {
+	data, err := CreationData(map[string]string{"name": "foo"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data["name"] != "foo" {
+		t.Fatalf("bad: name: %q", data["name"])
+	}
+	if len(data["random"]) != randomTokenLength {
+		t.Fatalf("bad: random: %q", data["random"])
+	}
+	if _, err := time.Parse(time.RFC3339, data["timestamp"]); err != nil {
+		t.Fatalf("bad: timestamp: %v", err)
+	}
+
+	// Each user gets its own token
+	other, err := CreationData(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other["random"] == data["random"] {
+		t.Fatalf("expected a new random token, got %q twice", other["random"])
+	}
+}
+
+func TestValidateTemplates(t *testing.T) {
+	valid := dbplugin.Statements{
+		CreationStatements: `CREATE ROLE "{{name}}" WITH PASSWORD '{{password}}'`,
+		GrantStatements:    []string{`GRANT a TO {{upper name}}; COMMENT '{{timestamp "2006-01-02"}} {{random}}'`},
+	}
+	if err := ValidateTemplates(valid); err != nil {
+		t.Fatalf("bad: %v", err)
+	}
+
+	invalid := []string{
+		`CREATE USER {{unknown name}}`,
+		`CREATE USER {{upper "name"}}`,
+		`COMMENT '{{timestamp 2006-01-02}}'`,
+	}
+	for _, stmt := range invalid {
+		if err := ValidateTemplates(dbplugin.Statements{RevokeStatements: []string{stmt}}); err == nil {
+			t.Fatalf("expected an error for %q", stmt)
+		}
+	}
+}
+
+func TestSubstituteVariables(t *testing.T) {
+	statements := dbplugin.Statements{
+		CreationStatements: `CREATE ROLE "{{name}}" IN SCHEMA {{schema}};`,
+		GrantStatements:    []string{`GRANT ALL ON {{upper schema}}.{{table}} TO "{{name}}";`},
+		RevocationSteps: []*dbplugin.RevocationStep{
+			{Statement: `REVOKE ALL ON {{schema}}.x FROM "{{lower name}}";`, ContinueOnError: true},
+		},
+	}
+
+	actual := SubstituteVariables(statements, map[string]string{"schema": "app"})
+	expected := dbplugin.Statements{
+		CreationStatements: `CREATE ROLE "{{name}}" IN SCHEMA app;`,
+		GrantStatements:    []string{`GRANT ALL ON APP.{{table}} TO "{{name}}";`},
+		RevocationSteps: []*dbplugin.RevocationStep{
+			{Statement: `REVOKE ALL ON app.x FROM "{{lower name}}";`, ContinueOnError: true},
+		},
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("expected %#v, got %#v", expected, actual)
+	}
+
+	// The statements given aren't changed
+	if statements.RevocationSteps[0].Statement != `REVOKE ALL ON {{schema}}.x FROM "{{lower name}}";` {
+		t.Fatalf("expected the original statements to be left as they were, got: %#v", statements)
+	}
+}
+
+func TestValidateVariables(t *testing.T) {
+	if err := ValidateVariables(map[string]string{"schema": "app", "table_space": "fast"}); err != nil {
+		t.Fatalf("bad: %v", err)
+	}
+
+	for _, name := range []string{"name", "password", "expiration", "schema-name", ""} {
+		if err := ValidateVariables(map[string]string{name: "x"}); err == nil {
+			t.Fatalf("expected an error for %q", name)
+		}
+	}
+}
@@ -347,6 +347,10 @@ func NewSystemBackend(core *Core) *SystemBackend {
 						Type:        framework.TypeString,
 						Description: strings.TrimSpace(sysHelp["mount_plugin_name"][0]),
 					},
+					"options": &framework.FieldSchema{
+						Type:        framework.TypeMap,
+						Description: strings.TrimSpace(sysHelp["mount_options"][0]),
+					},
 				},
 
 				Callbacks: map[logical.Operation]framework.OperationFunc{
@@ -1459,6 +1463,9 @@ func (b *SystemBackend) handleMountTable(ctx context.Context, req *logical.Reque
 			entryConfig["audit_non_hmac_response_keys"] = rawVal.([]string)
 		}
 		info["config"] = entryConfig
+		if len(entry.Options) > 0 {
+			info["options"] = entry.Options
+		}
 		resp.Data[entry.Path] = info
 	}
 
@@ -1480,9 +1487,29 @@ func (b *SystemBackend) handleMount(ctx context.Context, req *logical.Request, d
 	description := data.Get("description").(string)
 	pluginName := data.Get("plugin_name").(string)
 	sealWrap := data.Get("seal_wrap").(bool)
+	options := data.Get("options").(map[string]interface{})
 
 	path = sanitizeMountPath(path)
 
+	// The options are passed to the backend when it's created, which
+	// validates them before the mount is added to the mount table
+	var optionMap map[string]string
+	for k, v := range options {
+		vStr, ok := v.(string)
+		if !ok {
+			return logical.ErrorResponse("options must be string valued"),
+				logical.ErrInvalidRequest
+		}
+		if k == "plugin_name" {
+			return logical.ErrorResponse("plugin_name cannot be given as an option"),
+				logical.ErrInvalidRequest
+		}
+		if optionMap == nil {
+			optionMap = make(map[string]string, len(options))
+		}
+		optionMap[k] = vStr
+	}
+
 	var config MountConfig
 	var apiConfig APIMountConfig
 
@@ -1575,6 +1602,7 @@ func (b *SystemBackend) handleMount(ctx context.Context, req *logical.Request, d
 		Type:        logicalType,
 		Description: description,
 		Config:      config,
+		Options:     optionMap,
 		Local:       local,
 		SealWrap:    sealWrap,
 	}
@@ -3202,6 +3230,13 @@ west coast.
 and max_lease_ttl.`,
 	},
 
+	"mount_options": {
+		`Options passed to the backend when it is created, as string
+values. Their meaning depends on the type of the backend, which rejects the
+mount if they are invalid. Backends that take no options reject any. They
+cannot be changed once mounted.`,
+	},
+
 	"mount_local": {
 		`Mark the mount as a local mount, which is not replicated
 and is unaffected by replication.`,
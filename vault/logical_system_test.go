@@ -302,6 +302,76 @@ func TestSystemBackend_mount_force_no_cache(t *testing.T) {
 	}
 }
 
+// noopOptionsBackend is a NoopBackend that accepts the "foo" mount option.
+type noopOptionsBackend struct {
+	*NoopBackend
+}
+
+func (noopOptionsBackend) MountOptions() []string {
+	return []string{"foo"}
+}
+
+func TestSystemBackend_mount_options(t *testing.T) {
+	core, b, _ := testCoreSystemBackend(t)
+	core.logicalBackends["noop"] = func(context.Context, *logical.BackendConfig) (logical.Backend, error) {
+		return noopOptionsBackend{&NoopBackend{}}, nil
+	}
+
+	req := logical.TestRequest(t, logical.UpdateOperation, "mounts/prod/secret/")
+	req.Data["type"] = "noop"
+	req.Data["options"] = map[string]interface{}{
+		"foo": "bar",
+	}
+
+	resp, err := b.HandleRequest(context.Background(), req)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if resp != nil {
+		t.Fatalf("bad: %v", resp)
+	}
+
+	mountEntry := core.router.MatchingMountEntry("prod/secret/")
+	if mountEntry == nil {
+		t.Fatalf("missing mount entry")
+	}
+	if !reflect.DeepEqual(mountEntry.Options, map[string]string{"foo": "bar"}) {
+		t.Fatalf("bad options %#v", mountEntry)
+	}
+
+	req = logical.TestRequest(t, logical.ReadOperation, "mounts")
+	resp, err = b.HandleRequest(context.Background(), req)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if options := resp.Data["prod/secret/"].(map[string]interface{})["options"]; !reflect.DeepEqual(options, map[string]string{"foo": "bar"}) {
+		t.Fatalf("bad options: %#v", options)
+	}
+
+	// Options are string valued, can't override the plugin name and must be
+	// accepted by the backend
+	for _, tc := range []struct {
+		mountType string
+		options   map[string]interface{}
+	}{
+		{"noop", map[string]interface{}{"foo": 1}},
+		{"noop", map[string]interface{}{"plugin_name": "other"}},
+		{"noop", map[string]interface{}{"bar": "baz"}},
+		{"kv", map[string]interface{}{"foo": "bar"}},
+	} {
+		req := logical.TestRequest(t, logical.UpdateOperation, "mounts/other/")
+		req.Data["type"] = tc.mountType
+		req.Data["options"] = tc.options
+		_, err := b.HandleRequest(context.Background(), req)
+		if err != logical.ErrInvalidRequest {
+			t.Fatalf("expected invalid request for %s options %v, got: %v", tc.mountType, tc.options, err)
+		}
+	}
+	if core.router.MatchingMountEntry("other/") != nil {
+		t.Fatal("expected the refused mounts not to be added")
+	}
+}
+
 func TestSystemBackend_mount_invalid(t *testing.T) {
 	b := testSystemBackend(t)
 
@@ -284,6 +284,9 @@ func (c *Core) mountInternal(ctx context.Context, entry *MountEntry) error {
 	var err error
 	sysView := c.mountEntrySysView(entry)
 	conf := make(map[string]string)
+	for k, v := range entry.Options {
+		conf[k] = v
+	}
 	if entry.Config.PluginName != "" {
 		conf["plugin_name"] = entry.Config.PluginName
 	}
@@ -296,6 +299,10 @@ func (c *Core) mountInternal(ctx context.Context, entry *MountEntry) error {
 	if backend == nil {
 		return fmt.Errorf("nil backend of type %q returned from creation function", entry.Type)
 	}
+	if err := checkMountOptions(backend, entry.Options); err != nil {
+		backend.Cleanup(ctx)
+		return err
+	}
 
 	// Check for the correct backend type
 	backendType := backend.Type()
@@ -781,6 +788,9 @@ func (c *Core) setupMounts(ctx context.Context) error {
 		sysView := c.mountEntrySysView(entry)
 		// Set up conf to pass in plugin_name
 		conf := make(map[string]string)
+		for k, v := range entry.Options {
+			conf[k] = v
+		}
 		if entry.Config.PluginName != "" {
 			conf["plugin_name"] = entry.Config.PluginName
 		}
@@ -878,6 +888,22 @@ func (c *Core) newLogicalBackend(ctx context.Context, t string, sysView logical.
 	return b, nil
 }
 
+// checkMountOptions returns an error if options holds an option the backend
+// doesn't accept. Only backends implementing logical.OptionsBackend accept any.
+func checkMountOptions(backend logical.Backend, options map[string]string) error {
+	var accepted []string
+	if b, ok := backend.(logical.OptionsBackend); ok {
+		accepted = b.MountOptions()
+	}
+
+	for k := range options {
+		if !strutil.StrListContains(accepted, k) {
+			return fmt.Errorf("backend does not accept option %q", k)
+		}
+	}
+	return nil
+}
+
 // mountEntrySysView creates a logical.SystemView from global and
 // mount-specific entries; because this should be called when setting
 // up a mountEntry, it doesn't check to ensure that me is not nil
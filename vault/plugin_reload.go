@@ -102,6 +102,9 @@ func (c *Core) reloadPluginCommon(ctx context.Context, entry *MountEntry, isAuth
 
 	sysView := c.mountEntrySysView(entry)
 	conf := make(map[string]string)
+	for k, v := range entry.Options {
+		conf[k] = v
+	}
 	if entry.Config.PluginName != "" {
 		conf["plugin_name"] = entry.Config.PluginName
 	}